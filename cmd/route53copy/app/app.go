@@ -2,12 +2,18 @@ package app
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
 	"github.com/spf13/cobra"
 )
 
@@ -17,97 +23,1195 @@ type App struct {
 	Domain             string
 	DryRun             bool
 	UpdateNS           bool
+	Force              bool
+	IncludeTypes       []string
+	ExcludeTypes       []string
+	FilterTypes        []string
+	DestinationDomain  string
+	Rename             string
+	RewriteValues      bool
+	Output             string
+	Out                io.Writer
+	Region             string
+	AllowSameAccount   bool
+	Private            bool
+	Public             bool
+	VPCID              string
+	VPCs               []string
+	VPCRegion          string
+	SourceZoneID       string
+	DestinationZoneID  string
+	SrcZoneID          string
+	DstZoneID          string
+	IntoParent         bool
+	Subtree            string
+	PrintDelegation    bool
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	IsolateRejected    bool
+	ContinueOnError    bool
+	RateLimit          float64
+	EndpointURL        string
+	Insecure           bool
+	CopyHealthChecks   bool
+	HealthChecks       string
+	CopyCidr           bool
+	SkipDelegations    bool
+	SkipValidation     bool
+	FailTrafficPolicy  bool
+	CopySOA            bool
+	WaitNS             time.Duration
+	NSWaitTimeout      time.Duration
+	WaitTimeout        time.Duration
+	CleanupOnFailure   bool
+	Names              []string
+	IncludeNames       []string
+	ExcludeNames       []string
+	Include            []string
+	Exclude            []string
+	SkipUnresolvable   bool
+	Dealias            bool
+	DealiasTTL         int64
+	DealiasTimeout     time.Duration
+	Resolver           string
+	Lock               bool
+	BreakLock          bool
+	LockExpiry         time.Duration
+	Verify             bool
+	VerifyDNS          bool
+	Backup             string
+	Confirm            bool
+	Interactive        bool
+	UpsertUnchanged    bool
+	NoOverwrite        string
+	AllowLiveOverwrite bool
+	Domains            []string
+	AllZones           bool
+	Concurrency        int
+	ExcludeZones       []string
+	DelegationSetID    string
+	SyncComment        bool
+	ZoneComment        string
+	CopyVPC            bool
+	EnableDNSSEC       bool
+	KMSKeyARN          string
+	SourceRoleARN      string
+	DestinationRoleARN string
+	ExternalID         string
+	SessionName        string
+	Verbose            bool
+	Quiet              bool
+	Redact             bool
+	Report             string
+	TTLOverride        int64
+	MinTTL             int64
+	MaxTTL             int64
+	RestoreTTLs        string
+	PlanOut            string
+	StateFile          string
+	Resume             bool
+	Stream             bool
+	Comment            string
+	Timings            bool
+	Destinations       []string
+	MaxRecords         int64
+	AllowEmpty         bool
+	Rewrites           dns.RewriteRules
+	SubstituteFile     string
+
+	records       *output.RecordReport
+	timings       *dns.Timings
+	substitutions []dns.RewriteRule
+	excludeTypes  []rtypes.RRType
+	vpcs          []rtypes.VPC
 }
 
 func (a *App) Run(ctx context.Context) error {
-	srcService := dns.NewRouteCopy(ctx, a.SourceProfile)
-	dstService := dns.NewRouteCopy(ctx, a.DestinationProfile)
+	err := output.ValidateFormat(a.Output)
+	if err != nil {
+		return err
+	}
+	err = logging.Configure(a.Verbose, a.Quiet)
+	if err != nil {
+		return err
+	}
+	dns.SetRedaction(a.Redact)
+	if a.Report != "" {
+		err = output.ValidateRecordReportFile(a.Report)
+		if err != nil {
+			return err
+		}
+		a.records = output.NewRecordReport("route53copy")
+	}
+
+	err = a.runWithOutput(ctx)
+	if a.records != nil {
+		if werr := a.writeRecords(ctx, err); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (a *App) runWithOutput(ctx context.Context) error {
+	if a.Output != output.FormatJSON {
+		return a.run(ctx, output.NewReport("route53copy", a.Domain, a.DryRun))
+	}
+
+	restore := output.SilenceLog()
+	defer restore()
+
+	report := output.NewReport("route53copy", a.Domain, a.DryRun)
+	err := a.run(ctx, report)
+	report.SetError(err)
+	if werr := report.Write(a.out()); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+// writeRecords writes the --report file, even when the run failed with err.
+func (a *App) writeRecords(ctx context.Context, err error) error {
+	werr := a.records.WriteFile(a.Report, err)
+	if werr != nil {
+		return fmt.Errorf("failed to write report %s: %w", a.Report, werr)
+	}
+	logging.From(ctx).Infof("Wrote the outcome of %d records to %s\n", len(a.records.Records), a.Report)
+	return nil
+}
+
+func (a *App) out() io.Writer {
+	if a.Out == nil {
+		return os.Stdout
+	}
+	return a.Out
+}
+
+func (a *App) run(ctx context.Context, report *output.Report) error {
+	types, err := dns.ParseRecordTypes(a.IncludeTypes)
+	if err != nil {
+		return err
+	}
+	a.excludeTypes, err = dns.ParseRecordTypes(a.ExcludeTypes)
+	if err != nil {
+		return err
+	}
+	err = dns.ValidateNamePatterns(append(append([]string{}, a.IncludeNames...), a.ExcludeNames...))
+	if err != nil {
+		return err
+	}
+	err = a.validateTTLs()
+	if err != nil {
+		return err
+	}
+	if a.RestoreTTLs != "" {
+		return a.restoreTTLs(ctx, report)
+	}
+	err = a.validateAllZones()
+	if err != nil {
+		return err
+	}
+	err = a.validateIntoParent()
+	if err != nil {
+		return err
+	}
+	err = a.validateSubtree()
+	if err != nil {
+		return err
+	}
+	err = a.validateInteractive()
+	if err != nil {
+		return err
+	}
+	err = a.validateNoOverwrite()
+	if err != nil {
+		return err
+	}
+	err = a.validateHealthChecks()
+	if err != nil {
+		return err
+	}
+	err = a.validatePlanOut()
+	if err != nil {
+		return err
+	}
+	err = a.validateDestinations()
+	if err != nil {
+		return err
+	}
+	err = a.validateState()
+	if err != nil {
+		return err
+	}
+	err = a.validateStream()
+	if err != nil {
+		return err
+	}
+	err = a.validateEndpoint()
+	if err != nil {
+		return err
+	}
+	a.substitutions, err = a.readSubstitutions()
+	if err != nil {
+		return err
+	}
+	if a.Private && a.Public {
+		return errors.New("--private and --public cannot be used together")
+	}
+	if a.MaxRetries < 0 {
+		return errors.New("--max-retries cannot be negative, use 0 to disable retries")
+	}
+	if a.EnableDNSSEC && a.KMSKeyARN == "" {
+		return errors.New("--enable-dnssec requires --kms-key-arn")
+	}
+	if a.EnableDNSSEC && a.Private {
+		return errors.New("--enable-dnssec cannot be used with --private, private zones cannot be signed")
+	}
+	if a.CopyVPC && !a.Private {
+		return errors.New("--copy-vpc-associations requires --private")
+	}
+	if a.DelegationSetID != "" && a.Private {
+		return errors.New("--delegation-set-id cannot be used with --private, private zones have no delegation set")
+	}
+	err = a.validateVPCs()
+	if err != nil {
+		return err
+	}
 
-	zone, err := srcService.GetHostedZone(ctx, a.Domain)
+	if a.Timings {
+		a.timings = dns.NewTimings()
+		defer a.reportTimings(a.timings, report)
+	}
+	srcService, err := a.newService(ctx, a.SourceProfile, "source", a.SourceRoleARN)
+	if err != nil {
+		return err
+	}
+	if a.multipleDestinations() {
+		return a.copyToDestinations(ctx, srcService, types, report)
+	}
+	dstService, err := a.newService(ctx, a.DestinationProfile, "destination", a.DestinationRoleARN)
+	if err != nil {
+		return err
+	}
+	err = a.checkAccounts(ctx, srcService, dstService)
 	if err != nil {
 		return err
 	}
-	srcZoneID := aws.ToString(zone.Id)
 
-	recordSets, err := srcService.GetResourceRecords(ctx, srcZoneID)
+	if a.AllZones {
+		return a.copyAllZones(ctx, srcService, dstService, types, report)
+	}
+	if len(a.Domains) > 0 {
+		return a.copyDomains(ctx, srcService, dstService, types, report)
+	}
+	err = a.pickZones(ctx, srcService, dstService)
 	if err != nil {
 		return err
 	}
+	return a.copyZone(ctx, srcService, dstService, types, report)
+}
 
-	changes := srcService.CreateChanges(a.Domain, recordSets)
-	log.Println("Number of records to copy", len(changes))
+// newService creates the client of a profile and checks its credentials.
+func (a *App) newService(ctx context.Context, profile, side, roleARN string) (*dns.RouteCopy, error) {
+	service, err := dns.NewRouteCopy(ctx, profile, a.configOptions(side, roleARN)...)
+	if err != nil {
+		return nil, err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if a.showProgress() {
+		service.SetProgress(output.NewProgress(os.Stderr))
+	}
+	if a.timings != nil {
+		service.SetMetrics(a.timings)
+	}
+	// Continuing on errors drops the rejected changes of a batch and
+	// applies the others.
+	service.SetIsolateRejected(a.IsolateRejected || a.ContinueOnError)
+	service.SetContinueOnError(a.ContinueOnError)
+	return service, nil
+}
 
-	if a.DryRun {
-		log.Printf("Not copying records to %s since --dry is given\n", a.DestinationProfile)
-		zone, err := dstService.GetHostedZone(ctx, a.Domain)
-		if err != nil {
+// checkAccounts refuses to copy within an account, unless
+// --allow-same-account is given.
+func (a *App) checkAccounts(ctx context.Context, srcService, dstService *dns.RouteCopy) error {
+	err := dns.CheckDifferentAccounts(ctx, srcService, dstService)
+	if err != nil {
+		var e *dns.SameAccountError
+		if !errors.As(err, &e) {
 			return err
 		}
+		if !a.AllowSameAccount {
+			return fmt.Errorf("%w, use --allow-same-account to copy anyway", err)
+		}
+		logging.From(ctx).Infof("Copying within account %s since --allow-same-account is given\n", e.AccountID)
+	}
+	return nil
+}
+
+// reportTimings adds the timings of the run to report, and prints them
+// unless the report is the output.
+func (a *App) reportTimings(timings *dns.Timings, report *output.Report) {
+	summary := timings.Summary()
+	report.SetTimings(summary)
+	if a.Output != output.FormatJSON {
+		output.PrintTimings(a.out(), summary)
+	}
+}
+
+func (a *App) copyZone(ctx context.Context, srcService, dstService *dns.RouteCopy, types []rtypes.RRType, report *output.Report) error {
+	opts := a.copyOptions(types)
+	opts.Comment = a.batchComment(srcService, dstService)
+	result, err := dns.CopyZone(ctx, srcService, dstService, opts)
+	return a.finishCopy(ctx, dstService, result, err, report)
+}
 
-		log.Printf("Destination profile contains %d records, including NS and SOA\n",
-			*zone.ResourceRecordSetCount)
-	} else {
-		zone, err := dstService.GetOrCreateZone(ctx, a.Domain)
+// finishCopy reports the result of copying a zone to dstService, writes the
+// plans and updates the nameservers.
+func (a *App) finishCopy(ctx context.Context, dstService *dns.RouteCopy, result dns.CopyResult, err error, report *output.Report) error {
+	report.AddChanges(result.Changes)
+	report.CreatedZone = result.CreatedZone
+	report.Unchanged = len(result.Unchanged)
+	report.ZoneID = aws.ToString(result.DestinationZone.Id)
+	report.AddBatches(result.Batches)
+	report.AddWarnings(result.Warnings)
+	report.AddSkipped(result.Excluded)
+	report.AddConflicts(result.Conflicts)
+	report.SetDNSSEC(result.SourceDNSSEC, result.DestinationDNSSEC)
+	if a.records != nil {
+		outcomeErr := err
+		if result.Aborted {
+			outcomeErr = output.ErrAborted
+		}
+		outcomes := dns.RecordOutcomes(result.Changes, result.Existing, result.Batches, outcomeErr, a.DryRun)
+		a.records.Add(a.destinationDomain(), dns.WithOriginalTTLs(outcomes, result.TTLChanges), outcomeErr)
+	}
+	if err == nil && a.PlanOut != "" {
+		err = a.writePlan(ctx, result)
+	}
+	var interrupted *dns.Interrupted
+	if errors.As(err, &interrupted) && !a.Stream {
+		a.writeRemainingPlan(ctx, result, interrupted)
+	}
+	if result.Verification != nil {
+		report.SetVerification(*result.Verification)
+		logVerification(ctx, *result.Verification)
+	}
+	if err != nil {
+		logRejected(ctx, err)
+		a.logResumeHint(ctx)
+		return zoneHint(err)
+	}
+	if result.Aborted {
+		return output.ErrAborted
+	}
+	if a.PrintDelegation {
+		err = a.printDelegation(ctx, dstService, result.DestinationZone)
 		if err != nil {
 			return err
 		}
-		dstZoneID := aws.ToString(zone.Id)
+	}
 
-		if len(changes) > 0 {
-			changeInfo, err := dstService.UpdateRecords(ctx, a.SourceProfile, dstZoneID, changes)
-			if err != nil {
-				return err
-			}
-			log.Printf("%d records in '%s' were copied from %s to %s\n",
-				len(changes), a.Domain, a.SourceProfile, a.DestinationProfile)
-
-			if changeInfo.Status != rtypes.ChangeStatusInsync {
-				start := time.Now()
-				err = dstService.WaitForChange(ctx, aws.ToString(changeInfo.Id), 2*time.Minute)
-				if err != nil {
-					return err
-				}
-				log.Printf("%d records in '%s' are in sync after %s\n", len(changes), a.Domain, time.Since(start))
+	if !a.DryRun && a.UpdateNS {
+		dstDomain := a.destinationDomain()
+		if result.SourceDNSSEC.Signing() && !result.DestinationDNSSEC.Signing() {
+			if !a.Force {
+				return fmt.Errorf("'%s' is signed with DNSSEC but '%s' is not, not updating the nameservers: "+
+					"use --enable-dnssec, or --force to update them anyway", a.Domain, dstDomain)
 			}
+			logging.From(ctx).Warnf("Updating the nameservers of '%s' without DNSSEC signing since --force is given\n", dstDomain)
+		}
+		dstZoneID := aws.ToString(result.DestinationZone.Id)
+		logging.From(ctx).Infoln("Updating NS records")
+		nsOpts := dns.NSUpdateOptions{OperationWait: a.WaitNS, DelegationWait: a.NSWaitTimeout}
+		if nsOpts.OperationWait == 0 {
+			// The delegation only changes once the registrar applied the
+			// nameservers.
+			nsOpts.OperationWait = a.NSWaitTimeout
+		}
+		updated, err := dstService.UpdateNSRecordsWithOptions(ctx, dstDomain, dstZoneID, nsOpts)
+		if err != nil {
+			return err
+		}
+
+		if updated {
+			logging.From(ctx).Summaryf("Registrar NS records for '%s' updated\n", dstDomain)
 		} else {
-			log.Printf("No records to copy for '%s'\n", a.Domain)
+			logging.From(ctx).Summaryf("Registrar NS records for '%s' are already up to date\n", dstDomain)
+		}
+		if a.NSWaitTimeout > 0 {
+			logging.From(ctx).Summaryf("The parent zone delegates '%s' to the new nameservers\n", dstDomain)
 		}
+	}
+	return nil
+}
+
+// printDelegation writes the NS records of the destination zone as a zone
+// file, to be added to the source zone so it delegates the subtree.
+func (a *App) printDelegation(ctx context.Context, dstService *dns.RouteCopy, zone rtypes.HostedZone) error {
+	ns, err := dstService.GetNSRecords(ctx, aws.ToString(zone.Id), aws.ToString(zone.Name))
+	if err != nil {
+		return err
+	}
+	logging.From(ctx).Summaryf("Add these records to '%s' to delegate '%s' to the destination zone:\n", a.Domain, aws.ToString(zone.Name))
+	return dns.WriteZoneFile(a.out(), a.Domain, []rtypes.ResourceRecordSet{ns})
+}
 
-		if a.UpdateNS {
-			log.Println("Updating NS records")
-			updated, err := dstService.UpdateNSRecords(ctx, a.Domain, dstZoneID)
+func (a *App) copyOptions(types []rtypes.RRType) dns.CopyOptions {
+	opts := dns.CopyOptions{
+		Domain:                  a.Domain,
+		DestinationDomain:       a.DestinationDomain,
+		SourceZoneID:            a.SourceZoneID,
+		DestinationZoneID:       a.DestinationZoneID,
+		UpsertUnchanged:         a.UpsertUnchanged,
+		NoOverwrite:             a.NoOverwrite != "",
+		SkipConflicts:           a.NoOverwrite == noOverwriteWarn,
+		IntoParent:              a.IntoParent,
+		Subtree:                 a.Subtree,
+		Private:                 a.Private,
+		DelegationSetID:         a.DelegationSetID,
+		Types:                   types,
+		ExcludeTypes:            a.excludeTypes,
+		RewriteValues:           a.RewriteValues,
+		SkipDelegations:         a.SkipDelegations,
+		SkipValidationRecords:   a.SkipValidation,
+		FailOnTrafficPolicy:     a.FailTrafficPolicy,
+		TTL:                     a.ttlOptions(),
+		CopySOAValues:           a.CopySOA,
+		Names:                   a.Names,
+		Include:                 a.IncludeNames,
+		Exclude:                 a.ExcludeNames,
+		MaxRecords:              a.MaxRecords,
+		AllowEmpty:              a.AllowEmpty,
+		Substitutions:           a.substitutions,
+		SkipUnresolvableAliases: a.SkipUnresolvable,
+		Dealias:                 a.Dealias,
+		CopyHealthChecks:        a.copyHealthChecks(),
+		StripHealthChecks:       a.HealthChecks == healthChecksStrip,
+		RequireHealthChecks:     a.HealthChecks == healthChecksFail,
+		CopyCidrCollections:     a.CopyCidr,
+		SyncComment:             a.SyncComment,
+		ZoneComment:             a.ZoneComment,
+		CopyVPCAssociations:     a.CopyVPC,
+		EnableDNSSEC:            a.EnableDNSSEC,
+		KMSKeyARN:               a.KMSKeyARN,
+		DryRun:                  a.DryRun,
+		MaxWait:                 a.WaitTimeout,
+		CollectExisting:         a.Report != "" || a.PlanOut != "",
+		AllowLiveOverwrite:      a.AllowLiveOverwrite,
+		Verify:                  a.Verify,
+		VerifyDNS:               a.VerifyDNS,
+		StateFile:               a.stateFile(),
+		Resume:                  a.Resume,
+		Stream:                  a.Stream,
+	}
+	if a.PlanOut != "" {
+		// A plan cannot hold the ids of collections a dry run does not
+		// create, so it keeps the source ids, flagged by the analysis.
+		opts.CopyCidrCollections = false
+	}
+	if a.Stream {
+		// The collections are only known once every record is listed, so a
+		// streamed copy checks that the destination has them instead.
+		opts.CopyCidrCollections = false
+	}
+	if a.Lock || a.BreakLock {
+		opts.Lock = &dns.LockOptions{Expiry: a.LockExpiry, Break: a.BreakLock}
+	}
+	if len(a.vpcs) > 0 {
+		opts.VPCID = aws.ToString(a.vpcs[0].VPCId)
+		opts.VPCRegion = string(a.vpcs[0].VPCRegion)
+		opts.ExtraVPCs = a.vpcs[1:]
+	}
+	if a.Dealias {
+		opts.DealiasOptions = dns.DealiasOptions{TTL: a.DealiasTTL, Timeout: a.DealiasTimeout, Resolver: a.Resolver}
+	}
+	if a.Interactive {
+		opts.SelectChanges = a.selectChanges
+	}
+	if a.Confirm {
+		opts.Confirm = a.confirm
+	}
+	if a.Backup != "" {
+		opts.Backup = a.writeBackup
+	}
+	// Prompts of concurrent copies would interleave.
+	prompt := !a.multipleZones() && !a.multipleDestinations() && output.IsTerminal(os.Stdin)
+	if prompt {
+		opts.ConfirmLiveOverwrite = a.confirmLiveOverwrite
+	}
+	if a.CleanupOnFailure {
+		opts.CleanupOnFailure = func(context.Context, rtypes.HostedZone) (bool, error) {
+			return true, nil
+		}
+	} else if prompt {
+		opts.CleanupOnFailure = a.confirmCleanup
+	}
+	return opts
+}
+
+// logRejected lists the changes that failed when the copy went on after
+// them, see dns.RejectedChanges.
+func logRejected(ctx context.Context, err error) {
+	var rejected *dns.RejectedChanges
+	if !errors.As(err, &rejected) {
+		return
+	}
+	logging.From(ctx).Errorf("%d records failed:\n", len(rejected.Rejected))
+	for _, r := range rejected.Rejected {
+		rs := r.Change.ResourceRecordSet
+		logging.From(ctx).Errorf("  %s %s: %s\n", dns.DecodeName(aws.ToString(rs.Name)), rs.Type, r.Reason)
+	}
+}
+
+// zoneHint points at the flags selecting a zone by id when a zone name is
+// ambiguous, at --allow-live-overwrite when the zone serves the domain, at
+// --break-lock when another copy holds its lock and at --max-records when
+// the zone is larger than the limit.
+func zoneHint(err error) error {
+	var tooMany *dns.TooManyRecords
+	if errors.As(err, &tooMany) {
+		return fmt.Errorf("%w, use --max-records %d or higher to copy it, or --max-records 0 to disable the limit", err, tooMany.Records)
+	}
+	var cidr *dns.MissingCidrCollections
+	if errors.As(err, &cidr) {
+		return fmt.Errorf("%w, use --copy-cidr-collections to copy them", err)
+	}
+	var empty *dns.EmptyCopy
+	if errors.As(err, &empty) {
+		return fmt.Errorf("%w, check the filters or use --allow-empty if this is expected", err)
+	}
+	var live *dns.LiveZoneOverwrite
+	if errors.As(err, &live) {
+		return fmt.Errorf("%w, use --allow-live-overwrite to copy anyway", err)
+	}
+	var liveStream *dns.LiveZoneStream
+	if errors.As(err, &liveStream) {
+		return fmt.Errorf("%w, use --allow-live-overwrite to copy anyway, or copy without --stream", err)
+	}
+	var conflicts *dns.OverwriteConflicts
+	if errors.As(err, &conflicts) && !conflicts.Skipped {
+		return fmt.Errorf("%w, use --no-overwrite=warn to copy the other records", err)
+	}
+	var trafficPolicy *dns.TrafficPolicyRecords
+	if errors.As(err, &trafficPolicy) {
+		return fmt.Errorf("%w, copy their instances with route53copy traffic-policies and copy without --fail-on-traffic-policy", err)
+	}
+	var healthChecks *dns.MissingHealthChecks
+	if errors.As(err, &healthChecks) {
+		return fmt.Errorf("%w, use --health-checks=copy to copy them or --health-checks=strip to leave them out", err)
+	}
+	var locked *dns.ZoneLocked
+	if errors.As(err, &locked) {
+		return fmt.Errorf("%w, use --break-lock to copy anyway", err)
+	}
+	var existing *dns.ExistingState
+	if errors.As(err, &existing) {
+		return fmt.Errorf("%w, continue it with --resume or remove the file", err)
+	}
+	var stale *dns.StaleState
+	if errors.As(err, &stale) {
+		return fmt.Errorf("%w, remove the file to copy again from the start", err)
+	}
+	var le *dns.ZoneLookupError
+	var mismatch *dns.ZoneNameMismatch
+	if errors.As(err, &le) && !le.Source && errors.As(err, &mismatch) {
+		return fmt.Errorf("%w, use --dest-domain %s to copy into it", err, mismatch.Name)
+	}
+	var ae *dns.AmbiguousHostedZone
+	if !errors.As(err, &le) || !errors.As(err, &ae) {
+		return err
+	}
+	if le.Source {
+		return fmt.Errorf("%w, select one with --source-zone-id", err)
+	}
+	return fmt.Errorf("%w, select one with --dest-zone-id", err)
+}
+
+// confirm shows which records the changes create and overwrite in the
+// destination and asks whether to apply them.
+func (a *App) confirm(ctx context.Context, preview dns.Diff) (bool, error) {
+	if a.Output != output.FormatJSON {
+		dns.PrintChangePreview(preview, output.IsTerminal(os.Stdout))
+	}
+	err := output.Confirm("Apply these changes?", a.Output)
+	if errors.Is(err, output.ErrAborted) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// selectChanges asks which of the records to copy.
+func (a *App) selectChanges(ctx context.Context, groups [][]rtypes.Change) ([]int, error) {
+	return output.SelectChanges(fmt.Sprintf("Pick the records of '%s' to copy", a.Domain), groups, a.Output)
+}
+
+// confirmLiveOverwrite shows the records of the zone serving the domain that
+// the copy overwrites and asks whether to go on.
+func (a *App) confirmLiveOverwrite(ctx context.Context, live dns.LiveZoneOverwrite) (bool, error) {
+	if a.Output != output.FormatJSON {
+		dns.PrintChangePreview(dns.Diff{Update: live.Updates}, output.IsTerminal(os.Stdout))
+	}
+	label := fmt.Sprintf("Overwrite %d records of '%s', which serves live traffic?", len(live.Updates), live.Zone)
+	err := output.Confirm(label, a.Output)
+	if errors.Is(err, output.ErrAborted) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// confirmCleanup asks whether to delete the empty zone created by a failed
+// copy.
+func (a *App) confirmCleanup(ctx context.Context, zone rtypes.HostedZone) (bool, error) {
+	label := fmt.Sprintf("Delete the empty zone %s created by this run?", aws.ToString(zone.Id))
+	err := output.Confirm(label, a.Output)
+	if errors.Is(err, output.ErrAborted) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (a *App) writeBackup(ctx context.Context, backup dns.Backup) error {
+	file := a.backupFile()
+	err := dns.WriteBackupFile(file, backup)
+	if err != nil {
+		return err
+	}
+	logging.From(ctx).Infof("Backed up %d destination records to %s\n", len(backup.RecordSets), file)
+	return nil
+}
+
+func logVerification(ctx context.Context, v dns.Verification) {
+	for _, rs := range v.Missing {
+		logging.From(ctx).Infof("  missing: %s %s\n", aws.ToString(rs.Name), rs.Type)
+	}
+	for _, u := range v.Different {
+		logging.From(ctx).Infof("  differs: %s %s\n", aws.ToString(u.To.Name), u.To.Type)
+	}
+	for _, m := range v.DNSMismatches {
+		if m.Err != nil {
+			logging.From(ctx).Infof("  DNS query failed: %s %s: %s\n", aws.ToString(m.Record.Name), m.Record.Type, m.Err)
+			continue
+		}
+		logging.From(ctx).Infof("  DNS answer differs: %s %s from %s: %s\n", aws.ToString(m.Record.Name), m.Record.Type,
+			m.Nameserver, strings.Join(displayAnswers(m), ", "))
+	}
+}
+
+// displayAnswers returns the answers of m as they are displayed, see
+// dns.DisplayValue.
+func displayAnswers(m dns.DNSMismatch) []string {
+	answers := []string{}
+	for _, answer := range m.Answers {
+		answers = append(answers, dns.DisplayValue(m.Record.Type, answer))
+	}
+	return answers
+}
+
+// configOptions returns the options for the side profile, assuming roleARN
+// with its credentials when given.
+func (a *App) configOptions(side, roleARN string) []func(*dns.ConfigOptions) {
+	return []func(*dns.ConfigOptions){
+		dns.WithSide(side),
+		dns.WithRegion(a.Region),
+		dns.WithMaxRetries(a.MaxRetries),
+		dns.WithRetryBaseDelay(a.RetryBaseDelay),
+		dns.WithRateLimit(a.RateLimit),
+		dns.WithAssumeRole(roleARN, a.ExternalID, a.SessionName),
+		dns.WithEndpoint(a.EndpointURL, a.Insecure),
+	}
+}
+
+// validateEndpoint checks --endpoint-url, and that --insecure has an
+// endpoint to apply to.
+func (a *App) validateEndpoint() error {
+	if a.EndpointURL != "" {
+		return dns.ValidateEndpoint(a.EndpointURL)
+	}
+	if a.Insecure && os.Getenv(dns.EndpointEnv) == "" {
+		return fmt.Errorf("--insecure can only be used with --endpoint-url or %s", dns.EndpointEnv)
+	}
+	return nil
+}
+
+// validateVPCs parses the --vpc flags. The first VPC is the one a new zone
+// is created with, and the others are associated after.
+func (a *App) validateVPCs() error {
+	if len(a.VPCs) == 0 {
+		return nil
+	}
+	if !a.Private {
+		return errors.New("--vpc requires --private")
+	}
+	a.vpcs = []rtypes.VPC{}
+	for _, s := range a.VPCs {
+		vpc, err := dns.ParseVPC(s)
+		if err != nil {
+			return err
+		}
+		a.vpcs = append(a.vpcs, vpc)
+	}
+	return nil
+}
+
+// validateIntoParent rejects the flags that act on the destination zone as a
+// whole, since with --into-parent that zone is the enclosing one.
+func (a *App) validateIntoParent() error {
+	if !a.IntoParent {
+		return nil
+	}
+	switch {
+	case a.AllZones:
+		return errors.New("--into-parent cannot be used with --all-zones")
+	case a.DestinationZoneID != "":
+		return errors.New("--into-parent cannot be used with --dest-zone-id")
+	case a.UpdateNS:
+		return errors.New("--into-parent cannot be used with --update-ns")
+	case a.SyncComment:
+		return errors.New("--into-parent cannot be used with --sync-comment")
+	case a.ZoneComment != "":
+		return errors.New("--into-parent cannot be used with --zone-comment")
+	case a.DelegationSetID != "":
+		return errors.New("--into-parent cannot be used with --delegation-set-id")
+	case a.EnableDNSSEC:
+		return errors.New("--into-parent cannot be used with --enable-dnssec")
+	case a.CopySOA:
+		return errors.New("--into-parent cannot be used with --copy-soa-values")
+	case a.CopyVPC:
+		return errors.New("--into-parent cannot be used with --copy-vpc-associations")
+	}
+	return nil
+}
+
+// validateSubtree rejects the flags that cannot apply to a part of a zone
+// copied into a zone of its own.
+func (a *App) validateSubtree() error {
+	if a.Subtree == "" {
+		if a.PrintDelegation {
+			return errors.New("--print-delegation requires --subtree")
+		}
+		return nil
+	}
+	switch {
+	case a.AllZones:
+		return errors.New("--subtree cannot be used with --all-zones")
+	case len(a.Domains) > 0:
+		return errors.New("--subtree cannot be used with several domains")
+	case a.IntoParent:
+		return errors.New("--subtree cannot be used with --into-parent")
+	case a.UpdateNS:
+		return errors.New("--subtree cannot be used with --update-ns, the subtree is delegated by the source zone, see --print-delegation")
+	case a.PrintDelegation && a.Output == output.FormatJSON:
+		return errors.New("--print-delegation cannot be used with --output json")
+	}
+	return nil
+}
+
+// validateInteractive checks that the records can be picked on a terminal,
+// for a single zone.
+func (a *App) validateInteractive() error {
+	if !a.Interactive {
+		return nil
+	}
+	switch {
+	case !output.IsTerminal(os.Stdin) || !output.IsTerminal(os.Stdout):
+		return errors.New("--interactive requires a terminal")
+	case a.AllZones:
+		return errors.New("--interactive cannot be used with --all-zones")
+	case len(a.Domains) > 0:
+		return errors.New("--interactive cannot be used with several domains")
+	}
+	return nil
+}
+
+// Values of --health-checks.
+const (
+	healthChecksWarn  = "warn"
+	healthChecksStrip = "strip"
+	healthChecksFail  = "fail"
+	healthChecksCopy  = "copy"
+)
+
+// validateHealthChecks checks the --health-checks mode.
+func (a *App) validateHealthChecks() error {
+	switch a.HealthChecks {
+	case healthChecksWarn, healthChecksCopy:
+	case healthChecksStrip, healthChecksFail:
+	default:
+		return fmt.Errorf("invalid --health-checks: %s (valid values: %s,%s,%s,%s)",
+			a.HealthChecks, healthChecksWarn, healthChecksStrip, healthChecksFail, healthChecksCopy)
+	}
+	return nil
+}
+
+// copyHealthChecks reports whether the health checks referenced by the
+// records are copied, with --health-checks=copy.
+func (a *App) copyHealthChecks() bool {
+	return a.HealthChecks == healthChecksCopy
+}
+
+// Values of --no-overwrite.
+const (
+	noOverwriteFail = "fail"
+	noOverwriteWarn = "warn"
+)
+
+// validateNoOverwrite checks the --no-overwrite mode and rejects the flags
+// that overwrite destination records on purpose.
+func (a *App) validateNoOverwrite() error {
+	switch {
+	case a.NoOverwrite == "":
+		return nil
+	case a.NoOverwrite != noOverwriteFail && a.NoOverwrite != noOverwriteWarn:
+		return fmt.Errorf("invalid --no-overwrite: %s (valid values: %s,%s)", a.NoOverwrite, noOverwriteFail, noOverwriteWarn)
+	case a.UpsertUnchanged:
+		return errors.New("--no-overwrite cannot be used with --upsert-unchanged")
+	case a.CopySOA:
+		return errors.New("--no-overwrite cannot be used with --copy-soa-values, which overwrites the destination SOA")
+	}
+	return nil
+}
+
+// validatePlanOut rejects the flags whose changes cannot be planned, since
+// they depend on resources the copy creates.
+func (a *App) validatePlanOut() error {
+	if a.PlanOut == "" {
+		return nil
+	}
+	switch {
+	case !a.DryRun:
+		return errors.New("--plan-out requires --dry, apply the plan with route53copy apply")
+	case a.multipleZones():
+		return errors.New("--plan-out cannot be used with --all-zones or several domains")
+	case a.copyHealthChecks():
+		return errors.New("--plan-out cannot be used with --health-checks=copy")
+	}
+	return nil
+}
+
+func (a *App) writePlan(ctx context.Context, result dns.CopyResult) error {
+	plan := dns.NewPlan(result, a.Domain, a.SourceProfile)
+	err := dns.WritePlanFile(a.PlanOut, plan)
+	if err != nil {
+		return err
+	}
+	logging.From(ctx).Summaryf("Wrote %d changes to %s, apply them with: route53copy apply --plan %s %s\n",
+		len(plan.Changes), a.PlanOut, a.PlanOut, a.DestinationProfile)
+	return nil
+}
+
+// writeRemainingPlan lists the batches an interrupted copy applied, and
+// writes the changes it did not submit to a plan, so the copy can be resumed
+// with route53copy apply.
+func (a *App) writeRemainingPlan(ctx context.Context, result dns.CopyResult, interrupted *dns.Interrupted) {
+	log := logging.From(ctx)
+	log.Summaryf("%d of %d batches were applied and are in sync before the copy was interrupted\n",
+		interrupted.Batch, interrupted.Batches)
+	for _, b := range result.Batches {
+		log.Summaryf("  change %s: %d changes\n", aws.ToString(b.ChangeInfo.Id), b.Changes)
+	}
+
+	result.Changes = result.Remaining
+	plan := dns.NewPlan(result, a.Domain, a.SourceProfile)
+	file := fmt.Sprintf("route53copy-%s-remaining.json", strings.TrimSuffix(a.destinationDomain(), "."))
+	err := dns.WritePlanFile(file, plan)
+	if err != nil {
+		log.Errorf("Could not write the %d remaining changes to %s: %s\n", len(plan.Changes), file, err)
+		return
+	}
+	log.Summaryf("Wrote the %d remaining changes to %s, apply them with: route53copy apply --plan %s %s\n",
+		len(plan.Changes), file, file, a.DestinationProfile)
+}
+
+func (a *App) ttlOptions() dns.TTLOptions {
+	return dns.TTLOptions{Override: a.TTLOverride, Min: a.MinTTL, Max: a.MaxTTL}
+}
+
+// validateTTLs checks the TTL options, and warns that the original TTLs can
+// only be restored when they are written to a --report file.
+func (a *App) validateTTLs() error {
+	opts := a.ttlOptions()
+	err := opts.Validate()
+	if err != nil {
+		return err
+	}
+	if a.RestoreTTLs != "" {
+		switch {
+		case !opts.Empty():
+			return errors.New("--restore-ttls cannot be used with --ttl-override, --min-ttl or --max-ttl")
+		case a.DryRun:
+			return errors.New("--restore-ttls cannot be used with --dry")
+		}
+		return nil
+	}
+	if !opts.Empty() && a.Report == "" && !a.DryRun {
+		logging.Warnf("The original TTLs are only kept in a --report file, without one they cannot be restored with --restore-ttls\n")
+	}
+	return nil
+}
+
+// readSubstitutions returns the rewrite rules of --substitute-file followed
+// by the --rewrite ones, in the order they are applied.
+func (a *App) readSubstitutions() ([]dns.RewriteRule, error) {
+	rules := []dns.RewriteRule{}
+	if a.SubstituteFile != "" {
+		fileRules, err := dns.ReadRewriteRuleFile(a.SubstituteFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --substitute-file: %w", err)
+		}
+		rules = append(rules, fileRules...)
+	}
+	return append(rules, a.Rewrites...), nil
+}
+
+// resolveAliases sets the flags that others stand for: --rename is
+// --dest-domain with --rewrite-values, and the deprecated --filter-type,
+// --include and --exclude are --include-types, --include-names and
+// --exclude-names. The deprecated --vpc-id and --vpc-region are the first
+// --vpc, the one a new zone is created with, and the deprecated
+// --copy-health-checks is --health-checks=copy. --src-zone-id and
+// --dst-zone-id are --source-zone-id and --dest-zone-id.
+func (a *App) resolveAliases() error {
+	if a.SrcZoneID != "" {
+		if a.SourceZoneID != "" && a.SourceZoneID != a.SrcZoneID {
+			return errors.New("--src-zone-id and --source-zone-id name different zones")
+		}
+		a.SourceZoneID = a.SrcZoneID
+	}
+	if a.DstZoneID != "" {
+		if a.DestinationZoneID != "" && a.DestinationZoneID != a.DstZoneID {
+			return errors.New("--dst-zone-id and --dest-zone-id name different zones")
+		}
+		a.DestinationZoneID = a.DstZoneID
+	}
+	a.IncludeTypes = append(a.IncludeTypes, a.FilterTypes...)
+	a.IncludeNames = append(a.IncludeNames, a.Include...)
+	a.ExcludeNames = append(a.ExcludeNames, a.Exclude...)
+	if a.VPCRegion != "" && a.VPCID == "" {
+		return errors.New("--vpc-region requires --vpc-id")
+	}
+	if a.VPCID != "" {
+		vpc := a.VPCID
+		if a.VPCRegion != "" {
+			vpc += ":" + a.VPCRegion
+		}
+		a.VPCs = append([]string{vpc}, a.VPCs...)
+	}
+	if a.CopyHealthChecks {
+		if a.HealthChecks != healthChecksWarn && a.HealthChecks != healthChecksCopy {
+			return fmt.Errorf("--copy-health-checks cannot be used with --health-checks=%s", a.HealthChecks)
+		}
+		a.HealthChecks = healthChecksCopy
+	}
+	if a.Rename != "" {
+		if a.DestinationDomain != "" {
+			return errors.New("--rename cannot be used with --dest-domain, it sets the destination domain")
+		}
+		a.DestinationDomain = a.Rename
+		a.RewriteValues = true
+	}
+	return nil
+}
+
+// canonicalDomains converts the internationalized domains given on the
+// command line to punycode, see dns.CanonicalDomain.
+func (a *App) canonicalDomains() error {
+	var err error
+	for _, domain := range []*string{&a.Domain, &a.DestinationDomain, &a.Subtree} {
+		*domain, err = dns.CanonicalDomain(*domain)
+		if err != nil {
+			return err
+		}
+	}
+	for _, domains := range [][]string{a.Domains, a.ExcludeZones} {
+		for i := range domains {
+			domains[i], err = dns.CanonicalDomain(domains[i])
 			if err != nil {
 				return err
 			}
-
-			if updated {
-				log.Printf("Registrar NS records for '%s' updated\n", a.Domain)
-			} else {
-				log.Printf("Registrar NS records for '%s' are already up to date\n", a.Domain)
-			}
 		}
 	}
 	return nil
 }
 
+func (a *App) destinationDomain() string {
+	if a.DestinationDomain == "" && a.Subtree != "" {
+		return a.Subtree
+	}
+	if a.DestinationDomain == "" {
+		return a.Domain
+	}
+	return a.DestinationDomain
+}
+
 func NewCommand() *cobra.Command {
-	a := App{}
+	return newCommand(&App{})
+}
 
+// newCommand returns the route53copy command, which sets the fields of a
+// from its flags and arguments.
+func newCommand(a *App) *cobra.Command {
 	c := &cobra.Command{
-		Use:   "route53copy <source_profile> <dest_profile> <domain>",
+		Use:   "route53copy <source_profile> <dest_profile> [domain...]",
 		Short: "Route53Copy is a tool to copy records from one AWS account to another",
-		Args:  cobra.MinimumNArgs(3),
+		Long: `Route53Copy is a tool to copy records from one AWS account to another.
+
+With --dest, the destination profiles are given by the flag instead of the
+second argument, and the same records are copied into each of them:
+
+  route53copy --dest <dest_profile> --dest <dest_profile> <source_profile> <domain>`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			a.SourceProfile = args[0]
-			a.DestinationProfile = args[1]
-			a.Domain = args[2]
+			domains := args[1:]
+			if len(a.Destinations) == 0 {
+				if len(args) < 2 {
+					return errors.New("missing destination profile, give it as the second argument or with --dest")
+				}
+				a.DestinationProfile = args[1]
+				domains = args[2:]
+			} else {
+				a.DestinationProfile = a.Destinations[0]
+			}
+			switch {
+			case len(domains) == 1:
+				a.Domain = domains[0]
+			case len(domains) > 1:
+				a.Domains = domains
+			}
+			err := a.resolveAliases()
+			if err != nil {
+				return err
+			}
+			err = a.canonicalDomains()
+			if err != nil {
+				return err
+			}
+			for _, name := range []string{"ttl-override", "min-ttl", "max-ttl", "dealias-ttl"} {
+				ttl, _ := cmd.Flags().GetInt64(name)
+				if cmd.Flags().Changed(name) && ttl <= 0 {
+					return fmt.Errorf("--%s must be a positive number of seconds", name)
+				}
+			}
 			return a.Run(cmd.Context())
 		},
 		SilenceErrors: true,
 		SilenceUsage:  true,
 	}
 	f := c.Flags()
+	f.BoolVar(&a.AllZones, "all-zones", false, "Copy every hosted zone of the source profile, the domain argument is not used")
+	f.IntVar(&a.Concurrency, "concurrency", 2, "Number of zones or destinations copied in parallel with --all-zones, several domains or several --dest profiles")
+	f.StringSliceVar(&a.Destinations, "dest", nil, "Destination profiles to copy the same records into, instead of the second argument (repeatable or comma separated)")
+	f.StringSliceVar(&a.ExcludeZones, "exclude-zone", nil, "Domains to skip with --all-zones (comma separated)")
+	f.BoolVar(&a.Private, "private", false, "Use private hosted zones instead of public ones")
+	f.BoolVar(&a.Public, "public", false, "Use public hosted zones, ignoring private zones of the same name (the default, for scripts naming the zone type either way)")
+	f.StringArrayVar(&a.VPCs, "vpc", nil, "VPC to associate with the private destination zone, given as vpc-id or vpc-id:region, the first one creating it (repeatable)")
+	f.StringVar(&a.VPCID, "vpc-id", "", "VPC to associate with the destination zone when creating a private zone")
+	_ = f.MarkDeprecated("vpc-id", "use --vpc instead")
+	f.StringVar(&a.VPCRegion, "vpc-region", "", "Region of --vpc-id (defaults to the client region)")
+	_ = f.MarkDeprecated("vpc-region", "use --vpc vpc-id:region instead")
+	f.BoolVar(&a.CopyVPC, "copy-vpc-associations", false, "Associate the VPCs of the private source zone with the destination zone, authorizing the source profile for VPCs it owns")
+	f.StringVar(&a.DelegationSetID, "delegation-set-id", "", "Reusable delegation set for a newly created destination zone, which an existing destination zone must already use, see route53copy list-delegation-sets")
+	f.BoolVar(&a.SyncComment, "sync-comment", false, "Copy the source zone comment to an existing destination zone")
+	f.StringVar(&a.ZoneComment, "zone-comment", "", "Comment of a newly created destination zone, or of an existing one with --sync-comment, instead of the source zone comment and where it was copied from (not --comment, which is the change batch comment)")
+	f.StringVar(&a.SourceRoleARN, "source-role-arn", "", "Role to assume with the source profile credentials")
+	f.StringVar(&a.DestinationRoleARN, "dest-role-arn", "", "Role to assume with the destination profile credentials")
+	f.StringVar(&a.ExternalID, "external-id", "", "External id passed when assuming --source-role-arn or --dest-role-arn")
+	f.StringVar(&a.SessionName, "session-name", dns.DefaultSessionName, "Session name used when assuming --source-role-arn or --dest-role-arn")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
 	f.BoolVar(&a.DryRun, "dry", false, "Dry run")
 	f.BoolVar(&a.UpdateNS, "update-ns", false, "Update nameserver records")
+	f.StringVar(&a.NoOverwrite, "no-overwrite", "", "Only create the records missing from the destination: fail before copying anything when it holds some with other values, or with --no-overwrite=warn leave those out and fail once the others are copied")
+	f.Lookup("no-overwrite").NoOptDefVal = noOverwriteFail
+	f.BoolVar(&a.UpsertUnchanged, "upsert-unchanged", false, "Also upsert the records the destination already holds as is, instead of leaving them out (not --force, which is the DNSSEC override of --update-ns)")
+	f.BoolVar(&a.Force, "force", false, "With --update-ns, update the nameservers even when the source zone is signed with DNSSEC and the destination is not")
+	f.BoolVar(&a.EnableDNSSEC, "enable-dnssec", false, "Sign the destination zone with DNSSEC and print the DS record to publish at the registrar")
+	f.StringVar(&a.KMSKeyARN, "kms-key-arn", "", "KMS key (ECC_NIST_P256, in us-east-1) backing the key-signing key created by --enable-dnssec")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for each change batch to be in sync")
+	f.DurationVar(&a.WaitNS, "wait-ns", 0, "With --update-ns, wait up to this long for the registrar to apply the nameservers")
+	f.DurationVar(&a.NSWaitTimeout, "ns-wait-timeout", 0, "With --update-ns, wait up to this long for the parent zone to delegate to the new nameservers")
+	f.StringVar(&a.DestinationDomain, "dest-domain", "", "Copy records into a destination zone with a different domain name")
+	f.StringVar(&a.Rename, "rename", "", "Copy the zone under another domain name, moving the values pointing inside it too, same as --dest-domain with --rewrite-values")
+	f.BoolVar(&a.RewriteValues, "rewrite-values", false, "Rewrite CNAME, NS, MX and SRV values pointing inside the source domain when using --dest-domain")
+	f.BoolVar(&a.AllowSameAccount, "allow-same-account", false, "Allow the source and destination profiles to refer to the same account")
+	f.StringVar(&a.SourceZoneID, "source-zone-id", "", "Use the source hosted zone with this id instead of looking it up by name")
+	f.StringVar(&a.SrcZoneID, "src-zone-id", "", "Same as --source-zone-id")
+	f.StringVar(&a.Subtree, "subtree", "", "Only copy the records of this subdomain of the zone and below, into a zone named after it, e.g. corp.example.com")
+	f.BoolVar(&a.PrintDelegation, "print-delegation", false, "With --subtree, print the NS records to add to the source zone to delegate the subtree to the destination zone")
+	f.BoolVar(&a.IntoParent, "into-parent", false, "Copy the records into the closest destination zone enclosing the domain instead of a zone of its own")
+	f.StringVar(&a.DestinationZoneID, "dest-zone-id", "", "Use the destination hosted zone with this id instead of looking it up by name")
+	f.StringVar(&a.DstZoneID, "dst-zone-id", "", "Same as --dest-zone-id")
+	f.BoolVar(&a.SkipDelegations, "skip-delegations", false, "Do not copy NS records delegating subdomains")
+	f.BoolVar(&a.SkipValidation, "skip-validation-records", false, "Do not copy ACM validation CNAMEs and _acme-challenge TXT records, the destination issues its own certificates")
+	f.BoolVar(&a.FailTrafficPolicy, "fail-on-traffic-policy", false, "Fail when the source zone has records created by traffic policies instead of leaving them out with a warning")
+	f.BoolVar(&a.CleanupOnFailure, "cleanup-on-failure", false, "Delete the destination zone without asking when this run created it and the copy applied nothing")
+	f.BoolVar(&a.AllowLiveOverwrite, "allow-live-overwrite", false, "Overwrite records of a destination zone even when it is the one the domain is delegated to")
+	f.BoolVar(&a.Interactive, "interactive", false, "Pick the records to copy from a list, routing policy record sets of a name and type together")
+	f.BoolVar(&a.Confirm, "confirm", false, "Show the records that will be created or overwritten and ask before copying")
+	f.BoolVar(&a.Lock, "lock", false, "Lock the domain with a _route53copy-lock TXT record in the destination zone while copying, failing when another copy holds it")
+	f.BoolVar(&a.BreakLock, "break-lock", false, "Take over the lock of the domain even when it has not expired, implies --lock")
+	f.DurationVar(&a.LockExpiry, "lock-expiry", dns.DefaultLockExpiry, "How long the lock is held before another copy may take it over")
+	f.StringVar(&a.Backup, "backup", "", "Save the destination records to this file before copying, see route53restore")
+	f.StringVar(&a.PlanOut, "plan-out", "", "With --dry, write the changes to this file to apply them later with route53copy apply")
+	f.StringVar(&a.StateFile, "state-file", "", "Record which change batches were applied in this file, removed once the copy succeeds (defaults to .route53copy-state-<domain>.json)")
+	f.BoolVar(&a.Resume, "resume", false, "Continue a failed copy from its state file, submitting only the batches that were not applied")
+	f.BoolVar(&a.Stream, "stream", false, "Copy the records of each page of the source zone as it is listed, instead of listing the whole zone first (no state file)")
+	f.StringVar(&a.Comment, "comment", "", "Comment of the change batches, before the version, accounts, record count and time of the copy")
+	f.StringVar(&a.Report, "report", "", "Write the outcome of every record to this file, as JSON or CSV following its .json or .csv extension")
+	f.BoolVar(&a.Verify, "verify", false, "Compare the destination records with the copied ones after the copy")
+	f.BoolVar(&a.VerifyDNS, "verify-dns", false, "Also query a sample of the copied records from the destination nameservers")
+	f.BoolVar(&a.SkipUnresolvable, "skip-unresolvable-aliases", false, "Skip alias records to other hosted zones of the source account instead of failing")
+	f.BoolVar(&a.Dealias, "dealias", false, "Replace alias records to other hosted zones of the source account with A, AAAA or CNAME records holding the resolved values of their targets")
+	f.Int64Var(&a.DealiasTTL, "dealias-ttl", dns.DefaultDealiasTTL, "TTL of the records replacing aliases with --dealias")
+	f.DurationVar(&a.DealiasTimeout, "dealias-timeout", dns.DefaultDealiasTimeout, "How long to wait for each alias target to resolve with --dealias")
+	f.StringVar(&a.Resolver, "resolver", "", "DNS server (host:port) resolving alias targets with --dealias (defaults to the system resolvers)")
+	f.Var(&a.Rewrites, "rewrite", "Replace OLD with NEW in the copied record values and alias targets, given as OLD=NEW (repeatable, applied in order, re: prefix for a regular expression)")
+	f.Var(&a.Rewrites, "substitute", "Replace OLD with NEW in the copied record values and alias targets")
+	_ = f.MarkDeprecated("substitute", "use --rewrite instead")
+	f.StringVar(&a.SubstituteFile, "substitute-file", "", "Read OLD=NEW rewrite rules from this file, one per line, applied before the --rewrite ones")
+	f.BoolVar(&a.CopyCidr, "copy-cidr-collections", true, "Copy CIDR collections referenced by records using CIDR routing and point the copies at them (not with --plan-out or --stream)")
+	f.BoolVar(&a.CopyHealthChecks, "copy-health-checks", false, "Copy health checks referenced by the records and point the copies at them, same as --health-checks=copy")
+	_ = f.MarkDeprecated("copy-health-checks", "use --health-checks=copy instead")
+	f.StringVar(&a.HealthChecks, "health-checks", healthChecksWarn, "What to do with records referring to health checks missing from the destination: warn, strip them from the records, fail before copying, or copy them")
+	f.IntVar(&a.MaxRetries, "max-retries", 5, "Retries with exponential backoff for throttled Route53 calls (0 disables retries)")
+	f.DurationVar(&a.RetryBaseDelay, "retry-base-delay", 0, "Longest delay before the first retry of a throttled call, doubled for each further retry (defaults to the AWS SDK backoff)")
+	f.BoolVar(&a.IsolateRejected, "isolate-rejected", false, "When Route53 rejects a change batch, apply its records in smaller batches to find the rejected ones and copy all the others")
+	f.BoolVar(&a.ContinueOnError, "continue-on-error", false, "Go on with the next change batches when one fails, dropping the records Route53 rejects, and list the failed records at the end")
+	f.Float64Var(&a.RateLimit, "rate-limit", 0, "Maximum Route53 API calls per second for each profile (0 for no limit)")
+	f.StringVar(&a.EndpointURL, "endpoint-url", "", "Send the Route53, Route53 Domains and STS requests to this URL, such as a local emulator (defaults to $"+dns.EndpointEnv+")")
+	f.BoolVar(&a.Insecure, "insecure", false, "Do not verify the TLS certificate of the endpoint")
+	f.StringVarP(&a.Output, "output", "o", output.FormatText, "Output format: text or json")
+	f.BoolVar(&a.Timings, "timings", false, "Print how long the Route53 calls took, by operation, at the end of the run")
+	f.BoolVar(&a.Verbose, "verbose", false, "Log every Route53 request")
+	f.BoolVarP(&a.Quiet, "quiet", "q", false, "Only log errors and the final summary")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
+	f.StringArrayVar(&a.Names, "name", nil, "Only copy records with this name or under it, e.g. api.example.com (repeatable)")
+	f.StringArrayVar(&a.IncludeNames, "include-names", nil, "Only copy records whose name matches this glob pattern (repeatable, * matches within a label, ** across labels, re: prefix for a regular expression)")
+	f.StringArrayVar(&a.Include, "include", nil, "Only copy records whose name matches this glob pattern")
+	_ = f.MarkDeprecated("include", "use --include-names instead")
+	f.Int64Var(&a.MaxRecords, "max-records", dns.DefaultMaxRecords, "Fail before listing any record when the source zone has more records than this (0 for no limit)")
+	f.BoolVar(&a.AllowEmpty, "allow-empty", false, "Succeed when the filters leave no records of the source zone to copy")
+	f.StringArrayVar(&a.ExcludeNames, "exclude-names", nil, "Do not copy records whose name matches this glob pattern (repeatable, wins over --include-names)")
+	f.StringArrayVar(&a.Exclude, "exclude", nil, "Do not copy records whose name matches this glob pattern")
+	_ = f.MarkDeprecated("exclude", "use --exclude-names instead")
+	f.Int64Var(&a.TTLOverride, "ttl-override", 0, "Set the TTL of every copied record, except aliases, to this many seconds")
+	f.Int64Var(&a.MinTTL, "min-ttl", 0, "Raise the TTL of copied records below this many seconds")
+	f.Int64Var(&a.MaxTTL, "max-ttl", 0, "Lower the TTL of copied records above this many seconds")
+	f.BoolVar(&a.CopySOA, "copy-soa-values", false, "Set the refresh, retry, expire and negative caching values of the destination SOA to the source ones")
+	f.StringVar(&a.RestoreTTLs, "restore-ttls", "", "Set the destination records back to the original TTLs in this --report file instead of copying")
+	f.StringSliceVar(&a.IncludeTypes, "include-types", nil, "Only copy records of these types (comma separated, e.g. A,AAAA,CNAME)")
+	f.StringSliceVar(&a.ExcludeTypes, "exclude-types", nil, "Do not copy records of these types (comma separated, e.g. TXT,MX)")
+	f.StringSliceVar(&a.FilterTypes, "filter-type", nil, "Only copy records of these types")
+	_ = f.MarkDeprecated("filter-type", "use --include-types instead")
+	c.ValidArgsFunction = a.completeArgs
+	_ = c.RegisterFlagCompletionFunc("dest", completeProfiles)
+	c.AddCommand(newWaitCommand())
+	c.AddCommand(newApplyCommand())
+	c.AddCommand(newListCommand())
+	c.AddCommand(newListDelegationSetsCommand())
+	c.AddCommand(newCutoverCommand())
+	c.AddCommand(newTrafficPoliciesCommand())
 	return c
 }
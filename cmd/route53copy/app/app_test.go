@@ -0,0 +1,532 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+)
+
+func TestResolveAliases(t *testing.T) {
+	tests := []struct {
+		name              string
+		args              []string
+		destinationDomain string
+		rewriteValues     bool
+		wantErr           bool
+	}{
+		{
+			name:              "rename",
+			args:              []string{"--rename", "example.net"},
+			destinationDomain: "example.net",
+			rewriteValues:     true,
+		},
+		{
+			name:              "dest-domain",
+			args:              []string{"--dest-domain", "example.net"},
+			destinationDomain: "example.net",
+		},
+		{
+			name:    "rename with dest-domain",
+			args:    []string{"--rename", "example.net", "--dest-domain", "example.org"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{}
+			err := newCommand(a).Flags().Parse(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.resolveAliases()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.DestinationDomain != tt.destinationDomain || a.RewriteValues != tt.rewriteValues {
+				t.Errorf("got --dest-domain %q and --rewrite-values %t, want %q and %t",
+					a.DestinationDomain, a.RewriteValues, tt.destinationDomain, tt.rewriteValues)
+			}
+		})
+	}
+}
+
+func TestResolveAliasesFilterType(t *testing.T) {
+	a := &App{}
+	err := newCommand(a).Flags().Parse([]string{"--filter-type", "A,AAAA", "--include-types", "CNAME"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.resolveAliases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(a.IncludeTypes, ","); got != "CNAME,A,AAAA" {
+		t.Errorf("got --include-types %s, want CNAME,A,AAAA", got)
+	}
+}
+
+func TestResolveAliasesNamePatterns(t *testing.T) {
+	a := &App{}
+	err := newCommand(a).Flags().Parse([]string{"--include", "*.example.com", "--exclude", "test.example.com", "--exclude-names", "dev.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.resolveAliases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(a.IncludeNames, ","); got != "*.example.com" {
+		t.Errorf("got --include-names %s, want *.example.com", got)
+	}
+	if got := strings.Join(a.ExcludeNames, ","); got != "dev.example.com,test.example.com" {
+		t.Errorf("got --exclude-names %s, want dev.example.com,test.example.com", got)
+	}
+}
+
+func TestResolveAliasesVPC(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		vpcs    string
+		wantErr bool
+	}{
+		{
+			name: "vpc-id",
+			args: []string{"--vpc-id", "vpc-0a1b2c3d"},
+			vpcs: "vpc-0a1b2c3d",
+		},
+		{
+			name: "vpc-id and vpc-region before vpc",
+			args: []string{"--vpc", "vpc-4e5f6a7b", "--vpc-id", "vpc-0a1b2c3d", "--vpc-region", "eu-west-1"},
+			vpcs: "vpc-0a1b2c3d:eu-west-1,vpc-4e5f6a7b",
+		},
+		{
+			name:    "vpc-region without vpc-id",
+			args:    []string{"--vpc-region", "eu-west-1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{}
+			err := newCommand(a).Flags().Parse(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.resolveAliases()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(a.VPCs, ","); got != tt.vpcs {
+				t.Errorf("got --vpc %s, want %s", got, tt.vpcs)
+			}
+		})
+	}
+}
+
+func TestResolveAliasesCopyHealthChecks(t *testing.T) {
+	a := &App{}
+	err := newCommand(a).Flags().Parse([]string{"--copy-health-checks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.resolveAliases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.HealthChecks != healthChecksCopy || !a.copyHealthChecks() {
+		t.Errorf("got --health-checks=%s, want %s", a.HealthChecks, healthChecksCopy)
+	}
+
+	a = &App{}
+	err = newCommand(a).Flags().Parse([]string{"--copy-health-checks", "--health-checks", healthChecksStrip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.resolveAliases(); err == nil {
+		t.Error("--copy-health-checks with --health-checks=strip did not fail")
+	}
+}
+
+func TestRewriteFlag(t *testing.T) {
+	a := &App{}
+	err := newCommand(a).Flags().Parse([]string{"--rewrite", "a=b", "--substitute", `re:^c=d`, "--rewrite", "e=f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Rewrites.String(); got != "a=b,re:^c=d,e=f" {
+		t.Errorf("got rules %s, want a=b,re:^c=d,e=f", got)
+	}
+
+	err = newCommand(&App{}).Flags().Parse([]string{"--rewrite", "re:(10=11"})
+	if err == nil {
+		t.Error("an invalid regular expression was accepted")
+	}
+}
+
+func TestResolveAliasesZoneIDs(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		sourceZoneID string
+		destZoneID   string
+		wantErr      bool
+	}{
+		{
+			name:         "src-zone-id and dst-zone-id",
+			args:         []string{"--src-zone-id", "Z1SRC", "--dst-zone-id", "Z2DST"},
+			sourceZoneID: "Z1SRC",
+			destZoneID:   "Z2DST",
+		},
+		{
+			name:         "source-zone-id and dest-zone-id",
+			args:         []string{"--source-zone-id", "Z1SRC", "--dest-zone-id", "Z2DST"},
+			sourceZoneID: "Z1SRC",
+			destZoneID:   "Z2DST",
+		},
+		{
+			name:         "both names of the same zone",
+			args:         []string{"--src-zone-id", "Z1SRC", "--source-zone-id", "Z1SRC"},
+			sourceZoneID: "Z1SRC",
+		},
+		{
+			name:    "different source zones",
+			args:    []string{"--src-zone-id", "Z1SRC", "--source-zone-id", "Z3OTHER"},
+			wantErr: true,
+		},
+		{
+			name:    "different destination zones",
+			args:    []string{"--dst-zone-id", "Z2DST", "--dest-zone-id", "Z3OTHER"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{}
+			err := newCommand(a).Flags().Parse(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.resolveAliases()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.SourceZoneID != tt.sourceZoneID || a.DestinationZoneID != tt.destZoneID {
+				t.Errorf("got --source-zone-id %q and --dest-zone-id %q, want %q and %q",
+					a.SourceZoneID, a.DestinationZoneID, tt.sourceZoneID, tt.destZoneID)
+			}
+		})
+	}
+}
+
+func TestRegionFlag(t *testing.T) {
+	a := &App{}
+	err := newCommand(a).Flags().Parse([]string{"--region", "eu-central-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, side := range []string{"source", "destination"} {
+		options := dns.ConfigOptions{}
+		for _, fn := range a.configOptions(side, "") {
+			fn(&options)
+		}
+		if options.Region != "eu-central-1" {
+			t.Errorf("the %s client uses region %q, want eu-central-1", side, options.Region)
+		}
+	}
+}
+
+func TestRoleFlags(t *testing.T) {
+	a := &App{}
+	err := newCommand(a).Flags().Parse([]string{
+		"--dest-role-arn", "arn:aws:iam::210987654321:role/dns-admin",
+		"--external-id", "ticket-42",
+		"--session-name", "alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		side    string
+		roleARN string
+	}{
+		{side: "source", roleARN: a.SourceRoleARN},
+		{side: "destination", roleARN: a.DestinationRoleARN},
+	}
+	for _, tt := range tests {
+		options := dns.ConfigOptions{}
+		for _, fn := range a.configOptions(tt.side, tt.roleARN) {
+			fn(&options)
+		}
+		if options.RoleARN != tt.roleARN {
+			t.Errorf("the %s client assumes %q, want %q", tt.side, options.RoleARN, tt.roleARN)
+		}
+		if options.ExternalID != "ticket-42" || options.SessionName != "alice" {
+			t.Errorf("the %s client uses external id %q and session %q", tt.side, options.ExternalID, options.SessionName)
+		}
+	}
+	if a.SourceRoleARN != "" || a.DestinationRoleARN != "arn:aws:iam::210987654321:role/dns-admin" {
+		t.Errorf("got roles %q and %q", a.SourceRoleARN, a.DestinationRoleARN)
+	}
+}
+
+func TestCheckAccounts(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		dstAccount string
+		wantErr    bool
+	}{
+		{name: "different accounts", dstAccount: "210987654321"},
+		{name: "same account", dstAccount: "123456789012", wantErr: true},
+		{name: "same account allowed", args: []string{"--allow-same-account"}, dstAccount: "123456789012"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcServer := fakeroute53.NewServer()
+			t.Cleanup(srcServer.Close)
+			dstServer := fakeroute53.NewServer()
+			t.Cleanup(dstServer.Close)
+			dstServer.Account = tt.dstAccount
+			a := &App{}
+			err := newCommand(a).Flags().Parse(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = a.checkAccounts(context.Background(), dns.NewRouteCopyForTest("prod", srcServer.URL),
+				dns.NewRouteCopyForTest("staging", dstServer.URL))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "--allow-same-account") {
+					t.Errorf("got %v, want an error suggesting --allow-same-account", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func recordSet(name string, rrtype rtypes.RRType, value string) rtypes.ResourceRecordSet {
+	return rtypes.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            rrtype,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(value)}},
+	}
+}
+
+func TestRecordReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		reject string
+		// want are the name, action, previous value, new value and status
+		// of the rows in order.
+		want    [][5]string
+		wantErr bool
+	}{
+		{
+			name: "copy",
+			file: "report.csv",
+			want: [][5]string{
+				{"mail.example.com.", "UPSERT", "", "192.0.2.2", dns.OutcomeApplied},
+				{"www.example.com.", "UPSERT", "198.51.100.1", "192.0.2.1", dns.OutcomeApplied},
+			},
+		},
+		{
+			name:   "rejected batch",
+			file:   "report.json",
+			reject: "mail.example.com.",
+			want: [][5]string{
+				{"mail.example.com.", "UPSERT", "", "192.0.2.2", dns.OutcomeFailed},
+				{"www.example.com.", "UPSERT", "198.51.100.1", "192.0.2.1", dns.OutcomeFailed},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srcServer := fakeroute53.NewServer()
+			t.Cleanup(srcServer.Close)
+			dstServer := fakeroute53.NewServer()
+			t.Cleanup(dstServer.Close)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID,
+				recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+				recordSet("mail.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+			)
+			dstZoneID := dstServer.AddZone("example.com", false)
+			dstServer.AddRecords(dstZoneID, recordSet("www.example.com.", rtypes.RRTypeA, "198.51.100.1"))
+			dstServer.Reject = func(rs rtypes.ResourceRecordSet) string {
+				if aws.ToString(rs.Name) == tt.reject {
+					return "RRSet " + tt.reject + " is rejected by the test"
+				}
+				return ""
+			}
+			dir := t.TempDir()
+			report := filepath.Join(dir, tt.file)
+			a := &App{}
+			err := newCommand(a).Flags().Parse([]string{"--report", report, "--state-file", filepath.Join(dir, "state.json")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.Domain = "example.com"
+			a.records = output.NewRecordReport("route53copy")
+
+			err = a.copyZone(ctx, dns.NewRouteCopyForTest("prod", srcServer.URL), dns.NewRouteCopyForTest("staging", dstServer.URL),
+				nil, output.NewReport("route53copy", a.Domain, false))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %t", err, tt.wantErr)
+			}
+			if werr := a.writeRecords(ctx, err); werr != nil {
+				t.Fatal(werr)
+			}
+
+			written, err := output.ReadRecordReportFile(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr && !strings.Contains(written.Error, "InvalidChangeBatch") {
+				t.Errorf("the report has error %q, want the rejected batch", written.Error)
+			}
+			if len(written.Records) != len(tt.want) {
+				t.Fatalf("got %d records, want %d: %+v", len(written.Records), len(tt.want), written.Records)
+			}
+			for i, want := range tt.want {
+				row := written.Records[i]
+				if got := [5]string{row.Name, row.Action, row.Previous, row.Value, row.Status}; got != want {
+					t.Errorf("record %d is %v, want %v", i, got, want)
+				}
+				if row.Zone != "example.com" || row.Type != "A" {
+					t.Errorf("record %d is of zone %s and type %s", i, row.Zone, row.Type)
+				}
+				if tt.wantErr {
+					if row.ChangeID != "" || !strings.Contains(row.Error, "InvalidChangeBatch") {
+						t.Errorf("record %d has change %q and error %q, want the rejected batch", i, row.ChangeID, row.Error)
+					}
+				} else if row.ChangeID == "" || row.Error != "" {
+					t.Errorf("record %d has change %q and error %q, want its batch", i, row.ChangeID, row.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRemainingPlan(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	a := &App{}
+	err = newCommand(a).Flags().Parse([]string{"--dest-domain", "example.net."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Domain = "example.com"
+	a.SourceProfile = "prod"
+	www := recordSet("www.example.net.", rtypes.RRTypeA, "192.0.2.1")
+	mail := recordSet("mail.example.net.", rtypes.RRTypeA, "192.0.2.2")
+	remaining := []rtypes.Change{{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &mail}}
+	result := dns.CopyResult{
+		SourceZone:      rtypes.HostedZone{Id: aws.String("/hostedzone/ZSOURCE"), Name: aws.String("example.com.")},
+		DestinationZone: rtypes.HostedZone{Id: aws.String("/hostedzone/ZDEST"), Name: aws.String("example.net.")},
+		Changes:         []rtypes.Change{{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &www}},
+		Remaining:       remaining,
+		Existing:        []rtypes.ResourceRecordSet{www, recordSet("mail.example.net.", rtypes.RRTypeA, "198.51.100.2")},
+		Batches:         []dns.BatchResult{{ChangeInfo: &rtypes.ChangeInfo{Id: aws.String("/change/C1")}, Changes: 1}},
+	}
+
+	a.writeRemainingPlan(context.Background(), result, &dns.Interrupted{Batch: 1, Batches: 2, Remaining: remaining})
+	plan, err := dns.ReadPlanFile("route53copy-example.net-remaining.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Domain != "example.com" || plan.SourceProfile != "prod" || plan.DestinationZoneID != "ZDEST" {
+		t.Errorf("got a plan of %s from %s into %s", plan.Domain, plan.SourceProfile, plan.DestinationZoneID)
+	}
+	if len(plan.Changes) != 1 || aws.ToString(plan.Changes[0].ResourceRecordSet.Name) != "mail.example.net." {
+		t.Errorf("got %d changes in the plan, want the remaining change of mail.example.net.", len(plan.Changes))
+	}
+	if len(plan.Existing) != 1 || aws.ToString(plan.Existing[0].ResourceRecords[0].Value) != "198.51.100.2" {
+		t.Errorf("the plan replaces %+v, want the existing mail.example.net.", plan.Existing)
+	}
+}
+
+func TestCanonicalDomains(t *testing.T) {
+	a := &App{
+		Domain:            "münchen-shop.de",
+		DestinationDomain: "Example.COM",
+		Domains:           []string{"bücher.example", "xn--mnchen-shop-thb.de."},
+		ExcludeZones:      []string{"MÜNCHEN.de"},
+	}
+	if err := a.canonicalDomains(); err != nil {
+		t.Fatal(err)
+	}
+	got := append([]string{a.Domain, a.DestinationDomain}, append(a.Domains, a.ExcludeZones...)...)
+	want := []string{"xn--mnchen-shop-thb.de", "Example.COM", "xn--bcher-kva.example", "xn--mnchen-shop-thb.de.", "xn--mnchen-3ya.de"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got domains %v, want %v", got, want)
+	}
+
+	a = &App{Domain: "example.com", Domains: []string{"xn--zz-.com"}}
+	if err := a.canonicalDomains(); err == nil || !strings.Contains(err.Error(), "xn--zz-.com") {
+		t.Errorf("got %v, want an error naming xn--zz-.com", err)
+	}
+}
+
+func TestLogVerificationRedacted(t *testing.T) {
+	dns.SetRedaction(true)
+	t.Cleanup(func() { dns.SetRedaction(false) })
+	var buf bytes.Buffer
+	w := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(w) })
+	secret := `"google-site-verification=s3cr3t-t0k3n"`
+	logVerification(context.Background(), dns.Verification{
+		DNSMismatches: []dns.DNSMismatch{
+			{Record: recordSet("example.com.", rtypes.RRTypeTxt, `"other"`), Nameserver: "ns-1.awsdns-01.org.", Answers: []string{secret}},
+		},
+	})
+	if strings.Contains(buf.String(), secret) {
+		t.Fatalf("a TXT value is logged:\n%s", buf.String())
+	}
+	if want := dns.RedactValue(rtypes.RRTypeTxt, secret); !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %s in:\n%s", want, buf.String())
+	}
+}
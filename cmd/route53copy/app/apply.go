@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+// ApplyApp applies a plan written by a dry run with --plan-out, without
+// reading the source zone again.
+type ApplyApp struct {
+	Profile     string
+	Plan        string
+	Region      string
+	Force       bool
+	Comment     string
+	WaitTimeout time.Duration
+}
+
+func (a *ApplyApp) Run(ctx context.Context) error {
+	plan, err := dns.ReadPlanFile(a.Plan)
+	if err != nil {
+		return err
+	}
+	logging.Infof("Applying %d changes to '%s' planned at %s\n",
+		len(plan.Changes), plan.DestinationZone, plan.Timestamp.Format(time.RFC3339))
+
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	zone, err := service.CheckPlan(ctx, plan)
+	var stale *dns.StalePlan
+	if errors.As(err, &stale) {
+		dns.PrintDrift(stale.Diff, "plan", "destination")
+		if !a.Force {
+			return fmt.Errorf("%w, write a new plan or use --force to apply it anyway", err)
+		}
+		logging.Warnf("Applying the plan since --force is given: %s\n", err)
+	} else if err != nil {
+		return err
+	}
+
+	if len(plan.Changes) == 0 {
+		logging.Summaryf("The plan for '%s' has no changes\n", plan.DestinationZone)
+		return nil
+	}
+	start := time.Now()
+	comment := provenanceComment(a.Comment, fmt.Sprintf("applied %d changes of a plan from %s to %s",
+		len(plan.Changes), plan.SourceProfile, accountOf(ctx, service)))
+	_, err = service.UpdateRecords(ctx, aws.ToString(zone.Id), comment, plan.Changes, a.WaitTimeout)
+	if err != nil {
+		return err
+	}
+	logging.Summaryf("%d changes from %s applied to '%s' in %s\n", len(plan.Changes), a.Plan,
+		plan.DestinationZone, time.Since(start).Round(time.Second))
+	return nil
+}
+
+func newApplyCommand() *cobra.Command {
+	a := ApplyApp{}
+
+	c := &cobra.Command{
+		Use:   "apply --plan <file> <dest_profile>",
+		Short: "Apply the changes planned by a dry run with --plan-out",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProfiles(cmd, args, toComplete)
+	}
+	f := c.Flags()
+	f.StringVar(&a.Plan, "plan", "", "Plan file written by --plan-out")
+	f.StringVar(&a.Comment, "comment", "", "Comment of the change batches, before the version, accounts, change count and time")
+	f.BoolVar(&a.Force, "force", false, "Apply the plan even when the destination records it replaces changed since it was written")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for each change batch to be in sync")
+	_ = c.MarkFlagRequired("plan")
+	return c
+}
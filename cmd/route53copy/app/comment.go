@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/cmd"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+)
+
+// batchComment returns the dns.CopyOptions.Comment of a copy: --comment,
+// followed by who copied what, most important first since Route53 truncates
+// comments.
+func (a *App) batchComment(srcService, dstService *dns.RouteCopy) func(context.Context, []rtypes.Change) string {
+	return func(ctx context.Context, changes []rtypes.Change) string {
+		from := accountOf(ctx, srcService)
+		to := accountOf(ctx, dstService)
+		return provenanceComment(a.Comment, fmt.Sprintf("copied %d records from %s to %s", len(changes), from, to))
+	}
+}
+
+// provenanceComment prefixes what was done with the tool version and appends
+// the time, after the comment given by the user.
+func provenanceComment(comment, what string) string {
+	parts := []string{}
+	if comment != "" {
+		parts = append(parts, comment)
+	}
+	parts = append(parts, fmt.Sprintf("route53copy %s %s at %s", cmd.Version, what, time.Now().UTC().Format(time.RFC3339)))
+	return dns.TruncateComment(strings.Join(parts, "; "))
+}
+
+// accountOf describes the account of a profile, or only the profile when the
+// account cannot be found.
+func accountOf(ctx context.Context, service *dns.RouteCopy) string {
+	account, err := service.GetAccountID(ctx)
+	if err != nil {
+		return service.Profile()
+	}
+	return fmt.Sprintf("%s (%s)", account, service.Profile())
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pedrokiefer/route53copy/pkg/awsconfig"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds listing the zones of the source profile, so
+// completion never hangs on expired or slow credentials.
+const completionTimeout = 3 * time.Second
+
+// completeProfiles completes the profiles in the AWS config files.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles, err := awsconfig.Profiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return profiles, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeArgs completes the profiles from the AWS config files, then the
+// domains from the zones of the source profile. With --dest, only the
+// source profile is an argument.
+func (a *App) completeArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles := 2
+	if len(a.Destinations) > 0 {
+		profiles = 1
+	}
+	if len(args) < profiles {
+		return completeProfiles(cmd, args, toComplete)
+	}
+	if a.AllZones {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return a.zoneNames(cmd.Context(), args[0], args[profiles:]), cobra.ShellCompDirectiveNoFileComp
+}
+
+// zoneNames returns the names of the zones of profile besides the ones in
+// given, or none when they cannot be listed in time.
+func (a *App) zoneNames(ctx context.Context, profile string, given []string) []string {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+
+	service, err := dns.NewRouteCopy(ctx, profile, dns.WithRegion(a.Region), dns.WithMaxRetries(1))
+	if err != nil {
+		return nil
+	}
+	zones, err := service.ListAllZones(ctx)
+	if err != nil {
+		return nil
+	}
+	skip := map[string]bool{}
+	for _, domain := range given {
+		skip[strings.ToLower(strings.TrimSuffix(domain, "."))] = true
+	}
+	names := []string{}
+	for _, zone := range zones {
+		name := strings.TrimSuffix(aws.ToString(zone.Name), ".")
+		private := zone.Config != nil && zone.Config.PrivateZone
+		if private != a.Private || skip[strings.ToLower(name)] {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+func TestCompleteArgs(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	err := os.WriteFile(config, []byte("[profile prod]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\nregion = us-east-1\n\n[profile staging]\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", config)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	// A profile without credentials fails fast instead of waiting on the
+	// instance metadata service.
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	server := fakeroute53.NewServer()
+	t.Cleanup(server.Close)
+	server.AddZone("example.com", false)
+	server.AddZone("example.org", false)
+	server.AddZone("internal.example.com", true)
+	t.Setenv(dns.EndpointEnv, server.URL)
+
+	tests := []struct {
+		name  string
+		flags []string
+		args  []string
+		want  []string
+	}{
+		{name: "source profile", want: []string{"prod", "staging"}},
+		{name: "destination profile", args: []string{"prod"}, want: []string{"prod", "staging"}},
+		{name: "domains", args: []string{"prod", "staging"}, want: []string{"example.com", "example.org"}},
+		{name: "given domains skipped", args: []string{"prod", "staging", "Example.COM."}, want: []string{"example.org"}},
+		{name: "private domains", flags: []string{"--private"}, args: []string{"prod", "staging"}, want: []string{"internal.example.com"}},
+		{name: "domains with dest", flags: []string{"--dest", "staging"}, args: []string{"prod"}, want: []string{"example.com", "example.org"}},
+		{name: "all zones", flags: []string{"--all-zones"}, args: []string{"prod", "staging"}},
+		{name: "profile without credentials", args: []string{"staging", "prod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{}
+			cmd := newCommand(a)
+			if err := cmd.Flags().Parse(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+
+			got, _ := a.completeArgs(cmd, tt.args, "")
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
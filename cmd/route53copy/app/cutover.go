@@ -0,0 +1,258 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+// highDelegationTTL is the parent NS TTL above which --ns-ttl-check warns,
+// since resolvers keep the new delegation that long after a rollback.
+const highDelegationTTL = time.Hour
+
+// CutoverApp points the registrar nameservers of a domain at the
+// destination zone once it holds the source records and its nameservers
+// answer with them.
+type CutoverApp struct {
+	SourceProfile      string
+	DestinationProfile string
+	Domain             string
+	Region             string
+	Sample             int
+	SkipTypes          []string
+	Concurrency        int
+	Timeout            time.Duration
+	NSTTLCheck         bool
+	DryRun             bool
+	Force              bool
+	WaitNS             time.Duration
+	NSWaitTimeout      time.Duration
+	Verbose            bool
+	Quiet              bool
+	Redact             bool
+}
+
+func (a *CutoverApp) Run(ctx context.Context) error {
+	err := logging.Configure(a.Verbose, a.Quiet)
+	if err != nil {
+		return err
+	}
+	dns.SetRedaction(a.Redact)
+
+	srcService, err := a.newService(ctx, a.SourceProfile, "source")
+	if err != nil {
+		return err
+	}
+	dstService, err := a.newService(ctx, a.DestinationProfile, "destination")
+	if err != nil {
+		return err
+	}
+	return a.cutover(ctx, srcService, dstService)
+}
+
+func (a *CutoverApp) newService(ctx context.Context, profile, side string) (*dns.RouteCopy, error) {
+	service, err := dns.NewRouteCopy(ctx, profile, dns.WithSide(side), dns.WithRegion(a.Region))
+	if err != nil {
+		return nil, err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// cutover checks the destination zone against the source one, and updates
+// the registrar nameservers when they match.
+func (a *CutoverApp) cutover(ctx context.Context, srcService, dstService *dns.RouteCopy) error {
+	skipTypes, err := dns.ParseRecordTypes(a.SkipTypes)
+	if err != nil {
+		return err
+	}
+
+	srcZone, srcRecords, err := a.zoneRecords(ctx, srcService)
+	if err != nil {
+		return err
+	}
+	dstZone, dstRecords, err := a.zoneRecords(ctx, dstService)
+	if err != nil {
+		return err
+	}
+	dstZoneID := aws.ToString(dstZone.Id)
+
+	nameservers := dns.ZoneNameservers(a.Domain, dstRecords)
+	if len(nameservers) == 0 {
+		return fmt.Errorf("the destination zone of '%s' has no apex NS record", a.Domain)
+	}
+
+	srcRecords = dns.RemoveApexRecords(a.Domain, srcRecords)
+	// Aliases to records of the zone itself point at each account's own
+	// copy of the zone.
+	dstRecords = dns.RewriteRecordAliasZoneIDs(dns.RemoveApexRecords(a.Domain, dstRecords),
+		dstZoneID, aws.ToString(srcZone.Id))
+	diff := dns.DiffRecordSets(srcRecords, dstRecords)
+	if len(diff.Delete) > 0 {
+		logging.Warnf("%d records are only in %s, they will be served after the cutover\n", len(diff.Delete), a.DestinationProfile)
+	}
+
+	logging.Infof("Querying %s for the records of '%s'\n", strings.Join(nameservers, ", "), a.Domain)
+	v := dns.Verification{
+		Missing:   diff.Create,
+		Different: diff.Update,
+		DNSMismatches: dns.ProbeNameservers(ctx, nameservers, srcRecords, dns.ProbeOptions{
+			Sample:      a.Sample,
+			SkipTypes:   skipTypes,
+			Concurrency: a.Concurrency,
+			Timeout:     a.Timeout,
+		}),
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !v.OK() {
+		if len(diff.Create) > 0 || len(diff.Update) > 0 {
+			dns.PrintDrift(dns.Diff{Create: diff.Create, Update: diff.Update}, a.SourceProfile, a.DestinationProfile)
+		}
+		logVerification(ctx, v)
+		logging.Errorf("Not updating the nameservers of '%s', the destination does not match the source\n", a.Domain)
+		return &dns.VerificationFailed{Verification: v}
+	}
+	logging.Infof("The destination nameservers answer with the records of %s\n", a.SourceProfile)
+
+	if a.NSTTLCheck {
+		a.checkDelegationTTL()
+	}
+
+	srcDNSSEC, err := srcService.GetDNSSEC(ctx, aws.ToString(srcZone.Id))
+	if err != nil {
+		return err
+	}
+	dstDNSSEC, err := dstService.GetDNSSEC(ctx, dstZoneID)
+	if err != nil {
+		return err
+	}
+	if srcDNSSEC.Signing() && !dstDNSSEC.Signing() {
+		if !a.Force {
+			return fmt.Errorf("'%s' is signed with DNSSEC in %s but not in %s, not updating the nameservers: "+
+				"sign the destination zone, or use --force to update them anyway", a.Domain, a.SourceProfile, a.DestinationProfile)
+		}
+		logging.Warnf("Updating the nameservers of '%s' without DNSSEC signing since --force is given\n", a.Domain)
+	}
+
+	if a.DryRun {
+		logging.Summaryf("'%s' is ready for the cutover to %s, not updating the nameservers since --dry is given\n",
+			a.Domain, a.DestinationProfile)
+		return nil
+	}
+
+	logging.Infoln("Updating NS records")
+	nsOpts := dns.NSUpdateOptions{OperationWait: a.WaitNS, DelegationWait: a.NSWaitTimeout}
+	if nsOpts.OperationWait == 0 {
+		// The delegation only changes once the registrar applied the
+		// nameservers.
+		nsOpts.OperationWait = a.NSWaitTimeout
+	}
+	updated, err := dstService.UpdateNSRecordsWithOptions(ctx, a.Domain, dstZoneID, nsOpts)
+	if err != nil {
+		return err
+	}
+	if updated {
+		logging.Summaryf("Registrar NS records for '%s' updated\n", a.Domain)
+	} else {
+		logging.Summaryf("Registrar NS records for '%s' are already up to date\n", a.Domain)
+	}
+	if a.NSWaitTimeout > 0 {
+		logging.Summaryf("The parent zone delegates '%s' to the new nameservers\n", a.Domain)
+	}
+	return nil
+}
+
+// zoneRecords returns the zone of the domain in the account of service and
+// its record sets.
+func (a *CutoverApp) zoneRecords(ctx context.Context, service *dns.RouteCopy) (rtypes.HostedZone, []rtypes.ResourceRecordSet, error) {
+	zone, err := service.GetHostedZone(ctx, a.Domain)
+	if err != nil {
+		return zone, nil, err
+	}
+	records, err := service.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return zone, nil, err
+	}
+	return zone, records, nil
+}
+
+// checkDelegationTTL warns when the parent zone delegates the domain with a
+// TTL above highDelegationTTL. Failing to look it up is only logged.
+func (a *CutoverApp) checkDelegationTTL() {
+	ttl, err := dns.GetDelegationTTL(a.Domain)
+	if err != nil {
+		logging.Warnf("Could not look up the NS TTL of the parent zone of '%s': %s\n", a.Domain, err)
+		return
+	}
+	if ttl > highDelegationTTL {
+		logging.Warnf("The parent zone delegates '%s' with a TTL of %s, rolling back the cutover takes as long to reach every resolver\n",
+			a.Domain, ttl)
+		return
+	}
+	logging.Infof("The parent zone delegates '%s' with a TTL of %s\n", a.Domain, ttl)
+}
+
+func newCutoverCommand() *cobra.Command {
+	a := CutoverApp{}
+
+	c := &cobra.Command{
+		Use:   "cutover <source_profile> <dest_profile> <domain>",
+		Short: "Update the registrar nameservers once the destination zone answers like the source",
+		Long: `Cutover points the registrar nameservers of a domain at the destination zone,
+but only once:
+
+  1. the destination zone holds the same records as the source zone, and
+  2. every nameserver of the destination zone answers queries for the records,
+     or a --sample of them, with the source values.
+
+Otherwise it lists the differences and exits with 7 without touching the
+registrar. Alias records and records with a routing policy are compared in the
+zones but not queried, since their answers depend on the resolver.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.SourceProfile = args[0]
+			a.DestinationProfile = args[1]
+			domain, err := dns.CanonicalDomain(args[2])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 1 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProfiles(cmd, args, toComplete)
+	}
+	f := c.Flags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.IntVar(&a.Sample, "sample", 0, "Query this many records, spread over the zone, instead of all of them")
+	f.StringSliceVar(&a.SkipTypes, "skip-types", nil, "Do not query records of these types (comma separated, e.g. TXT,CAA)")
+	f.IntVar(&a.Concurrency, "concurrency", dns.DefaultProbeConcurrency, "Number of DNS queries sent at once")
+	f.DurationVar(&a.Timeout, "timeout", dns.DefaultProbeTimeout, "How long to wait for each DNS answer")
+	f.BoolVar(&a.NSTTLCheck, "ns-ttl-check", false, fmt.Sprintf("Warn when the parent zone delegates the domain with a TTL above %s, the time a rollback takes", highDelegationTTL))
+	f.BoolVar(&a.DryRun, "dry", false, "Only check the destination, do not update the nameservers")
+	f.BoolVar(&a.Force, "force", false, "Update the nameservers even when the source zone is signed with DNSSEC and the destination is not")
+	f.DurationVar(&a.WaitNS, "wait-ns", 0, "Wait up to this long for the registrar to apply the nameservers")
+	f.DurationVar(&a.NSWaitTimeout, "ns-wait-timeout", 0, "Wait up to this long for the parent zone to delegate to the new nameservers")
+	f.BoolVar(&a.Verbose, "verbose", false, "Log every Route53 request")
+	f.BoolVarP(&a.Quiet, "quiet", "q", false, "Only log errors and the final summary")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
+	return c
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	miekgdns "github.com/miekg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// nameserver serves the A records of answers, name to address, over UDP on
+// a local address.
+func nameserver(t *testing.T, answers map[string]string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &miekgdns.Server{PacketConn: pc, Handler: miekgdns.HandlerFunc(func(w miekgdns.ResponseWriter, req *miekgdns.Msg) {
+		m := &miekgdns.Msg{}
+		m.SetReply(req)
+		q := req.Question[0]
+		if value, ok := answers[strings.ToLower(q.Name)]; ok && q.Qtype == miekgdns.TypeA {
+			rr, _ := miekgdns.NewRR(q.Name + " 300 IN A " + value)
+			m.Answer = append(m.Answer, rr)
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestCutover(t *testing.T) {
+	tests := []struct {
+		name string
+		// dstRecords are the values of www and api in the destination zone.
+		dstRecords map[string]string
+		// answers are the ones of the destination nameserver.
+		answers   map[string]string
+		sample    int
+		skipTypes []string
+		// want is what the verification found, empty when it passed.
+		want string
+	}{
+		{
+			name:       "ready",
+			dstRecords: map[string]string{"www": "192.0.2.1", "api": "192.0.2.2"},
+			answers:    map[string]string{"www.example.com.": "192.0.2.1", "api.example.com.": "192.0.2.2"},
+		},
+		{
+			name:       "record missing",
+			dstRecords: map[string]string{"www": "192.0.2.1"},
+			answers:    map[string]string{"www.example.com.": "192.0.2.1", "api.example.com.": "192.0.2.2"},
+			want:       "1 missing, 0 different, 0 DNS mismatches",
+		},
+		{
+			name:       "record different",
+			dstRecords: map[string]string{"www": "192.0.2.1", "api": "198.51.100.2"},
+			answers:    map[string]string{"www.example.com.": "192.0.2.1", "api.example.com.": "198.51.100.2"},
+			want:       "0 missing, 1 different, 1 DNS mismatches",
+		},
+		{
+			name:       "nameserver answers differ",
+			dstRecords: map[string]string{"www": "192.0.2.1", "api": "192.0.2.2"},
+			answers:    map[string]string{"www.example.com.": "192.0.2.1"},
+			want:       "0 missing, 0 different, 1 DNS mismatches",
+		},
+		{
+			name:       "skipped types not queried",
+			dstRecords: map[string]string{"www": "192.0.2.1", "api": "192.0.2.2"},
+			skipTypes:  []string{"A"},
+		},
+		{
+			name:       "sample",
+			dstRecords: map[string]string{"www": "192.0.2.1", "api": "192.0.2.2"},
+			answers:    map[string]string{"api.example.com.": "192.0.2.2"},
+			sample:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcServer := fakeroute53.NewServer()
+			t.Cleanup(srcServer.Close)
+			dstServer := fakeroute53.NewServer()
+			t.Cleanup(dstServer.Close)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID,
+				recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+				recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+			)
+			dstZoneID := dstServer.AddZone("example.com", false)
+			for name, value := range tt.dstRecords {
+				dstServer.AddRecords(dstZoneID, recordSet(name+".example.com.", rtypes.RRTypeA, value))
+			}
+			// The destination zone is served by the local nameserver.
+			dstServer.AddRecords(dstZoneID, recordSet("example.com.", rtypes.RRTypeNs, nameserver(t, tt.answers)+"."))
+			a := &CutoverApp{
+				SourceProfile:      "prod",
+				DestinationProfile: "staging",
+				Domain:             "example.com",
+				Sample:             tt.sample,
+				SkipTypes:          tt.skipTypes,
+				DryRun:             true,
+			}
+
+			err := a.cutover(context.Background(), dns.NewRouteCopyForTest("prod", srcServer.URL),
+				dns.NewRouteCopyForTest("staging", dstServer.URL))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var vf *dns.VerificationFailed
+			if !errors.As(err, &vf) {
+				t.Fatalf("got %v, want VerificationFailed", err)
+			}
+			v := vf.Verification
+			if got := fmt.Sprintf("%d missing, %d different, %d DNS mismatches", len(v.Missing), len(v.Different), len(v.DNSMismatches)); got != tt.want {
+				t.Errorf("found %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
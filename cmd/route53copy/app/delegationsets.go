@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"os"
+
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// ListDelegationSetsApp lists the reusable delegation sets of a profile, to
+// pick one for --delegation-set-id.
+type ListDelegationSetsApp struct {
+	Profile string
+	Region  string
+	Output  string
+}
+
+func (a *ListDelegationSetsApp) Run(ctx context.Context) error {
+	err := output.ValidateFormat(a.Output)
+	if err != nil {
+		return err
+	}
+	if a.Output == output.FormatJSON {
+		restore := output.SilenceLog()
+		defer restore()
+	}
+
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	sets, err := service.GetReusableDelegationSets(ctx)
+	if err != nil {
+		return err
+	}
+	if a.Output == output.FormatJSON {
+		return output.WriteDelegationSets(os.Stdout, sets)
+	}
+	output.PrintDelegationSets(os.Stdout, sets)
+	logging.Summaryf("%d reusable delegation sets in %s\n", len(sets), a.Profile)
+	return nil
+}
+
+func newListDelegationSetsCommand() *cobra.Command {
+	a := ListDelegationSetsApp{}
+
+	c := &cobra.Command{
+		Use:   "list-delegation-sets <profile>",
+		Short: "List the reusable delegation sets of a profile with their nameservers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProfiles(cmd, args, toComplete)
+	}
+	f := c.Flags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.StringVarP(&a.Output, "output", "o", output.FormatText, "Output format: text or json")
+	return c
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+)
+
+// multipleDestinations reports whether the run copies to several
+// destination profiles, given with --dest.
+func (a *App) multipleDestinations() bool {
+	return len(a.Destinations) > 1
+}
+
+// validateDestinations rejects the flags that only make sense for a single
+// destination.
+func (a *App) validateDestinations() error {
+	if !a.multipleDestinations() {
+		return nil
+	}
+	switch {
+	case a.multipleZones():
+		return errors.New("several --dest profiles cannot be used with --all-zones or several domains")
+	case a.DestinationZoneID != "":
+		return errors.New("several --dest profiles cannot be used with --dest-zone-id")
+	case a.UpdateNS:
+		return errors.New("several --dest profiles cannot be used with --update-ns, the domain is delegated to a single zone")
+	case a.PlanOut != "":
+		return errors.New("several --dest profiles cannot be used with --plan-out")
+	case a.Report != "":
+		return errors.New("several --dest profiles cannot be used with --report")
+	case a.Concurrency < 1:
+		return fmt.Errorf("invalid --concurrency %d, must be at least 1", a.Concurrency)
+	}
+	seen := map[string]bool{}
+	for _, profile := range a.Destinations {
+		if seen[profile] {
+			return fmt.Errorf("destination profile %s is given more than once", profile)
+		}
+		seen[profile] = true
+	}
+	return nil
+}
+
+// copyToDestinations lists the source zone once and copies the same changes
+// to every --dest profile, with --concurrency workers. Log lines are
+// prefixed with the profile, and a destination failing does not stop the
+// others.
+func (a *App) copyToDestinations(ctx context.Context, srcService *dns.RouteCopy, types []rtypes.RRType, report *output.Report) error {
+	opts := a.copyOptions(types)
+	snapshot, err := dns.SnapshotSource(ctx, srcService, opts)
+	if err != nil {
+		return zoneHint(err)
+	}
+	logging.From(ctx).Infof("Copying '%s' from %s to %d destinations: %s\n", a.Domain, a.SourceProfile,
+		len(a.Destinations), strings.Join(a.Destinations, ", "))
+
+	if a.Backup != "" && !a.DryRun {
+		err := os.MkdirAll(a.Backup, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+
+	reports := make([]*output.Report, len(a.Destinations))
+	sem := make(chan struct{}, a.concurrency())
+	wg := sync.WaitGroup{}
+	for i, profile := range a.Destinations {
+		da := *a
+		da.DestinationProfile = profile
+		reports[i] = output.NewReport("route53copy", a.Domain, a.DryRun)
+		reports[i].Profile = profile
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(da *App, report *output.Report) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx := logging.WithPrefix(ctx, "["+da.DestinationProfile+"] ")
+			start := time.Now()
+			err := da.copyToDestination(ctx, srcService, snapshot, types, report)
+			report.Duration = time.Since(start).Seconds()
+			report.SetError(err)
+			if err != nil {
+				logging.From(ctx).Errorf("Failed to copy: %s\n", err)
+			}
+		}(&da, reports[i])
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range reports {
+		report.AddDestination(r)
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if a.Output != output.FormatJSON {
+		output.PrintDestinations(a.out(), reports)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d destinations failed", failed, len(a.Destinations))
+	}
+	return nil
+}
+
+// copyToDestination copies the changes of snapshot to a.DestinationProfile.
+func (a *App) copyToDestination(ctx context.Context, srcService *dns.RouteCopy, snapshot dns.SourceSnapshot, types []rtypes.RRType, report *output.Report) error {
+	dstService, err := a.newService(ctx, a.DestinationProfile, "destination", a.DestinationRoleARN)
+	if err != nil {
+		return err
+	}
+	err = a.checkAccounts(ctx, srcService, dstService)
+	if err != nil {
+		return err
+	}
+	opts := a.copyOptions(types)
+	opts.Comment = a.batchComment(srcService, dstService)
+	result, err := dns.CopyZoneFrom(ctx, srcService, dstService, snapshot, opts)
+	return a.finishCopy(ctx, dstService, result, err, report)
+}
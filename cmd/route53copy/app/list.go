@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"os"
+
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// ListApp lists the hosted zones of a profile, to take stock of an account
+// before a migration.
+type ListApp struct {
+	Profile      string
+	Region       string
+	Output       string
+	DomainFilter string
+}
+
+func (a *ListApp) Run(ctx context.Context) error {
+	err := output.ValidateFormat(a.Output)
+	if err != nil {
+		return err
+	}
+	err = dns.ValidateNamePatterns([]string{a.DomainFilter})
+	if err != nil {
+		return err
+	}
+	if a.Output == output.FormatJSON {
+		restore := output.SilenceLog()
+		defer restore()
+	}
+
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	zones, err := service.InventoryZones(ctx)
+	if err != nil {
+		return err
+	}
+	if a.DomainFilter != "" {
+		selected := []dns.ZoneInfo{}
+		for _, z := range zones {
+			if dns.MatchName(a.DomainFilter, z.Name) {
+				selected = append(selected, z)
+			}
+		}
+		zones = selected
+	}
+
+	if a.Output == output.FormatJSON {
+		return output.WriteInventory(os.Stdout, zones)
+	}
+	output.PrintInventory(os.Stdout, zones)
+	logging.Summaryf("%d hosted zones in %s\n", len(zones), a.Profile)
+	return nil
+}
+
+func newListCommand() *cobra.Command {
+	a := ListApp{}
+
+	c := &cobra.Command{
+		Use:   "list <profile>",
+		Short: "List the hosted zones of a profile with their record counts, registration and DNSSEC status",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProfiles(cmd, args, toComplete)
+	}
+	f := c.Flags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.StringVarP(&a.Output, "output", "o", output.FormatText, "Output format: text or json")
+	f.StringVar(&a.DomainFilter, "domain-filter", "", "Only list zones whose name matches this glob pattern (* matches within a label, ** across labels, re: prefix for a regular expression)")
+	return c
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// validateState rejects the flags that cannot be used with --state-file and
+// --resume.
+func (a *App) validateState() error {
+	switch {
+	case a.Resume && a.DryRun:
+		return errors.New("--resume cannot be used with --dry")
+	case a.StateFile != "" && a.DryRun:
+		return errors.New("--state-file cannot be used with --dry, a dry run applies no batches")
+	case a.StateFile != "" && a.multipleZones():
+		return errors.New("--state-file cannot be used with --all-zones or several domains, each zone gets its own default state file")
+	case a.StateFile != "" && a.multipleDestinations():
+		return errors.New("--state-file cannot be used with several --dest profiles, each destination gets its own default state file")
+	}
+	return nil
+}
+
+// stateFile returns the file recording the progress of the change batches,
+// see dns.CopyState, or nothing for a dry run or a streamed copy. Copies to
+// several destinations get a file per destination profile.
+func (a *App) stateFile() string {
+	if a.DryRun || a.Stream {
+		return ""
+	}
+	if a.StateFile != "" {
+		return a.StateFile
+	}
+	file := dns.DefaultStateFile(a.destinationDomain())
+	if a.multipleDestinations() {
+		file = strings.TrimSuffix(file, ".json") + "-" + a.DestinationProfile + ".json"
+	}
+	return file
+}
+
+// logResumeHint tells how to continue a copy that failed after writing its
+// state file.
+func (a *App) logResumeHint(ctx context.Context) {
+	file := a.stateFile()
+	if file == "" {
+		return
+	}
+	if _, err := os.Stat(file); err == nil {
+		logging.From(ctx).Infof("The progress of the copy is in %s, continue it with --resume\n", file)
+	}
+}
@@ -0,0 +1,30 @@
+package app
+
+import "errors"
+
+// validateStream rejects the flags a streamed copy cannot honor, since they
+// need every record of the source zone before the first one is copied.
+func (a *App) validateStream() error {
+	if !a.Stream {
+		return nil
+	}
+	switch {
+	case a.DryRun:
+		return errors.New("--stream cannot be used with --dry")
+	case a.StateFile != "" || a.Resume:
+		return errors.New("--stream cannot be used with --state-file or --resume, copy again to apply the records left out by a failed copy")
+	case a.Interactive || a.Confirm:
+		return errors.New("--stream cannot be used with --interactive or --confirm, which show every record before copying")
+	case a.Dealias || a.SkipUnresolvable:
+		return errors.New("--stream cannot be used with --dealias or --skip-unresolvable-aliases")
+	case a.copyHealthChecks():
+		return errors.New("--stream cannot be used with --health-checks=copy")
+	case a.CopySOA:
+		return errors.New("--stream cannot be used with --copy-soa-values")
+	case a.FailTrafficPolicy:
+		return errors.New("--stream cannot be used with --fail-on-traffic-policy")
+	case a.multipleDestinations():
+		return errors.New("--stream cannot be used with several --dest profiles, which get the same listing of the source zone")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+// TrafficPoliciesApp copies the traffic policy instances of a zone, and the
+// policy versions they use, to the zone of the same domain in another
+// account.
+type TrafficPoliciesApp struct {
+	SourceProfile      string
+	DestinationProfile string
+	Domain             string
+	Region             string
+	DryRun             bool
+	Verbose            bool
+	Quiet              bool
+}
+
+func (a *TrafficPoliciesApp) Run(ctx context.Context) error {
+	err := logging.Configure(a.Verbose, a.Quiet)
+	if err != nil {
+		return err
+	}
+
+	srcService, srcZone, err := a.zone(ctx, a.SourceProfile, "source")
+	if err != nil {
+		return err
+	}
+	dstService, dstZone, err := a.zone(ctx, a.DestinationProfile, "destination")
+	if err != nil {
+		return err
+	}
+
+	result, err := dstService.CopyTrafficPolicies(ctx, srcService, aws.ToString(srcZone.Id), aws.ToString(dstZone.Id), a.DryRun)
+	if err != nil {
+		return err
+	}
+	switch {
+	case result.InstancesCreated == 0 && result.InstancesSkipped == 0:
+		logging.Summaryf("'%s' has no traffic policy instances in %s\n", a.Domain, a.SourceProfile)
+	case a.DryRun:
+		logging.Summaryf("%d traffic policy instances and %d policy versions of '%s' would be copied to %s, %d instances are already there\n",
+			result.InstancesCreated, result.PoliciesCreated, a.Domain, a.DestinationProfile, result.InstancesSkipped)
+	default:
+		logging.Summaryf("%d traffic policy instances and %d policy versions of '%s' copied to %s, %d instances and %d policy versions were already there\n",
+			result.InstancesCreated, result.PoliciesCreated, a.Domain, a.DestinationProfile, result.InstancesSkipped, result.PoliciesReused)
+	}
+	return nil
+}
+
+// zone returns the service of the profile and its zone of the domain.
+func (a *TrafficPoliciesApp) zone(ctx context.Context, profile, side string) (*dns.RouteCopy, rtypes.HostedZone, error) {
+	service, err := dns.NewRouteCopy(ctx, profile, dns.WithSide(side), dns.WithRegion(a.Region))
+	if err != nil {
+		return nil, rtypes.HostedZone{}, err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return nil, rtypes.HostedZone{}, err
+	}
+	zone, err := service.GetHostedZone(ctx, a.Domain)
+	if err != nil {
+		return nil, zone, err
+	}
+	return service, zone, nil
+}
+
+func newTrafficPoliciesCommand() *cobra.Command {
+	a := TrafficPoliciesApp{}
+
+	c := &cobra.Command{
+		Use:   "traffic-policies <source_profile> <dest_profile> <domain>",
+		Short: "Copy the traffic policy instances of a zone and the policies they use",
+		Long: `Traffic-policies creates the traffic policy instances of the source zone in
+the destination zone of the same domain, which then holds the records they
+manage. The policy version each instance uses is created in the destination
+account first.
+
+A policy the destination already holds under the same name with the same
+document is reused instead of copied again, and instances the destination zone
+already holds with the same name and type are left as they are, so the command
+can be run again. Health checks referred to by the policy documents are not
+copied.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.SourceProfile = args[0]
+			a.DestinationProfile = args[1]
+			domain, err := dns.CanonicalDomain(args[2])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 1 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProfiles(cmd, args, toComplete)
+	}
+	f := c.Flags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.BoolVar(&a.DryRun, "dry", false, "Only list the policies and instances to copy, do not create them")
+	f.BoolVar(&a.Verbose, "verbose", false, "Log every Route53 request")
+	f.BoolVarP(&a.Quiet, "quiet", "q", false, "Only log errors and the final summary")
+	return c
+}
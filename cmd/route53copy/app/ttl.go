@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+)
+
+// restoreTTLs sets the destination records back to the original TTLs in the
+// --restore-ttls report. The source profile is not used.
+func (a *App) restoreTTLs(ctx context.Context, report *output.Report) error {
+	records, err := output.ReadRecordReportFile(a.RestoreTTLs)
+	if err != nil {
+		return err
+	}
+	zones := records.TTLChanges()
+	if len(zones) == 0 {
+		logging.Summaryf("No records in %s have an original TTL\n", a.RestoreTTLs)
+		return nil
+	}
+
+	dstService, err := dns.NewRouteCopy(ctx, a.DestinationProfile, a.configOptions("destination", a.DestinationRoleARN)...)
+	if err != nil {
+		return err
+	}
+	err = dstService.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Records copied with --into-parent are in an enclosing zone.
+		zone, err := dstService.FindEnclosingZone(ctx, name, dns.WithPrivateZone(a.Private))
+		if err != nil {
+			return err
+		}
+		results, err := dstService.RestoreTTLs(ctx, aws.ToString(zone.Id), zones[name], a.WaitTimeout)
+		report.AddBatches(results)
+		if err != nil {
+			return err
+		}
+		logging.Summaryf("Restored the TTLs of '%s' from %s\n", name, a.RestoreTTLs)
+	}
+	return nil
+}
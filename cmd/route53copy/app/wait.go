@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+// WaitApp waits for a change submitted by an earlier run that timed out.
+type WaitApp struct {
+	Profile     string
+	ChangeID    string
+	Region      string
+	WaitTimeout time.Duration
+}
+
+func (a *WaitApp) Run(ctx context.Context) error {
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	logging.Infof("Waiting up to %s for change %s\n", a.WaitTimeout, a.ChangeID)
+	start := time.Now()
+	err = service.WaitForChange(ctx, a.ChangeID, a.WaitTimeout)
+	if err != nil {
+		return err
+	}
+	logging.Summaryf("Change %s is in sync after %s\n", a.ChangeID, time.Since(start).Round(time.Second))
+	return nil
+}
+
+func newWaitCommand() *cobra.Command {
+	a := WaitApp{}
+
+	c := &cobra.Command{
+		Use:   "wait <profile> <change_id>",
+		Short: "Wait for a change that was still pending when a copy timed out",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			a.ChangeID = args[1]
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProfiles(cmd, args, toComplete)
+	}
+	f := c.Flags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for the change to be in sync")
+	return c
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+)
+
+func (a *App) validateAllZones() error {
+	if !a.multipleZones() {
+		if a.Domain == "" {
+			return errors.New("missing domain, or use --all-zones to copy every zone")
+		}
+		return nil
+	}
+	if a.AllZones && (a.Domain != "" || len(a.Domains) > 0) {
+		return errors.New("a domain cannot be given with --all-zones")
+	}
+	if a.DestinationDomain != "" || a.SourceZoneID != "" || a.DestinationZoneID != "" {
+		return errors.New("--dest-domain, --source-zone-id and --dest-zone-id cannot be used with --all-zones or several domains")
+	}
+	if a.Concurrency < 1 {
+		return fmt.Errorf("invalid --concurrency %d, must be at least 1", a.Concurrency)
+	}
+	return nil
+}
+
+// multipleZones reports whether the run copies several zones, with
+// --all-zones or several domain arguments.
+func (a *App) multipleZones() bool {
+	return a.AllZones || len(a.Domains) > 0
+}
+
+// concurrency is the number of zones copied in parallel. Zones are copied
+// one at a time with --confirm, so the prompts do not interleave.
+func (a *App) concurrency() int {
+	if a.Confirm {
+		return 1
+	}
+	return a.Concurrency
+}
+
+// showProgress reports whether progress is rendered on stderr. Concurrent
+// copies would overwrite each other's progress line, so they only log.
+func (a *App) showProgress() bool {
+	concurrent := a.multipleZones() || a.multipleDestinations()
+	return a.Output != output.FormatJSON && (!concurrent || a.concurrency() == 1)
+}
+
+// backupFile is the file --backup writes to. When copying several zones or
+// to several destinations, --backup is a directory holding one file per
+// zone or destination profile.
+func (a *App) backupFile() string {
+	if a.multipleDestinations() {
+		return filepath.Join(a.Backup, a.DestinationProfile+".json")
+	}
+	if !a.multipleZones() {
+		return a.Backup
+	}
+	return filepath.Join(a.Backup, strings.TrimSuffix(a.Domain, ".")+".json")
+}
+
+func (a *App) copyAllZones(ctx context.Context, srcService, dstService *dns.RouteCopy, types []rtypes.RRType, report *output.Report) error {
+	zones, err := srcService.ListAllZones(ctx)
+	if err != nil {
+		return err
+	}
+	return a.copyZones(ctx, srcService, dstService, types, a.selectZones(zones), report)
+}
+
+// copyDomains copies the zones of the domain arguments.
+func (a *App) copyDomains(ctx context.Context, srcService, dstService *dns.RouteCopy, types []rtypes.RRType, report *output.Report) error {
+	zones := []rtypes.HostedZone{}
+	for _, domain := range a.Domains {
+		zones = append(zones, rtypes.HostedZone{Name: aws.String(domain)})
+	}
+	return a.copyZones(ctx, srcService, dstService, types, zones, report)
+}
+
+// copyZones copies zones with --concurrency workers sharing the clients.
+// Log lines are prefixed with the zone name, and a zone failing does not
+// stop the others. Zones without an id are looked up by name.
+func (a *App) copyZones(ctx context.Context, srcService, dstService *dns.RouteCopy, types []rtypes.RRType, zones []rtypes.HostedZone, report *output.Report) error {
+	logging.Infof("Copying %d zones from %s to %s\n", len(zones), a.SourceProfile, a.DestinationProfile)
+
+	if a.Backup != "" && !a.DryRun {
+		err := os.MkdirAll(a.Backup, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+
+	reports := make([]*output.Report, len(zones))
+	sem := make(chan struct{}, a.concurrency())
+	wg := sync.WaitGroup{}
+	for i, zone := range zones {
+		za := *a
+		za.Domain = strings.TrimSuffix(aws.ToString(zone.Name), ".")
+		za.SourceZoneID = aws.ToString(zone.Id)
+		reports[i] = output.NewReport("route53copy", za.Domain, a.DryRun)
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(za *App, report *output.Report) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx := logging.WithPrefix(ctx, "["+za.Domain+"] ")
+			start := time.Now()
+			err := za.copyZone(ctx, srcService, dstService, types, report)
+			report.Duration = time.Since(start).Seconds()
+			report.SetError(err)
+			if err != nil {
+				logging.From(ctx).Errorf("Failed to copy: %s\n", err)
+			}
+		}(&za, reports[i])
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range reports {
+		report.AddZone(r)
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if a.Output != output.FormatJSON {
+		output.PrintZones(os.Stdout, reports)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d zones failed to copy", failed, len(zones))
+	}
+	return nil
+}
+
+// selectZones keeps the public or private zones, following --private, that
+// are not excluded with --exclude-zone.
+func (a *App) selectZones(zones []rtypes.HostedZone) []rtypes.HostedZone {
+	excluded := map[string]bool{}
+	for _, z := range a.ExcludeZones {
+		excluded[strings.ToLower(strings.TrimSuffix(z, "."))] = true
+	}
+
+	selected := []rtypes.HostedZone{}
+	for _, zone := range zones {
+		private := zone.Config != nil && zone.Config.PrivateZone
+		if private != a.Private {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(aws.ToString(zone.Name), "."))
+		if excluded[name] {
+			logging.Infof("Skipping '%s' since it is excluded with --exclude-zone\n", name)
+			continue
+		}
+		selected = append(selected, zone)
+	}
+	return selected
+}
+
+// pickZones asks which zones to copy from and to when several zones of the
+// domain have the same visibility, instead of failing with an
+// AmbiguousHostedZone. A dry run or a run without a terminal does not ask.
+func (a *App) pickZones(ctx context.Context, srcService, dstService *dns.RouteCopy) error {
+	if a.DryRun || !output.IsTerminal(os.Stdin) {
+		return nil
+	}
+	if a.SourceZoneID == "" {
+		id, err := a.pickZone(ctx, srcService, a.Domain, "source")
+		if err != nil {
+			return err
+		}
+		a.SourceZoneID = id
+	}
+	if a.DestinationZoneID == "" && !a.IntoParent {
+		id, err := a.pickZone(ctx, dstService, a.destinationDomain(), "destination")
+		if err != nil {
+			return err
+		}
+		a.DestinationZoneID = id
+	}
+	return nil
+}
+
+// pickZone returns the id of the zone of domain the user picks, or "" when
+// there is at most one zone to pick from.
+func (a *App) pickZone(ctx context.Context, service *dns.RouteCopy, domain, side string) (string, error) {
+	zones, err := service.GetHostedZones(ctx, domain, dns.WithPrivateZone(a.Private))
+	if err != nil || len(zones) < 2 {
+		return "", err
+	}
+	zone, err := output.SelectZone(fmt.Sprintf("%d zones of '%s' are in %s, pick the %s zone", len(zones), domain, service.Profile(), side), zones, a.Output)
+	if err != nil {
+		return "", err
+	}
+	logging.From(ctx).Infof("Using the %s zone %s\n", side, aws.ToString(zone.Id))
+	return aws.ToString(zone.Id), nil
+}
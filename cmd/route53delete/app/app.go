@@ -4,120 +4,318 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	rdtypes "github.com/aws/aws-sdk-go-v2/service/route53domains/types"
-	"github.com/manifoldco/promptui"
+	"github.com/pedrokiefer/route53copy/cmd"
 	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/pedrokiefer/route53copy/pkg/output"
 	"github.com/spf13/cobra"
 )
 
 type App struct {
-	Profile string
-	Domain  string
-	DryRun  bool
-	Force   bool
+	Profile     string
+	Domain      string
+	DryRun      bool
+	Force       bool
+	Output      string
+	Out         io.Writer
+	Region      string
+	Private     bool
+	Public      bool
+	KeepZone    bool
+	ZoneOnly    bool
+	Yes         bool
+	Resolver    string
+	Names       []string
+	WaitTimeout time.Duration
+	Verbose     bool
+	Quiet       bool
+	Redact      bool
+	Report      string
+	MaxRecords  int64
+
+	records *output.RecordReport
 }
 
 func (a *App) Run(ctx context.Context) error {
-	srcManager := dns.NewRouteCopy(ctx, a.Profile)
+	err := output.ValidateFormat(a.Output)
+	if err != nil {
+		return err
+	}
+	err = logging.Configure(a.Verbose, a.Quiet)
+	if err != nil {
+		return err
+	}
+	dns.SetRedaction(a.Redact)
+	if a.Private && a.Public {
+		return errors.New("--private and --public cannot be used together")
+	}
+	if a.Report != "" {
+		err = output.ValidateRecordReportFile(a.Report)
+		if err != nil {
+			return err
+		}
+		a.records = output.NewRecordReport("route53delete")
+	}
 
-	zone, err := srcManager.GetHostedZone(ctx, a.Domain)
+	err = a.runWithOutput(ctx)
+	if a.records != nil {
+		if werr := a.writeRecords(err); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (a *App) runWithOutput(ctx context.Context) error {
+	if a.Output != output.FormatJSON {
+		return a.run(ctx, output.NewReport("route53delete", a.Domain, a.DryRun))
+	}
+
+	restore := output.SilenceLog()
+	defer restore()
+
+	report := output.NewReport("route53delete", a.Domain, a.DryRun)
+	err := a.run(ctx, report)
+	report.SetError(err)
+	if werr := report.Write(a.out()); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+// writeRecords writes the --report file, even when the run failed with err.
+func (a *App) writeRecords(err error) error {
+	werr := a.records.WriteFile(a.Report, err)
+	if werr != nil {
+		return fmt.Errorf("failed to write report %s: %w", a.Report, werr)
+	}
+	logging.Infof("Wrote the outcome of %d records to %s\n", len(a.records.Records), a.Report)
+	return nil
+}
+
+// addOutcomes adds the outcome of the deletes to the --report file.
+func (a *App) addOutcomes(deletes []rtypes.Change, results []dns.BatchResult, err error) {
+	if a.records == nil {
+		return
+	}
+	a.records.Add(a.Domain, dns.RecordOutcomes(deletes, nil, results, err, a.DryRun), err)
+}
+
+func (a *App) out() io.Writer {
+	if a.Out == nil {
+		return os.Stdout
+	}
+	return a.Out
+}
+
+func (a *App) run(ctx context.Context, report *output.Report) error {
+	if a.KeepZone && a.ZoneOnly {
+		return errors.New("--keep-zone and --zone-only cannot be used together")
+	}
+	if len(a.Names) > 0 && a.ZoneOnly {
+		return errors.New("--name and --zone-only cannot be used together")
+	}
+
+	srcManager, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = srcManager.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	if a.Output != output.FormatJSON {
+		srcManager.SetProgress(output.NewProgress(os.Stderr))
+	}
+
+	zone, err := srcManager.GetHostedZone(ctx, a.Domain, dns.WithPrivateZone(a.Private))
 	if err != nil {
 		return err
 	}
 	srcZoneID := aws.ToString(zone.Id)
+	report.ZoneID = srcZoneID
+	err = dns.CheckRecordCount(zone, a.MaxRecords)
+	var tooMany *dns.TooManyRecords
+	if errors.As(err, &tooMany) {
+		return fmt.Errorf("%w, use --max-records %d or higher to delete it, or --max-records 0 to disable the limit", err, tooMany.Records)
+	}
 
 	recordSets, err := srcManager.GetResourceRecords(ctx, srcZoneID)
 	if err != nil {
 		return err
 	}
 
-	ns, err := dns.GetNameserversFor(a.Domain)
+	if len(a.Names) > 0 {
+		return a.deleteSubtrees(ctx, srcManager, srcZoneID, recordSets, report)
+	}
+
+	ns, err := dns.GetNameserversFor(a.Domain, dns.WithResolver(a.Resolver))
 	if err != nil {
 		var nsr *dns.NSRecordNotFound
 		if errors.As(err, &nsr) {
-			log.Println("No NS records found for", a.Domain)
+			logging.Infoln("No NS records found for", a.Domain)
 			a.Force = true
 		} else {
 			return err
 		}
 	}
-	nsRecords, err := dns.FindNSRecord(recordSets)
+	nsRecords, err := dns.FindNSRecord(a.Domain, recordSets)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Dig returned NS servers: %s\n", nsToString(ns))
-	log.Printf("Route53 has NS servers: %s\n", nsRecordsToString(nsRecords))
+	logging.Infof("Dig returned NS servers: %s\n", nsToString(ns))
+	logging.Infof("Route53 has NS servers: %s\n", nsRecordsToString(nsRecords))
 
 	if dns.MatchNSRecords(ns, nsRecords) && !a.Force {
-		log.Printf("Nameservers for %s match, not deleting zone\n", a.Domain)
+		logging.Infof("Nameservers for %s match, not deleting zone\n", a.Domain)
 		return nil
 	}
 
-	recordSets = dns.RemoveResourceRecordsWithTypes(recordSets, []rtypes.RRType{rtypes.RRTypeNs, rtypes.RRTypeSoa})
-	log.Printf("Found %d records for domain %s to delete\n", len(recordSets), a.Domain)
-	dns.PrintResourceRecords(recordSets)
-
-	if a.DryRun {
-		log.Printf("Dry run...exiting\n")
-		return nil
+	recordSets = dns.RemoveApexRecords(a.Domain, recordSets)
+	if a.ZoneOnly && len(recordSets) > 0 {
+		return fmt.Errorf("zone %s still contains %d records besides the apex NS and SOA, delete them first or drop --zone-only",
+			a.Domain, len(recordSets))
 	}
-
-	prompt := promptui.Prompt{
-		Label:     "Delete all records?",
-		IsConfirm: true,
+	logging.Infof("Found %d records for domain %s to delete\n", len(recordSets), a.Domain)
+	deletes := []rtypes.Change{}
+	for i := range recordSets {
+		deletes = append(deletes, rtypes.Change{Action: rtypes.ChangeActionDelete, ResourceRecordSet: &recordSets[i]})
 	}
-
-	result, err := prompt.Run()
-	if err != nil {
-		fmt.Printf("Prompt failed %v\n", err)
-		return nil
+	report.AddChanges(deletes)
+	if a.Output != output.FormatJSON {
+		dns.PrintResourceRecords(recordSets)
 	}
 
-	if result != "y" {
-		log.Printf("Aborting\n")
+	if a.DryRun {
+		logging.Infof("Dry run...exiting\n")
+		a.addOutcomes(deletes, nil, nil)
 		return nil
 	}
 
-	if len(recordSets) > 0 {
-		log.Printf("Deleting records...\n")
-		drchID, err := srcManager.DeleteRecords(ctx, srcZoneID, recordSets)
+	if a.Yes {
+		logging.Infof("Not asking for confirmation since --yes is given\n")
+	} else {
+		label := "Delete all records and the zone?"
+		if a.KeepZone {
+			label = "Delete all records?"
+		} else if a.ZoneOnly {
+			label = "Delete the zone?"
+		}
+		err := output.Confirm(label, a.Output)
 		if err != nil {
+			a.addOutcomes(deletes, nil, err)
 			return err
 		}
+	}
 
-		err = srcManager.WaitForChange(ctx, drchID, 2*time.Minute)
+	if len(recordSets) > 0 {
+		logging.Infof("Deleting records...\n")
+		results, err := srcManager.DeleteRecordsWithOptions(ctx, srcZoneID, a.Domain, recordSets, a.WaitTimeout, a.deleteOptions())
+		report.AddBatches(results)
+		a.addOutcomes(deletes, results, err)
 		if err != nil {
+			var be *dns.BatchError
+			if errors.As(err, &be) {
+				logging.Infof("%d records were deleted before the failure\n", len(be.Applied))
+			}
 			return err
 		}
 
-		log.Printf("Deleted all records for domain %s\n", a.Domain)
+		logging.Summaryf("Deleted all records for domain %s\n", a.Domain)
 	} else {
-		log.Printf("No records to delete for domain %s\n", a.Domain)
+		logging.Infof("No records to delete for domain %s\n", a.Domain)
+	}
+
+	if a.KeepZone {
+		logging.Summaryf("Keeping zoneId %s since --keep-zone is given\n", srcZoneID)
+		return nil
 	}
-	log.Printf("Removing zoneId %s...\n", srcZoneID)
+	logging.Infof("Removing zoneId %s...\n", srcZoneID)
 
 	chID, err := srcManager.DeleteHostedZone(ctx, srcZoneID)
 	if err != nil {
 		return err
 	}
 
-	err = srcManager.WaitForChange(ctx, chID, 2*time.Minute)
+	err = srcManager.WaitForChange(ctx, chID, a.WaitTimeout)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Deleted zoneId %s\n", srcZoneID)
+	logging.Summaryf("Deleted zoneId %s\n", srcZoneID)
+
+	return nil
+}
+
+// deleteSubtrees deletes the records under a.Names, keeping the zone and the
+// rest of its records. The nameservers are not checked since the zone stays.
+func (a *App) deleteSubtrees(ctx context.Context, srcManager *dns.RouteCopy, srcZoneID string, recordSets []rtypes.ResourceRecordSet, report *output.Report) error {
+	recordSets = dns.RemoveApexRecords(a.Domain, recordSets)
+	recordSets, _ = dns.FilterRecordSubtrees(recordSets, a.Names)
+	if len(recordSets) == 0 {
+		logging.Summaryf("No records found under %s\n", strings.Join(a.Names, ","))
+		return nil
+	}
+
+	logging.Infof("Found %d records under %s to delete\n", len(recordSets), strings.Join(a.Names, ","))
+	deletes := []rtypes.Change{}
+	for i := range recordSets {
+		deletes = append(deletes, rtypes.Change{Action: rtypes.ChangeActionDelete, ResourceRecordSet: &recordSets[i]})
+	}
+	report.AddChanges(deletes)
+	if a.Output != output.FormatJSON {
+		dns.PrintResourceRecords(recordSets)
+	}
+
+	if a.DryRun {
+		logging.Infof("Dry run...exiting\n")
+		a.addOutcomes(deletes, nil, nil)
+		return nil
+	}
+
+	if a.Yes {
+		logging.Infof("Not asking for confirmation since --yes is given\n")
+	} else {
+		err := output.Confirm(fmt.Sprintf("Delete the records under %s?", strings.Join(a.Names, ",")), a.Output)
+		if err != nil {
+			a.addOutcomes(deletes, nil, err)
+			return err
+		}
+	}
+
+	logging.Infof("Deleting records...\n")
+	results, err := srcManager.DeleteRecordsWithOptions(ctx, srcZoneID, a.Domain, recordSets, a.WaitTimeout, a.deleteOptions())
+	report.AddBatches(results)
+	a.addOutcomes(deletes, results, err)
+	if err != nil {
+		var be *dns.BatchError
+		if errors.As(err, &be) {
+			logging.Infof("%d records were deleted before the failure\n", len(be.Applied))
+		}
+		return err
+	}
 
+	logging.Summaryf("Deleted %d records under %s, keeping zoneId %s\n", len(recordSets), strings.Join(a.Names, ","), srcZoneID)
 	return nil
 }
 
+// deleteOptions sets the comment of the change batches to the tool version,
+// profile and time of the deletion.
+func (a *App) deleteOptions() dns.DeleteOptions {
+	return dns.DeleteOptions{
+		Comment: fmt.Sprintf("Deleted by route53delete %s with %s at %s", cmd.Version, a.Profile, time.Now().UTC().Format(time.RFC3339)),
+	}
+}
+
 func NewCommand() *cobra.Command {
 	a := App{}
 
@@ -127,15 +325,35 @@ func NewCommand() *cobra.Command {
 		Args:  cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			a.Profile = args[0]
-			a.Domain = args[1]
+			domain, err := dns.CanonicalDomain(args[1])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
 			return a.Run(cmd.Context())
 		},
 		SilenceErrors: true,
 		SilenceUsage:  true,
 	}
 	f := c.Flags()
+	f.BoolVar(&a.Private, "private", false, "Use private hosted zones instead of public ones")
+	f.BoolVar(&a.Public, "public", false, "Use public hosted zones, ignoring private zones of the same name (the default, for scripts naming the zone type either way)")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
 	f.BoolVar(&a.DryRun, "dry", false, "Dry run")
 	f.BoolVar(&a.Force, "force", false, "Force delete")
+	f.BoolVarP(&a.Yes, "yes", "y", false, "Do not ask for confirmation, for use in scripts")
+	f.BoolVar(&a.KeepZone, "keep-zone", false, "Only delete the records, keeping the hosted zone")
+	f.BoolVar(&a.KeepZone, "records-only", false, "Same as --keep-zone")
+	f.BoolVar(&a.ZoneOnly, "zone-only", false, "Only delete the hosted zone, failing if it still has records besides the apex NS and SOA")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for each change to be in sync")
+	f.StringVar(&a.Report, "report", "", "Write the outcome of every record to this file, as JSON or CSV following its .json or .csv extension")
+	f.Int64Var(&a.MaxRecords, "max-records", dns.DefaultMaxRecords, "Fail before listing any record when the zone has more records than this (0 for no limit)")
+	f.StringArrayVar(&a.Names, "name", nil, "Only delete records with this name or under it, keeping the zone (repeatable)")
+	f.StringVar(&a.Resolver, "resolver", "", "DNS server (host:port) used to look up the current nameservers (defaults to the system resolvers)")
+	f.StringVarP(&a.Output, "output", "o", output.FormatText, "Output format: text or json")
+	f.BoolVar(&a.Verbose, "verbose", false, "Log every Route53 request")
+	f.BoolVarP(&a.Quiet, "quiet", "q", false, "Only log errors and the final summary")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
 	return c
 }
 
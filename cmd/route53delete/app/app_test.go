@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+)
+
+func recordSet(name string, rrtype rtypes.RRType, value string) rtypes.ResourceRecordSet {
+	return rtypes.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            rrtype,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(value)}},
+	}
+}
+
+func TestDeleteSubtrees(t *testing.T) {
+	ctx := context.Background()
+	server := fakeroute53.NewServer()
+	defer server.Close()
+	zoneID := server.AddZone("example.com", false)
+	server.AddRecords(zoneID,
+		recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet(`\052.api.example.com.`, rtypes.RRTypeA, "192.0.2.2"),
+		recordSet("v1.api.example.com.", rtypes.RRTypeTxt, `"v=1"`),
+		recordSet("myapi.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.4"),
+	)
+	manager := dns.NewRouteCopyForTest("test", server.URL)
+	records, err := manager.GetResourceRecords(ctx, zoneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{Domain: "example.com", Names: []string{"api.example.com."}, Yes: true, WaitTimeout: time.Minute, records: output.NewRecordReport("route53delete")}
+	err = a.deleteSubtrees(ctx, manager, zoneID, records, output.NewReport("route53delete", a.Domain, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := map[string]bool{}
+	for _, rs := range server.Records(zoneID) {
+		left[aws.ToString(rs.Name)+" "+string(rs.Type)] = true
+	}
+	want := []string{"example.com. NS", "example.com. SOA", "myapi.example.com. A", "www.example.com. A"}
+	if len(left) != len(want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+	for _, w := range want {
+		if !left[w] {
+			t.Errorf("%s was deleted", w)
+		}
+	}
+	if len(server.FindZone("example.com")) != 1 {
+		t.Error("the zone was deleted")
+	}
+
+	// The report has the deleted records and their previous values.
+	deleted := map[string]string{}
+	for _, row := range a.records.Records {
+		if row.Action != "DELETE" || row.Status != dns.OutcomeApplied || row.ChangeID == "" || row.Value != "" {
+			t.Errorf("%s is reported as %s %s in change %q with value %q", row.Name, row.Action, row.Status, row.ChangeID, row.Value)
+		}
+		deleted[row.Name+" "+row.Type] = row.Previous
+	}
+	wantDeleted := map[string]string{
+		"api.example.com. A":      "192.0.2.1",
+		`\052.api.example.com. A`: "192.0.2.2",
+		"v1.api.example.com. TXT": `"v=1"`,
+	}
+	if len(deleted) != len(wantDeleted) {
+		t.Errorf("reported %v, want %v", deleted, wantDeleted)
+	}
+	for name, previous := range wantDeleted {
+		if deleted[name] != previous {
+			t.Errorf("%s is reported with previous value %q, want %q", name, deleted[name], previous)
+		}
+	}
+}
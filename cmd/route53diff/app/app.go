@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type App struct {
+	ProfileA    string
+	ProfileB    string
+	Domain      string
+	Region      string
+	Private     bool
+	IncludeApex bool
+	Verbose     bool
+	Quiet       bool
+	Redact      bool
+}
+
+// Run compares the zones of both profiles, returning a dns.ZonesDiffer when
+// they drifted apart.
+func (a *App) Run(ctx context.Context) error {
+	err := logging.Configure(a.Verbose, a.Quiet)
+	if err != nil {
+		return err
+	}
+	dns.SetRedaction(a.Redact)
+
+	serviceA, err := a.newService(ctx, a.ProfileA)
+	if err != nil {
+		return err
+	}
+	serviceB, err := a.newService(ctx, a.ProfileB)
+	if err != nil {
+		return err
+	}
+	return a.diff(ctx, serviceA, serviceB)
+}
+
+func (a *App) newService(ctx context.Context, profile string) (*dns.RouteCopy, error) {
+	service, err := dns.NewRouteCopy(ctx, profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return nil, err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// diff compares the zone in both accounts.
+func (a *App) diff(ctx context.Context, serviceA, serviceB *dns.RouteCopy) error {
+	zoneA, recordsA, err := a.zoneRecords(ctx, serviceA)
+	if err != nil {
+		return err
+	}
+	zoneB, recordsB, err := a.zoneRecords(ctx, serviceB)
+	if err != nil {
+		return err
+	}
+	// Aliases to records of the zone itself point at each account's own
+	// copy of the zone.
+	recordsB = dns.RewriteRecordAliasZoneIDs(recordsB, aws.ToString(zoneB.Id), aws.ToString(zoneA.Id))
+
+	diff := dns.DiffRecordSets(recordsA, recordsB)
+	if diff.Empty() {
+		logging.Summaryf("'%s' is the same in %s and %s\n", a.Domain, a.ProfileA, a.ProfileB)
+		return nil
+	}
+	logging.Infof("%d records only in %s, %d only in %s, %d different\n",
+		len(diff.Create), a.ProfileA, len(diff.Delete), a.ProfileB, len(diff.Update))
+	dns.PrintDrift(diff, a.ProfileA, a.ProfileB)
+	return &dns.ZonesDiffer{Diff: diff}
+}
+
+// zoneRecords returns the zone of the service and its record sets, without
+// the apex NS and SOA unless --include-apex is given.
+func (a *App) zoneRecords(ctx context.Context, service *dns.RouteCopy) (rtypes.HostedZone, []rtypes.ResourceRecordSet, error) {
+	zone, err := service.GetHostedZone(ctx, a.Domain, dns.WithPrivateZone(a.Private))
+	if err != nil {
+		return zone, nil, err
+	}
+	records, err := service.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return zone, nil, err
+	}
+	if !a.IncludeApex {
+		records = dns.RemoveApexRecords(a.Domain, records)
+	}
+	return zone, records, nil
+}
+
+func NewCommand() *cobra.Command {
+	a := App{}
+
+	c := &cobra.Command{
+		Use:   "route53diff <profile_a> <profile_b> <domain>",
+		Short: "Route53Diff is a tool to show how a zone differs between two AWS accounts",
+		Long: `Route53Diff is a tool to show how a zone differs between two AWS accounts.
+
+It exits with 0 when the zones match and 1 when they differ, so it can run as
+a scheduled check.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.ProfileA = args[0]
+			a.ProfileB = args[1]
+			domain, err := dns.CanonicalDomain(args[2])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	f := c.Flags()
+	f.BoolVar(&a.Private, "private", false, "Use private hosted zones instead of public ones")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.BoolVar(&a.IncludeApex, "include-apex", false, "Also compare the apex NS and SOA records, which always differ between accounts")
+	f.BoolVar(&a.Verbose, "verbose", false, "Log every Route53 request")
+	f.BoolVarP(&a.Quiet, "quiet", "q", false, "Only log errors and the final summary")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
+	return c
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+func recordSet(name string, rrtype rtypes.RRType, value string) rtypes.ResourceRecordSet {
+	return rtypes.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            rrtype,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(value)}},
+	}
+}
+
+// aliasTo returns an alias of name to target in the zone zoneID.
+func aliasTo(name, zoneID, target string) rtypes.ResourceRecordSet {
+	return rtypes.ResourceRecordSet{
+		Name:        aws.String(name),
+		Type:        rtypes.RRTypeA,
+		AliasTarget: &rtypes.AliasTarget{HostedZoneId: aws.String(zoneID), DNSName: aws.String(target)},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		includeApex bool
+		// wwwB is the value of www.example.com in B, and extraA and extraB
+		// are records only in A and B.
+		wwwB   string
+		extraA []rtypes.ResourceRecordSet
+		extraB []rtypes.ResourceRecordSet
+		// want are the number of records only in A, only in B and
+		// different, none when the zones match.
+		want *[3]int
+	}{
+		{name: "same records", wwwB: "192.0.2.1"},
+		{
+			name:   "drift",
+			wwwB:   "192.0.2.9",
+			extraA: []rtypes.ResourceRecordSet{recordSet("new.example.com.", rtypes.RRTypeA, "192.0.2.4")},
+			extraB: []rtypes.ResourceRecordSet{recordSet("old.example.com.", rtypes.RRTypeA, "192.0.2.3")},
+			want:   &[3]int{1, 1, 1},
+		},
+		{
+			name:        "apex records included",
+			includeApex: true,
+			wwwB:        "192.0.2.1",
+			want:        &[3]int{0, 0, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			serverA := fakeroute53.NewServer()
+			t.Cleanup(serverA.Close)
+			serverB := fakeroute53.NewServer()
+			t.Cleanup(serverB.Close)
+			// The zones get different ids and nameservers in each account.
+			serverB.AddZone("example.net", false)
+			zoneA := serverA.AddZone("example.com", false)
+			zoneB := serverB.AddZone("example.com", false)
+			serverA.AddRecords(zoneA,
+				recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+				recordSet("mail.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+				aliasTo("example.com.", zoneA, "www.example.com."),
+			)
+			serverA.AddRecords(zoneA, tt.extraA...)
+			serverB.AddRecords(zoneB,
+				recordSet("www.example.com.", rtypes.RRTypeA, tt.wwwB),
+				recordSet("mail.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+				aliasTo("example.com.", zoneB, "www.example.com."),
+			)
+			serverB.AddRecords(zoneB, tt.extraB...)
+			a := &App{ProfileA: "prod", ProfileB: "staging", Domain: "example.com", IncludeApex: tt.includeApex}
+
+			err := a.diff(ctx, dns.NewRouteCopyForTest("prod", serverA.URL), dns.NewRouteCopyForTest("staging", serverB.URL))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var differ *dns.ZonesDiffer
+			if !errors.As(err, &differ) {
+				t.Fatalf("got %v, want ZonesDiffer", err)
+			}
+			got := [3]int{len(differ.Diff.Create), len(differ.Diff.Delete), len(differ.Diff.Update)}
+			if got != *tt.want {
+				t.Errorf("got %v records only in A, only in B and different, want %v", got, *tt.want)
+			}
+		})
+	}
+}
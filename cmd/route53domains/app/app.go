@@ -3,11 +3,11 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/route53domains/types"
 	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
 	"github.com/spf13/cobra"
 )
 
@@ -15,15 +15,24 @@ type App struct {
 	SourceProfile      string
 	DestinationProfile string
 	DryRun             bool
+	Region             string
 }
 
 func (a *App) Run(ctx context.Context) error {
-	srcManager, err := dns.NewDomainManager(ctx, a.SourceProfile)
+	srcManager, err := dns.NewDomainManager(ctx, a.SourceProfile, dns.WithRegion(a.Region), dns.WithSide("source"))
+	if err != nil {
+		return err
+	}
+	err = srcManager.CheckCredentials(ctx)
 	if err != nil {
 		return err
 	}
 
-	dstManager, err := dns.NewDomainManager(ctx, a.DestinationProfile)
+	dstManager, err := dns.NewDomainManager(ctx, a.DestinationProfile, dns.WithRegion(a.Region), dns.WithSide("destination"))
+	if err != nil {
+		return err
+	}
+	err = dstManager.CheckCredentials(ctx)
 	if err != nil {
 		return err
 	}
@@ -38,16 +47,16 @@ func (a *App) Run(ctx context.Context) error {
 		return err
 	}
 
-	log.Printf("Found %d domains in %s to transfer\n", len(domains), a.SourceProfile)
+	logging.Infof("Found %d domains in %s to transfer\n", len(domains), a.SourceProfile)
 
 	if a.DryRun {
-		log.Printf("Dry run... \n The following domains will be copied: \n")
-		log.Println(domains)
+		logging.Infof("Dry run... \n The following domains will be copied: \n")
+		logging.Infoln(domains)
 		return nil
 	}
 
 	for _, domain := range domains {
-		log.Printf("Transferring domain %s...\n", domain)
+		logging.Infof("Transferring domain %s...\n", domain)
 		t, err := srcManager.TransferDomain(ctx, domain, accountID)
 		if err != nil {
 			continue
@@ -58,18 +67,18 @@ func (a *App) Run(ctx context.Context) error {
 			return err
 		}
 
-		log.Print("Waiting some more...")
+		logging.Infof("Waiting some more...")
 		time.Sleep(30 * time.Second)
 
-		log.Printf("Domain transfer initiated for %s: %s\n", domain, t.OperationID)
+		logging.Infof("Domain transfer initiated for %s: %s\n", domain, t.OperationID)
 		opID, err := dstManager.AcceptTransfer(ctx, domain, t.Password)
 		if err != nil {
-			log.Printf("failed to accept transfer for %s: %+v", domain, err)
-			copID, cerr := srcManager.CancelTranfer(ctx, domain)
+			logging.Infof("failed to accept transfer for %s: %+v", domain, err)
+			copID, cerr := srcManager.CancelTransfer(ctx, domain)
 			if cerr != nil {
 				return fmt.Errorf("failed to cancel transfer for %s: %s", domain, cerr)
 			}
-			log.Printf("cancelled transfer for %s: %s", domain, copID)
+			logging.Infof("cancelled transfer for %s: %s", domain, copID)
 			return err
 		}
 
@@ -78,7 +87,7 @@ func (a *App) Run(ctx context.Context) error {
 			return err
 		}
 
-		log.Printf("Domain transfer accepted for %s: %s\n", domain, opID)
+		logging.Infof("Domain transfer accepted for %s: %s\n", domain, opID)
 	}
 
 	return nil
@@ -100,6 +109,7 @@ func NewCommand() *cobra.Command {
 		SilenceUsage:  true,
 	}
 	f := c.Flags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
 	f.BoolVar(&a.DryRun, "dry", false, "Dry run")
 	return c
 }
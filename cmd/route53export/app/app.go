@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type App struct {
+	Profile string
+	Domain  string
+	File    string
+	Region  string
+	Private bool
+}
+
+func (a *App) Run(ctx context.Context) error {
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	zone, err := service.GetHostedZone(ctx, a.Domain, dns.WithPrivateZone(a.Private))
+	if err != nil {
+		return err
+	}
+
+	recordSets, err := service.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if a.File != "" {
+		f, err := os.Create(a.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = dns.WriteZoneFile(w, a.Domain, recordSets)
+	if err != nil {
+		return err
+	}
+
+	if a.File != "" {
+		logging.Summaryf("Exported %d records in '%s' to %s\n", len(recordSets), a.Domain, a.File)
+	}
+	return nil
+}
+
+func NewCommand() *cobra.Command {
+	a := App{}
+
+	c := &cobra.Command{
+		Use:   "route53export <profile> <domain>",
+		Short: "Route53Export is a tool to export a Route53 zone to a BIND zone file",
+		Long: `Route53Export is a tool to export a Route53 zone to a BIND zone file.
+
+Alias records are written as ";ALIAS" comments. Zone files cannot represent
+routing policies (weighted, latency, failover, geolocation, multivalue answer
+and CIDR routing) or health checks: those fields are dropped with a warning
+naming each record set, and importing the file back creates simple record sets.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			domain, err := dns.CanonicalDomain(args[1])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	f := c.Flags()
+	f.BoolVar(&a.Private, "private", false, "Use private hosted zones instead of public ones")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.StringVarP(&a.File, "file", "f", "", "Write the zone file to this file instead of stdout")
+	return c
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type App struct {
+	Profile     string
+	Domain      string
+	File        string
+	DryRun      bool
+	Region      string
+	Private     bool
+	VPCID       string
+	VPCRegion   string
+	Redact      bool
+	WaitTimeout time.Duration
+}
+
+func (a *App) Run(ctx context.Context) error {
+	dns.SetRedaction(a.Redact)
+	f, err := os.Open(a.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	recordSets, err := dns.ReadZoneFile(f, a.Domain)
+	if err != nil {
+		return err
+	}
+
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	err = a.checkRoutingPolicies(ctx, service, recordSets)
+	if err != nil {
+		return err
+	}
+	changes := service.CreateChanges(ctx, a.Domain, recordSets)
+	logging.Infoln("Number of records to import", len(changes))
+
+	if a.DryRun {
+		records := dns.RemoveApexRecords(a.Domain, recordSets)
+		dns.PrintResourceRecords(records)
+		logging.Infof("Not importing records to %s since --dry is given\n", a.Profile)
+		return nil
+	}
+
+	zone, err := service.GetOrCreateZone(ctx, a.Domain, dns.WithPrivateZone(a.Private), dns.WithVPC(a.VPCID, a.VPCRegion), dns.WithWaitTimeout(a.WaitTimeout))
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		logging.Infof("No records to import for '%s'\n", a.Domain)
+		return nil
+	}
+
+	start := time.Now()
+	_, err = service.UpdateRecords(ctx, aws.ToString(zone.Id), "Importing ALL records from "+a.File, changes, a.WaitTimeout)
+	if err != nil {
+		var be *dns.BatchError
+		if errors.As(err, &be) {
+			logging.Infof("%d records were imported before the failure\n", len(be.Applied))
+		}
+		return err
+	}
+	logging.Summaryf("%d records in '%s' were imported from %s and are in sync after %s\n",
+		len(changes), a.Domain, a.File, time.Since(start))
+	return nil
+}
+
+// checkRoutingPolicies refuses to import record sets over record sets of the
+// existing zone that use a routing policy, which a zone file cannot hold.
+func (a *App) checkRoutingPolicies(ctx context.Context, service *dns.RouteCopy, recordSets []rtypes.ResourceRecordSet) error {
+	zone, err := service.GetHostedZone(ctx, a.Domain, dns.WithPrivateZone(a.Private))
+	var nf *dns.HostedZoneNotFound
+	if errors.As(err, &nf) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	existing, err := service.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return err
+	}
+	if conflicts := dns.RoutingPolicyConflicts(recordSets, existing); len(conflicts) > 0 {
+		return &dns.RoutingPolicyConflict{RecordSets: conflicts}
+	}
+	return nil
+}
+
+func NewCommand() *cobra.Command {
+	a := App{}
+
+	c := &cobra.Command{
+		Use:   "route53import <profile> <domain> <zone_file>",
+		Short: "Route53Import is a tool to import a BIND zone file into a Route53 zone",
+		Long: `Route53Import is a tool to import a BIND zone file into a Route53 zone.
+
+Records with the same name and type are merged into one simple record set.
+The import is refused when the zone already has record sets with a routing
+policy for one of those names and types, since a simple record set would
+overwrite or conflict with them.`,
+		Args: cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			domain, err := dns.CanonicalDomain(args[1])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			a.File = args[2]
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	f := c.Flags()
+	f.BoolVar(&a.Private, "private", false, "Use private hosted zones instead of public ones")
+	f.StringVar(&a.VPCID, "vpc-id", "", "VPC to associate with the destination zone when creating a private zone")
+	f.StringVar(&a.VPCRegion, "vpc-region", "", "Region of --vpc-id (defaults to the client region)")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.BoolVar(&a.DryRun, "dry", false, "Dry run")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for each change to be in sync")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
+	return c
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type App struct {
+	Profile     string
+	File        string
+	ZoneID      string
+	DryRun      bool
+	Region      string
+	Redact      bool
+	WaitTimeout time.Duration
+}
+
+func (a *App) Run(ctx context.Context) error {
+	dns.SetRedaction(a.Redact)
+	backup, err := dns.ReadBackupFile(a.File)
+	if err != nil {
+		return err
+	}
+	logging.Infof("Restoring %d records of '%s' from backup taken at %s\n",
+		len(backup.RecordSets), backup.Name, backup.Timestamp.Format(time.RFC3339))
+
+	service, err := dns.NewRouteCopy(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = service.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	zoneID := a.ZoneID
+	if zoneID == "" {
+		zoneID = backup.ZoneID
+	}
+	zone, err := service.GetHostedZoneByID(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+	zoneID = aws.ToString(zone.Id)
+
+	records, err := service.GetResourceRecords(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+
+	// Restoring brings the zone back to the backup, so records added since
+	// the backup are deleted. The apex NS and SOA are managed by Route53.
+	diff := dns.DiffRecordSets(
+		dns.RemoveApexRecords(backup.Name, backup.RecordSets),
+		dns.RemoveApexRecords(backup.Name, records),
+	)
+	logging.Infof("%d records to create, %d to update, %d to delete\n",
+		len(diff.Create), len(diff.Update), len(diff.Delete))
+
+	if diff.Empty() {
+		logging.Infof("Records in '%s' already match the backup\n", backup.Name)
+		return nil
+	}
+
+	changes := diff.Changes(true)
+	if a.DryRun {
+		dns.PrintDiff(diff, true)
+		logging.Infof("Not restoring records since --dry is given\n")
+		return nil
+	}
+
+	start := time.Now()
+	_, err = service.ApplyChanges(ctx, zoneID, "Restoring records from "+a.File, changes, a.WaitTimeout)
+	if err != nil {
+		var be *dns.BatchError
+		if errors.As(err, &be) {
+			logging.Infof("%d changes were applied before the failure\n", len(be.Applied))
+		}
+		return err
+	}
+	logging.Summaryf("%d changes in '%s' were restored and are in sync after %s\n",
+		len(changes), backup.Name, time.Since(start))
+	return nil
+}
+
+func NewCommand() *cobra.Command {
+	a := App{}
+
+	c := &cobra.Command{
+		Use:   "route53restore <profile> <backup_file>",
+		Short: "Route53Restore is a tool to restore a Route53 zone from a route53copy backup",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			a.File = args[1]
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	f := c.Flags()
+	f.StringVar(&a.ZoneID, "zone-id", "", "Restore into the hosted zone with this id instead of the one in the backup")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.BoolVar(&a.DryRun, "dry", false, "Dry run")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for each change to be in sync")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
+	return c
+}
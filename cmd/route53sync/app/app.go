@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type App struct {
+	SourceProfile      string
+	DestinationProfile string
+	Domain             string
+	DryRun             bool
+	Prune              bool
+	Region             string
+	Private            bool
+	VPCID              string
+	VPCRegion          string
+	Redact             bool
+	WaitTimeout        time.Duration
+}
+
+func (a *App) Run(ctx context.Context) error {
+	dns.SetRedaction(a.Redact)
+	srcService, err := dns.NewRouteCopy(ctx, a.SourceProfile, dns.WithRegion(a.Region), dns.WithSide("source"))
+	if err != nil {
+		return err
+	}
+	err = srcService.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	dstService, err := dns.NewRouteCopy(ctx, a.DestinationProfile, dns.WithRegion(a.Region), dns.WithSide("destination"))
+	if err != nil {
+		return err
+	}
+	err = dstService.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	zone, err := srcService.GetHostedZone(ctx, a.Domain, dns.WithPrivateZone(a.Private))
+	if err != nil {
+		return err
+	}
+
+	srcRecords, err := srcService.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return err
+	}
+	srcRecords = dns.RemoveApexRecords(a.Domain, srcRecords)
+
+	var dstZoneID string
+	if a.DryRun {
+		zone, err := dstService.GetHostedZone(ctx, a.Domain, dns.WithPrivateZone(a.Private))
+		if err != nil {
+			var e *dns.HostedZoneNotFound
+			if !errors.As(err, &e) {
+				return err
+			}
+			logging.Infof("Destination profile does not contain %s, it will be created\n", a.Domain)
+		} else {
+			dstZoneID = aws.ToString(zone.Id)
+		}
+	} else {
+		zone, err := dstService.GetOrCreateZone(ctx, a.Domain, dns.WithPrivateZone(a.Private), dns.WithVPC(a.VPCID, a.VPCRegion), dns.WithWaitTimeout(a.WaitTimeout))
+		if err != nil {
+			return err
+		}
+		dstZoneID = aws.ToString(zone.Id)
+	}
+
+	dstRecords := []rtypes.ResourceRecordSet{}
+	if dstZoneID != "" {
+		dstRecords, err = dstService.GetResourceRecords(ctx, dstZoneID)
+		if err != nil {
+			return err
+		}
+		dstRecords = dns.RemoveApexRecords(a.Domain, dstRecords)
+	}
+
+	diff := dns.DiffRecordSets(srcRecords, dstRecords)
+	logging.Infof("%d records to create, %d to update, %d only in destination\n",
+		len(diff.Create), len(diff.Update), len(diff.Delete))
+	if len(diff.Delete) > 0 && !a.Prune {
+		logging.Infof("Not deleting %d destination records since --prune is not given\n", len(diff.Delete))
+	}
+
+	changes := diff.Changes(a.Prune)
+	if a.DryRun {
+		if !diff.Empty() {
+			dns.PrintDiff(diff, a.Prune)
+		}
+		logging.Infof("Not syncing records to %s since --dry is given\n", a.DestinationProfile)
+		return nil
+	}
+
+	if len(changes) == 0 {
+		logging.Infof("Records in '%s' are already in sync\n", a.Domain)
+		return nil
+	}
+
+	start := time.Now()
+	_, err = dstService.ApplyChanges(ctx, dstZoneID, "Syncing records from "+a.SourceProfile, changes, a.WaitTimeout)
+	if err != nil {
+		var be *dns.BatchError
+		if errors.As(err, &be) {
+			logging.Infof("%d changes were applied before the failure\n", len(be.Applied))
+		}
+		return err
+	}
+	logging.Summaryf("%d changes in '%s' were synced from %s to %s and are in sync after %s\n",
+		len(changes), a.Domain, a.SourceProfile, a.DestinationProfile, time.Since(start))
+	return nil
+}
+
+func NewCommand() *cobra.Command {
+	a := App{}
+
+	c := &cobra.Command{
+		Use:   "route53sync <source_profile> <dest_profile> <domain>",
+		Short: "Route53Sync is a tool to apply only the record differences from one AWS account to another",
+		Args:  cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.SourceProfile = args[0]
+			a.DestinationProfile = args[1]
+			domain, err := dns.CanonicalDomain(args[2])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Run(cmd.Context())
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	f := c.Flags()
+	f.BoolVar(&a.Private, "private", false, "Use private hosted zones instead of public ones")
+	f.StringVar(&a.VPCID, "vpc-id", "", "VPC to associate with the destination zone when creating a private zone")
+	f.StringVar(&a.VPCRegion, "vpc-region", "", "Region of --vpc-id (defaults to the client region)")
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.BoolVar(&a.DryRun, "dry", false, "Dry run")
+	f.DurationVar(&a.WaitTimeout, "wait-timeout", dns.DefaultWaitTimeout, "How long to wait for each change to be in sync")
+	f.BoolVar(&a.Redact, "redact", false, "Show TXT and SPF values as the first 8 hex digits of their SHA-256 in the output, logs and reports")
+	f.BoolVar(&a.Prune, "prune", false, "Delete destination records that are not in the source")
+	return c
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53domains/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type App struct {
+	SourceProfile      string
+	DestinationProfile string
+	Profile            string
+	Domain             string
+	OperationID        string
+	Region             string
+	Timeout            time.Duration
+}
+
+// Start transfers the domain from the source to the destination account and
+// accepts it there. If accepting fails, the transfer is cancelled again so
+// the domain stays in the source account.
+func (a *App) Start(ctx context.Context) error {
+	srcManager, err := dns.NewDomainManager(ctx, a.SourceProfile, dns.WithRegion(a.Region), dns.WithSide("source"))
+	if err != nil {
+		return err
+	}
+	err = srcManager.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	dstManager, err := dns.NewDomainManager(ctx, a.DestinationProfile, dns.WithRegion(a.Region), dns.WithSide("destination"))
+	if err != nil {
+		return err
+	}
+	err = dstManager.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	return a.transfer(ctx, srcManager, dstManager)
+}
+
+// transfer moves the domain from the account of srcManager to the account of
+// dstManager, see Start.
+func (a *App) transfer(ctx context.Context, srcManager, dstManager *dns.DomainManager) error {
+	accountID, err := dstManager.GetAccountID(ctx)
+	if err != nil {
+		return err
+	}
+
+	logging.Infof("Transferring domain %s from %s to account %s...\n", a.Domain, a.SourceProfile, accountID)
+	t, err := srcManager.TransferDomain(ctx, a.Domain, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to start transfer for %s: %w", a.Domain, err)
+	}
+	logging.Infof("Domain transfer initiated for %s: %s\n", a.Domain, t.OperationID)
+
+	err = srcManager.WaitOperation(ctx, types.OperationStatusInProgress, t.OperationID, a.Timeout)
+	if err != nil {
+		return err
+	}
+
+	opID, err := dstManager.AcceptTransfer(ctx, a.Domain, t.Password)
+	if err != nil {
+		logging.Infof("Failed to accept transfer for %s: %s\n", a.Domain, err)
+		cancelID, cerr := srcManager.CancelTransfer(ctx, a.Domain)
+		if cerr != nil {
+			return fmt.Errorf("failed to cancel transfer for %s: %s", a.Domain, cerr)
+		}
+		logging.Infof("Cancelled transfer for %s: %s\n", a.Domain, cancelID)
+		return err
+	}
+	logging.Infof("Domain transfer accepted for %s: %s\n", a.Domain, opID)
+	logging.Infof("Run 'route53transfer status %s %s' to follow it\n", a.DestinationProfile, opID)
+
+	return nil
+}
+
+// Status waits for the operation to complete or the timeout to expire.
+func (a *App) Status(ctx context.Context) error {
+	manager, err := dns.NewDomainManager(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = manager.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	logging.Infof("Waiting up to %s for operation %s...\n", a.Timeout, a.OperationID)
+	status, err := manager.WaitForOperation(ctx, a.OperationID, a.Timeout)
+	if err != nil {
+		return err
+	}
+	logging.Infof("Operation %s is %s\n", a.OperationID, status)
+	return nil
+}
+
+// Cancel cancels a pending transfer of the domain out of the account.
+func (a *App) Cancel(ctx context.Context) error {
+	manager, err := dns.NewDomainManager(ctx, a.Profile, dns.WithRegion(a.Region))
+	if err != nil {
+		return err
+	}
+	err = manager.CheckCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	opID, err := manager.CancelTransfer(ctx, a.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to cancel transfer for %s: %w", a.Domain, err)
+	}
+	logging.Infof("Cancelled transfer for %s: %s\n", a.Domain, opID)
+	return nil
+}
+
+func NewCommand() *cobra.Command {
+	a := App{}
+
+	c := &cobra.Command{
+		Use:           "route53transfer",
+		Short:         "Route53Transfer is a tool to transfer a single domain between AWS accounts",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	f := c.PersistentFlags()
+	f.StringVar(&a.Region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
+	f.DurationVar(&a.Timeout, "timeout", 5*time.Minute, "Maximum time to wait for an operation")
+
+	c.AddCommand(&cobra.Command{
+		Use:   "start <source_profile> <dest_profile> <domain>",
+		Short: "Transfer a domain and accept it in the destination account",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.SourceProfile = args[0]
+			a.DestinationProfile = args[1]
+			domain, err := dns.CanonicalDomain(args[2])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Start(cmd.Context())
+		},
+	})
+	c.AddCommand(&cobra.Command{
+		Use:   "status <profile> <operation_id>",
+		Short: "Wait for a domain operation to complete",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			a.OperationID = args[1]
+			return a.Status(cmd.Context())
+		},
+	})
+	c.AddCommand(&cobra.Command{
+		Use:   "cancel <profile> <domain>",
+		Short: "Cancel a pending domain transfer",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a.Profile = args[0]
+			domain, err := dns.CanonicalDomain(args[1])
+			if err != nil {
+				return err
+			}
+			a.Domain = domain
+			return a.Cancel(cmd.Context())
+		},
+	})
+	return c
+}
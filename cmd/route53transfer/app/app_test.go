@@ -0,0 +1,161 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53domains"
+	"github.com/aws/aws-sdk-go-v2/service/route53domains/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// transferPassword is the password the registrar hands out, which must not
+// show up in the logs.
+const transferPassword = "s3cr3t-transfer-password"
+
+// registrar is a mocked Route53Domains shared by the accounts, tracking the
+// owner of each domain and its pending transfer.
+type registrar struct {
+	owners map[string]string
+	// pending is the account each domain is being transferred to.
+	pending map[string]string
+	// acceptErr, when set, fails AcceptDomainTransferFromAnotherAwsAccount.
+	acceptErr error
+	calls     []string
+	nextID    int
+}
+
+// domainsClient is the Route53Domains client of an account.
+type domainsClient struct {
+	*registrar
+	account string
+}
+
+func (c domainsClient) operation(name string) *string {
+	c.calls = append(c.calls, name+" "+c.account)
+	c.nextID++
+	return aws.String(fmt.Sprintf("op-%d", c.nextID))
+}
+
+func (c domainsClient) GetOperationDetail(ctx context.Context, params *route53domains.GetOperationDetailInput, optFns ...func(*route53domains.Options)) (*route53domains.GetOperationDetailOutput, error) {
+	return &route53domains.GetOperationDetailOutput{OperationId: params.OperationId, Status: types.OperationStatusInProgress}, nil
+}
+
+func (c domainsClient) ListDomains(ctx context.Context, params *route53domains.ListDomainsInput, optFns ...func(*route53domains.Options)) (*route53domains.ListDomainsOutput, error) {
+	out := &route53domains.ListDomainsOutput{}
+	for domain, owner := range c.owners {
+		if owner == c.account {
+			out.Domains = append(out.Domains, types.DomainSummary{DomainName: aws.String(domain)})
+		}
+	}
+	return out, nil
+}
+
+func (c domainsClient) TransferDomainToAnotherAwsAccount(ctx context.Context, params *route53domains.TransferDomainToAnotherAwsAccountInput, optFns ...func(*route53domains.Options)) (*route53domains.TransferDomainToAnotherAwsAccountOutput, error) {
+	domain := aws.ToString(params.DomainName)
+	if c.owners[domain] != c.account {
+		return nil, fmt.Errorf("%s is not registered in account %s", domain, c.account)
+	}
+	c.pending[domain] = aws.ToString(params.AccountId)
+	return &route53domains.TransferDomainToAnotherAwsAccountOutput{
+		OperationId: c.operation("transfer"),
+		Password:    aws.String(transferPassword),
+	}, nil
+}
+
+func (c domainsClient) AcceptDomainTransferFromAnotherAwsAccount(ctx context.Context, params *route53domains.AcceptDomainTransferFromAnotherAwsAccountInput, optFns ...func(*route53domains.Options)) (*route53domains.AcceptDomainTransferFromAnotherAwsAccountOutput, error) {
+	id := c.operation("accept")
+	domain := aws.ToString(params.DomainName)
+	if c.acceptErr != nil {
+		return nil, c.acceptErr
+	}
+	if c.pending[domain] != c.account || aws.ToString(params.Password) != transferPassword {
+		return nil, fmt.Errorf("no transfer of %s to account %s with this password", domain, c.account)
+	}
+	c.owners[domain] = c.account
+	delete(c.pending, domain)
+	return &route53domains.AcceptDomainTransferFromAnotherAwsAccountOutput{OperationId: id}, nil
+}
+
+func (c domainsClient) CancelDomainTransferToAnotherAwsAccount(ctx context.Context, params *route53domains.CancelDomainTransferToAnotherAwsAccountInput, optFns ...func(*route53domains.Options)) (*route53domains.CancelDomainTransferToAnotherAwsAccountOutput, error) {
+	domain := aws.ToString(params.DomainName)
+	if _, ok := c.pending[domain]; !ok || c.owners[domain] != c.account {
+		return nil, fmt.Errorf("no pending transfer of %s from account %s", domain, c.account)
+	}
+	delete(c.pending, domain)
+	return &route53domains.CancelDomainTransferToAnotherAwsAccountOutput{OperationId: c.operation("cancel")}, nil
+}
+
+// callerIdentity is a mocked STS client of an account.
+type callerIdentity string
+
+func (account callerIdentity) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String(string(account))}, nil
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name      string
+		acceptErr error
+		owner     string
+		calls     []string
+	}{
+		{
+			name:  "accepted",
+			owner: "210987654321",
+			calls: []string{"transfer 123456789012", "accept 210987654321"},
+		},
+		{
+			name:      "accept fails and the transfer is cancelled",
+			acceptErr: errors.New("the destination account cannot register domains"),
+			owner:     "123456789012",
+			calls:     []string{"transfer 123456789012", "accept 210987654321", "cancel 123456789012"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(w) })
+			logging.SetLevel(logging.LevelDebug)
+			t.Cleanup(func() { logging.SetLevel(logging.LevelInfo) })
+
+			r := &registrar{
+				owners:    map[string]string{"example.com": "123456789012"},
+				pending:   map[string]string{},
+				acceptErr: tt.acceptErr,
+			}
+			src := dns.NewDomainManagerWithClients("prod", domainsClient{r, "123456789012"}, callerIdentity("123456789012"))
+			dst := dns.NewDomainManagerWithClients("staging", domainsClient{r, "210987654321"}, callerIdentity("210987654321"))
+			a := &App{SourceProfile: "prod", DestinationProfile: "staging", Domain: "example.com.", Timeout: time.Minute}
+
+			err := a.transfer(context.Background(), src, dst)
+			if !errors.Is(err, tt.acceptErr) {
+				t.Fatalf("got %v, want %v", err, tt.acceptErr)
+			}
+			if r.owners["example.com"] != tt.owner {
+				t.Errorf("example.com is in account %s, want %s", r.owners["example.com"], tt.owner)
+			}
+			if len(r.pending) != 0 {
+				t.Errorf("transfers %v are left pending", r.pending)
+			}
+			if !reflect.DeepEqual(r.calls, tt.calls) {
+				t.Errorf("got calls %v, want %v", r.calls, tt.calls)
+			}
+			if strings.Contains(logs.String(), transferPassword) {
+				t.Errorf("the password is logged:\n%s", logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/pedrokiefer/route53copy/cmd"
+	"github.com/pedrokiefer/route53copy/cmd/route53transfer/app"
+)
+
+func main() {
+	cmd.Run(app.NewCommand())
+}
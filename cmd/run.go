@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -9,22 +10,133 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/aws/smithy-go"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/output"
 	"github.com/spf13/cobra"
 )
 
+// Exit codes, so scripts can tell failures apart.
+const (
+	ExitError = 1
+	// ExitDrift is used by route53diff when the zones differ, like diff(1)
+	// does.
+	ExitDrift = 1
+	// ExitZoneNotFound is used when a hosted zone cannot be found or is
+	// ambiguous.
+	ExitZoneNotFound = 2
+	// ExitAuth is used when the credentials are missing, invalid or not
+	// allowed to make a call.
+	ExitAuth = 3
+	// ExitChangeFailed is used when Route53 or Route53 Domains rejected a
+	// change.
+	ExitChangeFailed = 4
+	// ExitAborted is used when the user declines a confirmation.
+	ExitAborted = 5
+	// ExitWaitTimeout is used when a change was submitted but is not in
+	// sync yet.
+	ExitWaitTimeout = 6
+	// ExitVerificationFailed is used when the copied records differ from
+	// the source after the copy.
+	ExitVerificationFailed = 7
+	// ExitInterrupted is used when the run was interrupted by SIGINT or
+	// SIGTERM, like shells do.
+	ExitInterrupted = 130
+)
+
+// authErrorCodes are the API error codes returned for bad or insufficient
+// credentials.
+var authErrorCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"InvalidClientTokenId":        true,
+	"SignatureDoesNotMatch":       true,
+	"UnrecognizedClientException": true,
+}
+
 func Run(command *cobra.Command) {
 	rand.Seed(time.Now().UnixNano())
 	command.Version = fmt.Sprintf("%s, commit: %s, built: %s", Version, Commit, BuildDate)
 	err := run(command)
-	if err != nil {
+	if err == nil {
+		return
+	}
+
+	var differ *dns.ZonesDiffer
+	if errors.As(err, &differ) {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(ExitDrift)
+	}
+
+	code := exitCode(err)
+	switch code {
+	case ExitAborted:
+		_, _ = fmt.Fprintln(os.Stderr, "Aborted by user")
+	case ExitWaitTimeout:
+		var timeout *dns.ChangeTimeout
+		errors.As(err, &timeout)
+		_, _ = fmt.Fprintf(os.Stderr, "Program aborted: %v\n", err)
+		_, _ = fmt.Fprintf(os.Stderr, "The change was submitted and is usually applied later, to keep waiting run:\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  route53copy wait %s %s\n", timeout.Profile, timeout.ChangeID)
+	case ExitInterrupted:
+		_, _ = fmt.Fprintf(os.Stderr, "Program interrupted: %v\n", err)
+	default:
 		_, _ = fmt.Fprintf(os.Stderr, "Program aborted: %v\n", err)
-		os.Exit(1)
 	}
+	os.Exit(code)
+}
+
+func exitCode(err error) int {
+	var timeout *dns.ChangeTimeout
+	var notFound *dns.HostedZoneNotFound
+	var ambiguous *dns.AmbiguousHostedZone
+	var profile *dns.ProfileError
+	var apiErr smithy.APIError
+	var batch *dns.BatchError
+	var rejected *dns.RejectedChanges
+	var operation *dns.OperationFailed
+	var verification *dns.VerificationFailed
+	var vpc *dns.VPCAssociationError
+	switch {
+	case errors.Is(err, output.ErrAborted):
+		return ExitAborted
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &timeout):
+		return ExitWaitTimeout
+	case errors.As(err, &notFound), errors.As(err, &ambiguous):
+		return ExitZoneNotFound
+	case errors.As(err, &profile):
+		return ExitAuth
+	case errors.As(err, &apiErr) && authErrorCodes[apiErr.ErrorCode()]:
+		return ExitAuth
+	case errors.As(err, &batch), errors.As(err, &rejected), errors.As(err, &operation), errors.As(err, &vpc):
+		return ExitChangeFailed
+	case errors.As(err, &verification):
+		return ExitVerificationFailed
+	}
+	return ExitError
 }
 
+// run executes the command with a context canceled by the first SIGINT or
+// SIGTERM, which lets the change batch in flight finish. The second one
+// exits immediately.
 func run(command *cobra.Command) error {
-	ctx, cancelFunc := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		_, _ = fmt.Fprintln(os.Stderr, "Interrupted, finishing the change batch in flight, interrupt again to exit immediately")
+		cancelFunc()
+		<-signals
+		os.Exit(ExitInterrupted)
+	}()
+
 	return command.ExecuteContext(ctx)
 }
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/output"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "interrupted", err: &dns.Interrupted{Batch: 1, Batches: 3}, want: ExitInterrupted},
+		{name: "wrapped interrupted", err: fmt.Errorf("copy example.com: %w", &dns.Interrupted{}), want: ExitInterrupted},
+		{name: "canceled before a batch", err: fmt.Errorf("listing zones: %w", context.Canceled), want: ExitInterrupted},
+		{name: "aborted", err: output.ErrAborted, want: ExitAborted},
+		{name: "wait timeout", err: &dns.ChangeTimeout{Profile: "prod", ChangeID: "C1"}, want: ExitWaitTimeout},
+		{name: "zone not found", err: &dns.HostedZoneNotFound{Zone: "example.com"}, want: ExitZoneNotFound},
+		{name: "access denied", err: &smithy.GenericAPIError{Code: "AccessDenied"}, want: ExitAuth},
+		{name: "rejected batch", err: &dns.BatchError{Batch: 2, Batches: 3, Err: errors.New("InvalidChangeBatch")}, want: ExitChangeFailed},
+		{name: "other", err: errors.New("boom"), want: ExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("got exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package awsconfig reads the profile names from the AWS shared config and
+// credentials files, which the SDK only loads one profile at a time.
+package awsconfig
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigFile returns the shared config file, from AWS_CONFIG_FILE or
+// ~/.aws/config.
+func ConfigFile() string {
+	if f := os.Getenv("AWS_CONFIG_FILE"); f != "" {
+		return f
+	}
+	return filepath.Join(homeDir(), ".aws", "config")
+}
+
+// CredentialsFile returns the shared credentials file, from
+// AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+func CredentialsFile() string {
+	if f := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); f != "" {
+		return f
+	}
+	return filepath.Join(homeDir(), ".aws", "credentials")
+}
+
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+// Profiles returns the sorted names of the profiles in the shared config
+// and credentials files. Missing files have no profiles.
+func Profiles() ([]string, error) {
+	names := map[string]bool{}
+	for _, file := range []struct {
+		name        string
+		credentials bool
+	}{{ConfigFile(), false}, {CredentialsFile(), true}} {
+		f, err := os.Open(file.name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		profiles, err := ParseProfiles(f, file.credentials)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range profiles {
+			names[p] = true
+		}
+	}
+
+	profiles := []string{}
+	for name := range names {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// ParseProfiles returns the profile names of the sections of a config file,
+// in order. In the config file profiles are named [profile name], besides
+// [default], and other sections such as [sso-session name] are skipped. In
+// the credentials file, when credentials is set, every section is a profile.
+func ParseProfiles(r io.Reader, credentials bool) ([]string, error) {
+	profiles := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.TrimSpace(line[1 : len(line)-1])
+		switch {
+		case credentials, section == "default":
+			profiles = append(profiles, section)
+		case strings.HasPrefix(section, "profile "):
+			profiles = append(profiles, strings.TrimSpace(strings.TrimPrefix(section, "profile ")))
+		}
+	}
+	return profiles, scanner.Err()
+}
@@ -0,0 +1,116 @@
+package awsconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const configFile = `# Shared config
+[default]
+region = us-east-1
+
+[profile prod]
+sso_session = corp
+sso_account_id = 123456789012
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+
+  [ profile  staging ]
+role_arn = arn:aws:iam::210987654321:role/dns-admin
+source_profile = prod
+; [profile commented]
+[services local]
+route53 =
+  endpoint_url = http://localhost:4566
+[profile]
+[broken
+`
+
+const credentialsFile = `[default]
+aws_access_key_id = AKIDDEFAULT
+
+[legacy]
+aws_access_key_id = AKIDLEGACY
+[prod]
+aws_access_key_id = AKIDPROD
+`
+
+func TestParseProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		credentials bool
+		want        []string
+	}{
+		{name: "config", file: configFile, want: []string{"default", "prod", "staging"}},
+		{name: "credentials", file: credentialsFile, credentials: true, want: []string{"default", "legacy", "prod"}},
+		{name: "empty", file: "", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProfiles(strings.NewReader(tt.file), tt.credentials)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	credentials := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(config, []byte(configFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentials, []byte(credentialsFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		config      string
+		credentials string
+		want        []string
+	}{
+		{name: "both files", config: config, credentials: credentials, want: []string{"default", "legacy", "prod", "staging"}},
+		{name: "config only", config: config, credentials: filepath.Join(dir, "missing"), want: []string{"default", "prod", "staging"}},
+		{name: "no files", config: filepath.Join(dir, "missing"), credentials: filepath.Join(dir, "missing"), want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_CONFIG_FILE", tt.config)
+			t.Setenv("AWS_SHARED_CREDENTIALS_FILE", tt.credentials)
+			if ConfigFile() != tt.config || CredentialsFile() != tt.credentials {
+				t.Fatalf("got files %s and %s", ConfigFile(), CredentialsFile())
+			}
+
+			got, err := Profiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_CONFIG_FILE", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "")
+	if got := ConfigFile(); got != filepath.Join(home, ".aws", "config") {
+		t.Errorf("got config file %s", got)
+	}
+	if got := CredentialsFile(); got != filepath.Join(home, ".aws", "credentials") {
+		t.Errorf("got credentials file %s", got)
+	}
+}
@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"github.com/pedrokiefer/route53copy/cmd/route53diff/app"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newDiffCommand())
+}
+
+// newDiffCommand reuses the route53diff command, so both tools share the
+// same flags and behavior.
+func newDiffCommand() *cobra.Command {
+	c := app.NewCommand()
+	c.Use = "diff <profile_a> <profile_b> <domain>"
+	return c
+}
@@ -2,8 +2,8 @@ package cli
 
 import (
 	"context"
-	"log"
 
+	"github.com/pedrokiefer/route53copy/pkg/logging"
 	"github.com/spf13/cobra"
 )
 
@@ -18,7 +18,7 @@ func init() {
 
 func (a *parkApp) Run(ctx context.Context) error {
 
-	log.Printf("Parking domains in %s...\n", a.Profile)
+	logging.Infof("Parking domains in %s...\n", a.Profile)
 
 	return nil
 }
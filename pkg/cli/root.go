@@ -5,6 +5,7 @@ import "github.com/spf13/cobra"
 var (
 	//flags
 	dryRun bool
+	region string
 
 	rootCmd = newRootCmd()
 )
@@ -18,5 +19,6 @@ func newRootCmd() *cobra.Command {
 	}
 	f := c.PersistentFlags()
 	f.BoolVar(&dryRun, "dry", false, "Dry run")
+	f.StringVar(&region, "region", "", "AWS region (defaults to the profile region, then us-east-1)")
 	return c
 }
@@ -0,0 +1,81 @@
+package dns
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// shortZoneID strips the "/hostedzone/" prefix Route53 adds to zone ids in
+// some responses.
+func shortZoneID(zoneId string) string {
+	return strings.TrimPrefix(zoneId, "/hostedzone/")
+}
+
+// RewriteAliasZoneIDs points aliases to records in the source zone at the
+// destination zone instead. Aliases to other zones, such as the ones of
+// load balancers or CloudFront distributions, are left untouched.
+func RewriteAliasZoneIDs(changes []rtypes.Change, srcZoneID, dstZoneID string) []rtypes.Change {
+	rewritten := []rtypes.Change{}
+	for _, c := range changes {
+		alias := c.ResourceRecordSet.AliasTarget
+		if alias != nil && shortZoneID(aws.ToString(alias.HostedZoneId)) == shortZoneID(srcZoneID) {
+			target := *alias
+			target.HostedZoneId = aws.String(shortZoneID(dstZoneID))
+			rs := *c.ResourceRecordSet
+			rs.AliasTarget = &target
+			c.ResourceRecordSet = &rs
+		}
+		rewritten = append(rewritten, c)
+	}
+	return rewritten
+}
+
+// RewriteRecordAliasZoneIDs is RewriteAliasZoneIDs for record sets, so
+// aliases inside two copies of a zone compare equal.
+func RewriteRecordAliasZoneIDs(records []rtypes.ResourceRecordSet, srcZoneID, dstZoneID string) []rtypes.ResourceRecordSet {
+	changes := []rtypes.Change{}
+	for i := range records {
+		changes = append(changes, rtypes.Change{ResourceRecordSet: &records[i]})
+	}
+	rewritten := []rtypes.ResourceRecordSet{}
+	for _, c := range RewriteAliasZoneIDs(changes, srcZoneID, dstZoneID) {
+		rewritten = append(rewritten, *c.ResourceRecordSet)
+	}
+	return rewritten
+}
+
+// HostedZoneIDs returns the ids of every hosted zone in the account.
+func (r *RouteCopy) HostedZoneIDs(ctx context.Context) (map[string]bool, error) {
+	zones, err := r.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, zone := range zones {
+		ids[shortZoneID(aws.ToString(zone.Id))] = true
+	}
+	return ids, nil
+}
+
+// RemoveUnresolvableAliases drops the changes for aliases to hosted zones of
+// the source account other than the source zone itself, given the ids of the
+// zones in srcZones. Such aliases cannot be created in another account.
+func RemoveUnresolvableAliases(changes []rtypes.Change, srcZoneID string, srcZones map[string]bool) ([]rtypes.Change, []rtypes.Change) {
+	kept := []rtypes.Change{}
+	dropped := []rtypes.Change{}
+	for _, c := range changes {
+		alias := c.ResourceRecordSet.AliasTarget
+		if alias != nil {
+			id := shortZoneID(aws.ToString(alias.HostedZoneId))
+			if id != shortZoneID(srcZoneID) && srcZones[id] {
+				dropped = append(dropped, c)
+				continue
+			}
+		}
+		kept = append(kept, c)
+	}
+	return kept, dropped
+}
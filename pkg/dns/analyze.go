@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Warning flags a copied record that may not work in the destination
+// account because it refers to a resource of the source account.
+type Warning struct {
+	Record rtypes.ResourceRecordSet
+	Reason string
+}
+
+// AnalyzeOptions is what AnalyzeChangesWithOptions knows about the accounts.
+// Checks whose information is missing are skipped.
+type AnalyzeOptions struct {
+	// SourceAccountID flags AWS resource names containing the source
+	// account id.
+	SourceAccountID string
+	// SourceZoneID is the copied zone. Aliases to it are rewritten to the
+	// destination zone, so they are not flagged.
+	SourceZoneID string
+	// SourceZones are the ids of the hosted zones of the source account.
+	SourceZones map[string]bool
+	// DestinationHealthChecks are the ids of the health checks of the
+	// destination account.
+	DestinationHealthChecks map[string]bool
+	// DestinationCidrCollections are the ids of the CIDR collections of the
+	// destination account.
+	DestinationCidrCollections map[string]bool
+}
+
+var (
+	// awsAccountNameRe matches AWS resource names embedding an account id,
+	// such as ECR registries and S3 access points.
+	awsAccountNameRe = regexp.MustCompile(`(?i)(?:^|[.-])(\d{12})[.-](?:[a-z0-9-]+\.)*amazonaws\.com\.?$`)
+	// vpcEndpointRe matches the DNS names of interface VPC endpoints.
+	vpcEndpointRe = regexp.MustCompile(`(?i)^(?:\*\.)?(vpce-[0-9a-f]+)-[0-9a-z]+(?:-[a-z0-9-]+)?\.(?:[a-z0-9-]+\.)*vpce\.amazonaws\.com\.?$`)
+)
+
+// AnalyzeChanges flags the records pointing at VPC endpoints, which stay in
+// the account that created them. See AnalyzeChangesWithOptions for the
+// checks that need to know about the accounts.
+func AnalyzeChanges(changes []rtypes.Change) []Warning {
+	return AnalyzeChangesWithOptions(changes, AnalyzeOptions{})
+}
+
+// AnalyzeChangesWithOptions flags the records that refer to resources only
+// found in the source account: aliases to other hosted zones of the source
+// account, health checks and CIDR collections missing from the destination,
+// and values naming AWS resources of the source account or VPC endpoints.
+func AnalyzeChangesWithOptions(changes []rtypes.Change, opts AnalyzeOptions) []Warning {
+	warnings := []Warning{}
+	for _, c := range changes {
+		if c.Action == rtypes.ChangeActionDelete {
+			continue
+		}
+		rs := *c.ResourceRecordSet
+		for _, reason := range analyzeRecord(rs, opts) {
+			warnings = append(warnings, Warning{Record: rs, Reason: reason})
+		}
+	}
+	return warnings
+}
+
+func analyzeRecord(rs rtypes.ResourceRecordSet, opts AnalyzeOptions) []string {
+	reasons := []string{}
+	values := []string{}
+	if rs.AliasTarget != nil {
+		id := shortZoneID(aws.ToString(rs.AliasTarget.HostedZoneId))
+		if id != shortZoneID(opts.SourceZoneID) && opts.SourceZones[id] {
+			reasons = append(reasons, fmt.Sprintf("alias target zone %s is a hosted zone of the source account", id))
+		}
+		values = append(values, aws.ToString(rs.AliasTarget.DNSName))
+	}
+	for _, rr := range rs.ResourceRecords {
+		values = append(values, aws.ToString(rr.Value))
+	}
+
+	if id := aws.ToString(rs.HealthCheckId); id != "" && opts.DestinationHealthChecks != nil && !opts.DestinationHealthChecks[id] {
+		reasons = append(reasons, fmt.Sprintf("health check %s does not exist in the destination account", id))
+	}
+
+	if cidr := rs.CidrRoutingConfig; cidr != nil && opts.DestinationCidrCollections != nil && !opts.DestinationCidrCollections[aws.ToString(cidr.CollectionId)] {
+		reasons = append(reasons, fmt.Sprintf("CIDR collection %s does not exist in the destination account", aws.ToString(cidr.CollectionId)))
+	}
+
+	for _, v := range values {
+		// Values of MX, SRV and similar records end with the host name.
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			continue
+		}
+		host := fields[len(fields)-1]
+		if m := vpcEndpointRe.FindStringSubmatch(host); m != nil {
+			reasons = append(reasons, fmt.Sprintf("%s is VPC endpoint %s, which stays in the account that created it", host, m[1]))
+			continue
+		}
+		if opts.SourceAccountID == "" {
+			continue
+		}
+		if m := awsAccountNameRe.FindStringSubmatch(host); m != nil && m[1] == opts.SourceAccountID {
+			reasons = append(reasons, fmt.Sprintf("%s belongs to the source account %s", host, opts.SourceAccountID))
+		}
+	}
+	return reasons
+}
+
+// HealthCheckIDSet returns the ids of every health check in the account.
+func (r *RouteCopy) HealthCheckIDSet(ctx context.Context) (map[string]bool, error) {
+	ids := map[string]bool{}
+	paginator := route53.NewListHealthChecksPaginator(r.cli, &route53.ListHealthChecksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, hc := range page.HealthChecks {
+			ids[aws.ToString(hc.Id)] = true
+		}
+	}
+	return ids, nil
+}
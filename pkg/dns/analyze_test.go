@@ -0,0 +1,192 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestAnalyzeChanges(t *testing.T) {
+	opts := AnalyzeOptions{
+		SourceAccountID:            "111111111111",
+		SourceZoneID:               "/hostedzone/ZSOURCE",
+		SourceZones:                map[string]bool{"ZSOURCE": true, "ZINTERNAL": true},
+		DestinationHealthChecks:    map[string]bool{"hc-copied": true},
+		DestinationCidrCollections: map[string]bool{"cidr-copied": true},
+	}
+	alias := func(name, zoneID, target string) rtypes.ResourceRecordSet {
+		return rtypes.ResourceRecordSet{
+			Name:        aws.String(name),
+			Type:        rtypes.RRTypeA,
+			AliasTarget: &rtypes.AliasTarget{HostedZoneId: aws.String(zoneID), DNSName: aws.String(target)},
+		}
+	}
+	withHealthCheck := func(rs rtypes.ResourceRecordSet, id string) rtypes.ResourceRecordSet {
+		rs.HealthCheckId = aws.String(id)
+		return rs
+	}
+	withCidrCollection := func(rs rtypes.ResourceRecordSet, id string) rtypes.ResourceRecordSet {
+		rs.SetIdentifier = aws.String("office")
+		rs.CidrRoutingConfig = &rtypes.CidrRoutingConfig{CollectionId: aws.String(id), LocationName: aws.String("office")}
+		return rs
+	}
+	tests := []struct {
+		name   string
+		record rtypes.ResourceRecordSet
+		opts   AnalyzeOptions
+		// want is part of the reason of the single warning, or empty when
+		// the record is not flagged.
+		want string
+	}{
+		{
+			name:   "alias to another zone of the source account",
+			record: alias("internal.example.com.", "/hostedzone/ZINTERNAL", "api.internal.example."),
+			opts:   opts,
+			want:   "alias target zone ZINTERNAL is a hosted zone of the source account",
+		},
+		{
+			name:   "alias within the copied zone",
+			record: alias("example.com.", "ZSOURCE", "www.example.com."),
+			opts:   opts,
+		},
+		{
+			name:   "alias to a load balancer",
+			record: alias("lb.example.com.", "Z35SXDOTRQ7X7K", "my-lb-1234567890.us-east-1.elb.amazonaws.com."),
+			opts:   opts,
+		},
+		{
+			name:   "health check missing from the destination",
+			record: withHealthCheck(recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"), "hc-source"),
+			opts:   opts,
+			want:   "health check hc-source does not exist in the destination account",
+		},
+		{
+			name:   "health check in the destination",
+			record: withHealthCheck(recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"), "hc-copied"),
+			opts:   opts,
+		},
+		{
+			name:   "health checks of the destination unknown",
+			record: withHealthCheck(recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"), "hc-source"),
+		},
+		{
+			name:   "CIDR collection missing from the destination",
+			record: withCidrCollection(recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"), "cidr-source"),
+			opts:   opts,
+			want:   "CIDR collection cidr-source does not exist in the destination account",
+		},
+		{
+			name:   "CIDR collection in the destination",
+			record: withCidrCollection(recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"), "cidr-copied"),
+			opts:   opts,
+		},
+		{
+			name:   "ECR registry of the source account",
+			record: recordSet("registry.example.com.", rtypes.RRTypeCname, "111111111111.dkr.ecr.us-east-1.amazonaws.com."),
+			opts:   opts,
+			want:   "belongs to the source account 111111111111",
+		},
+		{
+			name:   "S3 access point of the source account",
+			record: recordSet("files.example.com.", rtypes.RRTypeCname, "files-111111111111.s3-accesspoint.us-east-1.amazonaws.com"),
+			opts:   opts,
+			want:   "belongs to the source account 111111111111",
+		},
+		{
+			name:   "ECR registry of another account",
+			record: recordSet("registry.example.com.", rtypes.RRTypeCname, "222222222222.dkr.ecr.us-east-1.amazonaws.com."),
+			opts:   opts,
+		},
+		{
+			name:   "source account unknown",
+			record: recordSet("registry.example.com.", rtypes.RRTypeCname, "111111111111.dkr.ecr.us-east-1.amazonaws.com."),
+		},
+		{
+			name:   "account id outside an AWS name",
+			record: recordSet("note.example.com.", rtypes.RRTypeTxt, `"ticket 111111111111.example.net"`),
+			opts:   opts,
+		},
+		{
+			name:   "MX host of the source account",
+			record: recordSet("example.com.", rtypes.RRTypeMx, "10 111111111111.mail.us-east-1.amazonaws.com."),
+			opts:   opts,
+			want:   "belongs to the source account 111111111111",
+		},
+		{
+			name:   "VPC endpoint",
+			record: recordSet("db.example.com.", rtypes.RRTypeCname, "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com."),
+			want:   "is VPC endpoint vpce-0123456789abcdef0",
+		},
+		{
+			name:   "VPC endpoint of an availability zone",
+			record: recordSet("db.example.com.", rtypes.RRTypeCname, "vpce-0123456789abcdef0-abcdefgh-us-east-1a.ec2.us-east-1.vpce.amazonaws.com"),
+			want:   "is VPC endpoint vpce-0123456789abcdef0",
+		},
+		{
+			name:   "plain records",
+			record: recordSet("www.example.com.", rtypes.RRTypeCname, "example.com."),
+			opts:   opts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := tt.record
+			warnings := AnalyzeChangesWithOptions([]rtypes.Change{{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &rs}}, tt.opts)
+			if tt.want == "" {
+				if len(warnings) > 0 {
+					t.Errorf("got warnings %v, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0].Reason, tt.want) {
+				t.Fatalf("got warnings %v, want one saying %q", warnings, tt.want)
+			}
+			if aws.ToString(warnings[0].Record.Name) != aws.ToString(rs.Name) {
+				t.Errorf("the warning is for %s, want %s", aws.ToString(warnings[0].Record.Name), aws.ToString(rs.Name))
+			}
+
+			// Deleted records are not flagged.
+			warnings = AnalyzeChangesWithOptions([]rtypes.Change{{Action: rtypes.ChangeActionDelete, ResourceRecordSet: &rs}}, tt.opts)
+			if len(warnings) > 0 {
+				t.Errorf("a delete got warnings %v", warnings)
+			}
+		})
+	}
+}
+
+func TestCopyZoneWarnings(t *testing.T) {
+	for _, dryRun := range []bool{true, false} {
+		t.Run(map[bool]string{true: "dry run", false: "copy"}[dryRun], func(t *testing.T) {
+			ctx := context.Background()
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			srcServer.Account = "111111111111"
+			dstServer.Account = "222222222222"
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID,
+				recordSet("registry.example.com.", rtypes.RRTypeCname, "111111111111.dkr.ecr.us-east-1.amazonaws.com."),
+				recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			)
+			dstServer.AddZone("example.com", false)
+			var logs bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(w) })
+
+			result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", DryRun: dryRun})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Warnings) != 1 || aws.ToString(result.Warnings[0].Record.Name) != "registry.example.com." {
+				t.Fatalf("got warnings %v, want one for registry.example.com.", result.Warnings)
+			}
+			if !strings.Contains(logs.String(), "Warning: registry.example.com. CNAME: 111111111111.dkr.ecr.us-east-1.amazonaws.com. belongs to the source account") {
+				t.Errorf("the warning is not logged:\n%s", logs.String())
+			}
+		})
+	}
+}
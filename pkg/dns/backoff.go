@@ -0,0 +1,27 @@
+package dns
+
+import (
+	"math/rand"
+	"time"
+)
+
+// maxRetryBackoff caps the delay between two attempts of a call, like the
+// SDK default.
+const maxRetryBackoff = 20 * time.Second
+
+// exponentialBackoff waits a random delay of up to base doubled for each
+// attempt, capped at maxRetryBackoff, so clients throttled together do not
+// retry together.
+type exponentialBackoff struct {
+	base time.Duration
+}
+
+func (b exponentialBackoff) BackoffDelay(attempt int, err error) (time.Duration, error) {
+	delay := maxRetryBackoff
+	if attempt < 32 {
+		if d := b.base << (attempt - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1)), nil
+}
@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := exponentialBackoff{base: 100 * time.Millisecond}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 5, max: 1600 * time.Millisecond},
+		{attempt: 10, max: maxRetryBackoff},
+		{attempt: 64, max: maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			longest := time.Duration(0)
+			for i := 0; i < 1000; i++ {
+				d, err := b.BackoffDelay(tt.attempt, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d < 0 || d > tt.max {
+					t.Fatalf("delay %s, want at most %s", d, tt.max)
+				}
+				if d > longest {
+					longest = d
+				}
+			}
+			if longest < tt.max/2 {
+				t.Errorf("longest delay %s of 1000, want up to %s", longest, tt.max)
+			}
+		})
+	}
+}
+
+func TestNewRetryer(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     ConfigOptions
+		maxAttempts int
+	}{
+		{name: "default", options: newConfigOptions(nil)},
+		{name: "no retries", options: newConfigOptions([]func(*ConfigOptions){WithMaxRetries(0)}), maxAttempts: 1},
+		{name: "retries", options: newConfigOptions([]func(*ConfigOptions){WithMaxRetries(5)}), maxAttempts: 6},
+		{name: "base delay", options: newConfigOptions([]func(*ConfigOptions){WithRetryBaseDelay(time.Second)}), maxAttempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryer := newRetryer(tt.options)
+			if tt.maxAttempts == 0 {
+				if retryer != nil {
+					t.Error("the SDK default retryer is replaced")
+				}
+				return
+			}
+			if retryer == nil {
+				t.Fatal("no retryer")
+			}
+			if got := retryer().MaxAttempts(); got != tt.maxAttempts {
+				t.Errorf("got %d attempts, want %d", got, tt.maxAttempts)
+			}
+		})
+	}
+}
+
+func TestThrottlingRetried(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		// throttled is how many calls of each operation are throttled.
+		throttled int
+		wantErr   bool
+	}{
+		{name: "retried until it succeeds", retries: 3, throttled: 2},
+		{name: "out of retries", retries: 1, throttled: 2, wantErr: true},
+		{name: "retries disabled", retries: 0, throttled: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sharedConfig(t, "[profile test]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n", "")
+			srcServer, _, dstServer, _ := fakeAccounts(t)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID, hostRecords("example.com", 10)...)
+			dstServer.AddZone("example.com", false)
+			throttled := map[string]int{}
+			srcServer.Fail = func(operation string) string {
+				if operation == fakeroute53.OpListResourceRecordSets && throttled[operation] < tt.throttled {
+					throttled[operation]++
+					return "Throttling"
+				}
+				return ""
+			}
+			dstServer.Fail = func(operation string) string {
+				if operation == fakeroute53.OpChangeResourceRecordSets && throttled[operation] < tt.throttled {
+					throttled[operation]++
+					return "Throttling"
+				}
+				return ""
+			}
+			optFns := []func(*ConfigOptions){WithMaxRetries(tt.retries), WithRetryBaseDelay(time.Millisecond)}
+			src, err := NewRouteCopy(ctx, "test", append(optFns, WithEndpoint(srcServer.URL, false))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dst, err := NewRouteCopy(ctx, "test", append(optFns, WithEndpoint(dstServer.URL, false))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+			if tt.wantErr {
+				var apiErr smithy.APIError
+				if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "Throttling" {
+					t.Fatalf("got %v, want a Throttling error", err)
+				}
+				if calls := srcServer.Calls(fakeroute53.OpListResourceRecordSets); calls != tt.retries+1 {
+					t.Errorf("listed the records %d times, want %d", calls, tt.retries+1)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Changes) != 10 {
+				t.Errorf("copied %d record sets, want 10", len(result.Changes))
+			}
+			if calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets); calls != tt.throttled+1 {
+				t.Errorf("submitted the changes %d times, want %d", calls, tt.throttled+1)
+			}
+		})
+	}
+}
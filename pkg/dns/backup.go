@@ -0,0 +1,82 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Backup is a snapshot of every record set in a hosted zone.
+type Backup struct {
+	ZoneID     string                     `json:"zone_id"`
+	Name       string                     `json:"name"`
+	Comment    string                     `json:"comment,omitempty"`
+	Private    bool                       `json:"private"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	RecordSets []rtypes.ResourceRecordSet `json:"record_sets"`
+}
+
+// BackupZone snapshots the record sets of zone.
+func (r *RouteCopy) BackupZone(ctx context.Context, zone rtypes.HostedZone) (Backup, error) {
+	records, err := r.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return Backup{}, err
+	}
+	backup := Backup{
+		ZoneID:     shortZoneID(aws.ToString(zone.Id)),
+		Name:       aws.ToString(zone.Name),
+		Private:    isPrivateZone(zone),
+		Timestamp:  time.Now().UTC(),
+		RecordSets: withoutLockRecords(records),
+	}
+	if zone.Config != nil {
+		backup.Comment = aws.ToString(zone.Config.Comment)
+	}
+	return backup, nil
+}
+
+func WriteBackup(w io.Writer, backup Backup) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(backup)
+}
+
+func ReadBackup(r io.Reader) (Backup, error) {
+	backup := Backup{}
+	err := json.NewDecoder(r).Decode(&backup)
+	if err != nil {
+		return backup, fmt.Errorf("invalid backup: %w", err)
+	}
+	if backup.Name == "" {
+		return backup, fmt.Errorf("invalid backup: missing zone name")
+	}
+	return backup, nil
+}
+
+// WriteBackupFile writes backup to the file name, replacing it.
+func WriteBackupFile(name string, backup Backup) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = WriteBackup(f, backup)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func ReadBackupFile(name string) (Backup, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return Backup{}, err
+	}
+	defer f.Close()
+	return ReadBackup(f)
+}
@@ -0,0 +1,364 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+const (
+	// MaxRecordsPerBatch is the maximum number of ResourceRecord elements
+	// Route53 accepts in a single change batch.
+	MaxRecordsPerBatch = 1000
+	// MaxValueCharsPerBatch is the maximum number of characters Route53
+	// accepts across all Value elements of a single change batch.
+	MaxValueCharsPerBatch = 32000
+	// MaxCommentLength is the longest change batch comment Route53 accepts.
+	MaxCommentLength = 256
+)
+
+type BatchError struct {
+	Batch   int
+	Batches int
+	Applied []rtypes.Change
+	// Failed are the changes of the batch Route53 rejected. A batch that
+	// was submitted but did not get in sync is in the results instead.
+	Failed []rtypes.Change
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d/%d failed after %d changes were applied: %s", e.Batch, e.Batches, len(e.Applied), e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Interrupted is returned by ApplyChanges when its context is canceled. The
+// batch in flight at that moment is finished and in sync, and the remaining
+// ones are not submitted.
+type Interrupted struct {
+	Batch     int
+	Batches   int
+	Applied   []rtypes.Change
+	Remaining []rtypes.Change
+}
+
+func (e *Interrupted) Error() string {
+	return fmt.Sprintf("interrupted after %d/%d batches with %d changes applied, %d changes were not submitted",
+		e.Batch, e.Batches, len(e.Applied), len(e.Remaining))
+}
+
+func (e *Interrupted) Unwrap() error {
+	return context.Canceled
+}
+
+// BatchResult describes a submitted change batch.
+type BatchResult struct {
+	ChangeInfo *rtypes.ChangeInfo
+	Changes    int
+	// Submitted are the changes of the batch, without the deletes of
+	// records that were already gone.
+	Submitted []rtypes.Change
+	Waited    time.Duration
+}
+
+// SplitChanges splits changes into batches that respect the Route53 limits on
+// the number of records and the number of value characters per request. An
+// UPSERT counts twice towards both limits, as documented by Route53. The
+// changes of a group, see GroupChanges, are kept in the same batch unless
+// the group alone exceeds the limits.
+func SplitChanges(ctx context.Context, changes []rtypes.Change) [][]rtypes.Change {
+	batches := [][]rtypes.Change{}
+	batch := []rtypes.Change{}
+	records, chars := 0, 0
+	add := func(group []rtypes.Change, r, c int) {
+		if len(batch) > 0 && (records+r > MaxRecordsPerBatch || chars+c > MaxValueCharsPerBatch) {
+			batches = append(batches, batch)
+			batch = []rtypes.Change{}
+			records, chars = 0, 0
+		}
+		batch = append(batch, group...)
+		records += r
+		chars += c
+	}
+	for _, group := range GroupChanges(changes) {
+		r, c := groupSize(group)
+		if r <= MaxRecordsPerBatch && c <= MaxValueCharsPerBatch {
+			add(group, r, c)
+			continue
+		}
+		rs := group[0].ResourceRecordSet
+		logging.From(ctx).Warnf("The %d changes of %s %s exceed the limits of a change batch, they are split across batches\n",
+			len(group), DecodeName(aws.ToString(rs.Name)), rs.Type)
+		for _, change := range group {
+			r, c := changeSize(change)
+			add([]rtypes.Change{change}, r, c)
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// GroupChanges groups changes by record name and type, in the order of the
+// first change of each group. The record sets of weighted, latency,
+// geolocation, failover and multivalue answer routing only differ by their
+// set identifier, so a group holds every record set of such a name, and
+// applying only part of it would serve a partial distribution.
+func GroupChanges(changes []rtypes.Change) [][]rtypes.Change {
+	groups := [][]rtypes.Change{}
+	index := map[string]int{}
+	for _, change := range changes {
+		rs := change.ResourceRecordSet
+		key := strings.ToLower(normalizeDomain(DecodeName(aws.ToString(rs.Name)))) + "|" + string(rs.Type)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, []rtypes.Change{})
+		}
+		groups[i] = append(groups[i], change)
+	}
+	return groups
+}
+
+func groupSize(group []rtypes.Change) (int, int) {
+	records, chars := 0, 0
+	for _, change := range group {
+		r, c := changeSize(change)
+		records += r
+		chars += c
+	}
+	return records, chars
+}
+
+func changeSize(change rtypes.Change) (int, int) {
+	records, chars := 1, 0
+	if change.ResourceRecordSet != nil && len(change.ResourceRecordSet.ResourceRecords) > 0 {
+		records = len(change.ResourceRecordSet.ResourceRecords)
+		for _, rr := range change.ResourceRecordSet.ResourceRecords {
+			chars += len(aws.ToString(rr.Value))
+		}
+	}
+	if change.Action == rtypes.ChangeActionUpsert {
+		return records * 2, chars * 2
+	}
+	return records, chars
+}
+
+// ApplyChanges submits changes in batches, waiting for each batch to be
+// in-sync before submitting the next one. When a batch fails a BatchError
+// with the changes already applied is returned. When ctx is canceled the
+// batch in flight is still submitted and waited for, and an Interrupted is
+// returned before the next one.
+func (r *RouteCopy) ApplyChanges(ctx context.Context, zoneId, comment string, changes []rtypes.Change, maxWait time.Duration) ([]BatchResult, error) {
+	return r.ApplyBatches(ctx, zoneId, comment, SplitChanges(ctx, changes), maxWait, nil)
+}
+
+// BatchObserver is called by ApplyBatches with the index of a batch and its
+// change id once Route53 accepted it, and again once it is in sync. A batch
+// left empty by deletes of records that were already gone is in sync
+// without a change id. A batch with changes Route53 rejected, see
+// SetIsolateRejected and SetContinueOnError, is reported without a change
+// id as ChangeStatusRejected once its other changes are in sync.
+type BatchObserver func(i int, changeID string, status rtypes.ChangeStatus)
+
+// ChangeStatusRejected is the status reported to a BatchObserver for a batch
+// with changes Route53 rejected. The rejected changes are returned in a
+// RejectedChanges.
+const ChangeStatusRejected rtypes.ChangeStatus = "REJECTED"
+
+// ApplyBatches is ApplyChanges with the batches already split, see
+// SplitChanges. observe, when not nil, follows the progress of each batch.
+func (r *RouteCopy) ApplyBatches(ctx context.Context, zoneId, comment string, batches [][]rtypes.Change, maxWait time.Duration, observe BatchObserver) ([]BatchResult, error) {
+	if observe == nil {
+		observe = func(int, string, rtypes.ChangeStatus) {}
+	}
+	applied := []rtypes.Change{}
+	results := []BatchResult{}
+	rejected := []RejectedChange{}
+	for i, batch := range batches {
+		if ctx.Err() != nil {
+			remaining := []rtypes.Change{}
+			for _, b := range batches[i:] {
+				remaining = append(remaining, b...)
+			}
+			return results, &Interrupted{Batch: i, Batches: len(batches), Applied: applied, Remaining: remaining}
+		}
+		// The batch is not abandoned halfway when ctx is canceled.
+		callCtx := detach(ctx)
+		params := &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneId),
+			ChangeBatch: &rtypes.ChangeBatch{
+				Changes: batch,
+			},
+		}
+		if comment != "" {
+			params.ChangeBatch.Comment = aws.String(TruncateComment(comment))
+		}
+		logging.From(ctx).Debugf("Submitting batch %d/%d with %d changes to zone %s\n", i+1, len(batches), len(batch), zoneId)
+		start := time.Now()
+		resp, err := r.cli.ChangeResourceRecordSets(callCtx, params)
+		r.observe(OpChangeRecords, start)
+		isolated := false
+		rejectedBefore := len(rejected)
+		for err != nil {
+			// Records deleted since they were listed make the whole batch
+			// fail, so the batch is retried without them.
+			var missing []rtypes.Change
+			batch, missing = withoutMissingDeletes(batch, err)
+			var icb *rtypes.InvalidChangeBatch
+			if len(missing) == 0 && r.isolateRejected && errors.As(err, &icb) {
+				logging.From(ctx).Warnf("Route53 rejected batch %d/%d, applying its %d changes in smaller batches to find the rejected ones\n",
+					i+1, len(batches), len(batch))
+				more, rej, ierr := r.isolate(callCtx, zoneId, comment, GroupChanges(batch), err, maxWait)
+				results = append(results, more...)
+				for _, b := range more {
+					if b.ChangeInfo.Status == rtypes.ChangeStatusInsync {
+						applied = append(applied, b.Submitted...)
+					}
+				}
+				rejected = append(rejected, rej...)
+				if ierr != nil {
+					return results, &BatchError{Batch: i + 1, Batches: len(batches), Applied: applied, Err: ierr}
+				}
+				isolated = true
+				break
+			}
+			if len(missing) == 0 && r.continueOnError && !errors.Is(err, context.Canceled) {
+				logging.From(ctx).Errorf("Batch %d/%d failed, going on with the next one: %s\n", i+1, len(batches), err)
+				rejected = append(rejected, rejectBatch(batch, err)...)
+				isolated = true
+				break
+			}
+			if len(missing) == 0 {
+				return results, &BatchError{Batch: i + 1, Batches: len(batches), Applied: applied, Failed: batch, Err: err}
+			}
+			for _, c := range missing {
+				logging.From(ctx).Warnf("Skipping %s %s, it was already deleted\n", DecodeName(aws.ToString(c.ResourceRecordSet.Name)), c.ResourceRecordSet.Type)
+			}
+			if len(batch) == 0 {
+				break
+			}
+			params.ChangeBatch.Changes = batch
+			start = time.Now()
+			resp, err = r.cli.ChangeResourceRecordSets(callCtx, params)
+			r.observe(OpChangeRecords, start)
+		}
+		for j := rejectedBefore; j < len(rejected); j++ {
+			rejected[j].Batch = i
+		}
+		if len(rejected) > rejectedBefore {
+			observe(i, "", ChangeStatusRejected)
+			r.progress.Update(PhaseSync, i+1, len(batches))
+			continue
+		}
+		if len(batch) == 0 || isolated {
+			observe(i, "", rtypes.ChangeStatusInsync)
+			r.progress.Update(PhaseSync, i+1, len(batches))
+			continue
+		}
+		logging.From(ctx).Debugf("Batch %d/%d submitted as change %s (%s)\n", i+1, len(batches), aws.ToString(resp.ChangeInfo.Id), resp.ChangeInfo.Status)
+		result := BatchResult{ChangeInfo: resp.ChangeInfo, Changes: len(batch), Submitted: batch}
+		observe(i, aws.ToString(resp.ChangeInfo.Id), resp.ChangeInfo.Status)
+		r.progress.Update(PhaseSubmit, i+1, len(batches))
+
+		if resp.ChangeInfo.Status != rtypes.ChangeStatusInsync {
+			start := time.Now()
+			err = r.WaitForChange(callCtx, aws.ToString(resp.ChangeInfo.Id), maxWait)
+			result.Waited = time.Since(start)
+			if err != nil {
+				results = append(results, result)
+				return results, &BatchError{Batch: i + 1, Batches: len(batches), Applied: applied, Err: err}
+			}
+			result.ChangeInfo.Status = rtypes.ChangeStatusInsync
+			observe(i, aws.ToString(resp.ChangeInfo.Id), rtypes.ChangeStatusInsync)
+		}
+		r.progress.Update(PhaseSync, i+1, len(batches))
+		results = append(results, result)
+		applied = append(applied, batch...)
+	}
+	r.progress.Done(PhaseSubmit)
+	r.progress.Done(PhaseSync)
+	if len(rejected) > 0 {
+		return results, &RejectedChanges{Applied: applied, Rejected: rejected}
+	}
+	return results, nil
+}
+
+// TruncateComment shortens a change batch comment to MaxCommentLength
+// characters, so the beginning of a long comment is kept.
+func TruncateComment(comment string) string {
+	runes := []rune(comment)
+	if len(runes) <= MaxCommentLength {
+		return comment
+	}
+	return string(runes[:MaxCommentLength-3]) + "..."
+}
+
+var missingDeleteRe = regexp.MustCompile(`Tried to delete resource record set \[name='([^']*)', type='([^']*)'(?:, set-identifier='([^']*)')?\] but it was not found`)
+
+// withoutMissingDeletes splits out the deletes that err reports as not
+// found from batch.
+func withoutMissingDeletes(batch []rtypes.Change, err error) ([]rtypes.Change, []rtypes.Change) {
+	var icb *rtypes.InvalidChangeBatch
+	if !errors.As(err, &icb) {
+		return batch, nil
+	}
+	notFound := map[string]bool{}
+	messages := icb.Messages
+	if icb.Message != nil {
+		messages = append(messages, aws.ToString(icb.Message))
+	}
+	for _, m := range messages {
+		for _, match := range missingDeleteRe.FindAllStringSubmatch(m, -1) {
+			notFound[missingDeleteKey(match[1], match[2], match[3])] = true
+		}
+	}
+
+	kept := []rtypes.Change{}
+	missing := []rtypes.Change{}
+	for _, c := range batch {
+		rs := c.ResourceRecordSet
+		key := missingDeleteKey(aws.ToString(rs.Name), string(rs.Type), aws.ToString(rs.SetIdentifier))
+		if c.Action == rtypes.ChangeActionDelete && notFound[key] {
+			missing = append(missing, c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept, missing
+}
+
+func missingDeleteKey(name, t, setIdentifier string) string {
+	return strings.ToLower(normalizeDomain(DecodeName(name))) + "|" + t + "|" + setIdentifier
+}
+
+// detachedContext keeps the values of a context but not its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// detach returns a context with the values of ctx that is never canceled.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
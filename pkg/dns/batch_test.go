@@ -0,0 +1,250 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// routingChanges returns the upserts of the weighted, geolocation and
+// multivalue answer record sets of www, geo and mv under domain, n of each.
+func routingChanges(domain string, n int) []rtypes.Change {
+	changes := []rtypes.Change{}
+	for i := 0; i < n; i++ {
+		weighted := recordSet("www."+domain+".", rtypes.RRTypeA, fmt.Sprintf("192.0.2.%d", i))
+		weighted.SetIdentifier = aws.String(fmt.Sprintf("weight-%d", i))
+		weighted.Weight = aws.Int64(10)
+		geo := recordSet("geo."+domain+".", rtypes.RRTypeCname, fmt.Sprintf("site%d.example.org.", i))
+		geo.SetIdentifier = aws.String(fmt.Sprintf("geo-%d", i))
+		geo.GeoLocation = &rtypes.GeoLocation{CountryCode: aws.String([]string{"*", "US", "BR", "DE"}[i%4])}
+		mv := recordSet("mv."+domain+".", rtypes.RRTypeA, fmt.Sprintf("198.51.100.%d", i))
+		mv.SetIdentifier = aws.String(fmt.Sprintf("mv-%d", i))
+		mv.MultiValueAnswer = aws.Bool(true)
+		for _, rs := range []rtypes.ResourceRecordSet{weighted, geo, mv} {
+			rs := rs
+			changes = append(changes, rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &rs})
+		}
+	}
+	return changes
+}
+
+// upserts returns the upserts of records.
+func upserts(records []rtypes.ResourceRecordSet) []rtypes.Change {
+	changes := []rtypes.Change{}
+	for i := range records {
+		changes = append(changes, rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &records[i]})
+	}
+	return changes
+}
+
+// weighted returns the changes of the weighted record sets of routingChanges.
+func weighted(changes []rtypes.Change) []rtypes.Change {
+	www := []rtypes.Change{}
+	for _, c := range changes {
+		if c.ResourceRecordSet.Weight != nil {
+			www = append(www, c)
+		}
+	}
+	return www
+}
+
+// changeKey returns the name and type of the record set of a change.
+func changeKey(c rtypes.Change) string {
+	return strings.ToLower(aws.ToString(c.ResourceRecordSet.Name)) + " " + string(c.ResourceRecordSet.Type)
+}
+
+func TestGroupChanges(t *testing.T) {
+	changes := routingChanges("example.com", 3)
+	// Names differing by case or an escape are the same name.
+	upper := recordSet("WWW.example.com.", rtypes.RRTypeA, "192.0.2.9")
+	upper.SetIdentifier = aws.String("weight-9")
+	escaped := recordSet(`\052.example.com.`, rtypes.RRTypeA, "192.0.2.10")
+	wildcard := recordSet("*.example.com.", rtypes.RRTypeA, "192.0.2.11")
+	changes = append(changes, upserts([]rtypes.ResourceRecordSet{upper, escaped, wildcard})...)
+	changes = append(changes, upserts([]rtypes.ResourceRecordSet{recordSet("www.example.com.", rtypes.RRTypeAaaa, "2001:db8::1")})...)
+
+	got := []string{}
+	for _, group := range GroupChanges(changes) {
+		got = append(got, fmt.Sprintf("%s %d", changeKey(group[0]), len(group)))
+	}
+	want := []string{"www.example.com. A 4", "geo.example.com. CNAME 3", "mv.example.com. A 3", `\052.example.com. A 2`, "www.example.com. AAAA 1"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+}
+
+func TestSplitChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []rtypes.Change
+		batches int
+		// split is the group left split across batches, with a warning.
+		split string
+	}{
+		{
+			// The 497 upserts take 996 records, so only 2 of the 3
+			// upserts of www would still fit in the first batch.
+			name:    "groups after single records",
+			changes: append(upserts(hostRecords("example.com", 497)), routingChanges("example.com", 3)...),
+			batches: 2,
+		},
+		{
+			name:    "groups between single records",
+			changes: append(append(upserts(hostRecords("example.com", 250)), routingChanges("example.com", 100)...), upserts(hostRecords("example.org", 250))...),
+			batches: 2,
+		},
+		{
+			name: "values over the character limit",
+			changes: func() []rtypes.Change {
+				changes := []rtypes.Change{}
+				for i := 0; i < 40; i++ {
+					rs := recordSet(fmt.Sprintf("txt%02d.example.com.", i), rtypes.RRTypeTxt, `"`+strings.Repeat("a", 500)+`"`, `"`+strings.Repeat("b", 500)+`"`)
+					changes = append(changes, rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &rs})
+				}
+				return append(changes, routingChanges("example.com", 5)...)
+			}(),
+			batches: 3,
+		},
+		{
+			name:    "group over the limits",
+			changes: append(upserts(hostRecords("example.com", 10)), weighted(routingChanges("example.com", 600))...),
+			batches: 2,
+			split:   "www.example.com. A",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&buf)
+			t.Cleanup(func() { log.SetOutput(w) })
+
+			batches := SplitChanges(context.Background(), tt.changes)
+			if len(batches) != tt.batches {
+				t.Errorf("got %d batches, want %d", len(batches), tt.batches)
+			}
+			total := 0
+			for i, batch := range batches {
+				total += len(batch)
+				records, chars := groupSize(batch)
+				if records > MaxRecordsPerBatch || chars > MaxValueCharsPerBatch {
+					t.Errorf("batch %d has %d records and %d characters", i+1, records, chars)
+				}
+				if i == 0 {
+					continue
+				}
+				prev := batches[i-1][len(batches[i-1])-1]
+				if changeKey(prev) == changeKey(batch[0]) && changeKey(prev) != tt.split {
+					t.Errorf("batches %d and %d split the group of %s", i, i+1, changeKey(prev))
+				}
+			}
+			if total != len(tt.changes) {
+				t.Errorf("the batches hold %d changes, want %d", total, len(tt.changes))
+			}
+			warned := strings.Contains(buf.String(), "exceed the limits of a change batch")
+			if warned != (tt.split != "") || !strings.Contains(buf.String(), tt.split) {
+				t.Errorf("warned: %t, want a warning for %q:\n%s", warned, tt.split, buf.String())
+			}
+		})
+	}
+}
+
+// blockingRoute53 is a Route53 client whose change batches wait for release
+// once they are submitted, so a test can cancel a copy in the middle of one.
+type blockingRoute53 struct {
+	Route53API
+	submitted chan<- struct{}
+	release   <-chan struct{}
+}
+
+func (c blockingRoute53) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	c.submitted <- struct{}{}
+	<-c.release
+	return c.Route53API.ChangeResourceRecordSets(ctx, params, optFns...)
+}
+
+func TestCopyZoneInterrupted(t *testing.T) {
+	srcServer, src, dstServer, fake := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	// 3 batches of upserts, see TestResumeRejectedBatch.
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 1200)...)
+	dstZoneID := dstServer.AddZone("example.com", false)
+	submitted := make(chan struct{}, 3)
+	release := make(chan struct{})
+	dst := NewRouteCopyWithClients("destination", DefaultRegion,
+		blockingRoute53{fake.cli, submitted, release}, fake.domains, fake.stscli)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type copied struct {
+		result CopyResult
+		err    error
+	}
+	done := make(chan copied)
+	go func() {
+		result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", AllowLiveOverwrite: true})
+		done <- copied{result, err}
+	}()
+	<-submitted
+	cancel()
+	close(release)
+	c := <-done
+
+	var ie *Interrupted
+	if !errors.As(c.err, &ie) {
+		t.Fatalf("got %v, want Interrupted", c.err)
+	}
+	if !errors.Is(c.err, context.Canceled) {
+		t.Error("Interrupted is not context.Canceled")
+	}
+	if ie.Batch != 1 || ie.Batches != 3 {
+		t.Errorf("interrupted after %d/%d batches, want 1/3", ie.Batch, ie.Batches)
+	}
+	if len(ie.Applied) == 0 || len(ie.Applied)+len(ie.Remaining) != 1200 {
+		t.Errorf("%d changes applied and %d remaining, want the 1200 changes split between them", len(ie.Applied), len(ie.Remaining))
+	}
+	if len(c.result.Batches) != 1 || len(c.result.Remaining) != len(ie.Remaining) || c.result.Existing == nil {
+		t.Errorf("the result holds %d batches, %d remaining changes and existing records %t, want 1, %d and true",
+			len(c.result.Batches), len(c.result.Remaining), c.result.Existing != nil, len(ie.Remaining))
+	}
+	if calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets); calls != 1 {
+		t.Errorf("submitted %d batches, want 1", calls)
+	}
+	// The batch in flight is waited for even though ctx is canceled.
+	if calls := dstServer.Calls(fakeroute53.OpGetChange); calls != 1 {
+		t.Errorf("polled the change %d times, want 1", calls)
+	}
+	if got := len(dstServer.Records(dstZoneID)); got != 2+len(ie.Applied) {
+		t.Errorf("the destination holds %d record sets, want the apex and the %d applied", got, len(ie.Applied))
+	}
+}
+
+func TestApplyChangesCanceled(t *testing.T) {
+	_, _, dstServer, dst := fakeAccounts(t)
+	dstZoneID := dstServer.AddZone("example.com", false)
+	changes := dst.CreateChanges(context.Background(), "example.com", hostRecords("example.com", 10))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := dst.ApplyChanges(ctx, dstZoneID, "", changes, time.Minute)
+	var ie *Interrupted
+	if !errors.As(err, &ie) {
+		t.Fatalf("got %v, want Interrupted", err)
+	}
+	if ie.Batch != 0 || ie.Batches != 1 || len(ie.Applied) != 0 || len(ie.Remaining) != 10 || len(results) != 0 {
+		t.Errorf("got %+v and %d results, want nothing applied", ie, len(results))
+	}
+	if calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets); calls != 0 {
+		t.Errorf("submitted %d batches, want none", calls)
+	}
+}
@@ -0,0 +1,234 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// maxCidrBlocksPerChange is the most CIDR blocks a single change of
+// ChangeCidrCollection accepts.
+const maxCidrBlocksPerChange = 1000
+
+// CidrCollectionIDs returns the distinct CIDR collection ids referenced by
+// changes.
+func CidrCollectionIDs(changes []rtypes.Change) []string {
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, c := range changes {
+		cidr := c.ResourceRecordSet.CidrRoutingConfig
+		if cidr == nil || seen[aws.ToString(cidr.CollectionId)] {
+			continue
+		}
+		seen[aws.ToString(cidr.CollectionId)] = true
+		ids = append(ids, aws.ToString(cidr.CollectionId))
+	}
+	return ids
+}
+
+// RewriteCidrCollectionIDs returns a copy of changes with CIDR collection
+// ids replaced according to ids. Ids missing from the map are kept.
+func RewriteCidrCollectionIDs(changes []rtypes.Change, ids map[string]string) []rtypes.Change {
+	rewritten := []rtypes.Change{}
+	for _, c := range changes {
+		cidr := c.ResourceRecordSet.CidrRoutingConfig
+		if cidr != nil {
+			if id, ok := ids[aws.ToString(cidr.CollectionId)]; ok {
+				config := *cidr
+				config.CollectionId = aws.String(id)
+				rs := *c.ResourceRecordSet
+				rs.CidrRoutingConfig = &config
+				c.ResourceRecordSet = &rs
+			}
+		}
+		rewritten = append(rewritten, c)
+	}
+	return rewritten
+}
+
+// CopyCidrCollections copies the CIDR collections with the given ids from
+// src to r and returns a map from source to destination ids. Collection ids
+// are scoped to an account, so a collection with the same name is reused
+// and the missing CIDR blocks are added to it.
+func (r *RouteCopy) CopyCidrCollections(ctx context.Context, src *RouteCopy, ids []string) (map[string]string, error) {
+	srcCollections, err := src.cidrCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dstCollections, err := r.cidrCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dstByName := map[string]rtypes.CollectionSummary{}
+	for _, c := range dstCollections {
+		dstByName[aws.ToString(c.Name)] = c
+	}
+
+	copied := map[string]string{}
+	for _, id := range ids {
+		collection, ok := srcCollections[id]
+		if !ok {
+			return copied, fmt.Errorf("CIDR collection %s not found in %s", id, src.profile)
+		}
+		name := aws.ToString(collection.Name)
+
+		dstID := ""
+		if existing, ok := dstByName[name]; ok {
+			dstID = aws.ToString(existing.Id)
+			logging.From(ctx).Infof("CIDR collection %s already exists as %s\n", name, dstID)
+		} else {
+			created, err := r.cli.CreateCidrCollection(ctx, &route53.CreateCidrCollectionInput{
+				Name:            aws.String(name),
+				CallerReference: aws.String("route53copy-" + id),
+			})
+			if err != nil {
+				return copied, fmt.Errorf("failed to create CIDR collection %s: %w", name, err)
+			}
+			dstID = aws.ToString(created.Collection.Id)
+			logging.From(ctx).Infof("CIDR collection %s copied as %s\n", name, dstID)
+		}
+
+		err := r.copyCidrBlocks(ctx, src, id, dstID)
+		if err != nil {
+			return copied, err
+		}
+		copied[id] = dstID
+	}
+	return copied, nil
+}
+
+// copyCidrBlocks adds the blocks of the source collection missing from the
+// destination collection, location by location.
+func (r *RouteCopy) copyCidrBlocks(ctx context.Context, src *RouteCopy, srcID, dstID string) error {
+	srcBlocks, err := src.cidrBlocks(ctx, srcID)
+	if err != nil {
+		return err
+	}
+	dstBlocks, err := r.cidrBlocks(ctx, dstID)
+	if err != nil {
+		return err
+	}
+
+	missing := map[string][]string{}
+	for location, blocks := range srcBlocks {
+		existing := map[string]bool{}
+		for _, b := range dstBlocks[location] {
+			existing[b] = true
+		}
+		for _, b := range blocks {
+			if !existing[b] {
+				missing[location] = append(missing[location], b)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	locations := []string{}
+	for location := range missing {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	changes := []rtypes.CidrCollectionChange{}
+	for _, location := range locations {
+		blocks := missing[location]
+		for start := 0; start < len(blocks); start += maxCidrBlocksPerChange {
+			end := start + maxCidrBlocksPerChange
+			if end > len(blocks) {
+				end = len(blocks)
+			}
+			changes = append(changes, rtypes.CidrCollectionChange{
+				Action:       rtypes.CidrCollectionChangeActionPut,
+				LocationName: aws.String(location),
+				CidrList:     blocks[start:end],
+			})
+		}
+	}
+	_, err = r.cli.ChangeCidrCollection(ctx, &route53.ChangeCidrCollectionInput{
+		Id:      aws.String(dstID),
+		Changes: changes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add CIDR blocks to collection %s: %w", dstID, err)
+	}
+	logging.From(ctx).Infof("Added CIDR blocks for %d locations to collection %s\n", len(locations), dstID)
+	return nil
+}
+
+// cidrCollections returns the CIDR collections of the account by id.
+func (r *RouteCopy) cidrCollections(ctx context.Context) (map[string]rtypes.CollectionSummary, error) {
+	collections := map[string]rtypes.CollectionSummary{}
+	paginator := route53.NewListCidrCollectionsPaginator(r.cli, &route53.ListCidrCollectionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range page.CidrCollections {
+			collections[aws.ToString(c.Id)] = c
+		}
+	}
+	return collections, nil
+}
+
+// cidrBlocks returns the blocks of a CIDR collection by location.
+func (r *RouteCopy) cidrBlocks(ctx context.Context, collectionID string) (map[string][]string, error) {
+	blocks := map[string][]string{}
+	paginator := route53.NewListCidrBlocksPaginator(r.cli, &route53.ListCidrBlocksInput{
+		CollectionId: aws.String(collectionID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list CIDR blocks of collection %s: %w", collectionID, err)
+		}
+		for _, b := range page.CidrBlocks {
+			location := aws.ToString(b.LocationName)
+			blocks[location] = append(blocks[location], aws.ToString(b.CidrBlock))
+		}
+	}
+	return blocks, nil
+}
+
+// MissingCidrCollections is returned when records route with CIDR
+// collections that do not exist in the destination account, which Route53
+// would reject.
+type MissingCidrCollections struct {
+	Profile string
+	IDs     []string
+}
+
+func (e *MissingCidrCollections) Error() string {
+	return fmt.Sprintf("CIDR collections %s used by the records do not exist in %s", strings.Join(e.IDs, ", "), e.Profile)
+}
+
+// checkCidrCollections returns a MissingCidrCollections when changes refer
+// to CIDR collections r does not have.
+func (r *RouteCopy) checkCidrCollections(ctx context.Context, changes []rtypes.Change) error {
+	ids := CidrCollectionIDs(changes)
+	if len(ids) == 0 {
+		return nil
+	}
+	collections, err := r.cidrCollections(ctx)
+	if err != nil {
+		return err
+	}
+	missing := []string{}
+	for _, id := range ids {
+		if _, ok := collections[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingCidrCollections{Profile: r.profile, IDs: missing}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// cidrRecords returns the record sets of api.example.com routed by the
+// locations of a CIDR collection.
+func cidrRecords(collectionID string) []rtypes.ResourceRecordSet {
+	records := []rtypes.ResourceRecordSet{}
+	for _, location := range []string{"office", "*"} {
+		rs := recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.1")
+		rs.SetIdentifier = aws.String("cidr-" + location)
+		rs.CidrRoutingConfig = &rtypes.CidrRoutingConfig{
+			CollectionId: aws.String(collectionID),
+			LocationName: aws.String(location),
+		}
+		records = append(records, rs)
+	}
+	return records
+}
+
+func TestCopyCidrRoutingConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing are the blocks of a collection of the same name in the
+		// destination account, if any.
+		existing map[string][]string
+	}{
+		{name: "created"},
+		{name: "reused", existing: map[string][]string{"office": {"198.51.100.0/24"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			blocks := map[string][]string{"office": {"198.51.100.0/24", "203.0.113.0/24"}}
+			srcCollectionID := srcServer.AddCidrCollection("offices", blocks)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID, cidrRecords(srcCollectionID)...)
+			// The collection ids of both accounts differ.
+			dstServer.AddCidrCollection("other", nil)
+			if tt.existing != nil {
+				dstServer.AddCidrCollection("offices", tt.existing)
+			}
+
+			_, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", CopyCidrCollections: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dstCollectionID, dstBlocks := dstServer.CidrCollection("offices")
+			if dstCollectionID == "" || dstCollectionID == srcCollectionID {
+				t.Fatalf("destination collection id is %q, source one is %s", dstCollectionID, srcCollectionID)
+			}
+			if !reflect.DeepEqual(dstBlocks, blocks) {
+				t.Errorf("destination blocks are %v, want %v", dstBlocks, blocks)
+			}
+
+			zones := dstServer.FindZone("example.com")
+			if len(zones) != 1 {
+				t.Fatalf("destination has %d zones of example.com, want 1", len(zones))
+			}
+			copied := 0
+			for _, rs := range dstServer.Records(zones[0]) {
+				if rs.CidrRoutingConfig == nil {
+					continue
+				}
+				copied++
+				if id := aws.ToString(rs.CidrRoutingConfig.CollectionId); id != dstCollectionID {
+					t.Errorf("%s routes with collection %s, want %s", aws.ToString(rs.SetIdentifier), id, dstCollectionID)
+				}
+			}
+			if copied != 2 {
+				t.Errorf("copied %d record sets with CIDR routing, want 2", copied)
+			}
+		})
+	}
+}
+
+func TestCopyCidrRoutingConfigMissingCollection(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcCollectionID := srcServer.AddCidrCollection("offices", map[string][]string{"office": {"198.51.100.0/24"}})
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, cidrRecords(srcCollectionID)...)
+
+	_, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	var missing *MissingCidrCollections
+	if !errors.As(err, &missing) || !reflect.DeepEqual(missing.IDs, []string{srcCollectionID}) {
+		t.Fatalf("got %v, want a MissingCidrCollections for %s", err, srcCollectionID)
+	}
+	if calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets); calls != 0 {
+		t.Errorf("submitted %d change batches", calls)
+	}
+}
@@ -0,0 +1,78 @@
+package dns
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53domains"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Route53API is the subset of the Route53 client used by RouteCopy.
+type Route53API interface {
+	ListResourceRecordSetsAPIClient
+	route53.GetChangeAPIClient
+	route53.ListHealthChecksAPIClient
+	route53.ListHostedZonesAPIClient
+	route53.ListCidrCollectionsAPIClient
+	route53.ListCidrBlocksAPIClient
+
+	ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error)
+	CreateHostedZone(ctx context.Context, params *route53.CreateHostedZoneInput, optFns ...func(*route53.Options)) (*route53.CreateHostedZoneOutput, error)
+	DeleteHostedZone(ctx context.Context, params *route53.DeleteHostedZoneInput, optFns ...func(*route53.Options)) (*route53.DeleteHostedZoneOutput, error)
+	GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error)
+	ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error)
+	UpdateHostedZoneComment(ctx context.Context, params *route53.UpdateHostedZoneCommentInput, optFns ...func(*route53.Options)) (*route53.UpdateHostedZoneCommentOutput, error)
+	GetHealthCheck(ctx context.Context, params *route53.GetHealthCheckInput, optFns ...func(*route53.Options)) (*route53.GetHealthCheckOutput, error)
+	CreateHealthCheck(ctx context.Context, params *route53.CreateHealthCheckInput, optFns ...func(*route53.Options)) (*route53.CreateHealthCheckOutput, error)
+	ChangeTagsForResource(ctx context.Context, params *route53.ChangeTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ChangeTagsForResourceOutput, error)
+	ListTagsForResources(ctx context.Context, params *route53.ListTagsForResourcesInput, optFns ...func(*route53.Options)) (*route53.ListTagsForResourcesOutput, error)
+	CreateCidrCollection(ctx context.Context, params *route53.CreateCidrCollectionInput, optFns ...func(*route53.Options)) (*route53.CreateCidrCollectionOutput, error)
+	ChangeCidrCollection(ctx context.Context, params *route53.ChangeCidrCollectionInput, optFns ...func(*route53.Options)) (*route53.ChangeCidrCollectionOutput, error)
+	GetDNSSEC(ctx context.Context, params *route53.GetDNSSECInput, optFns ...func(*route53.Options)) (*route53.GetDNSSECOutput, error)
+	CreateKeySigningKey(ctx context.Context, params *route53.CreateKeySigningKeyInput, optFns ...func(*route53.Options)) (*route53.CreateKeySigningKeyOutput, error)
+	CreateVPCAssociationAuthorization(ctx context.Context, params *route53.CreateVPCAssociationAuthorizationInput, optFns ...func(*route53.Options)) (*route53.CreateVPCAssociationAuthorizationOutput, error)
+	AssociateVPCWithHostedZone(ctx context.Context, params *route53.AssociateVPCWithHostedZoneInput, optFns ...func(*route53.Options)) (*route53.AssociateVPCWithHostedZoneOutput, error)
+	EnableHostedZoneDNSSEC(ctx context.Context, params *route53.EnableHostedZoneDNSSECInput, optFns ...func(*route53.Options)) (*route53.EnableHostedZoneDNSSECOutput, error)
+	ListReusableDelegationSets(ctx context.Context, params *route53.ListReusableDelegationSetsInput, optFns ...func(*route53.Options)) (*route53.ListReusableDelegationSetsOutput, error)
+	ListTrafficPolicies(ctx context.Context, params *route53.ListTrafficPoliciesInput, optFns ...func(*route53.Options)) (*route53.ListTrafficPoliciesOutput, error)
+	ListTrafficPolicyVersions(ctx context.Context, params *route53.ListTrafficPolicyVersionsInput, optFns ...func(*route53.Options)) (*route53.ListTrafficPolicyVersionsOutput, error)
+	ListTrafficPolicyInstancesByHostedZone(ctx context.Context, params *route53.ListTrafficPolicyInstancesByHostedZoneInput, optFns ...func(*route53.Options)) (*route53.ListTrafficPolicyInstancesByHostedZoneOutput, error)
+	GetTrafficPolicy(ctx context.Context, params *route53.GetTrafficPolicyInput, optFns ...func(*route53.Options)) (*route53.GetTrafficPolicyOutput, error)
+	CreateTrafficPolicy(ctx context.Context, params *route53.CreateTrafficPolicyInput, optFns ...func(*route53.Options)) (*route53.CreateTrafficPolicyOutput, error)
+	CreateTrafficPolicyVersion(ctx context.Context, params *route53.CreateTrafficPolicyVersionInput, optFns ...func(*route53.Options)) (*route53.CreateTrafficPolicyVersionOutput, error)
+	CreateTrafficPolicyInstance(ctx context.Context, params *route53.CreateTrafficPolicyInstanceInput, optFns ...func(*route53.Options)) (*route53.CreateTrafficPolicyInstanceOutput, error)
+}
+
+// Route53DomainsAPI is the subset of the Route53Domains client used by
+// RouteCopy.
+type Route53DomainsAPI interface {
+	GetOperationDetailAPIClient
+	route53domains.ListDomainsAPIClient
+
+	GetDomainDetail(ctx context.Context, params *route53domains.GetDomainDetailInput, optFns ...func(*route53domains.Options)) (*route53domains.GetDomainDetailOutput, error)
+	UpdateDomainNameservers(ctx context.Context, params *route53domains.UpdateDomainNameserversInput, optFns ...func(*route53domains.Options)) (*route53domains.UpdateDomainNameserversOutput, error)
+}
+
+// DomainTransferAPI is the subset of the Route53Domains client used by
+// DomainManager.
+type DomainTransferAPI interface {
+	GetOperationDetailAPIClient
+	route53domains.ListDomainsAPIClient
+
+	TransferDomainToAnotherAwsAccount(ctx context.Context, params *route53domains.TransferDomainToAnotherAwsAccountInput, optFns ...func(*route53domains.Options)) (*route53domains.TransferDomainToAnotherAwsAccountOutput, error)
+	AcceptDomainTransferFromAnotherAwsAccount(ctx context.Context, params *route53domains.AcceptDomainTransferFromAnotherAwsAccountInput, optFns ...func(*route53domains.Options)) (*route53domains.AcceptDomainTransferFromAnotherAwsAccountOutput, error)
+	CancelDomainTransferToAnotherAwsAccount(ctx context.Context, params *route53domains.CancelDomainTransferToAnotherAwsAccountInput, optFns ...func(*route53domains.Options)) (*route53domains.CancelDomainTransferToAnotherAwsAccountOutput, error)
+}
+
+// STSAPI is the subset of the STS client used by RouteCopy.
+type STSAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+var (
+	_ Route53API        = (*route53.Client)(nil)
+	_ Route53DomainsAPI = (*route53domains.Client)(nil)
+	_ DomainTransferAPI = (*route53domains.Client)(nil)
+	_ STSAPI            = (*sts.Client)(nil)
+)
@@ -0,0 +1,328 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// DefaultRegion is used when neither the environment nor the shared config
+// profile sets a region. Route53 is a global service homed in us-east-1.
+const DefaultRegion = "us-east-1"
+
+// EndpointEnv is the environment variable holding the endpoint used when
+// none is given, see ConfigOptions.Endpoint.
+const EndpointEnv = "ROUTE53COPY_ENDPOINT"
+
+// ProfileError is returned when the configuration or credentials of a
+// profile cannot be loaded or are rejected.
+type ProfileError struct {
+	Profile string
+	// Side tells which profile of the tool failed, such as source or
+	// destination. It is empty for tools using a single profile.
+	Side string
+	Err  error
+}
+
+func (e *ProfileError) Error() string {
+	name := fmt.Sprintf("profile '%s'", e.Profile)
+	if e.Side != "" {
+		name = e.Side + " " + name
+	}
+	if hint := credentialsHint(name, e.Profile, e.Err); hint != "" {
+		return fmt.Sprintf("%s: %s", hint, e.Err)
+	}
+	return fmt.Sprintf("%s not found or credentials invalid: %s", name, e.Err)
+}
+
+func (e *ProfileError) Unwrap() error {
+	return e.Err
+}
+
+// credentialsHint tells how to fix the common ways loading credentials
+// fails, or returns "" for other errors. name names the profile in the
+// message and profile is passed to the aws cli commands suggested.
+func credentialsHint(name, profile string, err error) string {
+	var notExist config.SharedConfigProfileNotExistError
+	var ssoToken *ssocreds.InvalidTokenError
+	var mfa config.AssumeRoleTokenProviderNotSetError
+	var apiErr smithy.APIError
+	code := ""
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+	switch {
+	case errors.As(err, &notExist):
+		return fmt.Sprintf("%s is not in the AWS config or credentials files", name)
+	case errors.As(err, &ssoToken), failedIn(err, "SSO", ""):
+		return fmt.Sprintf("SSO session for %s expired, run `aws sso login --profile %s`", name, profile)
+	case errors.As(err, &mfa), strings.Contains(err.Error(), "assume role with MFA enabled"):
+		return fmt.Sprintf("%s assumes a role requiring an MFA code, which cannot be prompted for, "+
+			"export temporary credentials from `aws sts get-session-token` instead", name)
+	case failedIn(err, "STS", "AssumeRole"):
+		return fmt.Sprintf("%s cannot assume its role, check the role ARN and that the role trusts the credentials assuming it", name)
+	case code == "ExpiredToken", code == "ExpiredTokenException", code == "RequestExpired":
+		return fmt.Sprintf("credentials of %s expired, refresh them", name)
+	case code == "InvalidClientTokenId", code == "UnrecognizedClientException", code == "SignatureDoesNotMatch":
+		return fmt.Sprintf("credentials of %s are invalid, check its access keys", name)
+	}
+	return ""
+}
+
+// failedIn reports whether err, or an error it wraps, comes from a call to
+// operation of service, or to any operation of service when operation is
+// empty. Credentials fail inside the call that needed them, so the failed
+// call is usually wrapped in another one.
+func failedIn(err error, service, operation string) bool {
+	var op *smithy.OperationError
+	for errors.As(err, &op) {
+		if op.Service() == service && (operation == "" || op.Operation() == operation) {
+			return true
+		}
+		err = op.Unwrap()
+	}
+	return false
+}
+
+// ConfigOptions are the options used to load the AWS configuration for a
+// profile.
+type ConfigOptions struct {
+	// Region overrides the region from the environment and the shared
+	// config profile.
+	Region string
+	// MaxRetries is the number of times a throttled or failed call is
+	// retried with exponential backoff. Zero disables retries, and a
+	// negative number, the default, keeps the SDK default.
+	MaxRetries int
+	// RetryBaseDelay is the longest delay before the first retry, doubled
+	// for each further retry. Zero keeps the SDK backoff.
+	RetryBaseDelay time.Duration
+	// RateLimit caps the number of API calls per second sent by the
+	// clients. Zero disables the limit.
+	RateLimit float64
+	// RoleARN is a role assumed with the profile credentials, for accounts
+	// only reachable through a role. ExternalID and SessionName are passed
+	// to AssumeRole.
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	// Side names the profile in errors, such as source or destination,
+	// see ProfileError.
+	Side string
+	// Endpoint sends the requests of every client to this URL instead of
+	// AWS, such as a Route53 emulator. It defaults to EndpointEnv.
+	Endpoint string
+	// Insecure skips the verification of the TLS certificate of Endpoint.
+	Insecure bool
+}
+
+// DefaultSessionName is the role session name used when none is given.
+const DefaultSessionName = "route53copy"
+
+// WithRegion sets the region used by the clients, taking precedence over
+// AWS_REGION and the profile region.
+func WithRegion(region string) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.Region = region
+	}
+}
+
+// WithMaxRetries sets how many times throttled calls are retried, zero
+// disabling retries.
+func WithMaxRetries(retries int) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.MaxRetries = retries
+	}
+}
+
+// WithRetryBaseDelay sets the longest delay before retrying a throttled
+// call for the first time, see ConfigOptions.RetryBaseDelay.
+func WithRetryBaseDelay(delay time.Duration) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.RetryBaseDelay = delay
+	}
+}
+
+// WithRateLimit limits the clients to perSecond API calls per second.
+func WithRateLimit(perSecond float64) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.RateLimit = perSecond
+	}
+}
+
+// WithAssumeRole makes the clients use the credentials of roleARN, assumed
+// with the profile credentials. externalID and sessionName are optional.
+func WithAssumeRole(roleARN, externalID, sessionName string) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.RoleARN = roleARN
+		o.ExternalID = externalID
+		o.SessionName = sessionName
+	}
+}
+
+// WithEndpoint sends the requests to endpoint instead of AWS, skipping the
+// verification of its TLS certificate when insecure is set.
+func WithEndpoint(endpoint string, insecure bool) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.Endpoint = endpoint
+		o.Insecure = insecure
+	}
+}
+
+// WithSide names the profile in errors, for tools using several profiles.
+func WithSide(side string) func(*ConfigOptions) {
+	return func(o *ConfigOptions) {
+		o.Side = side
+	}
+}
+
+func newConfigOptions(optFns []func(*ConfigOptions)) ConfigOptions {
+	options := ConfigOptions{MaxRetries: -1}
+	for _, fn := range optFns {
+		fn(&options)
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = os.Getenv(EndpointEnv)
+	}
+	return options
+}
+
+// ValidateEndpoint checks that endpoint is an absolute http or https URL.
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint '%s': %w", endpoint, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint '%s': expected an http or https URL such as http://localhost:5000", endpoint)
+	}
+	return nil
+}
+
+// endpointResolver resolves every service to endpoint, signing the requests
+// for the region of the client.
+func endpointResolver(endpoint string) aws.EndpointResolverWithOptions {
+	return aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{URL: endpoint, SigningRegion: region, HostnameImmutable: true}, nil
+	})
+}
+
+// newRetryer returns the retryer of the clients, or nil to keep the SDK
+// default.
+func newRetryer(options ConfigOptions) func() aws.Retryer {
+	if options.MaxRetries < 0 && options.RetryBaseDelay <= 0 {
+		return nil
+	}
+	return func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			if options.MaxRetries >= 0 {
+				o.MaxAttempts = options.MaxRetries + 1
+			}
+			if options.RetryBaseDelay > 0 {
+				o.Backoff = exponentialBackoff{base: options.RetryBaseDelay}
+			}
+		})
+	}
+}
+
+// LoadConfig loads the AWS configuration for profile.
+func LoadConfig(ctx context.Context, profile string, optFns ...func(*ConfigOptions)) (aws.Config, error) {
+	options := newConfigOptions(optFns)
+
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithSharedConfigProfile(profile),
+		config.WithDefaultRegion(DefaultRegion),
+	}
+	if options.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(options.Region))
+	}
+	if retryer := newRetryer(options); retryer != nil {
+		loadOpts = append(loadOpts, config.WithRetryer(retryer))
+	}
+	apiOptions := []func(*middleware.Stack) error{addDebugMiddleware}
+	if options.RateLimit > 0 {
+		limiter := newRateLimiter(options.RateLimit)
+		apiOptions = append(apiOptions, limiter.addMiddleware)
+	}
+	loadOpts = append(loadOpts, config.WithAPIOptions(apiOptions))
+	if options.Endpoint != "" {
+		err := ValidateEndpoint(options.Endpoint)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		loadOpts = append(loadOpts, config.WithEndpointResolverWithOptions(endpointResolver(options.Endpoint)))
+		if options.Insecure {
+			loadOpts = append(loadOpts, config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+				tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			})))
+		}
+	}
+	err := checkProfile(ctx, profile)
+	if err != nil {
+		return aws.Config{}, &ProfileError{Profile: profile, Side: options.Side, Err: err}
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return cfg, &ProfileError{Profile: profile, Side: options.Side, Err: err}
+	}
+	if options.RoleARN != "" {
+		cfg.Credentials = assumeRoleCredentials(cfg, options)
+	}
+	return cfg, nil
+}
+
+// checkProfile returns a SharedConfigProfileNotExistError when profile is in
+// neither the shared config nor the credentials file. LoadDefaultConfig
+// ignores a missing profile, which leaves the credentials to fail later with
+// a less helpful error. The default profile may be missing, since the
+// credentials can come from the environment.
+func checkProfile(ctx context.Context, profile string) error {
+	if profile == "" || profile == "default" {
+		return nil
+	}
+	_, err := config.LoadSharedConfigProfile(ctx, profile, func(o *config.LoadSharedConfigOptions) {
+		if file := os.Getenv("AWS_CONFIG_FILE"); file != "" {
+			o.ConfigFiles = []string{file}
+		}
+		if file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); file != "" {
+			o.CredentialsFiles = []string{file}
+		}
+	})
+	var notExist config.SharedConfigProfileNotExistError
+	if errors.As(err, &notExist) {
+		return err
+	}
+	// Other errors are left to LoadDefaultConfig to report.
+	return nil
+}
+
+// assumeRoleCredentials returns cached credentials for options.RoleARN,
+// assumed with the credentials in cfg.
+func assumeRoleCredentials(cfg aws.Config, options ConfigOptions) aws.CredentialsProvider {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), options.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = options.SessionName
+		if o.RoleSessionName == "" {
+			o.RoleSessionName = DefaultSessionName
+		}
+		if options.ExternalID != "" {
+			o.ExternalID = aws.String(options.ExternalID)
+		}
+	})
+	return aws.NewCredentialsCache(provider)
+}
@@ -0,0 +1,349 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53domains"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// sharedConfig points the SDK at config and credentials files holding config
+// and credentials, clearing the environment variables that would take
+// precedence over them.
+func sharedConfig(t *testing.T, config, credentials string) {
+	t.Helper()
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentialsFile, []byte(credentials), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE", EndpointEnv} {
+		t.Setenv(env, "")
+	}
+}
+
+func TestLoadConfigRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		env     string
+		region  string
+		want    string
+	}{
+		{name: "flag set", profile: "plain", env: "ap-south-1", region: "eu-central-1", want: "eu-central-1"},
+		{name: "flag set over the profile", profile: "regional", region: "eu-central-1", want: "eu-central-1"},
+		{name: "env set", profile: "plain", env: "ap-south-1", want: "ap-south-1"},
+		{name: "neither set", profile: "plain", want: DefaultRegion},
+		{name: "profile region", profile: "regional", want: "eu-west-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedConfig(t, "[profile plain]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n\n"+
+				"[profile regional]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\nregion = eu-west-1\n", "")
+			t.Setenv("AWS_REGION", tt.env)
+			optFns := []func(*ConfigOptions){}
+			if tt.region != "" {
+				optFns = append(optFns, WithRegion(tt.region))
+			}
+
+			cfg, err := LoadConfig(context.Background(), tt.profile, optFns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Region != tt.want {
+				t.Errorf("got region %s, want %s", cfg.Region, tt.want)
+			}
+			if env := os.Getenv("AWS_REGION"); env != tt.env {
+				t.Errorf("AWS_REGION changed to %q", env)
+			}
+		})
+	}
+}
+
+func TestNewRouteCopyProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		credentials string
+		profile     string
+		// want is the start of the error, none when the profile loads.
+		want string
+	}{
+		{
+			name:    "missing profile",
+			config:  "[profile prod]\nregion = us-east-1\n",
+			profile: "foo",
+			want:    "source profile 'foo' is not in the AWS config or credentials files",
+		},
+		{
+			name:        "profile in the credentials file",
+			credentials: "[foo]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n",
+			profile:     "foo",
+		},
+		{
+			name:    "profile in the config file",
+			config:  "[profile foo]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n",
+			profile: "foo",
+		},
+		{
+			name:    "default profile with the credentials elsewhere",
+			profile: "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedConfig(t, tt.config, tt.credentials)
+			r, err := NewRouteCopy(context.Background(), tt.profile, WithSide("source"))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.Profile() != tt.profile {
+					t.Errorf("got profile %s, want %s", r.Profile(), tt.profile)
+				}
+				return
+			}
+			var pe *ProfileError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v, want a ProfileError", err)
+			}
+			if pe.Profile != tt.profile {
+				t.Errorf("got profile %s, want %s", pe.Profile, tt.profile)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to start with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAssumeRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		roleARN     string
+		externalID  string
+		sessionName string
+		// want is the role session, none when the profile credentials are
+		// used as they are.
+		want *fakeroute53.AssumedRole
+	}{
+		{name: "profile credentials"},
+		{
+			name:        "assumed role",
+			roleARN:     "arn:aws:iam::210987654321:role/dns-admin",
+			externalID:  "ticket-42",
+			sessionName: "alice",
+			want:        &fakeroute53.AssumedRole{RoleARN: "arn:aws:iam::210987654321:role/dns-admin", ExternalID: "ticket-42", SessionName: "alice", AccessKeyID: "AKIDBASE"},
+		},
+		{
+			name:    "default session name",
+			roleARN: "arn:aws:iam::210987654321:role/dns-admin",
+			want:    &fakeroute53.AssumedRole{RoleARN: "arn:aws:iam::210987654321:role/dns-admin", SessionName: DefaultSessionName, AccessKeyID: "AKIDBASE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sharedConfig(t, "[profile base]\naws_access_key_id = AKIDBASE\naws_secret_access_key = secret\n", "")
+			server := fakeroute53.NewServer()
+			t.Cleanup(server.Close)
+
+			cfg, err := LoadConfig(ctx, "base", WithEndpoint(server.URL, false), WithAssumeRole(tt.roleARN, tt.externalID, tt.sessionName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			creds, err := cfg.Credentials.Retrieve(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roles := server.AssumedRoles()
+			if tt.want == nil {
+				if creds.AccessKeyID != "AKIDBASE" || len(roles) > 0 {
+					t.Errorf("got credentials %s after assuming %v, want the profile ones", creds.AccessKeyID, roles)
+				}
+				return
+			}
+			if len(roles) != 1 || roles[0] != *tt.want {
+				t.Fatalf("assumed %+v, want %+v", roles, *tt.want)
+			}
+			if !strings.HasPrefix(creds.AccessKeyID, "ASIAFAKE") || creds.SessionToken == "" {
+				t.Errorf("got credentials %s, want the assumed role ones", creds.AccessKeyID)
+			}
+			// The credentials are cached until they expire.
+			if _, err := cfg.Credentials.Retrieve(ctx); err != nil || server.Calls(fakeroute53.OpAssumeRole) != 1 {
+				t.Errorf("assumed the role %d times (%v), want once", server.Calls(fakeroute53.OpAssumeRole), err)
+			}
+
+			r, err := NewRouteCopy(ctx, "base", WithEndpoint(server.URL, false), WithAssumeRole(tt.roleARN, tt.externalID, tt.sessionName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			identity, err := r.stscli.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if arn := aws.ToString(identity.Arn); arn != "arn:aws:sts::123456789012:assumed-role/dns-admin/"+tt.want.SessionName {
+				t.Errorf("the client calls AWS as %s, want the assumed role", arn)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		env      string
+		insecure bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "AWS"},
+		{name: "option", endpoint: "http://localhost:5000", want: "http://localhost:5000"},
+		{name: "environment", env: "http://localhost:4566", want: "http://localhost:4566"},
+		{name: "option over the environment", endpoint: "http://localhost:5000", env: "http://localhost:4566", want: "http://localhost:5000"},
+		{name: "insecure", endpoint: "https://localhost:5000", insecure: true, want: "https://localhost:5000"},
+		{name: "not a URL", endpoint: "localhost:5000", wantErr: true},
+		{name: "invalid environment", env: "ftp://localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedConfig(t, "[profile test]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n", "")
+			t.Setenv(EndpointEnv, tt.env)
+
+			cfg, err := LoadConfig(context.Background(), "test", WithEndpoint(tt.endpoint, tt.insecure))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("the endpoint was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if cfg.EndpointResolverWithOptions != nil {
+					t.Error("the requests are not sent to AWS")
+				}
+				return
+			}
+			for _, service := range []string{route53.ServiceID, route53domains.ServiceID, sts.ServiceID} {
+				e, err := cfg.EndpointResolverWithOptions.ResolveEndpoint(service, cfg.Region)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e.URL != tt.want || e.SigningRegion != cfg.Region {
+					t.Errorf("%s resolves to %s signed for %s, want %s signed for %s", service, e.URL, e.SigningRegion, tt.want, cfg.Region)
+				}
+			}
+			client, ok := cfg.HTTPClient.(*awshttp.BuildableClient)
+			insecure := ok && client.GetTransport().TLSClientConfig != nil && client.GetTransport().TLSClientConfig.InsecureSkipVerify
+			if insecure != tt.insecure {
+				t.Errorf("TLS verification skipped: %t, want %t", insecure, tt.insecure)
+			}
+		})
+	}
+}
+
+// endpointServer records the service of each request it gets, and fails
+// them.
+type endpointServer struct {
+	mu       sync.Mutex
+	services map[string]bool
+}
+
+func (s *endpointServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	service := ""
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/2013-04-01/"):
+		service = route53.ServiceID
+	case strings.HasPrefix(req.Header.Get("X-Amz-Target"), "Route53Domains_"):
+		service = route53domains.ServiceID
+	case strings.Contains(string(body), "Action=GetCallerIdentity"):
+		service = sts.ServiceID
+	}
+	s.mu.Lock()
+	s.services[service] = true
+	s.mu.Unlock()
+	http.Error(w, "served by the test", http.StatusBadRequest)
+}
+
+func (s *endpointServer) served(service string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.services[service]
+}
+
+func TestClientsEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      bool
+		insecure bool
+		// reached is whether the requests get to the server.
+		reached bool
+	}{
+		{name: "http", reached: true},
+		{name: "https", tls: true, insecure: true, reached: true},
+		{name: "https verified", tls: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sharedConfig(t, "[profile test]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n", "")
+			handler := &endpointServer{services: map[string]bool{}}
+			server := httptest.NewServer(handler)
+			if tt.tls {
+				server.Close()
+				server = httptest.NewTLSServer(handler)
+			}
+			t.Cleanup(server.Close)
+			optFns := []func(*ConfigOptions){WithEndpoint(server.URL, tt.insecure), WithMaxRetries(0)}
+
+			r, err := NewRouteCopy(ctx, "test", optFns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.cli.ListHostedZones(ctx, &route53.ListHostedZonesInput{})
+			r.stscli.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			r.domains.ListDomains(ctx, &route53domains.ListDomainsInput{})
+			for _, service := range []string{route53.ServiceID, route53domains.ServiceID, sts.ServiceID} {
+				if handler.served(service) != tt.reached {
+					t.Errorf("RouteCopy %s requests served: %t, want %t", service, handler.served(service), tt.reached)
+				}
+			}
+
+			handler.services = map[string]bool{}
+			dm, err := NewDomainManager(ctx, "test", optFns...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dm.cli.ListDomains(ctx, &route53domains.ListDomainsInput{})
+			dm.stscli.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			for _, service := range []string{route53domains.ServiceID, sts.ServiceID} {
+				if handler.served(service) != tt.reached {
+					t.Errorf("DomainManager %s requests served: %t, want %t", service, handler.served(service), tt.reached)
+				}
+			}
+		})
+	}
+}
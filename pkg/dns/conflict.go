@@ -0,0 +1,70 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// Conflict is a record set of the source that the destination holds with
+// other values, see CopyOptions.NoOverwrite.
+type Conflict struct {
+	Source      rtypes.ResourceRecordSet
+	Destination rtypes.ResourceRecordSet
+}
+
+// OverwriteConflicts is returned by CopyZone with NoOverwrite when the
+// destination holds some of the record sets with other values. Skipped is
+// set when the other record sets were copied, see SkipConflicts.
+type OverwriteConflicts struct {
+	Conflicts []Conflict
+	Skipped   bool
+}
+
+func (e *OverwriteConflicts) Error() string {
+	names := []string{}
+	for _, c := range e.Conflicts {
+		names = append(names, DecodeName(aws.ToString(c.Source.Name))+" "+string(c.Source.Type))
+	}
+	if e.Skipped {
+		return fmt.Sprintf("%d records already exist in the destination with other values and were left out: %s",
+			len(e.Conflicts), strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("%d records already exist in the destination with other values, nothing was copied: %s",
+		len(e.Conflicts), strings.Join(names, ", "))
+}
+
+// createOnly turns the upserts of changes into creates of the record sets
+// missing from existing, and splits off the ones existing holds with other
+// values. Record sets existing holds as is must be left out before, see
+// withoutUnchanged, since creating them fails.
+func createOnly(ctx context.Context, changes []rtypes.Change, existing []rtypes.ResourceRecordSet) ([]rtypes.Change, []Conflict) {
+	current := map[string]rtypes.ResourceRecordSet{}
+	for _, rs := range existing {
+		current[recordSetKey(rs)] = rs
+	}
+	kept := []rtypes.Change{}
+	conflicts := []Conflict{}
+	for _, c := range changes {
+		if c.Action != rtypes.ChangeActionUpsert {
+			kept = append(kept, c)
+			continue
+		}
+		if rs, ok := current[recordSetKey(*c.ResourceRecordSet)]; ok {
+			conflicts = append(conflicts, Conflict{Source: *c.ResourceRecordSet, Destination: rs})
+			continue
+		}
+		c.Action = rtypes.ChangeActionCreate
+		kept = append(kept, c)
+	}
+	for _, c := range conflicts {
+		logging.From(ctx).Warnf("%s %s already exists in the destination with other values: %s, not overwriting it with %s\n",
+			DecodeName(aws.ToString(c.Source.Name)), c.Source.Type,
+			strings.ReplaceAll(recordValues(c.Destination), "\n", ", "), strings.ReplaceAll(recordValues(c.Source), "\n", ", "))
+	}
+	return kept, conflicts
+}
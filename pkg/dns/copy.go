@@ -0,0 +1,1306 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// verifyDNSSample is the number of records queried when verifying against
+// the destination nameservers.
+const verifyDNSSample = 20
+
+// CopyOptions controls what CopyZone copies and where.
+type CopyOptions struct {
+	// Domain is the source zone name.
+	Domain string
+	// DestinationDomain copies the records into a zone with another name.
+	// Defaults to Subtree, or Domain.
+	DestinationDomain string
+	// Subtree, when set to a subdomain of Domain, only copies the records
+	// of that subdomain and below, into a zone of its own. Its NS and SOA
+	// records are left out like the apex ones of a whole zone.
+	Subtree string
+	// SourceZoneID and DestinationZoneID select zones by id instead of
+	// looking them up by name.
+	SourceZoneID      string
+	DestinationZoneID string
+	// IntoParent writes the records into the closest destination zone
+	// enclosing DestinationDomain, see FindEnclosingZone, instead of a zone
+	// of its own. No zone is created.
+	IntoParent bool
+
+	// Private, VPCID, VPCRegion and DelegationSetID select private zones
+	// and configure a newly created destination zone, see ZoneOptions.
+	Private         bool
+	VPCID           string
+	VPCRegion       string
+	DelegationSetID string
+
+	// Types, ExcludeTypes, RewriteValues, SkipDelegations and
+	// SkipValidationRecords select and transform the copied record sets,
+	// see ChangeOptions.
+	Types                 []rtypes.RRType
+	ExcludeTypes          []rtypes.RRType
+	RewriteValues         bool
+	SkipDelegations       bool
+	SkipValidationRecords bool
+	// FailOnTrafficPolicy fails the copy with a TrafficPolicyRecords when
+	// the source zone holds record sets created by traffic policy
+	// instances. Otherwise they are left out with a warning.
+	FailOnTrafficPolicy bool
+	// TTL overrides or clamps the TTLs of the copied record sets, see
+	// TTLOptions.
+	TTL TTLOptions
+	// CopySOAValues sets the refresh, retry, expire and minimum values of
+	// the destination SOA to the source ones, see SOAValuesChange. It is
+	// ignored with IntoParent.
+	CopySOAValues bool
+	// Names restricts the copy to these names and their subdomains, see
+	// FilterRecordSubtrees.
+	Names []string
+	// Include and Exclude filter record names, see FilterRecordNames.
+	Include []string
+	Exclude []string
+	// Substitutions replace text in the values and alias targets of the
+	// copied record sets, in order, see ApplySubstitutions.
+	Substitutions []RewriteRule
+	// MaxRecords fails the copy with a TooManyRecords before listing the
+	// source records when the source zone has more, see CheckRecordCount.
+	// Zero copies zones of any size.
+	MaxRecords int64
+	// AllowEmpty lets a copy go on when the filters leave no records of a
+	// source zone holding some. Otherwise it fails with an EmptyCopy.
+	AllowEmpty bool
+
+	// SkipUnresolvableAliases drops aliases to other zones of the source
+	// account instead of failing the change batch.
+	SkipUnresolvableAliases bool
+	// Dealias replaces those aliases with records holding the values their
+	// targets resolve to instead, see DealiasChanges.
+	Dealias        bool
+	DealiasOptions DealiasOptions
+	// CopyHealthChecks copies the health checks referenced by the records.
+	CopyHealthChecks bool
+	// Otherwise the records referring to health checks missing from the
+	// destination are only flagged, see Warning, unless StripHealthChecks
+	// removes those health checks from the records, or RequireHealthChecks
+	// fails the copy with a MissingHealthChecks before the destination zone
+	// is created.
+	StripHealthChecks   bool
+	RequireHealthChecks bool
+	// CopyCidrCollections copies the CIDR collections referenced by records
+	// using CIDR routing.
+	CopyCidrCollections bool
+	// SyncComment copies the source zone comment to an existing zone.
+	SyncComment bool
+	// ZoneComment replaces the comment a newly created destination zone, or
+	// an existing one with SyncComment, gets from the source zone, see
+	// CopiedZoneComment.
+	ZoneComment string
+	// CopyVPCAssociations associates the VPCs of a private source zone with
+	// the destination zone, see CopyVPCAssociations.
+	CopyVPCAssociations bool
+	// ExtraVPCs are associated with a private destination zone besides
+	// VPCID, see AssociateVPCs.
+	ExtraVPCs []rtypes.VPC
+	// EnableDNSSEC signs the destination zone with a key-signing key backed
+	// by the KMS key KMSKeyARN, see EnableDNSSEC.
+	EnableDNSSEC bool
+	KMSKeyARN    string
+
+	// Lock, when set, locks the destination domain while the copy runs, see
+	// AcquireLock. A dry run only warns about an existing lock.
+	Lock *LockOptions
+	// StateFile, when set, records the change batches and which of them
+	// were applied, see CopyState. It is written after each batch and
+	// removed once every batch is in sync. A copy fails with an
+	// ExistingState when the file exists, unless Resume is set.
+	StateFile string
+	// Resume applies the batches of StateFile that were not applied,
+	// instead of the computed changes. It fails with a StaleState when the
+	// source records changed since the file was written.
+	Resume bool
+
+	// UpsertUnchanged also submits the changes of record sets the
+	// destination already holds as is. Otherwise they are left out, see
+	// CompareRecordSets.
+	UpsertUnchanged bool
+	// NoOverwrite only creates the record sets missing from the
+	// destination, leaving out the ones it holds as is. The ones it holds
+	// with other values are conflicts: the copy fails with an
+	// OverwriteConflicts before applying anything, or, with SkipConflicts,
+	// leaves them out and fails once the other record sets are copied.
+	NoOverwrite   bool
+	SkipConflicts bool
+
+	// DryRun computes the changes without modifying the destination.
+	DryRun bool
+	// Stream applies the changes of each page of source records as it is
+	// listed, instead of listing the whole zone first. It cannot be used
+	// with the options that need every change before applying the first
+	// one, see streamConflicts.
+	Stream bool
+	// MaxWait is how long to wait for each change batch. Defaults to
+	// DefaultWaitTimeout.
+	MaxWait time.Duration
+
+	// SelectChanges, when set, is called with the changes grouped by record
+	// name and type, see GroupChanges, and returns the indexes of the groups
+	// to copy. The other groups are left out of the copy.
+	SelectChanges func(ctx context.Context, groups [][]rtypes.Change) ([]int, error)
+	// Confirm, when set, is called with the records that will be created
+	// and overwritten before anything is applied. Returning false aborts
+	// the copy.
+	Confirm func(ctx context.Context, preview Diff) (bool, error)
+	// AllowLiveOverwrite skips checking whether the destination zone is the
+	// one serving the domain. Otherwise overwriting records of that zone
+	// fails with a LiveZoneOverwrite, unless ConfirmLiveOverwrite is set and
+	// returns true.
+	AllowLiveOverwrite   bool
+	ConfirmLiveOverwrite func(ctx context.Context, live LiveZoneOverwrite) (bool, error)
+	// Comment, when set, returns the comment of the change batches applying
+	// changes. Defaults to "Importing ALL records from <source profile>".
+	Comment func(ctx context.Context, changes []rtypes.Change) string
+	// Backup, when set, is called with a snapshot of the destination zone
+	// before anything is applied.
+	Backup func(ctx context.Context, backup Backup) error
+	// CollectExisting fills CopyResult.Existing with the destination
+	// records before anything is applied.
+	CollectExisting bool
+	// CleanupOnFailure, when set, is called when the copy failed or was
+	// aborted before applying anything to a zone it created. Returning true
+	// deletes the zone.
+	CleanupOnFailure func(ctx context.Context, zone rtypes.HostedZone) (bool, error)
+
+	// Verify compares the destination records with the changes after the
+	// copy, and VerifyDNS also queries the destination nameservers.
+	Verify    bool
+	VerifyDNS bool
+}
+
+// CopyResult describes what CopyZone did. It is filled as far as the copy
+// got when an error is returned.
+type CopyResult struct {
+	SourceZone      rtypes.HostedZone
+	DestinationZone rtypes.HostedZone
+	// CreatedZone is set when the destination zone was created by the copy,
+	// and ZoneDeleted when it was deleted again by CleanupOnFailure.
+	CreatedZone bool
+	ZoneDeleted bool
+	// Changes are the changes applied, or that would be applied on a dry
+	// run.
+	Changes []rtypes.Change
+	// Unchanged are the changes left out because the destination already
+	// holds their record sets as is, see CopyOptions.UpsertUnchanged.
+	Unchanged []rtypes.Change
+	// Conflicts are the record sets left out because the destination holds
+	// them with other values, see CopyOptions.NoOverwrite.
+	Conflicts []Conflict
+	// Excluded are the source record sets left out of the copy and why.
+	Excluded []ExcludedRecord
+	Batches  []BatchResult
+	// TTLChanges are the changes whose TTL was changed by the TTL options,
+	// with their original TTL.
+	TTLChanges []TTLChange
+	// Dealiased are the aliases replaced with resolved records, see
+	// CopyOptions.Dealias.
+	Dealiased []DealiasedRecord
+	// Existing are the destination records before the copy, set when
+	// CollectExisting or Confirm is.
+	Existing []rtypes.ResourceRecordSet
+	// Warnings flags the changes referring to resources of the source
+	// account, see AnalyzeChangesWithOptions.
+	Warnings []Warning
+	// SourceDNSSEC is the signing status of a public source zone, and
+	// DestinationDNSSEC the one of the destination zone when the source is
+	// signed or EnableDNSSEC is set.
+	SourceDNSSEC      DNSSEC
+	DestinationDNSSEC DNSSEC
+	// Verification is set when verification was requested.
+	Verification *Verification
+	// VPCAssociations is set when CopyVPCAssociations was requested.
+	VPCAssociations *VPCAssociations
+	// Aborted is set when Confirm declined the changes.
+	Aborted bool
+	// Remaining are the changes that were not submitted because the copy
+	// was interrupted, see Interrupted.
+	Remaining []rtypes.Change
+
+	// sourceHash is SourceSnapshot.Hash, checked when resuming a copy.
+	sourceHash string
+}
+
+// ZoneLookupError is returned by CopyZone when the source or destination
+// zone cannot be found.
+type ZoneLookupError struct {
+	Source bool
+	Err    error
+}
+
+func (e *ZoneLookupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ZoneLookupError) Unwrap() error {
+	return e.Err
+}
+
+// ZoneNameMismatch is returned by CopyZone when a zone selected by id is
+// not a zone of the domain copied.
+type ZoneNameMismatch struct {
+	ZoneID string
+	Name   string
+	Domain string
+}
+
+func (e *ZoneNameMismatch) Error() string {
+	return fmt.Sprintf("hosted zone %s is '%s', not '%s'", e.ZoneID, e.Name, e.Domain)
+}
+
+// SourceSnapshot is the source side of a copy: the source zone, its records
+// and the changes copying them. SnapshotSource computes it once, so
+// CopyZoneFrom applies the same changes to every destination.
+type SourceSnapshot struct {
+	Zone   rtypes.HostedZone
+	DNSSEC DNSSEC
+	// Records are all the records of the source zone, before filtering.
+	Records    []rtypes.ResourceRecordSet
+	Changes    []rtypes.Change
+	Excluded   []ExcludedRecord
+	TTLChanges []TTLChange
+	Dealiased  []DealiasedRecord
+	// Hash is the ChangesHash of the changes before aliases are replaced,
+	// whose targets may resolve differently each time.
+	Hash string
+}
+
+// result returns the CopyResult of a copy that got no further than the
+// source.
+func (s SourceSnapshot) result() CopyResult {
+	return CopyResult{
+		SourceZone:   s.Zone,
+		SourceDNSSEC: s.DNSSEC,
+		Changes:      s.Changes,
+		Excluded:     s.Excluded,
+		TTLChanges:   s.TTLChanges,
+		Dealiased:    s.Dealiased,
+		sourceHash:   s.Hash,
+	}
+}
+
+// CopyZone copies the records of a zone from src to dst, creating the
+// destination zone when needed.
+func CopyZone(ctx context.Context, src, dst *RouteCopy, opts CopyOptions) (CopyResult, error) {
+	if opts.Stream {
+		return streamZone(ctx, src, dst, opts)
+	}
+	snapshot, err := SnapshotSource(ctx, src, opts)
+	if err != nil {
+		return snapshot.result(), err
+	}
+	return CopyZoneFrom(ctx, src, dst, snapshot, opts)
+}
+
+func copyDefaults(opts CopyOptions) CopyOptions {
+	if opts.DestinationDomain == "" {
+		opts.DestinationDomain = opts.recordsDomain()
+	}
+	if opts.MaxWait == 0 {
+		opts.MaxWait = DefaultWaitTimeout
+	}
+	if opts.NoOverwrite {
+		// Creating a record set that exists as is fails.
+		opts.UpsertUnchanged = false
+	}
+	return opts
+}
+
+// recordsDomain returns the domain the copied records are under: Subtree
+// when set, or Domain.
+func (opts CopyOptions) recordsDomain() string {
+	if opts.Subtree != "" {
+		return opts.Subtree
+	}
+	return opts.Domain
+}
+
+// checkSubtree checks that opts.Subtree, when set, is under opts.Domain.
+func checkSubtree(opts CopyOptions) error {
+	if opts.Subtree != "" && (sameDomain(opts.Subtree, opts.Domain) || !InSubtree(opts.Subtree, opts.Domain)) {
+		return fmt.Errorf("'%s' is not a subdomain of '%s'", opts.Subtree, opts.Domain)
+	}
+	return nil
+}
+
+// SnapshotSource lists the records of the source zone and computes the
+// changes copying them, following the source side of opts: the filters,
+// TTLs and aliases. It is filled as far as it got when an error is
+// returned.
+func SnapshotSource(ctx context.Context, src *RouteCopy, opts CopyOptions) (SourceSnapshot, error) {
+	opts = copyDefaults(opts)
+	result := SourceSnapshot{}
+	err := checkSubtree(opts)
+	if err != nil {
+		return result, err
+	}
+
+	zone, err := sourceZone(ctx, src, opts)
+	if err != nil {
+		return result, &ZoneLookupError{Source: true, Err: err}
+	}
+	result.Zone = zone
+	err = CheckRecordCount(zone, opts.MaxRecords)
+	if err != nil {
+		return result, err
+	}
+	srcZoneID := aws.ToString(zone.Id)
+	if !opts.Private {
+		result.DNSSEC = sourceDNSSEC(ctx, src, srcZoneID, opts)
+	}
+
+	recordSets, err := src.GetResourceRecords(ctx, srcZoneID)
+	if err != nil {
+		return result, err
+	}
+	// The apex SOA may be filtered out below.
+	result.Records = recordSets
+	for _, rs := range recordSets {
+		if IsLockRecord(rs) {
+			result.Excluded = append(result.Excluded, ExcludedRecord{Record: rs, Cause: ExcludedLock, Reason: "locks the zone during a copy"})
+		}
+	}
+	recordSets = withoutLockRecords(recordSets)
+
+	if opts.Subtree != "" {
+		var excluded []ExcludedRecord
+		recordSets, excluded = FilterRecordSubtrees(recordSets, []string{opts.Subtree})
+		logging.From(ctx).Infof("Copying the %d records under '%s', %d records are outside it\n", len(recordSets), opts.Subtree, len(excluded))
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+	if len(opts.Names) > 0 {
+		var excluded []ExcludedRecord
+		recordSets, excluded = FilterRecordSubtrees(recordSets, opts.Names)
+		logging.From(ctx).Infof("%d records are not under %s\n", len(excluded), strings.Join(opts.Names, ","))
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		var excluded []ExcludedRecord
+		recordSets, excluded = FilterRecordNames(recordSets, opts.Include, opts.Exclude)
+		logging.From(ctx).Infof("%d records excluded by name patterns\n", len(excluded))
+		if opts.DryRun {
+			for _, e := range excluded {
+				logging.From(ctx).Infof("  %s %s: %s\n", DecodeName(aws.ToString(e.Record.Name)), e.Record.Type, e.Reason)
+			}
+		}
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+
+	changes, excluded := src.CreateChangesWithOptions(ctx, opts.recordsDomain(), recordSets, ChangeOptions{
+		Types:                 opts.Types,
+		ExcludeTypes:          opts.ExcludeTypes,
+		DestinationDomain:     opts.DestinationDomain,
+		RewriteValues:         opts.RewriteValues,
+		SkipDelegations:       opts.SkipDelegations,
+		SkipValidationRecords: opts.SkipValidationRecords,
+	})
+	result.Excluded = append(result.Excluded, excluded...)
+	err = checkTrafficPolicyRecords(ctx, opts.Domain, excluded, opts.FailOnTrafficPolicy)
+	if err != nil {
+		return result, err
+	}
+	if len(opts.Types) > 0 {
+		logging.From(ctx).Infof("Only copying records of type %s\n", typesToString(opts.Types))
+	}
+	if len(opts.ExcludeTypes) > 0 {
+		logging.From(ctx).Infof("Not copying records of type %s\n", typesToString(opts.ExcludeTypes))
+	}
+	if (len(opts.Types) > 0 || len(opts.ExcludeTypes) > 0) && len(changes) == 0 {
+		logging.From(ctx).Infof("No records in '%s' match the given types\n", opts.Domain)
+	}
+	if opts.SelectChanges != nil {
+		changes, excluded, err = selectChanges(ctx, changes, opts.SelectChanges)
+		if err != nil {
+			return result, err
+		}
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+	if !opts.TTL.Empty() {
+		changes, result.TTLChanges = ApplyTTLOptions(changes, opts.TTL)
+		logging.From(ctx).Infof("Changing the TTL of %d records\n", len(result.TTLChanges))
+		if opts.DryRun {
+			logTTLChanges(ctx, result.TTLChanges)
+		}
+		warnApexTTLs(ctx, opts.Domain, recordSets, opts.TTL)
+	}
+	if len(opts.Substitutions) > 0 {
+		var substituted []SubstitutedRecord
+		changes, substituted, err = ApplySubstitutions(changes, opts.Substitutions)
+		if err != nil {
+			return result, err
+		}
+		logging.From(ctx).Infof("Substituted values of %d records\n", len(substituted))
+		if opts.DryRun {
+			logSubstitutions(ctx, substituted)
+		}
+	}
+	logging.From(ctx).Infoln("Number of records to copy", len(changes))
+	result.Changes = changes
+	result.Hash = ChangesHash(changes)
+
+	if opts.Dealias {
+		result.Changes, result.Dealiased, excluded, err = dealiasUnresolvableAliases(ctx, src, srcZoneID, changes, opts.DealiasOptions)
+		if err != nil {
+			return result, err
+		}
+		result.Excluded = append(result.Excluded, excluded...)
+	} else if opts.SkipUnresolvableAliases {
+		result.Changes, excluded, err = skipUnresolvableAliases(ctx, src, srcZoneID, changes)
+		if err != nil {
+			return result, err
+		}
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+
+	small, err := checkCopySize(opts.Domain, zone, result.Changes, result.Excluded)
+	var empty *EmptyCopy
+	switch {
+	case errors.As(err, &empty) && opts.AllowEmpty:
+		logging.From(ctx).Warnf("No records of '%s' left to copy, %s\n", opts.Domain, empty.Skipped)
+	case err != nil:
+		return result, err
+	case small && opts.Subtree == "" && opts.SelectChanges == nil:
+		// Copying a subtree or picked records is meant to leave most
+		// records behind.
+		logging.From(ctx).Warnf("Copying only %d of the %d records of '%s', %s\n", len(result.Changes),
+			aws.ToInt64(zone.ResourceRecordSetCount), opts.Domain, SkippedBreakdown(result.Excluded))
+	}
+	return result, nil
+}
+
+// selectChanges keeps the groups of changes selected by selectFn, see
+// CopyOptions.SelectChanges, and returns the record sets of the others as
+// excluded.
+func selectChanges(ctx context.Context, changes []rtypes.Change, selectFn func(context.Context, [][]rtypes.Change) ([]int, error)) ([]rtypes.Change, []ExcludedRecord, error) {
+	groups := GroupChanges(changes)
+	if len(groups) == 0 {
+		return changes, nil, nil
+	}
+	indexes, err := selectFn(ctx, groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	selected := map[int]bool{}
+	for _, i := range indexes {
+		selected[i] = true
+	}
+
+	kept := []rtypes.Change{}
+	excluded := []ExcludedRecord{}
+	for i, group := range groups {
+		if selected[i] {
+			kept = append(kept, group...)
+			continue
+		}
+		for _, change := range group {
+			excluded = append(excluded, ExcludedRecord{Record: *change.ResourceRecordSet, Cause: ExcludedSelection, Reason: "not selected"})
+		}
+	}
+	logging.From(ctx).Infof("Selected %d of %d records to copy\n", len(kept), len(changes))
+	return kept, excluded, nil
+}
+
+// CopyZoneFrom copies the changes of a snapshot of the source zone, see
+// SnapshotSource, to dst. The snapshot is not modified, so it can be copied
+// to several destinations at once.
+func CopyZoneFrom(ctx context.Context, src, dst *RouteCopy, snapshot SourceSnapshot, opts CopyOptions) (CopyResult, error) {
+	opts = copyDefaults(opts)
+	result := snapshot.result()
+	zone := snapshot.Zone
+	srcZoneID := aws.ToString(zone.Id)
+	srcRecords := snapshot.Records
+	changes := append([]rtypes.Change{}, snapshot.Changes...)
+	var err error
+
+	if opts.CopyHealthChecks {
+		changes, err = copyHealthChecks(ctx, src, dst, changes, opts.DryRun)
+		if err != nil {
+			return result, err
+		}
+	}
+	if opts.CopyCidrCollections {
+		changes, err = copyCidrCollections(ctx, src, dst, changes, opts.DryRun)
+		if err != nil {
+			return result, err
+		}
+	}
+	if !opts.CopyHealthChecks && (opts.StripHealthChecks || opts.RequireHealthChecks) {
+		changes, err = dst.checkHealthChecks(ctx, changes, opts.StripHealthChecks)
+		if err != nil {
+			return result, err
+		}
+	}
+	result.Changes = changes
+
+	result.Warnings, err = analyzeChanges(ctx, src, dst, srcZoneID, changes, opts)
+	if err != nil {
+		return result, err
+	}
+
+	if opts.DryRun {
+		logging.From(ctx).Infof("Not copying records to %s since this is a dry run\n", dst.profile)
+		if !sameDomain(opts.DestinationDomain, opts.recordsDomain()) {
+			logRenamedChanges(ctx, changes, opts.recordsDomain(), opts.DestinationDomain)
+		}
+		if isPrivateZone(zone) {
+			logSourceVPCs(ctx, src, srcZoneID, opts)
+		}
+		zone, _, err := destinationZone(ctx, src, dst, opts, zone, false)
+		if err != nil {
+			return result, &ZoneLookupError{Err: err}
+		}
+		result.DestinationZone = zone
+		result.Changes = RewriteAliasZoneIDs(result.Changes, srcZoneID, aws.ToString(zone.Id))
+		if opts.Lock != nil {
+			warnLocked(ctx, dst, zone, opts)
+		}
+		if copySOA(opts) {
+			result.Changes, err = copySOAValues(ctx, dst, zone, opts, srcRecords, result.Changes)
+			if err != nil {
+				return result, err
+			}
+		}
+		if opts.CollectExisting || !opts.UpsertUnchanged {
+			result.Existing, err = dst.GetResourceRecords(ctx, aws.ToString(zone.Id))
+			if err != nil {
+				return result, err
+			}
+		}
+		if !opts.UpsertUnchanged {
+			result.Changes, result.Unchanged = withoutUnchanged(ctx, result.Changes, result.Existing)
+		}
+		if opts.NoOverwrite {
+			result.Changes, result.Conflicts = createOnly(ctx, result.Changes, result.Existing)
+		}
+		if opts.CollectExisting {
+			if !copySOA(opts) && !opts.IntoParent {
+				warnSOADifferences(ctx, opts.Domain, srcRecords, aws.ToString(zone.Name), result.Existing)
+			}
+		}
+		if opts.EnableDNSSEC {
+			logging.From(ctx).Infof("Not enabling DNSSEC for '%s' since this is a dry run\n", opts.DestinationDomain)
+		}
+		if opts.Private && opts.CopyVPCAssociations {
+			err = copyVPCAssociations(ctx, src, dst, srcZoneID, zone, opts, &result)
+			if err != nil {
+				return result, err
+			}
+		}
+		for _, vpc := range opts.ExtraVPCs {
+			logging.From(ctx).Infof("Not associating VPC %s since this is a dry run\n", vpcString(vpc))
+		}
+
+		logging.From(ctx).Infof("Destination profile contains %d records, including NS and SOA\n",
+			aws.ToInt64(zone.ResourceRecordSetCount))
+		if len(result.Conflicts) > 0 {
+			return result, &OverwriteConflicts{Conflicts: result.Conflicts, Skipped: opts.SkipConflicts}
+		}
+		return result, nil
+	}
+
+	if !opts.CopyCidrCollections {
+		err = dst.checkCidrCollections(ctx, changes)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	srcZone := zone
+	zone, result.CreatedZone, err = destinationZone(ctx, src, dst, opts, srcZone, true)
+	if err != nil {
+		return result, &ZoneLookupError{Err: err}
+	}
+	result.DestinationZone = zone
+
+	unlock, err := lockZone(ctx, dst, zone, opts)
+	if err != nil {
+		if result.CreatedZone {
+			result.ZoneDeleted = cleanupCreatedZone(ctx, dst, zone, opts)
+		}
+		return result, err
+	}
+	defer unlock()
+
+	if copySOA(opts) {
+		changes, err = copySOAValues(ctx, dst, zone, opts, srcRecords, changes)
+		if err != nil {
+			return result, err
+		}
+	}
+	err = copyRecords(ctx, src, dst, srcZoneID, srcZone, srcRecords, changes, opts, &result)
+	return result, completeCopy(ctx, src, dst, srcZoneID, opts, unlock, &result, err)
+}
+
+// completeCopy finishes a copy whose records were applied with err: it
+// sets up DNSSEC and the VPC associations of the destination zone in
+// result, unlocks it, and deletes it when the copy created it and left it
+// empty.
+func completeCopy(ctx context.Context, src, dst *RouteCopy, srcZoneID string, opts CopyOptions, unlock func(), result *CopyResult, err error) error {
+	zone := result.DestinationZone
+	if err == nil && !result.Aborted && (opts.EnableDNSSEC || result.SourceDNSSEC.Signing()) {
+		result.DestinationDNSSEC, err = destinationDNSSEC(ctx, dst, aws.ToString(zone.Id), opts)
+	}
+	if err == nil && !result.Aborted && opts.Private && opts.CopyVPCAssociations {
+		err = copyVPCAssociations(ctx, src, dst, srcZoneID, zone, opts, result)
+	}
+	if err == nil && !result.Aborted && opts.Private && len(opts.ExtraVPCs) > 0 {
+		err = associateExtraVPCs(ctx, dst, zone, opts, result)
+	}
+	if err == nil && !result.Aborted && len(result.Conflicts) > 0 {
+		err = &OverwriteConflicts{Conflicts: result.Conflicts, Skipped: true}
+	}
+	// The lock record would keep a created zone from being deleted.
+	unlock()
+	if result.CreatedZone && len(result.Batches) == 0 && (err != nil || result.Aborted) {
+		result.ZoneDeleted = cleanupCreatedZone(ctx, dst, zone, opts)
+	}
+	return err
+}
+
+// withoutUnchanged splits off the upserts of record sets existing already
+// holds as is, see CompareRecordSets.
+func withoutUnchanged(ctx context.Context, changes []rtypes.Change, existing []rtypes.ResourceRecordSet) ([]rtypes.Change, []rtypes.Change) {
+	current := map[string]rtypes.ResourceRecordSet{}
+	for _, rs := range existing {
+		current[recordSetKey(rs)] = rs
+	}
+	kept := []rtypes.Change{}
+	unchanged := []rtypes.Change{}
+	for _, c := range changes {
+		rs, ok := current[recordSetKey(*c.ResourceRecordSet)]
+		if c.Action == rtypes.ChangeActionUpsert && ok && CompareRecordSets(rs, *c.ResourceRecordSet) {
+			unchanged = append(unchanged, c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	logging.From(ctx).Infof("%d unchanged, %d to upsert\n", len(unchanged), len(kept))
+	return kept, unchanged
+}
+
+// copyRecords applies changes to the destination zone in result.
+func copyRecords(ctx context.Context, src, dst *RouteCopy, srcZoneID string, srcZone rtypes.HostedZone, srcRecords []rtypes.ResourceRecordSet, changes []rtypes.Change, opts CopyOptions, result *CopyResult) error {
+	zone := result.DestinationZone
+	dstZoneID := aws.ToString(zone.Id)
+
+	if opts.SyncComment {
+		err := syncComment(ctx, src, dst, srcZone, zone, opts)
+		if err != nil {
+			return err
+		}
+	}
+	changes = RewriteAliasZoneIDs(changes, srcZoneID, dstZoneID)
+	result.Changes = changes
+
+	// A resumed copy applies the batches of the state file as they are, and
+	// a zone created by the copy holds nothing to compare with.
+	listed := false
+	if !opts.UpsertUnchanged && !opts.Resume && !result.CreatedZone {
+		existing, err := dst.GetResourceRecords(ctx, dstZoneID)
+		if err != nil {
+			return err
+		}
+		result.Existing = existing
+		listed = true
+		if !copySOA(opts) && !opts.IntoParent {
+			warnSOADifferences(ctx, opts.Domain, srcRecords, aws.ToString(zone.Name), existing)
+		}
+		changes, result.Unchanged = withoutUnchanged(ctx, changes, existing)
+		result.Changes = changes
+	}
+	if opts.NoOverwrite && !opts.Resume {
+		changes, result.Conflicts = createOnly(ctx, changes, result.Existing)
+		result.Changes = changes
+		if len(result.Conflicts) > 0 && !opts.SkipConflicts {
+			return &OverwriteConflicts{Conflicts: result.Conflicts}
+		}
+	}
+
+	var state *CopyState
+	if opts.StateFile != "" && len(changes) > 0 {
+		s, err := newCopyState(ctx, dst, opts, srcZoneID, dstZoneID, result.sourceHash, changes)
+		if err != nil {
+			return err
+		}
+		state = &s
+		if opts.Resume {
+			changes = resumedChanges(s)
+			result.Changes = changes
+			if len(changes) == 0 {
+				logging.From(ctx).Summaryf("Every batch in %s was already applied\n", opts.StateFile)
+				return os.Remove(opts.StateFile)
+			}
+		}
+	}
+
+	if len(changes) == 0 && len(result.Unchanged) > 0 {
+		logging.From(ctx).Summaryf("The records of '%s' are already up to date in '%s'\n", opts.Domain, opts.DestinationDomain)
+		return nil
+	}
+	if len(changes) == 0 {
+		logging.From(ctx).Summaryf("No records to copy for '%s'\n", opts.Domain)
+		return nil
+	}
+
+	// A zone created by the copy cannot serve the domain yet.
+	checkLive := !opts.Private && !opts.AllowLiveOverwrite && !result.CreatedZone
+	if (opts.Confirm != nil || opts.CollectExisting || checkLive) && !listed {
+		existing, err := dst.GetResourceRecords(ctx, dstZoneID)
+		if err != nil {
+			return err
+		}
+		result.Existing = existing
+		if !copySOA(opts) && !opts.IntoParent {
+			warnSOADifferences(ctx, opts.Domain, srcRecords, aws.ToString(zone.Name), existing)
+		}
+	}
+
+	if checkLive {
+		ok, err := checkLiveOverwrite(ctx, zone, changes, result.Existing, opts)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			result.Aborted = true
+			return nil
+		}
+	}
+
+	if opts.Confirm != nil {
+		preview := PreviewChanges(changes, result.Existing)
+		logging.From(ctx).Infof("%d records will be created and %d existing records overwritten\n",
+			len(preview.Create), len(preview.Update))
+		if !preview.Empty() {
+			ok, err := opts.Confirm(ctx, preview)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				result.Aborted = true
+				return nil
+			}
+		}
+	}
+
+	if opts.Backup != nil && state != nil && opts.Resume {
+		logging.From(ctx).Infof("Not backing up '%s' again, the copy being resumed already changed it\n", opts.DestinationDomain)
+	} else if opts.Backup != nil {
+		backup, err := dst.BackupZone(ctx, zone)
+		if err != nil {
+			return err
+		}
+		err = opts.Backup(ctx, backup)
+		if err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	var err error
+	comment := "Importing ALL records from " + src.profile
+	if opts.Comment != nil {
+		comment = opts.Comment(ctx, changes)
+	}
+	if state != nil {
+		result.Batches, err = applyWithState(ctx, dst, dstZoneID, comment, *state, opts)
+	} else {
+		result.Batches, err = dst.UpdateRecords(ctx, dstZoneID, comment, changes, opts.MaxWait)
+	}
+	if err != nil {
+		var be *BatchError
+		if errors.As(err, &be) {
+			logAppliedChanges(ctx, be.Applied)
+		}
+		var ie *Interrupted
+		if errors.As(err, &ie) {
+			result.Remaining = ie.Remaining
+			if result.Existing == nil {
+				// Needed to plan the remaining changes. The records they
+				// replace were not touched by the applied batches.
+				existing, lerr := dst.GetResourceRecords(detach(ctx), dstZoneID)
+				if lerr != nil {
+					logging.From(ctx).Warnf("Could not list the records of the destination zone: %s\n", lerr)
+				}
+				result.Existing = existing
+			}
+		}
+		return err
+	}
+	logging.From(ctx).Summaryf("%d records in '%s' were copied from %s to %s and are in sync after %s\n",
+		len(changes), opts.DestinationDomain, src.profile, dst.profile, time.Since(start))
+
+	if opts.Verify || opts.VerifyDNS {
+		v, err := verifyCopy(ctx, dst, zone, opts, changes)
+		if err != nil {
+			return err
+		}
+		result.Verification = &v
+		if !v.OK() {
+			return &VerificationFailed{Verification: v}
+		}
+		logging.From(ctx).Summaryf("All copied records verified\n")
+	}
+	return nil
+}
+
+// copySOA reports whether the SOA values are copied, which they are not
+// into a parent zone.
+func copySOA(opts CopyOptions) bool {
+	return opts.CopySOAValues && !opts.IntoParent
+}
+
+func copyVPCAssociations(ctx context.Context, src, dst *RouteCopy, srcZoneID string, zone rtypes.HostedZone, opts CopyOptions, result *CopyResult) error {
+	associations, err := CopyVPCAssociations(ctx, src, dst, srcZoneID, aws.ToString(zone.Id), opts.DryRun, opts.MaxWait)
+	result.VPCAssociations = &associations
+	return err
+}
+
+// associateExtraVPCs associates opts.ExtraVPCs with the destination zone,
+// adding them to the VPCAssociations of result.
+func associateExtraVPCs(ctx context.Context, dst *RouteCopy, zone rtypes.HostedZone, opts CopyOptions, result *CopyResult) error {
+	associations, err := dst.AssociateVPCs(ctx, aws.ToString(zone.Id), opts.ExtraVPCs, opts.MaxWait)
+	if result.VPCAssociations == nil {
+		result.VPCAssociations = &VPCAssociations{}
+	}
+	result.VPCAssociations.Associated = append(result.VPCAssociations.Associated, associations.Associated...)
+	result.VPCAssociations.Existing = append(result.VPCAssociations.Existing, associations.Existing...)
+	result.VPCAssociations.Failed = append(result.VPCAssociations.Failed, associations.Failed...)
+	return err
+}
+
+// logSourceVPCs lists the VPCs associated with the private source zone.
+// Failing to list them only logs a warning.
+func logSourceVPCs(ctx context.Context, src *RouteCopy, srcZoneID string, opts CopyOptions) {
+	vpcs, err := src.ListVPCAssociations(ctx, srcZoneID)
+	if err != nil {
+		logging.From(ctx).Warnf("Could not list the VPCs of the source zone of '%s': %s\n", opts.Domain, err)
+		return
+	}
+	if len(vpcs) == 0 {
+		logging.From(ctx).Infof("The source zone of '%s' is associated with no VPCs\n", opts.Domain)
+		return
+	}
+	logging.From(ctx).Infof("The source zone of '%s' is associated with the VPCs %s, pass %s to associate them\n",
+		opts.Domain, vpcsString(vpcs), vpcFlags(vpcs))
+}
+
+// lockZone locks the destination domain when opts.Lock is set. The returned
+// function releases the lock, and does nothing after the first call.
+func lockZone(ctx context.Context, dst *RouteCopy, zone rtypes.HostedZone, opts CopyOptions) (func(), error) {
+	if opts.Lock == nil {
+		return func() {}, nil
+	}
+	zoneId := aws.ToString(zone.Id)
+	lock, err := dst.AcquireLock(ctx, zoneId, opts.DestinationDomain, *opts.Lock)
+	if err != nil {
+		return func() {}, err
+	}
+	released := false
+	return func() {
+		if released {
+			return
+		}
+		released = true
+		// Released even when the copy was interrupted.
+		err := dst.ReleaseLock(detach(ctx), zoneId, lock)
+		if err != nil {
+			logging.From(ctx).Warnf("Could not release the lock: %s\n", err)
+		}
+	}, nil
+}
+
+// warnLocked warns when a dry run finds the destination domain locked.
+func warnLocked(ctx context.Context, dst *RouteCopy, zone rtypes.HostedZone, opts CopyOptions) {
+	if zone.Id == nil {
+		return
+	}
+	lock, err := dst.CheckLock(ctx, aws.ToString(zone.Id), opts.DestinationDomain)
+	if err != nil {
+		logging.From(ctx).Warnf("Could not check the lock on '%s': %s\n", opts.DestinationDomain, err)
+		return
+	}
+	if lock != nil && !lock.Expired(time.Now()) && !opts.Lock.Break {
+		logging.From(ctx).Warnf("'%s' is locked by %s, the copy would fail\n", opts.DestinationDomain, lock)
+	}
+}
+
+func sourceZone(ctx context.Context, src *RouteCopy, opts CopyOptions) (rtypes.HostedZone, error) {
+	if opts.SourceZoneID != "" {
+		return zoneByID(ctx, src, opts.SourceZoneID, opts.Domain)
+	}
+	return src.GetHostedZone(ctx, opts.Domain, WithPrivateZone(opts.Private))
+}
+
+// zoneByID returns the zone with the given id, or a ZoneNameMismatch when
+// it is not a zone of domain.
+func zoneByID(ctx context.Context, r *RouteCopy, zoneID, domain string) (rtypes.HostedZone, error) {
+	zone, err := r.GetHostedZoneByID(ctx, zoneID)
+	if err != nil {
+		return zone, err
+	}
+	if !sameDomain(aws.ToString(zone.Name), domain) {
+		return zone, &ZoneNameMismatch{ZoneID: shortZoneID(zoneID), Name: denormalizeDomain(aws.ToString(zone.Name)), Domain: denormalizeDomain(domain)}
+	}
+	return zone, nil
+}
+
+// destinationZone looks up the destination zone, creating it when create is
+// set and the zone does not exist, unless opts.IntoParent is. It reports
+// whether the zone was created. A private source zone is never copied into a
+// new public zone, and an existing zone must use opts.DelegationSetID when
+// it is set.
+func destinationZone(ctx context.Context, src, dst *RouteCopy, opts CopyOptions, srcZone rtypes.HostedZone, create bool) (rtypes.HostedZone, bool, error) {
+	if opts.DestinationZoneID != "" {
+		zone, err := zoneByID(ctx, dst, opts.DestinationZoneID, opts.DestinationDomain)
+		if err == nil && opts.DelegationSetID != "" {
+			err = dst.checkDelegationSet(ctx, aws.ToString(zone.Id), opts.DestinationDomain, opts.DelegationSetID)
+		}
+		return zone, false, err
+	}
+	if opts.IntoParent {
+		zone, err := dst.FindEnclosingZone(ctx, opts.DestinationDomain, WithPrivateZone(opts.Private))
+		if err == nil {
+			logging.From(ctx).Infof("Copying the records of '%s' into the destination zone '%s'\n", opts.DestinationDomain, aws.ToString(zone.Name))
+		}
+		return zone, false, err
+	}
+	zone, err := dst.GetHostedZone(ctx, opts.DestinationDomain, WithPrivateZone(opts.Private))
+	if err == nil && opts.DelegationSetID != "" {
+		err = dst.checkDelegationSet(ctx, aws.ToString(zone.Id), opts.DestinationDomain, opts.DelegationSetID)
+	}
+	var e *HostedZoneNotFound
+	if !create || !errors.As(err, &e) {
+		return zone, false, err
+	}
+
+	if isPrivateZone(srcZone) && !opts.Private {
+		return zone, false, fmt.Errorf("the source zone of '%s' is private, use --private and --vpc to create a private destination zone", opts.Domain)
+	}
+	if opts.Private && opts.VPCID == "" {
+		vpcs, err := src.ListVPCAssociations(ctx, aws.ToString(srcZone.Id))
+		if err != nil {
+			return zone, false, err
+		}
+		if len(vpcs) == 0 {
+			return zone, false, fmt.Errorf("creating the private zone '%s' requires a VPC, use --vpc", opts.DestinationDomain)
+		}
+		return zone, false, fmt.Errorf("creating the private zone '%s' requires a VPC, use %s for the VPCs of the source zone",
+			opts.DestinationDomain, vpcFlags(vpcs))
+	}
+	logging.From(ctx).Infof("Destination profile does not contain %s, creating it\n", DisplayDomain(opts.DestinationDomain))
+	zone, err = dst.CreateZone(ctx, opts.DestinationDomain,
+		WithPrivateZone(opts.Private),
+		WithVPC(opts.VPCID, opts.VPCRegion),
+		WithComment(copiedZoneComment(src, srcZone, opts, time.Now())),
+		WithDelegationSet(opts.DelegationSetID),
+		WithWaitTimeout(opts.MaxWait),
+	)
+	return zone, err == nil, err
+}
+
+// cleanupCreatedZone deletes a zone created by a copy that applied nothing
+// when opts.CleanupOnFailure agrees, so a rerun starts clean. It reports
+// whether the zone was deleted.
+func cleanupCreatedZone(ctx context.Context, dst *RouteCopy, zone rtypes.HostedZone, opts CopyOptions) bool {
+	zoneID := aws.ToString(zone.Id)
+	if opts.CleanupOnFailure == nil {
+		logging.From(ctx).Infof("Zone %s was created by this run and is still empty\n", zoneID)
+		return false
+	}
+	ok, err := opts.CleanupOnFailure(ctx, zone)
+	if err != nil || !ok {
+		logging.From(ctx).Infof("Keeping zone %s created by this run\n", zoneID)
+		return false
+	}
+
+	changeID, err := dst.DeleteHostedZone(ctx, zoneID)
+	if err == nil {
+		err = dst.WaitForChange(ctx, changeID, opts.MaxWait)
+	}
+	if err != nil {
+		logging.From(ctx).Errorf("failed to delete zone %s created by this run: %s\n", zoneID, err)
+		return false
+	}
+	logging.From(ctx).Summaryf("Deleted zone %s created by this run\n", zoneID)
+	return true
+}
+
+// sourceDNSSEC looks up whether the source zone is signed and warns that the
+// copy is not. Failing to look it up, such as without the route53:GetDNSSEC
+// permission, only logs a warning.
+func sourceDNSSEC(ctx context.Context, src *RouteCopy, zoneID string, opts CopyOptions) DNSSEC {
+	d, err := src.GetDNSSEC(ctx, zoneID)
+	if err != nil {
+		logging.From(ctx).Warnf("Could not check whether '%s' is signed with DNSSEC: %s\n", opts.Domain, err)
+		return d
+	}
+	if d.Signing() && !opts.EnableDNSSEC {
+		logging.From(ctx).Warnf("'%s' is signed with DNSSEC but the copy will not be. Switching the nameservers breaks the domain "+
+			"until the DS record at the registrar matches the destination, see --enable-dnssec\n", opts.Domain)
+	}
+	return d
+}
+
+// destinationDNSSEC signs the destination zone when opts.EnableDNSSEC is set
+// and returns its signing status, logging the DS records to publish at the
+// registrar.
+func destinationDNSSEC(ctx context.Context, dst *RouteCopy, zoneID string, opts CopyOptions) (DNSSEC, error) {
+	var d DNSSEC
+	var err error
+	if opts.EnableDNSSEC {
+		logging.From(ctx).Infof("Enabling DNSSEC signing for '%s'\n", opts.DestinationDomain)
+		d, err = dst.EnableDNSSEC(ctx, zoneID, opts.KMSKeyARN, opts.MaxWait)
+	} else {
+		d, err = dst.GetDNSSEC(ctx, zoneID)
+	}
+	if err != nil {
+		return d, err
+	}
+	if !d.Signing() {
+		logging.From(ctx).Warnf("'%s' is not signed with DNSSEC in %s\n", opts.DestinationDomain, dst.profile)
+		return d, nil
+	}
+	for _, ds := range d.DSRecords {
+		logging.From(ctx).Summaryf("Publish this DS record for '%s' at the registrar: %s\n", DisplayDomain(opts.DestinationDomain), ds)
+	}
+	return d, nil
+}
+
+func zoneComment(zone rtypes.HostedZone) string {
+	if zone.Config == nil {
+		return ""
+	}
+	return aws.ToString(zone.Config.Comment)
+}
+
+// copiedZoneComment returns opts.ZoneComment, or the CopiedZoneComment of
+// srcZone on date.
+func copiedZoneComment(src *RouteCopy, srcZone rtypes.HostedZone, opts CopyOptions, date time.Time) string {
+	if opts.ZoneComment != "" {
+		return opts.ZoneComment
+	}
+	return CopiedZoneComment(zoneComment(srcZone), src.profile, date)
+}
+
+// syncComment copies the comment of srcZone to an existing dstZone. A
+// comment copied on another day is left as it is.
+func syncComment(ctx context.Context, src, dst *RouteCopy, srcZone, dstZone rtypes.HostedZone, opts CopyOptions) error {
+	date := time.Now()
+	if m := copiedZoneCommentDate.FindStringSubmatch(zoneComment(dstZone)); m != nil {
+		if copied, err := time.Parse("2006-01-02", m[1]); err == nil {
+			date = copied
+		}
+	}
+	if zoneComment(dstZone) == copiedZoneComment(src, srcZone, opts, date) {
+		return nil
+	}
+	comment := copiedZoneComment(src, srcZone, opts, time.Now())
+	err := dst.UpdateZoneComment(ctx, aws.ToString(dstZone.Id), comment)
+	if err != nil {
+		return err
+	}
+	logging.From(ctx).Infof("Updated the comment of '%s' to %q\n", aws.ToString(dstZone.Name), comment)
+	return nil
+}
+
+func skipUnresolvableAliases(ctx context.Context, src *RouteCopy, srcZoneID string, changes []rtypes.Change) ([]rtypes.Change, []ExcludedRecord, error) {
+	zones, err := src.HostedZoneIDs(ctx)
+	if err != nil {
+		return changes, nil, err
+	}
+	changes, dropped := RemoveUnresolvableAliases(changes, srcZoneID, zones)
+	for _, c := range dropped {
+		logging.From(ctx).Warnf("Skipping alias %s %s to zone %s of the source account\n",
+			DecodeName(aws.ToString(c.ResourceRecordSet.Name)), c.ResourceRecordSet.Type,
+			aws.ToString(c.ResourceRecordSet.AliasTarget.HostedZoneId))
+	}
+	return changes, excludedAliases(dropped, "aliases a zone of the source account"), nil
+}
+
+// dealiasUnresolvableAliases replaces the aliases RemoveUnresolvableAliases
+// drops with the records DealiasChanges resolves them to.
+func dealiasUnresolvableAliases(ctx context.Context, src *RouteCopy, srcZoneID string, changes []rtypes.Change, opts DealiasOptions) ([]rtypes.Change, []DealiasedRecord, []ExcludedRecord, error) {
+	zones, err := src.HostedZoneIDs(ctx)
+	if err != nil {
+		return changes, nil, nil, err
+	}
+	changes, aliases := RemoveUnresolvableAliases(changes, srcZoneID, zones)
+	if len(aliases) == 0 {
+		return changes, nil, nil, nil
+	}
+	resolved, dealiased, skipped := DealiasChanges(ctx, aliases, opts)
+	logging.From(ctx).Infof("Replaced %d aliases with resolved records, skipped %d\n", len(dealiased), len(skipped))
+	return append(changes, resolved...), dealiased, excludedAliases(skipped, "its target did not resolve"), nil
+}
+
+// excludedAliases returns the record sets of the alias changes dropped
+// from a copy.
+func excludedAliases(changes []rtypes.Change, reason string) []ExcludedRecord {
+	excluded := []ExcludedRecord{}
+	for _, c := range changes {
+		excluded = append(excluded, ExcludedRecord{Record: *c.ResourceRecordSet, Cause: ExcludedAlias, Reason: reason})
+	}
+	return excluded
+}
+
+func copyHealthChecks(ctx context.Context, src, dst *RouteCopy, changes []rtypes.Change, dryRun bool) ([]rtypes.Change, error) {
+	ids := HealthCheckIDs(changes)
+	if len(ids) == 0 {
+		logging.From(ctx).Infoln("No health checks referenced by the records to copy")
+		return changes, nil
+	}
+	if dryRun {
+		logging.From(ctx).Infof("Not copying %d health checks to %s since this is a dry run\n", len(ids), dst.profile)
+		return changes, nil
+	}
+	copier := NewHealthCheckCopier(src, dst)
+	err := copier.Copy(ctx, ids)
+	if err != nil {
+		return changes, err
+	}
+	return RewriteHealthCheckIDs(changes, copier.IDs), nil
+}
+
+// analyzeChanges looks up what AnalyzeChangesWithOptions needs to know about
+// the accounts and logs the warnings.
+func analyzeChanges(ctx context.Context, src, dst *RouteCopy, srcZoneID string, changes []rtypes.Change, opts CopyOptions) ([]Warning, error) {
+	accountID, err := src.GetAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	analyze := AnalyzeOptions{
+		SourceAccountID: accountID,
+		SourceZoneID:    srcZoneID,
+	}
+	for _, c := range changes {
+		alias := c.ResourceRecordSet.AliasTarget
+		if alias != nil && shortZoneID(aws.ToString(alias.HostedZoneId)) != shortZoneID(srcZoneID) {
+			analyze.SourceZones, err = src.HostedZoneIDs(ctx)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	// Copied health checks are created in the destination, even if a dry
+	// run did not rewrite their ids yet.
+	if !opts.CopyHealthChecks && len(HealthCheckIDs(changes)) > 0 {
+		analyze.DestinationHealthChecks, err = dst.HealthCheckIDSet(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.CopyCidrCollections && len(CidrCollectionIDs(changes)) > 0 {
+		collections, err := dst.cidrCollections(ctx)
+		if err != nil {
+			return nil, err
+		}
+		analyze.DestinationCidrCollections = map[string]bool{}
+		for id := range collections {
+			analyze.DestinationCidrCollections[id] = true
+		}
+	}
+
+	warnings := AnalyzeChangesWithOptions(changes, analyze)
+	for _, w := range warnings {
+		logging.From(ctx).Warnf("%s %s: %s\n", DecodeName(aws.ToString(w.Record.Name)), w.Record.Type, w.Reason)
+	}
+	return warnings, nil
+}
+
+func copyCidrCollections(ctx context.Context, src, dst *RouteCopy, changes []rtypes.Change, dryRun bool) ([]rtypes.Change, error) {
+	ids := CidrCollectionIDs(changes)
+	if len(ids) == 0 {
+		return changes, nil
+	}
+	if dryRun {
+		logging.From(ctx).Infof("Not copying %d CIDR collections to %s since this is a dry run\n", len(ids), dst.profile)
+		return changes, nil
+	}
+	copied, err := dst.CopyCidrCollections(ctx, src, ids)
+	if err != nil {
+		return changes, err
+	}
+	return RewriteCidrCollectionIDs(changes, copied), nil
+}
+
+func verifyCopy(ctx context.Context, dst *RouteCopy, zone rtypes.HostedZone, opts CopyOptions, changes []rtypes.Change) (Verification, error) {
+	logging.From(ctx).Infoln("Verifying destination records")
+	records, err := dst.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return Verification{}, err
+	}
+	v := VerifyChanges(changes, records)
+
+	if opts.VerifyDNS {
+		// With IntoParent the records are served by the enclosing zone.
+		nameservers := ZoneNameservers(aws.ToString(zone.Name), records)
+		intended := []rtypes.ResourceRecordSet{}
+		for _, c := range changes {
+			if c.Action != rtypes.ChangeActionDelete {
+				intended = append(intended, *c.ResourceRecordSet)
+			}
+		}
+		v.DNSMismatches = VerifyDNS(ctx, nameservers, intended, verifyDNSSample)
+	}
+	return v, nil
+}
+
+func typesToString(types []rtypes.RRType) string {
+	str := []string{}
+	for _, t := range types {
+		str = append(str, string(t))
+	}
+	return strings.Join(str, ",")
+}
+
+func logAppliedChanges(ctx context.Context, changes []rtypes.Change) {
+	if len(changes) == 0 {
+		logging.From(ctx).Infoln("No records were applied before the failure")
+		return
+	}
+	logging.From(ctx).Infof("%d records were applied before the failure:\n", len(changes))
+	for _, c := range changes {
+		logging.From(ctx).Infof("  %s %s\n", DecodeName(aws.ToString(c.ResourceRecordSet.Name)), c.ResourceRecordSet.Type)
+	}
+}
+
+func logSubstitutions(ctx context.Context, substituted []SubstitutedRecord) {
+	for _, s := range substituted {
+		from := strings.ReplaceAll(recordValues(s.From), "\n", ", ")
+		to := strings.ReplaceAll(recordValues(s.To), "\n", ", ")
+		logging.From(ctx).Infof("  %s %s: %s -> %s\n", DecodeName(aws.ToString(s.From.Name)), s.From.Type, from, to)
+	}
+}
+
+func logTTLChanges(ctx context.Context, ttlChanges []TTLChange) {
+	for _, c := range ttlChanges {
+		logging.From(ctx).Infof("  %s %s: TTL %d -> %d\n", DecodeName(aws.ToString(c.Record.Name)), c.Record.Type, c.Original, aws.ToInt64(c.Record.TTL))
+	}
+}
+
+func logRenamedChanges(ctx context.Context, changes []rtypes.Change, from, to string) {
+	logging.From(ctx).Infof("Records will be renamed from '%s' to '%s':\n", from, to)
+	for _, c := range changes {
+		name := aws.ToString(c.ResourceRecordSet.Name)
+		logging.From(ctx).Infof("  %s -> %s (%s)\n", RewriteDomainName(name, to, from), name, c.ResourceRecordSet.Type)
+	}
+}
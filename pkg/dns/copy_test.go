@@ -0,0 +1,206 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// notCopiedFields are the fields of a ResourceRecordSet that
+// recordSetChange leaves out on purpose.
+var notCopiedFields = map[string]string{}
+
+// setField sets v, a field of a ResourceRecordSet, to a value other than
+// its zero value.
+func setField(t *testing.T, name string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		switch elem := v.Elem(); elem.Kind() {
+		case reflect.String:
+			elem.SetString(name)
+		case reflect.Int64:
+			elem.SetInt(1)
+		case reflect.Bool:
+			elem.SetBool(true)
+		}
+	case reflect.String:
+		v.SetString(name)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+	default:
+		t.Fatalf("cannot set %s of kind %s, add it to setField", name, v.Kind())
+	}
+}
+
+func TestRecordSetChangeCopiesEveryField(t *testing.T) {
+	rs := rtypes.ResourceRecordSet{}
+	v := reflect.ValueOf(&rs).Elem()
+	fields := reflect.TypeOf(rs)
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).IsExported() {
+			setField(t, fields.Field(i).Name, v.Field(i))
+		}
+	}
+
+	change := recordSetChange(rtypes.ChangeActionUpsert, rs)
+	copied := reflect.ValueOf(change.ResourceRecordSet).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if reason, ok := notCopiedFields[field.Name]; ok {
+			if !copied.Field(i).IsZero() {
+				t.Errorf("%s is copied, but is listed as not copied: %s", field.Name, reason)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(copied.Field(i).Interface(), v.Field(i).Interface()) {
+			t.Errorf("%s is not copied by recordSetChange, copy it or add it to notCopiedFields", field.Name)
+		}
+	}
+}
+
+func TestCopyZoneDelegationSet(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.SetComment(srcZoneID, "Zone of the team")
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 3)...)
+	delegationSetID := dstServer.AddDelegationSet()
+	opts := CopyOptions{Domain: "example.com", DelegationSetID: delegationSetID}
+
+	result, err := CopyZone(ctx, src, dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.CreatedZone {
+		t.Fatal("the destination zone was not created")
+	}
+	zoneID := aws.ToString(result.DestinationZone.Id)
+	if actual, err := dst.ZoneDelegationSetID(ctx, zoneID); err != nil || actual != delegationSetID {
+		t.Errorf("the zone uses delegation set %q (%v), want %s", actual, err, delegationSetID)
+	}
+	comment := aws.ToString(result.DestinationZone.Config.Comment)
+	if !strings.HasPrefix(comment, "Zone of the team (copied from source by route53copy on ") {
+		t.Errorf("got comment %q, want the source comment with a provenance note", comment)
+	}
+
+	// A copy into the zone with the same delegation set goes ahead, and one
+	// expecting another delegation set is refused.
+	if _, err := CopyZone(ctx, src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.DelegationSetID = dstServer.AddDelegationSet()
+	_, err = CopyZone(ctx, src, dst, opts)
+	var mismatch *DelegationSetMismatch
+	if !errors.As(err, &mismatch) || mismatch.Actual != delegationSetID {
+		t.Errorf("got %v, want a DelegationSetMismatch", err)
+	}
+}
+
+func TestCopyZoneSyncComment(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.SetComment(srcZoneID, "Zone of the team")
+	dstZoneID := dstServer.AddZone("example.com", false)
+	dstServer.SetComment(dstZoneID, "Old comment")
+
+	for _, sync := range []bool{false, true} {
+		_, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", SyncComment: sync})
+		if err != nil {
+			t.Fatal(err)
+		}
+		zone, err := dst.GetHostedZone(ctx, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		comment := aws.ToString(zone.Config.Comment)
+		if synced := strings.HasPrefix(comment, "Zone of the team (copied from source"); synced != sync {
+			t.Errorf("with SyncComment %t, got comment %q", sync, comment)
+		}
+	}
+}
+
+func TestCopyZoneNames(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID,
+		recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet(`\052.api.example.com.`, rtypes.RRTypeA, "192.0.2.2"),
+		recordSet("v1.api.example.com.", rtypes.RRTypeTxt, `"v=1"`),
+		recordSet("myapi.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.4"),
+	)
+	dstZoneID := dstServer.AddZone("example.com", false)
+
+	result, err := CopyZone(ctx, src, dst, CopyOptions{
+		Domain: "example.com",
+		Names:  []string{"api.example.com"},
+		Types:  []rtypes.RRType{rtypes.RRTypeA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := []rtypes.ResourceRecordSet{}
+	for _, c := range result.Changes {
+		copied = append(copied, *c.ResourceRecordSet)
+	}
+	if got := recordNames(copied); got != `api.example.com. A, \052.api.example.com. A` {
+		t.Errorf("copied %s, want the A records under api.example.com", got)
+	}
+	for _, name := range []string{"v1.api.example.com.", "myapi.example.com.", "www.example.com."} {
+		if _, ok := findRecord(dstServer.Records(dstZoneID), name); ok {
+			t.Errorf("%s was copied", name)
+		}
+	}
+}
+
+func TestCopyZoneIntoParent(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("internal.example.com", false)
+	srcServer.AddRecords(srcZoneID,
+		recordSet("internal.example.com.", rtypes.RRTypeTxt, `"v=1"`),
+		recordSet("db.internal.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet("api.internal.example.com.", rtypes.RRTypeCname, "db.internal.example.com."),
+	)
+	parentID := dstServer.AddZone("example.com", false)
+	parentApex := dstServer.Records(parentID)
+
+	result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "internal.example.com", IntoParent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CreatedZone || len(dstServer.FindZone("internal.example.com")) > 0 {
+		t.Fatal("a zone was created for internal.example.com")
+	}
+	if got := aws.ToString(result.DestinationZone.Id); got != "/hostedzone/"+parentID {
+		t.Errorf("copied into %s, want the zone of example.com", got)
+	}
+	records := dstServer.Records(parentID)
+	want := `example.com. NS, example.com. SOA, internal.example.com. TXT, api.internal.example.com. CNAME, db.internal.example.com. A`
+	if got := recordNames(records); got != want {
+		t.Errorf("the parent zone has %s, want %s", got, want)
+	}
+	// The apex NS and SOA of the source are not copied.
+	apex, _ := findRecord(records, "example.com.")
+	if !reflect.DeepEqual(apex, parentApex[0]) {
+		t.Errorf("the apex NS of the parent changed to %+v", apex)
+	}
+
+	// Without a zone enclosing the domain, none is created.
+	_, err = CopyZone(ctx, src, dst, CopyOptions{Domain: "internal.example.com", DestinationDomain: "internal.example.net", IntoParent: true})
+	var notFound *HostedZoneNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("got %v, want HostedZoneNotFound", err)
+	}
+}
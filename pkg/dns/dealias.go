@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// DefaultDealiasTTL is the TTL of the records replacing aliases when none is
+// given.
+const DefaultDealiasTTL = 300
+
+// DefaultDealiasTimeout bounds resolving the target of each alias when no
+// timeout is given.
+const DefaultDealiasTimeout = 5 * time.Second
+
+// DealiasOptions are the options used by DealiasChanges.
+type DealiasOptions struct {
+	// TTL of the records replacing the aliases. Defaults to
+	// DefaultDealiasTTL.
+	TTL int64
+	// Timeout bounds resolving each alias target. Defaults to
+	// DefaultDealiasTimeout.
+	Timeout time.Duration
+	// Resolver is the host:port of the DNS server to query, see
+	// LookupOptions.
+	Resolver string
+}
+
+// DealiasedRecord is an alias replaced by DealiasChanges, with the values
+// its target resolved to.
+type DealiasedRecord struct {
+	Alias  rtypes.ResourceRecordSet
+	Record rtypes.ResourceRecordSet
+}
+
+// DealiasChanges resolves the targets of the aliases in changes and replaces
+// each alias with a record of the same type holding the answers. The alias
+// of an A record becomes an A record with the addresses of its target, and
+// likewise for AAAA; CNAMEs followed by the resolver are flattened. Aliases
+// whose target resolves to nothing, or cannot be resolved, are dropped and
+// returned as skipped.
+func DealiasChanges(ctx context.Context, changes []rtypes.Change, opts DealiasOptions) ([]rtypes.Change, []DealiasedRecord, []rtypes.Change) {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultDealiasTTL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultDealiasTimeout
+	}
+	servers := resolvers(LookupOptions{Resolver: opts.Resolver})
+	c := &dns.Client{Timeout: opts.Timeout}
+
+	kept := []rtypes.Change{}
+	dealiased := []DealiasedRecord{}
+	skipped := []rtypes.Change{}
+	for _, change := range changes {
+		rs := change.ResourceRecordSet
+		if rs.AliasTarget == nil {
+			kept = append(kept, change)
+			continue
+		}
+		target := DecodeName(aws.ToString(rs.AliasTarget.DNSName))
+		values, err := resolveTarget(ctx, c, servers, target, rs.Type, opts.Timeout)
+		if err != nil || len(values) == 0 {
+			if err == nil {
+				err = fmt.Errorf("no %s records found", rs.Type)
+			}
+			logging.From(ctx).Warnf("Skipping alias %s %s, its target %s did not resolve: %s\n",
+				DecodeName(aws.ToString(rs.Name)), rs.Type, target, err)
+			skipped = append(skipped, change)
+			continue
+		}
+
+		record := *rs
+		record.AliasTarget = nil
+		record.TTL = aws.Int64(opts.TTL)
+		record.ResourceRecords = []rtypes.ResourceRecord{}
+		for _, v := range values {
+			record.ResourceRecords = append(record.ResourceRecords, rtypes.ResourceRecord{Value: aws.String(v)})
+		}
+		logging.From(ctx).Infof("Replacing alias %s %s to %s with %s\n",
+			DecodeName(aws.ToString(rs.Name)), rs.Type, target, strings.Join(values, ", "))
+		change.ResourceRecordSet = &record
+		kept = append(kept, change)
+		dealiased = append(dealiased, DealiasedRecord{Alias: *rs, Record: record})
+	}
+	return kept, dealiased, skipped
+}
+
+// resolveTarget asks the resolvers for the records of type rrType of target,
+// trying the next resolver when one fails. Only the answers of rrType are
+// returned, so the CNAMEs leading to them are left out.
+func resolveTarget(ctx context.Context, c *dns.Client, servers []string, target string, rrType rtypes.RRType, timeout time.Duration) ([]string, error) {
+	qtype, ok := dns.StringToType[string(rrType)]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %s", rrType)
+	}
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(target), qtype)
+	m.RecursionDesired = true
+
+	var err error
+	for _, server := range servers {
+		var values []string
+		values, err = queryAnswers(ctx, c, server, m, qtype, timeout)
+		if err == nil {
+			return values, nil
+		}
+		logging.From(ctx).Debugf("Resolver %s failed to resolve %s %s: %s\n", server, target, rrType, err)
+	}
+	return nil, err
+}
+
+func queryAnswers(ctx context.Context, c *dns.Client, server string, m *dns.Msg, qtype uint16, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r, _, err := c.ExchangeContext(ctx, m, server)
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode == dns.RcodeNameError {
+		return nil, nil
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("query returned %s", dns.RcodeToString[r.Rcode])
+	}
+
+	values := []string{}
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		values = append(values, answerValue(rr))
+	}
+	sort.Strings(values)
+	return values, nil
+}
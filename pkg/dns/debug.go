@@ -0,0 +1,27 @@
+package dns
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// addDebugMiddleware logs every API call, including retries, at debug level.
+func addDebugMiddleware(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("DebugLog",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if !logging.Enabled(logging.LevelDebug) {
+				return next.HandleFinalize(ctx, in)
+			}
+			service := awsmiddleware.GetServiceID(ctx)
+			operation := awsmiddleware.GetOperationName(ctx)
+			logging.From(ctx).Debugf("%s %s\n", service, operation)
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if err != nil {
+				logging.From(ctx).Debugf("%s %s failed: %s\n", service, operation, err)
+			}
+			return out, metadata, err
+		}), middleware.After)
+}
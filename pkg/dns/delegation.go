@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	rdtypes "github.com/aws/aws-sdk-go-v2/service/route53domains/types"
+	smithytime "github.com/aws/smithy-go/time"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// delegationPollInterval is how often WaitForDelegation queries the parent
+// zone.
+const delegationPollInterval = 30 * time.Second
+
+// DelegationTimeout is returned by WaitForDelegation when the parent zone
+// still delegates the domain to other nameservers.
+type DelegationTimeout struct {
+	Domain string
+	Served []rdtypes.Nameserver
+	Waited time.Duration
+}
+
+func (e *DelegationTimeout) Error() string {
+	served := []string{}
+	for _, ns := range e.Served {
+		served = append(served, aws.ToString(ns.Name))
+	}
+	return fmt.Sprintf("the parent zone still delegates %s to %s after %s", e.Domain, strings.Join(served, ","), e.Waited)
+}
+
+// WaitForDelegation queries the parent zone of domain until it delegates to
+// exactly the nameservers in nsRecords, for up to maxWait.
+func WaitForDelegation(ctx context.Context, domain string, nsRecords rtypes.ResourceRecordSet, maxWait time.Duration) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	var served []rdtypes.Nameserver
+	for {
+		ns, err := GetDelegationFor(domain)
+		if err != nil {
+			logging.From(ctx).Infof("Failed to query the delegation of %s: %s\n", domain, err)
+		} else if sameNameservers(ns, nsRecords) {
+			logging.From(ctx).Infof("The parent zone delegates %s to %s\n", domain, nsToNames(ns))
+			return nil
+		} else if nsToNames(ns) != nsToNames(served) {
+			logging.From(ctx).Infof("The parent zone still delegates %s to %s\n", domain, nsToNames(ns))
+			served = ns
+		}
+
+		if err := smithytime.SleepWithContext(ctx, delegationPollInterval); err != nil {
+			return &DelegationTimeout{Domain: domain, Served: served, Waited: time.Since(start).Round(time.Second)}
+		}
+	}
+}
+
+// sameNameservers reports whether ns are exactly the nameservers of
+// nsRecords, ignoring order, case and trailing dots.
+func sameNameservers(ns []rdtypes.Nameserver, nsRecords rtypes.ResourceRecordSet) bool {
+	if len(ns) != len(nsRecords.ResourceRecords) {
+		return false
+	}
+	names := map[string]bool{}
+	for _, n := range ns {
+		names[strings.ToLower(denormalizeDomain(aws.ToString(n.Name)))] = true
+	}
+	for _, r := range nsRecords.ResourceRecords {
+		if !names[strings.ToLower(denormalizeDomain(aws.ToString(r.Value)))] {
+			return false
+		}
+	}
+	return true
+}
+
+func nsToNames(ns []rdtypes.Nameserver) string {
+	names := []string{}
+	for _, n := range ns {
+		names = append(names, aws.ToString(n.Name))
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,93 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+)
+
+// DelegationSetInfo is a reusable delegation set of an account.
+type DelegationSetInfo struct {
+	ID              string   `json:"id"`
+	CallerReference string   `json:"caller_reference,omitempty"`
+	NameServers     []string `json:"name_servers"`
+}
+
+// DelegationSetMismatch is returned when the existing destination zone does
+// not use the reusable delegation set given, see
+// CopyOptions.DelegationSetID.
+type DelegationSetMismatch struct {
+	Zone     string
+	Expected string
+	// Actual is the delegation set of the zone, empty when it does not use
+	// a reusable one.
+	Actual string
+}
+
+func (e *DelegationSetMismatch) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("the destination zone '%s' does not use a reusable delegation set, not %s", e.Zone, e.Expected)
+	}
+	return fmt.Sprintf("the destination zone '%s' uses the delegation set %s, not %s", e.Zone, e.Actual, e.Expected)
+}
+
+// GetReusableDelegationSets returns the reusable delegation sets of the
+// account.
+func (r *RouteCopy) GetReusableDelegationSets(ctx context.Context) ([]DelegationSetInfo, error) {
+	sets := []DelegationSetInfo{}
+	input := &route53.ListReusableDelegationSetsInput{}
+	for {
+		resp, err := r.cli.ListReusableDelegationSets(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list reusable delegation sets: %w", err)
+		}
+		for _, set := range resp.DelegationSets {
+			sets = append(sets, DelegationSetInfo{
+				ID:              shortDelegationSetID(aws.ToString(set.Id)),
+				CallerReference: aws.ToString(set.CallerReference),
+				NameServers:     set.NameServers,
+			})
+		}
+		if !resp.IsTruncated {
+			return sets, nil
+		}
+		input.Marker = resp.NextMarker
+	}
+}
+
+// ZoneDelegationSetID returns the id of the reusable delegation set the zone
+// uses, or "" when it does not use one.
+func (r *RouteCopy) ZoneDelegationSetID(ctx context.Context, zoneId string) (string, error) {
+	resp, err := r.cli.GetHostedZone(ctx, &route53.GetHostedZoneInput{
+		Id: aws.String(zoneId),
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.DelegationSet == nil {
+		return "", nil
+	}
+	return shortDelegationSetID(aws.ToString(resp.DelegationSet.Id)), nil
+}
+
+// checkDelegationSet returns a DelegationSetMismatch when the zone does not
+// use the reusable delegation set id.
+func (r *RouteCopy) checkDelegationSet(ctx context.Context, zoneId, zoneName, id string) error {
+	actual, err := r.ZoneDelegationSetID(ctx, zoneId)
+	if err != nil {
+		return err
+	}
+	if actual != shortDelegationSetID(id) {
+		return &DelegationSetMismatch{Zone: DisplayDomain(zoneName), Expected: shortDelegationSetID(id), Actual: actual}
+	}
+	return nil
+}
+
+// shortDelegationSetID strips the "/delegationset/" prefix Route53 may add
+// to delegation set ids.
+func shortDelegationSetID(id string) string {
+	return strings.TrimPrefix(id, "/delegationset/")
+}
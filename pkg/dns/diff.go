@@ -0,0 +1,227 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Diff holds the differences between a source and a destination list of
+// record sets.
+type Diff struct {
+	// Create holds record sets only present in the source.
+	Create []rtypes.ResourceRecordSet
+	// Update holds record sets present in both but with different contents.
+	Update []RecordSetUpdate
+	// Delete holds record sets only present in the destination.
+	Delete []rtypes.ResourceRecordSet
+}
+
+// RecordSetUpdate pairs a destination record set with the source record set
+// that should replace it.
+type RecordSetUpdate struct {
+	From rtypes.ResourceRecordSet
+	To   rtypes.ResourceRecordSet
+}
+
+// ZonesDiffer is returned when two zones compared with DiffRecordSets are
+// expected to match but do not.
+type ZonesDiffer struct {
+	Diff Diff
+}
+
+func (e *ZonesDiffer) Error() string {
+	return fmt.Sprintf("zones differ: %d records only in the first, %d only in the second, %d different",
+		len(e.Diff.Create), len(e.Diff.Delete), len(e.Diff.Update))
+}
+
+// Empty reports whether the diff contains no differences.
+func (d Diff) Empty() bool {
+	return len(d.Create) == 0 && len(d.Update) == 0 && len(d.Delete) == 0
+}
+
+// Changes returns the changes needed to make the destination match the
+// source. Record sets only present in the destination are deleted only when
+// prune is set.
+func (d Diff) Changes(prune bool) []rtypes.Change {
+	changes := []rtypes.Change{}
+	if prune {
+		for _, rs := range d.Delete {
+			changes = append(changes, recordSetChange(rtypes.ChangeActionDelete, rs))
+		}
+	}
+	for _, rs := range d.Create {
+		changes = append(changes, recordSetChange(rtypes.ChangeActionUpsert, rs))
+	}
+	for _, u := range d.Update {
+		changes = append(changes, recordSetChange(rtypes.ChangeActionUpsert, u.To))
+	}
+	return changes
+}
+
+// DiffRecordSets compares src and dst record sets, matching them by name,
+// type and set identifier.
+func DiffRecordSets(src, dst []rtypes.ResourceRecordSet) Diff {
+	diff := Diff{}
+	dstByKey := map[string]rtypes.ResourceRecordSet{}
+	for _, rs := range dst {
+		dstByKey[recordSetKey(rs)] = rs
+	}
+
+	seen := map[string]bool{}
+	for _, rs := range src {
+		key := recordSetKey(rs)
+		seen[key] = true
+		existing, ok := dstByKey[key]
+		if !ok {
+			diff.Create = append(diff.Create, rs)
+			continue
+		}
+		if !CompareRecordSets(rs, existing) {
+			diff.Update = append(diff.Update, RecordSetUpdate{From: existing, To: rs})
+		}
+	}
+
+	for _, rs := range dst {
+		if !seen[recordSetKey(rs)] {
+			diff.Delete = append(diff.Delete, rs)
+		}
+	}
+	return diff
+}
+
+// PreviewChanges compares the record sets changes would write with the
+// record sets already in the destination. Create holds the record sets that
+// are new and Update the ones that overwrite different existing values.
+// Changes that would leave a record set as is, and deletes, are left out.
+func PreviewChanges(changes []rtypes.Change, existing []rtypes.ResourceRecordSet) Diff {
+	intended := []rtypes.ResourceRecordSet{}
+	for _, c := range changes {
+		if c.Action != rtypes.ChangeActionDelete {
+			intended = append(intended, *c.ResourceRecordSet)
+		}
+	}
+	diff := DiffRecordSets(intended, existing)
+	diff.Delete = nil
+	return diff
+}
+
+// CompareRecordSets reports whether two record sets would serve the same
+// answers with the same routing policy. Value order and trailing dots on
+// names are ignored.
+func CompareRecordSets(a, b rtypes.ResourceRecordSet) bool {
+	return recordSetKey(a) == recordSetKey(b) && len(DifferentFields(a, b)) == 0
+}
+
+// DifferentFields returns the names of the fields that differ between two
+// record sets with the same name, type and set identifier, compared as
+// CompareRecordSets does.
+func DifferentFields(a, b rtypes.ResourceRecordSet) []string {
+	fields := []string{}
+	if aws.ToInt64(a.TTL) != aws.ToInt64(b.TTL) {
+		fields = append(fields, "ttl")
+	}
+	if aws.ToInt64(a.Weight) != aws.ToInt64(b.Weight) || (a.Weight == nil) != (b.Weight == nil) {
+		fields = append(fields, "weight")
+	}
+	if aws.ToBool(a.MultiValueAnswer) != aws.ToBool(b.MultiValueAnswer) {
+		fields = append(fields, "multivalue_answer")
+	}
+	if aws.ToString(a.HealthCheckId) != aws.ToString(b.HealthCheckId) {
+		fields = append(fields, "health_check_id")
+	}
+	if aws.ToString(a.TrafficPolicyInstanceId) != aws.ToString(b.TrafficPolicyInstanceId) {
+		fields = append(fields, "traffic_policy_instance_id")
+	}
+	if a.Failover != b.Failover {
+		fields = append(fields, "failover")
+	}
+	if a.Region != b.Region {
+		fields = append(fields, "region")
+	}
+	if !equalGeoLocation(a.GeoLocation, b.GeoLocation) {
+		fields = append(fields, "geolocation")
+	}
+	if !equalAliasTarget(a.AliasTarget, b.AliasTarget) {
+		fields = append(fields, "alias_target")
+	}
+	if !equalCidrRoutingConfig(a.CidrRoutingConfig, b.CidrRoutingConfig) {
+		fields = append(fields, "cidr_routing_config")
+	}
+	if !equalValues(a.Type, a.ResourceRecords, b.ResourceRecords) {
+		fields = append(fields, "values")
+	}
+	return fields
+}
+
+func recordSetKey(rs rtypes.ResourceRecordSet) string {
+	return strings.Join([]string{
+		strings.ToLower(normalizeDomain(DecodeName(aws.ToString(rs.Name)))),
+		string(rs.Type),
+		aws.ToString(rs.SetIdentifier),
+	}, "|")
+}
+
+func equalCidrRoutingConfig(a, b *rtypes.CidrRoutingConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return aws.ToString(a.CollectionId) == aws.ToString(b.CollectionId) &&
+		aws.ToString(a.LocationName) == aws.ToString(b.LocationName)
+}
+
+func equalGeoLocation(a, b *rtypes.GeoLocation) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return aws.ToString(a.ContinentCode) == aws.ToString(b.ContinentCode) &&
+		aws.ToString(a.CountryCode) == aws.ToString(b.CountryCode) &&
+		aws.ToString(a.SubdivisionCode) == aws.ToString(b.SubdivisionCode)
+}
+
+func equalAliasTarget(a, b *rtypes.AliasTarget) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return strings.EqualFold(normalizeDomain(aws.ToString(a.DNSName)), normalizeDomain(aws.ToString(b.DNSName))) &&
+		aws.ToString(a.HostedZoneId) == aws.ToString(b.HostedZoneId) &&
+		a.EvaluateTargetHealth == b.EvaluateTargetHealth
+}
+
+func equalValues(t rtypes.RRType, a, b []rtypes.ResourceRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	av := normalizedValues(t, a)
+	bv := normalizedValues(t, b)
+	for i := range av {
+		if av[i] != bv[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedValues(records []rtypes.ResourceRecord) []string {
+	values := []string{}
+	for _, r := range records {
+		values = append(values, aws.ToString(r.Value))
+	}
+	sort.Strings(values)
+	return values
+}
+
+// normalizedValues is sortedValues with the values normalized, see
+// normalizeValue, so quoting, escaping and trailing dot differences do not
+// count.
+func normalizedValues(t rtypes.RRType, records []rtypes.ResourceRecord) []string {
+	values := []string{}
+	for _, r := range records {
+		values = append(values, normalizeValue(t, aws.ToString(r.Value)))
+	}
+	sort.Strings(values)
+	return values
+}
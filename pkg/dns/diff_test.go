@@ -0,0 +1,337 @@
+package dns
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestCompareRecordSets(t *testing.T) {
+	weighted := func(id string, weight *int64) rtypes.ResourceRecordSet {
+		rs := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+		rs.SetIdentifier = aws.String(id)
+		rs.Weight = weight
+		return rs
+	}
+	alias := func(target string) rtypes.ResourceRecordSet {
+		return rtypes.ResourceRecordSet{
+			Name: aws.String("cdn.example.com."),
+			Type: rtypes.RRTypeA,
+			AliasTarget: &rtypes.AliasTarget{
+				DNSName:      aws.String(target),
+				HostedZoneId: aws.String("Z2FDTNDATAQYW2"),
+			},
+		}
+	}
+	tests := []struct {
+		name string
+		a, b rtypes.ResourceRecordSet
+		// fields are the fields DifferentFields reports, none when the
+		// record sets are equal.
+		fields []string
+	}{
+		{
+			name: "value order",
+			a:    recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1", "192.0.2.2"),
+			b:    recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.2", "192.0.2.1"),
+		},
+		{
+			name: "trailing dot on the name",
+			a:    recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			b:    recordSet("www.example.com", rtypes.RRTypeA, "192.0.2.1"),
+		},
+		{
+			name: "trailing dot and case of values",
+			a:    recordSet("mail.example.com.", rtypes.RRTypeMx, "10 MX1.example.com.", "20 mx2.example.com."),
+			b:    recordSet("mail.example.com.", rtypes.RRTypeMx, "20 mx2.example.com", "10 mx1.example.com"),
+		},
+		{
+			name: "octal escaped wildcard",
+			a:    recordSet(`\052.example.com.`, rtypes.RRTypeA, "192.0.2.1"),
+			b:    recordSet("*.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		},
+		{
+			name:   "different values",
+			a:      recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			b:      recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1", "192.0.2.2"),
+			fields: []string{"values"},
+		},
+		{
+			name:   "different TTL",
+			a:      recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			b:      rtypes.ResourceRecordSet{Name: aws.String("www.example.com."), Type: rtypes.RRTypeA, TTL: aws.Int64(60), ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String("192.0.2.1")}}},
+			fields: []string{"ttl"},
+		},
+		{
+			name:   "weight zero and no weight",
+			a:      weighted("blue", aws.Int64(0)),
+			b:      weighted("blue", nil),
+			fields: []string{"weight"},
+		},
+		{
+			name: "same weight",
+			a:    weighted("blue", aws.Int64(0)),
+			b:    weighted("blue", aws.Int64(0)),
+		},
+		{
+			name:   "different weight",
+			a:      weighted("blue", aws.Int64(10)),
+			b:      weighted("blue", aws.Int64(90)),
+			fields: []string{"weight"},
+		},
+		{
+			name: "alias target with and without the trailing dot",
+			a:    alias("d111111abcdef8.cloudfront.net."),
+			b:    alias("D111111ABCDEF8.cloudfront.net"),
+		},
+		{
+			name:   "different alias target",
+			a:      alias("d111111abcdef8.cloudfront.net."),
+			b:      alias("d222222abcdef8.cloudfront.net."),
+			fields: []string{"alias_target"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareRecordSets(tt.a, tt.b); got != (len(tt.fields) == 0) {
+				t.Errorf("CompareRecordSets = %t, want %t", got, len(tt.fields) == 0)
+			}
+			got := DifferentFields(tt.a, tt.b)
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("DifferentFields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestDifferentFields(t *testing.T) {
+	alias := func(zoneID, target string, evaluate bool) rtypes.ResourceRecordSet {
+		return rtypes.ResourceRecordSet{
+			Name: aws.String("example.com."),
+			Type: rtypes.RRTypeA,
+			AliasTarget: &rtypes.AliasTarget{
+				DNSName:              aws.String(target),
+				HostedZoneId:         aws.String(zoneID),
+				EvaluateTargetHealth: evaluate,
+			},
+		}
+	}
+	weighted := func(weight int64, healthCheck string) rtypes.ResourceRecordSet {
+		rs := recordSet("api.example.com.", rtypes.RRTypeCname, "blue.example.net.")
+		rs.SetIdentifier = aws.String("blue")
+		rs.Weight = aws.Int64(weight)
+		if healthCheck != "" {
+			rs.HealthCheckId = aws.String(healthCheck)
+		}
+		return rs
+	}
+	multivalue := func(ttl int64, values ...string) rtypes.ResourceRecordSet {
+		rs := recordSet("www.example.com.", rtypes.RRTypeA, values...)
+		rs.SetIdentifier = aws.String("one")
+		rs.MultiValueAnswer = aws.Bool(true)
+		rs.TTL = aws.Int64(ttl)
+		return rs
+	}
+	tests := []struct {
+		name string
+		a, b rtypes.ResourceRecordSet
+		want []string
+	}{
+		{
+			name: "same alias",
+			a:    alias("Z35SXDOTRQ7X7K", "my-lb-1.us-east-1.elb.amazonaws.com.", true),
+			b:    alias("Z35SXDOTRQ7X7K", "MY-LB-1.us-east-1.elb.amazonaws.com", true),
+			want: []string{},
+		},
+		{
+			name: "alias target",
+			a:    alias("Z35SXDOTRQ7X7K", "my-lb-1.us-east-1.elb.amazonaws.com.", true),
+			b:    alias("Z35SXDOTRQ7X7K", "my-lb-2.us-east-1.elb.amazonaws.com.", true),
+			want: []string{"alias_target"},
+		},
+		{
+			name: "alias evaluating the target health",
+			a:    alias("Z35SXDOTRQ7X7K", "my-lb-1.us-east-1.elb.amazonaws.com.", true),
+			b:    alias("Z35SXDOTRQ7X7K", "my-lb-1.us-east-1.elb.amazonaws.com.", false),
+			want: []string{"alias_target"},
+		},
+		{
+			name: "alias and values",
+			a:    alias("Z35SXDOTRQ7X7K", "my-lb-1.us-east-1.elb.amazonaws.com.", false),
+			b:    recordSet("example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			want: []string{"ttl", "alias_target", "values"},
+		},
+		{
+			name: "weight",
+			a:    weighted(90, ""),
+			b:    weighted(10, ""),
+			want: []string{"weight"},
+		},
+		{
+			name: "weight and health check",
+			a:    weighted(90, "hc-1"),
+			b:    weighted(10, "hc-2"),
+			want: []string{"weight", "health_check_id"},
+		},
+		{
+			name: "multivalue answer in another order",
+			a:    multivalue(60, "192.0.2.1", "192.0.2.2"),
+			b:    multivalue(60, "192.0.2.2", "192.0.2.1"),
+			want: []string{},
+		},
+		{
+			name: "multivalue answer values and TTL",
+			a:    multivalue(60, "192.0.2.1", "192.0.2.2"),
+			b:    multivalue(300, "192.0.2.1"),
+			want: []string{"ttl", "values"},
+		},
+		{
+			name: "multivalue answer flag",
+			a:    multivalue(300, "192.0.2.1"),
+			b:    recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			want: []string{"multivalue_answer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DifferentFields(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := DifferentFields(tt.b, tt.a); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("swapped, got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareRecordSetsSetIdentifier(t *testing.T) {
+	blue := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	blue.SetIdentifier = aws.String("blue")
+	blue.Weight = aws.Int64(50)
+	green := blue
+	green.SetIdentifier = aws.String("green")
+	if CompareRecordSets(blue, green) {
+		t.Error("record sets with different set identifiers compare equal")
+	}
+}
+
+func TestDiffRecordSets(t *testing.T) {
+	blue := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	blue.SetIdentifier = aws.String("blue")
+	blue.Weight = aws.Int64(90)
+	green := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.2")
+	green.SetIdentifier = aws.String("green")
+	green.Weight = aws.Int64(10)
+	greenUpdated := green
+	greenUpdated.Weight = aws.Int64(50)
+	src := []rtypes.ResourceRecordSet{
+		recordSet("api.example.com.", rtypes.RRTypeCname, "www.example.com."),
+		blue,
+		greenUpdated,
+		recordSet("mail.example.com.", rtypes.RRTypeMx, "10 mx1.example.com.", "20 mx2.example.com."),
+	}
+	dst := []rtypes.ResourceRecordSet{
+		green,
+		blue,
+		recordSet("MAIL.example.com", rtypes.RRTypeMx, "20 mx2.example.com", "10 mx1.example.com"),
+		recordSet("old.example.com.", rtypes.RRTypeA, "192.0.2.9"),
+	}
+
+	diff := DiffRecordSets(src, dst)
+	if got := recordNames(diff.Create); got != "api.example.com. CNAME" {
+		t.Errorf("create %s, want api.example.com. CNAME", got)
+	}
+	if len(diff.Update) != 1 || aws.ToString(diff.Update[0].To.SetIdentifier) != "green" {
+		t.Fatalf("update %+v, want the green weighted record", diff.Update)
+	}
+	if aws.ToInt64(diff.Update[0].From.Weight) != 10 || aws.ToInt64(diff.Update[0].To.Weight) != 50 {
+		t.Errorf("update from weight %d to %d, want 10 to 50", aws.ToInt64(diff.Update[0].From.Weight), aws.ToInt64(diff.Update[0].To.Weight))
+	}
+	if got := recordNames(diff.Delete); got != "old.example.com. A" {
+		t.Errorf("delete %s, want old.example.com. A", got)
+	}
+	if !DiffRecordSets(src, src).Empty() {
+		t.Error("a zone differs from itself")
+	}
+}
+
+func TestDiffChanges(t *testing.T) {
+	diff := Diff{
+		Create: []rtypes.ResourceRecordSet{recordSet("new.example.com.", rtypes.RRTypeA, "192.0.2.1")},
+		Update: []RecordSetUpdate{{
+			From: recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+			To:   recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+		}},
+		Delete: []rtypes.ResourceRecordSet{recordSet("old.example.com.", rtypes.RRTypeA, "192.0.2.4")},
+	}
+	tests := []struct {
+		prune bool
+		want  []string
+	}{
+		{prune: false, want: []string{"UPSERT new.example.com.", "UPSERT www.example.com."}},
+		// Deletes come first, so a record set moving to another type, such as
+		// an A record replaced by a CNAME, never exists twice.
+		{prune: true, want: []string{"DELETE old.example.com.", "UPSERT new.example.com.", "UPSERT www.example.com."}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, c := range diff.Changes(tt.prune) {
+			got = append(got, string(c.Action)+" "+aws.ToString(c.ResourceRecordSet.Name))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("prune %t: got %v, want %v", tt.prune, got, tt.want)
+		}
+	}
+	if value := aws.ToString(diff.Changes(false)[1].ResourceRecordSet.ResourceRecords[0].Value); value != "192.0.2.3" {
+		t.Errorf("update upserts %s, want the source value 192.0.2.3", value)
+	}
+}
+
+func TestPreviewChanges(t *testing.T) {
+	upsert := func(rs rtypes.ResourceRecordSet) rtypes.Change {
+		return rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &rs}
+	}
+	gone := recordSet("gone.example.com.", rtypes.RRTypeA, "192.0.2.5")
+	changes := []rtypes.Change{
+		upsert(recordSet("new.example.com.", rtypes.RRTypeA, "192.0.2.1")),
+		upsert(recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.3")),
+		upsert(recordSet("same.example.com.", rtypes.RRTypeCname, "www.example.com.")),
+		{Action: rtypes.ChangeActionDelete, ResourceRecordSet: &gone},
+	}
+	existing := []rtypes.ResourceRecordSet{
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+		recordSet("same.example.com", rtypes.RRTypeCname, "www.example.com"),
+		gone,
+		recordSet("other.example.com.", rtypes.RRTypeA, "192.0.2.6"),
+	}
+
+	preview := PreviewChanges(changes, existing)
+	if got := recordNames(preview.Create); got != "new.example.com. A" {
+		t.Errorf("creates %s, want new.example.com. A", got)
+	}
+	if len(preview.Update) != 1 {
+		t.Fatalf("got %d overwrites, want www.example.com. only", len(preview.Update))
+	}
+	u := preview.Update[0]
+	if aws.ToString(u.From.ResourceRecords[0].Value) != "192.0.2.2" || aws.ToString(u.To.ResourceRecords[0].Value) != "192.0.2.3" {
+		t.Errorf("overwrites %s with %s, want 192.0.2.2 with 192.0.2.3", recordValues(u.From), recordValues(u.To))
+	}
+	// Records the copy leaves alone are not previewed as deleted, nor are
+	// the deletes of the changes.
+	if len(preview.Delete) != 0 {
+		t.Errorf("previews deleting %s", recordNames(preview.Delete))
+	}
+}
+
+// recordNames returns the names and types of records, comma separated.
+func recordNames(records []rtypes.ResourceRecordSet) string {
+	names := []string{}
+	for _, rs := range records {
+		names = append(names, aws.ToString(rs.Name)+" "+string(rs.Type))
+	}
+	return strings.Join(names, ", ")
+}
@@ -3,14 +3,28 @@ package dns
 import (
 	"fmt"
 	"net"
-	"regexp"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	rdtypes "github.com/aws/aws-sdk-go-v2/service/route53domains/types"
 	"github.com/miekg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
 )
 
-var nsre = regexp.MustCompile(`.*NS.(.*)`)
+// DefaultResolver is queried when no resolver is given and the system has no
+// usable /etc/resolv.conf, as on Windows or in minimal containers.
+const DefaultResolver = "8.8.8.8:53"
+
+const resolvConf = "/etc/resolv.conf"
+
+// maxReferrals bounds how many delegations are followed from the root.
+const maxReferrals = 10
+
+// rootServers are a.root-servers.net, b.root-servers.net and
+// c.root-servers.net, used to follow referrals down to a domain when the
+// resolvers do not return its nameservers.
+var rootServers = []string{"198.41.0.4:53", "170.247.170.2:53", "192.33.4.12:53"}
 
 type NSRecordNotFound struct {
 	Domain string
@@ -20,16 +34,89 @@ func (e *NSRecordNotFound) Error() string {
 	return fmt.Sprintf("failed to get nameservers for: %s", e.Domain)
 }
 
-func GetNameserversFor(domain string) ([]rdtypes.Nameserver, error) {
-	config, _ := dns.ClientConfigFromFile("/etc/resolv.conf")
+// LookupOptions are the options used by GetNameserversFor.
+type LookupOptions struct {
+	// Resolver is the host:port of the DNS server to query. When empty the
+	// servers from /etc/resolv.conf are used, falling back to
+	// DefaultResolver.
+	Resolver string
+}
+
+// WithResolver queries resolver instead of the system resolvers. A missing
+// port defaults to 53.
+func WithResolver(resolver string) func(*LookupOptions) {
+	return func(o *LookupOptions) {
+		o.Resolver = resolver
+	}
+}
+
+// GetNameserversFor returns the nameservers the DNS reports for domain. When
+// the resolvers have no answer and no resolver was given, the delegations
+// are followed from the root servers instead.
+func GetNameserversFor(domain string, optFns ...func(*LookupOptions)) ([]rdtypes.Nameserver, error) {
+	options := LookupOptions{}
+	for _, fn := range optFns {
+		fn(&options)
+	}
 
 	c := &dns.Client{}
+	var err error
+	for _, server := range resolvers(options) {
+		var nss []rdtypes.Nameserver
+		nss, err = queryNameservers(c, server, domain)
+		if err == nil {
+			return nss, nil
+		}
+		logging.Debugf("Resolver %s returned no nameservers for %s: %s\n", server, domain, err)
+	}
+	if options.Resolver != "" {
+		return nil, err
+	}
+
+	logging.Debugf("Following referrals from the root servers for %s\n", domain)
+	return followReferrals(c, domain)
+}
+
+// GetDelegationFor returns the nameservers the parent zone delegates domain
+// to. The delegations are followed from the root servers, so the answer is
+// never a cached one.
+func GetDelegationFor(domain string) ([]rdtypes.Nameserver, error) {
+	return followReferrals(&dns.Client{}, domain)
+}
+
+func resolvers(options LookupOptions) []string {
+	if options.Resolver != "" {
+		return []string{withDefaultPort(options.Resolver)}
+	}
+
+	config, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil || len(config.Servers) == 0 {
+		logging.Debugf("No resolvers found in %s, using %s\n", resolvConf, DefaultResolver)
+		return []string{DefaultResolver}
+	}
+	servers := []string{}
+	for _, s := range config.Servers {
+		servers = append(servers, net.JoinHostPort(s, config.Port))
+	}
+	return servers
+}
+
+func withDefaultPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
 
+// queryNameservers asks a recursive resolver for the NS records of domain.
+// Resolvers answering without recursion return a referral, so the authority
+// section is used when the answer is empty.
+func queryNameservers(c *dns.Client, server, domain string) ([]rdtypes.Nameserver, error) {
 	m := &dns.Msg{}
 	m.SetQuestion(dns.Fqdn(domain), dns.TypeNS)
 	m.RecursionDesired = true
 
-	r, _, err := c.Exchange(m, net.JoinHostPort(config.Servers[0], config.Port))
+	r, err := exchange(c, m, server)
 	if err != nil {
 		return nil, err
 	}
@@ -37,16 +124,185 @@ func GetNameserversFor(domain string) ([]rdtypes.Nameserver, error) {
 		return nil, &NSRecordNotFound{Domain: domain}
 	}
 
+	nss := answerNameservers(r.Answer, domain)
+	if len(nss) == 0 {
+		nss = nameserversIn(r.Ns, domain)
+	}
+	if len(nss) == 0 {
+		return nil, &NSRecordNotFound{Domain: domain}
+	}
+	return nss, nil
+}
+
+// GetDelegationTTL returns the TTL of the NS records the parent zone
+// delegates domain with, which bounds how long resolvers keep using the old
+// nameservers after the delegation changes.
+func GetDelegationTTL(domain string) (time.Duration, error) {
+	_, ttl, err := followDelegation(&dns.Client{}, domain)
+	return ttl, err
+}
+
+// followReferrals walks the delegations from the root servers down to
+// domain, returning the nameservers its parent delegates it to.
+func followReferrals(c *dns.Client, domain string) ([]rdtypes.Nameserver, error) {
+	nss, _, err := followDelegation(c, domain)
+	return nss, err
+}
+
+// followDelegation is followReferrals, also returning the lowest TTL of the
+// NS records found.
+func followDelegation(c *dns.Client, domain string) ([]rdtypes.Nameserver, time.Duration, error) {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(domain), dns.TypeNS)
+
+	servers := rootServers
+	zone := "."
+	for i := 0; i < maxReferrals; i++ {
+		r, err := exchangeAny(c, m, servers)
+		if err != nil {
+			return nil, 0, err
+		}
+		if r.Rcode != dns.RcodeSuccess {
+			return nil, 0, &NSRecordNotFound{Domain: domain}
+		}
+		if nss := answerNameservers(r.Answer, domain); len(nss) > 0 {
+			return nss, nsTTL(r.Answer), nil
+		}
+		if nss := nameserversIn(r.Ns, domain); len(nss) > 0 {
+			return nss, nsTTL(r.Ns), nil
+		}
+
+		next, ok := referral(r, zone, domain)
+		if !ok {
+			return nil, 0, &NSRecordNotFound{Domain: domain}
+		}
+		logging.Debugf("Referred to %s for %s\n", next, domain)
+		zone = next
+		servers = referralServers(r, zone)
+		if len(servers) == 0 {
+			return nil, 0, &NSRecordNotFound{Domain: domain}
+		}
+	}
+	return nil, 0, &NSRecordNotFound{Domain: domain}
+}
+
+// nsTTL returns the lowest TTL of the NS records in rrs.
+func nsTTL(rrs []dns.RR) time.Duration {
+	var ttl uint32
+	found := false
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.NS); ok && (!found || rr.Header().Ttl < ttl) {
+			ttl = rr.Header().Ttl
+			found = true
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// exchange sends m to server, retrying over TCP when the UDP response was
+// truncated.
+func exchange(c *dns.Client, m *dns.Msg, server string) (*dns.Msg, error) {
+	r, _, err := c.Exchange(m, server)
+	if err != nil || !r.Truncated || c.Net == "tcp" {
+		return r, err
+	}
+	logging.Debugf("Response from %s was truncated, retrying over TCP\n", server)
+	tcp := &dns.Client{Net: "tcp", Timeout: c.Timeout}
+	r, _, err = tcp.Exchange(m, server)
+	return r, err
+}
+
+// exchangeAny sends m to each server in turn until one answers.
+func exchangeAny(c *dns.Client, m *dns.Msg, servers []string) (*dns.Msg, error) {
+	var err error
+	for _, server := range servers {
+		var r *dns.Msg
+		r, err = exchange(c, m, server)
+		if err == nil {
+			return r, nil
+		}
+	}
+	return nil, err
+}
+
+// referral returns the zone r delegates to, which must be closer to domain
+// than the zone that was queried.
+func referral(r *dns.Msg, zone, domain string) (string, bool) {
+	for _, rr := range r.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		owner := ns.Hdr.Name
+		if dns.IsSubDomain(owner, dns.Fqdn(domain)) && !sameDomain(owner, zone) && dns.IsSubDomain(zone, owner) {
+			return owner, true
+		}
+	}
+	return "", false
+}
+
+// referralServers returns the addresses of the nameservers r delegates zone
+// to, using the glue records when present.
+func referralServers(r *dns.Msg, zone string) []string {
+	glue := map[string][]string{}
+	for _, rr := range r.Extra {
+		switch a := rr.(type) {
+		case *dns.A:
+			glue[strings.ToLower(a.Hdr.Name)] = append(glue[strings.ToLower(a.Hdr.Name)], a.A.String())
+		case *dns.AAAA:
+			glue[strings.ToLower(a.Hdr.Name)] = append(glue[strings.ToLower(a.Hdr.Name)], a.AAAA.String())
+		}
+	}
+
+	servers := []string{}
+	for _, rr := range r.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok || !sameDomain(ns.Hdr.Name, zone) {
+			continue
+		}
+		addrs, ok := glue[strings.ToLower(ns.Ns)]
+		if !ok {
+			var err error
+			addrs, err = net.LookupHost(ns.Ns)
+			if err != nil {
+				logging.Debugf("Failed to resolve nameserver %s: %s\n", ns.Ns, err)
+				continue
+			}
+		}
+		for _, addr := range addrs {
+			servers = append(servers, net.JoinHostPort(addr, "53"))
+		}
+	}
+	return servers
+}
+
+// answerNameservers returns the NS records in an answer section for domain,
+// following the CNAME records the resolver chased to reach them.
+func answerNameservers(answer []dns.RR, domain string) []rdtypes.Nameserver {
+	name := dns.Fqdn(domain)
+	for i := 0; i < len(answer); i++ {
+		for _, rr := range answer {
+			if cname, ok := rr.(*dns.CNAME); ok && sameDomain(cname.Hdr.Name, name) {
+				name = cname.Target
+				break
+			}
+		}
+	}
+	return nameserversIn(answer, name)
+}
+
+// nameserversIn returns the NS records in rrs owned by domain.
+func nameserversIn(rrs []dns.RR, domain string) []rdtypes.Nameserver {
 	nss := []rdtypes.Nameserver{}
-	for _, rr := range r.Answer {
-		if ns, ok := rr.(*dns.NS); ok {
-			nsStr := ns.String()
-			server := nsre.FindStringSubmatch(nsStr)[1]
-			server = denormalizeDomain(server)
-			nss = append(nss, rdtypes.Nameserver{
-				Name: aws.String(server),
-			})
+	for _, rr := range rrs {
+		ns, ok := rr.(*dns.NS)
+		if !ok || !sameDomain(ns.Hdr.Name, domain) {
+			continue
 		}
+		logging.Debugf("Found nameserver %s\n", ns.Ns)
+		nss = append(nss, rdtypes.Nameserver{
+			Name: aws.String(denormalizeDomain(ns.Ns)),
+		})
 	}
-	return nss, nil
+	return nss
 }
@@ -0,0 +1,211 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdtypes "github.com/aws/aws-sdk-go-v2/service/route53domains/types"
+	"github.com/miekg/dns"
+)
+
+// localDNS serves handler over UDP and TCP on the same local port and
+// returns its address.
+func localDNS(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+	for attempt := 0; attempt < 10; attempt++ {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("tcp", pc.LocalAddr().String())
+		if err != nil {
+			// The port is taken over TCP, try another one.
+			pc.Close()
+			continue
+		}
+		udp := &dns.Server{PacketConn: pc, Handler: handler}
+		tcp := &dns.Server{Listener: l, Handler: handler}
+		go func() { _ = udp.ActivateAndServe() }()
+		go func() { _ = tcp.ActivateAndServe() }()
+		t.Cleanup(func() {
+			_ = udp.Shutdown()
+			_ = tcp.Shutdown()
+		})
+		return pc.LocalAddr().String()
+	}
+	t.Fatal("no local port is free over both UDP and TCP")
+	return ""
+}
+
+// rr parses a resource record in zone file format.
+func rr(t *testing.T, s string) dns.RR {
+	t.Helper()
+	r, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func nameserverNames(nss []rdtypes.Nameserver) []string {
+	names := []string{}
+	for _, ns := range nss {
+		names = append(names, aws.ToString(ns.Name))
+	}
+	return names
+}
+
+func TestAnswerNameservers(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer []string
+		want   []string
+	}{
+		{
+			name:   "NS records",
+			answer: []string{"example.com. 300 IN NS ns-1.awsdns-01.org.", "example.com. 300 IN NS ns-2.awsdns-02.com."},
+			want:   []string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"},
+		},
+		{
+			name:   "owner in another case",
+			answer: []string{"EXAMPLE.com. 300 IN NS NS-1.awsdns-01.org."},
+			want:   []string{"NS-1.awsdns-01.org"},
+		},
+		{
+			name: "mixed with a chased CNAME and other types",
+			answer: []string{
+				"example.com. 300 IN CNAME alias.example.net.",
+				"alias.example.net. 300 IN A 192.0.2.1",
+				"alias.example.net. 300 IN NS ns1.example.net.",
+				"other.example.net. 300 IN NS ns9.example.net.",
+			},
+			want: []string{"ns1.example.net"},
+		},
+		{
+			name:   "NS records of another name",
+			answer: []string{"sub.example.com. 300 IN NS ns1.other.net."},
+			want:   []string{},
+		},
+		{
+			name: "empty",
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := []dns.RR{}
+			for _, s := range tt.answer {
+				answer = append(answer, rr(t, s))
+			}
+			got := nameserverNames(answerNameservers(answer, "example.com"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryNameservers(t *testing.T) {
+	tests := []struct {
+		name string
+		// reply fills the response to a query received over network.
+		reply func(t *testing.T, m *dns.Msg, network string)
+		want  []string
+	}{
+		{
+			name: "answer",
+			reply: func(t *testing.T, m *dns.Msg, network string) {
+				m.Answer = []dns.RR{rr(t, "example.com. 300 IN NS ns1.example.net.")}
+			},
+			want: []string{"ns1.example.net"},
+		},
+		{
+			name: "truncated over UDP",
+			reply: func(t *testing.T, m *dns.Msg, network string) {
+				if network == "udp" {
+					m.Truncated = true
+					return
+				}
+				m.Answer = []dns.RR{rr(t, "example.com. 300 IN NS ns1.example.net."), rr(t, "example.com. 300 IN NS ns2.example.net.")}
+			},
+			want: []string{"ns1.example.net", "ns2.example.net"},
+		},
+		{
+			name: "referral in the authority section",
+			reply: func(t *testing.T, m *dns.Msg, network string) {
+				m.Ns = []dns.RR{rr(t, "example.com. 172800 IN NS ns1.registry.net.")}
+			},
+			want: []string{"ns1.registry.net"},
+		},
+		{
+			name:  "empty answer",
+			reply: func(t *testing.T, m *dns.Msg, network string) {},
+		},
+		{
+			name: "NXDOMAIN",
+			reply: func(t *testing.T, m *dns.Msg, network string) {
+				m.Rcode = dns.RcodeNameError
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := localDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+				m := &dns.Msg{}
+				m.SetReply(r)
+				tt.reply(t, m, w.RemoteAddr().Network())
+				_ = w.WriteMsg(m)
+			})
+
+			nss, err := GetNameserversFor("example.com", WithResolver(server))
+			if tt.want == nil {
+				var notFound *NSRecordNotFound
+				if !errors.As(err, &notFound) {
+					t.Fatalf("got %v and %v, want NSRecordNotFound", nss, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := nameserverNames(nss); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNSTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		want    time.Duration
+	}{
+		{
+			name:    "lowest NS TTL",
+			records: []string{"example.com. 172800 IN NS a.gtld.net.", "example.com. 3600 IN NS b.gtld.net.", "example.com. 86400 IN NS c.gtld.net."},
+			want:    time.Hour,
+		},
+		{
+			name:    "other types ignored",
+			records: []string{"example.com. 60 IN SOA a.gtld.net. hostmaster.example.com. 1 7200 900 1209600 86400", "example.com. 172800 IN NS a.gtld.net."},
+			want:    48 * time.Hour,
+		},
+		{name: "no NS records"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rrs := []dns.RR{}
+			for _, s := range tt.records {
+				rrs = append(rrs, rr(t, s))
+			}
+			if got := nsTTL(rrs); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+const (
+	dnssecSigning = "SIGNING"
+	kskActive     = "ACTIVE"
+	// kskName names the key-signing key created by EnableDNSSEC.
+	kskName = "route53copy"
+)
+
+// DNSSEC describes the DNSSEC signing of a hosted zone.
+type DNSSEC struct {
+	// Status is the signing status, such as SIGNING or NOT_SIGNING.
+	Status string
+	// DSRecords are the DS records of the active key-signing keys, to be
+	// published at the registrar.
+	DSRecords []string
+}
+
+// Signing reports whether the zone is signed.
+func (d DNSSEC) Signing() bool {
+	return d.Status == dnssecSigning
+}
+
+// GetDNSSEC returns the DNSSEC signing status of a hosted zone. Private zones
+// cannot be signed.
+func (r *RouteCopy) GetDNSSEC(ctx context.Context, zoneId string) (DNSSEC, error) {
+	out, err := r.cli.GetDNSSEC(ctx, &route53.GetDNSSECInput{
+		HostedZoneId: aws.String(zoneId),
+	})
+	if err != nil {
+		return DNSSEC{}, fmt.Errorf("failed to get DNSSEC status of zone %s: %w", zoneId, err)
+	}
+	d := DNSSEC{Status: aws.ToString(out.Status.ServeSignature)}
+	for _, ksk := range out.KeySigningKeys {
+		if aws.ToString(ksk.Status) == kskActive && aws.ToString(ksk.DSRecord) != "" {
+			d.DSRecords = append(d.DSRecords, aws.ToString(ksk.DSRecord))
+		}
+	}
+	return d, nil
+}
+
+// EnableDNSSEC signs a hosted zone, creating an active key-signing key backed
+// by kmsKeyARN unless the zone already has one, and waits up to maxWait for
+// each change. The key must be an asymmetric ECC_NIST_P256 key in us-east-1
+// that Route53 is allowed to use.
+func (r *RouteCopy) EnableDNSSEC(ctx context.Context, zoneId, kmsKeyARN string, maxWait time.Duration) (DNSSEC, error) {
+	d, err := r.GetDNSSEC(ctx, zoneId)
+	if err != nil {
+		return d, err
+	}
+	if d.Signing() {
+		logging.From(ctx).Infof("DNSSEC signing is already enabled for zone %s\n", zoneId)
+		return d, nil
+	}
+
+	if len(d.DSRecords) == 0 {
+		ksk, err := r.cli.CreateKeySigningKey(ctx, &route53.CreateKeySigningKeyInput{
+			HostedZoneId:            aws.String(zoneId),
+			KeyManagementServiceArn: aws.String(kmsKeyARN),
+			Name:                    aws.String(kskName),
+			Status:                  aws.String(kskActive),
+			CallerReference:         aws.String(fmt.Sprintf("route53copy-%d", time.Now().UnixNano())),
+		})
+		if err != nil {
+			return d, fmt.Errorf("failed to create key-signing key for zone %s: %w", zoneId, err)
+		}
+		err = r.WaitForChange(ctx, aws.ToString(ksk.ChangeInfo.Id), maxWait)
+		if err != nil {
+			return d, err
+		}
+		logging.From(ctx).Infof("Created key-signing key %s for zone %s\n", kskName, zoneId)
+	}
+
+	enabled, err := r.cli.EnableHostedZoneDNSSEC(ctx, &route53.EnableHostedZoneDNSSECInput{
+		HostedZoneId: aws.String(zoneId),
+	})
+	if err != nil {
+		return d, fmt.Errorf("failed to enable DNSSEC signing for zone %s: %w", zoneId, err)
+	}
+	err = r.WaitForChange(ctx, aws.ToString(enabled.ChangeInfo.Id), maxWait)
+	if err != nil {
+		return d, err
+	}
+	return r.GetDNSSEC(ctx, zoneId)
+}
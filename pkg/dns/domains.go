@@ -3,22 +3,24 @@ package dns
 import (
 	"context"
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/route53domains"
 	"github.com/aws/aws-sdk-go-v2/service/route53domains/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go/middleware"
 	smithytime "github.com/aws/smithy-go/time"
 	smithywaiter "github.com/aws/smithy-go/waiter"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
 )
 
 type DomainManager struct {
-	cli    *route53domains.Client
-	stscli *sts.Client
+	profile string
+	side    string
+	cli     DomainTransferAPI
+	stscli  STSAPI
 }
 
 type Transfer struct {
@@ -26,21 +28,35 @@ type Transfer struct {
 	OperationID string
 }
 
-func NewDomainManager(ctx context.Context, profile string) (*DomainManager, error) {
-	if r := os.Getenv("AWS_REGION"); r == "" {
-		os.Setenv("AWS_REGION", "us-east-1")
-	}
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithSharedConfigProfile(profile))
-
+func NewDomainManager(ctx context.Context, profile string, optFns ...func(*ConfigOptions)) (*DomainManager, error) {
+	cfg, err := LoadConfig(ctx, profile, optFns...)
 	if err != nil {
 		return nil, err
 	}
 
+	dm := NewDomainManagerWithClients(profile, route53domains.NewFromConfig(cfg), sts.NewFromConfig(cfg))
+	dm.side = newConfigOptions(optFns).Side
+	return dm, nil
+}
+
+// NewDomainManagerWithClients returns a DomainManager using the given
+// clients, see NewRouteCopyWithClients.
+func NewDomainManagerWithClients(profile string, cli DomainTransferAPI, stscli STSAPI) *DomainManager {
 	return &DomainManager{
-		cli:    route53domains.NewFromConfig(cfg),
-		stscli: sts.NewFromConfig(cfg),
-	}, nil
+		profile: profile,
+		cli:     cli,
+		stscli:  stscli,
+	}
+}
+
+// CheckCredentials verifies the profile credentials with a cheap STS call,
+// see RouteCopy.CheckCredentials.
+func (dm *DomainManager) CheckCredentials(ctx context.Context) error {
+	_, err := dm.GetAccountID(ctx)
+	if err != nil {
+		return &ProfileError{Profile: dm.profile, Side: dm.side, Err: err}
+	}
+	return nil
 }
 
 func (dm *DomainManager) GetAccountID(ctx context.Context) (string, error) {
@@ -53,7 +69,13 @@ func (dm *DomainManager) GetAccountID(ctx context.Context) (string, error) {
 }
 
 func (dm *DomainManager) ListRegisteredDomains(ctx context.Context) ([]string, error) {
-	paginator := route53domains.NewListDomainsPaginator(dm.cli, &route53domains.ListDomainsInput{})
+	return listRegisteredDomains(ctx, dm.cli)
+}
+
+// listRegisteredDomains returns the domains registered in the account of
+// the client.
+func listRegisteredDomains(ctx context.Context, cli route53domains.ListDomainsAPIClient) ([]string, error) {
+	paginator := route53domains.NewListDomainsPaginator(cli, &route53domains.ListDomainsInput{})
 
 	domains := []string{}
 	for paginator.HasMorePages() {
@@ -72,7 +94,7 @@ func (dm *DomainManager) ListRegisteredDomains(ctx context.Context) ([]string, e
 func (dm *DomainManager) TransferDomain(ctx context.Context, domain, dstAccount string) (*Transfer, error) {
 	resp, err := dm.cli.TransferDomainToAnotherAwsAccount(ctx, &route53domains.TransferDomainToAnotherAwsAccountInput{
 		AccountId:  aws.String(dstAccount),
-		DomainName: aws.String(domain),
+		DomainName: aws.String(registrarDomain(domain)),
 	})
 	if err != nil {
 		return nil, err
@@ -93,9 +115,18 @@ func (dm *DomainManager) WaitOperation(ctx context.Context, expected types.Opera
 	}, d)
 }
 
+// CancelTranfer cancels a pending transfer of domain.
+//
+// Deprecated: use CancelTransfer.
 func (dm *DomainManager) CancelTranfer(ctx context.Context, domain string) (string, error) {
+	return dm.CancelTransfer(ctx, domain)
+}
+
+// CancelTransfer cancels a pending transfer of domain to another account and
+// returns the id of the cancel operation.
+func (dm *DomainManager) CancelTransfer(ctx context.Context, domain string) (string, error) {
 	resp, err := dm.cli.CancelDomainTransferToAnotherAwsAccount(ctx, &route53domains.CancelDomainTransferToAnotherAwsAccountInput{
-		DomainName: aws.String(domain),
+		DomainName: aws.String(registrarDomain(domain)),
 	})
 	if err != nil {
 		return "", err
@@ -105,7 +136,7 @@ func (dm *DomainManager) CancelTranfer(ctx context.Context, domain string) (stri
 
 func (dm *DomainManager) AcceptTransfer(ctx context.Context, domain, password string) (string, error) {
 	resp, err := dm.cli.AcceptDomainTransferFromAnotherAwsAccount(ctx, &route53domains.AcceptDomainTransferFromAnotherAwsAccountInput{
-		DomainName: aws.String(domain),
+		DomainName: aws.String(registrarDomain(domain)),
 		Password:   aws.String(password),
 	})
 	if err != nil {
@@ -114,6 +145,69 @@ func (dm *DomainManager) AcceptTransfer(ctx context.Context, domain, password st
 	return aws.ToString(resp.OperationId), nil
 }
 
+type OperationFailed struct {
+	OperationID string
+	Status      types.OperationStatus
+	Message     string
+}
+
+func (e *OperationFailed) Error() string {
+	return fmt.Sprintf("operation %s %s: %s", e.OperationID, strings.ToLower(string(e.Status)), e.Message)
+}
+
+// WaitForOperation polls the operation until it is SUCCESSFUL, FAILED or
+// ERROR and returns the final status. Failed operations return an
+// OperationFailed error with the operation message.
+func (dm *DomainManager) WaitForOperation(ctx context.Context, operationID string, maxWait time.Duration) (types.OperationStatus, error) {
+	return waitForOperation(ctx, dm.cli, operationID, maxWait)
+}
+
+func waitForOperation(ctx context.Context, client GetOperationDetailAPIClient, operationID string, maxWait time.Duration) (types.OperationStatus, error) {
+	const minDelay, maxDelay = 5 * time.Second, 60 * time.Second
+
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	var status types.OperationStatus
+	for attempt := int64(1); ; attempt++ {
+		out, err := client.GetOperationDetail(ctx, &route53domains.GetOperationDetailInput{
+			OperationId: aws.String(operationID),
+		})
+		if err != nil {
+			return status, err
+		}
+		if out.Status != status {
+			logging.From(ctx).Infof("Operation %s is %s\n", operationID, strings.ToLower(string(out.Status)))
+		}
+		status = out.Status
+		switch status {
+		case types.OperationStatusSuccessful:
+			return status, nil
+		case types.OperationStatusFailed, types.OperationStatusError:
+			return status, &OperationFailed{
+				OperationID: operationID,
+				Status:      status,
+				Message:     aws.ToString(out.Message),
+			}
+		}
+
+		remaining := maxWait
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining = time.Until(deadline)
+		}
+		if remaining < minDelay {
+			return status, fmt.Errorf("operation %s still %s after %s", operationID, strings.ToLower(string(status)), maxWait)
+		}
+		delay, err := smithywaiter.ComputeDelay(attempt, minDelay, maxDelay, remaining)
+		if err != nil {
+			return status, fmt.Errorf("error computing waiter delay, %w", err)
+		}
+		if err := smithytime.SleepWithContext(ctx, delay); err != nil {
+			return status, fmt.Errorf("operation %s still %s: %w", operationID, strings.ToLower(string(status)), err)
+		}
+	}
+}
+
 // GetOperationDetailAPIClient is a client that implements the GetOperationDetail operation.
 type GetOperationDetailAPIClient interface {
 	GetOperationDetail(ctx context.Context, params *route53domains.GetOperationDetailInput, optFns ...func(*route53domains.Options)) (*route53domains.GetOperationDetailOutput, error)
@@ -0,0 +1,316 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// fakeAccounts returns a source and a destination account served by fake
+// Route53 servers.
+func fakeAccounts(t *testing.T) (*fakeroute53.Server, *RouteCopy, *fakeroute53.Server, *RouteCopy) {
+	t.Helper()
+	srcServer := fakeroute53.NewServer()
+	t.Cleanup(srcServer.Close)
+	dstServer := fakeroute53.NewServer()
+	t.Cleanup(dstServer.Close)
+	return srcServer, NewRouteCopyForTest("source", srcServer.URL),
+		dstServer, NewRouteCopyForTest("destination", dstServer.URL)
+}
+
+// hostRecords returns n A record sets under domain, the last one with two
+// values.
+func hostRecords(domain string, n int) []rtypes.ResourceRecordSet {
+	records := []rtypes.ResourceRecordSet{}
+	for i := 0; i < n; i++ {
+		rs := rtypes.ResourceRecordSet{
+			Name:            aws.String(fmt.Sprintf("host%03d.%s.", i, domain)),
+			Type:            rtypes.RRTypeA,
+			TTL:             aws.Int64(300),
+			ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(fmt.Sprintf("192.0.2.%d", i%250))}},
+		}
+		if i == n-1 {
+			rs.ResourceRecords = append(rs.ResourceRecords, rtypes.ResourceRecord{Value: aws.String("198.51.100.1")})
+		}
+		records = append(records, rs)
+	}
+	return records
+}
+
+func TestCopyZonePageBoundaries(t *testing.T) {
+	tests := []struct {
+		// records is the number of record sets listed from the source
+		// zone, including its apex NS and SOA.
+		records int
+		pages   int
+	}{
+		{records: fakeroute53.DefaultMaxRecords, pages: 1},
+		{records: fakeroute53.DefaultMaxRecords + 1, pages: 2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.records), func(t *testing.T) {
+			ctx := context.Background()
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID, hostRecords("example.com", tt.records-2)...)
+			dstZoneID := dstServer.AddZone("example.com", false)
+
+			listed, err := src.GetResourceRecords(ctx, srcZoneID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listed) != tt.records {
+				t.Fatalf("listed %d record sets, want %d", len(listed), tt.records)
+			}
+			if calls := srcServer.Calls(fakeroute53.OpListResourceRecordSets); calls != tt.pages {
+				t.Errorf("listed %d pages, want %d", calls, tt.pages)
+			}
+
+			result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Changes) != tt.records-2 {
+				t.Errorf("copied %d record sets, want %d", len(result.Changes), tt.records-2)
+			}
+			copied := dstServer.Records(dstZoneID)
+			if len(copied) != tt.records {
+				t.Fatalf("destination holds %d record sets, want %d", len(copied), tt.records)
+			}
+			last := copied[len(copied)-1]
+			if len(last.ResourceRecords) != 2 {
+				t.Errorf("%s has %d values, want 2", aws.ToString(last.Name), len(last.ResourceRecords))
+			}
+		})
+	}
+}
+
+func TestCopyZoneTwice(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 20)...)
+
+	first, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.CreatedZone {
+		t.Error("the first copy did not create the destination zone")
+	}
+	changes := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets)
+	if changes == 0 {
+		t.Fatal("the first copy changed nothing")
+	}
+
+	second, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.CreatedZone {
+		t.Error("the second copy created another destination zone")
+	}
+	if len(second.Unchanged) != 20 || len(second.Batches) != 0 {
+		t.Errorf("second copy: %d unchanged, %d batches, want 20 unchanged and no batches", len(second.Unchanged), len(second.Batches))
+	}
+	if calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets); calls != changes {
+		t.Errorf("the second copy submitted %d change batches", calls-changes)
+	}
+	if zones := dstServer.FindZone("example.com"); len(zones) != 1 {
+		t.Errorf("destination has %d zones of example.com, want 1", len(zones))
+	}
+}
+
+func TestCopyZoneExistingZone(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 3)...)
+	dstZoneID := dstServer.AddZone("example.com", false)
+	extra := rtypes.ResourceRecordSet{
+		Name:            aws.String("extra.example.com."),
+		Type:            rtypes.RRTypeA,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String("198.51.100.9")}},
+	}
+	stale := hostRecords("example.com", 1)[0]
+	stale.ResourceRecords = []rtypes.ResourceRecord{{Value: aws.String("198.51.100.10")}}
+	dstServer.AddRecords(dstZoneID, hostRecords("example.com", 3)[1], stale, extra)
+
+	result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CreatedZone || aws.ToString(result.DestinationZone.Id) != "/hostedzone/"+dstZoneID {
+		t.Errorf("copied into zone %s, created %t, want the existing zone %s", aws.ToString(result.DestinationZone.Id), result.CreatedZone, dstZoneID)
+	}
+	// host001 is already there, host000 is overwritten and host002 added.
+	if len(result.Changes) != 2 || len(result.Unchanged) != 1 {
+		t.Errorf("%d changes and %d unchanged, want 2 and 1", len(result.Changes), len(result.Unchanged))
+	}
+	records := dstServer.Records(dstZoneID)
+	for _, want := range hostRecords("example.com", 3) {
+		got, ok := findRecord(records, aws.ToString(want.Name))
+		if !ok || !CompareRecordSets(got, want) {
+			t.Errorf("the destination holds %+v, want %+v", got, want)
+		}
+	}
+	if _, ok := findRecord(records, "extra.example.com."); !ok {
+		t.Error("a record only in the destination was deleted without pruning")
+	}
+	if zones := dstServer.FindZone("example.com"); len(zones) != 1 {
+		t.Errorf("destination has %d zones of example.com, want 1", len(zones))
+	}
+}
+
+// recordingRoute53 is a Route53 client recording the change batches it
+// submits, to check that RouteCopy uses the clients it is given.
+type recordingRoute53 struct {
+	Route53API
+	batches *int
+}
+
+func (c recordingRoute53) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	*c.batches++
+	return c.Route53API.ChangeResourceRecordSets(ctx, params, optFns...)
+}
+
+func TestNewRouteCopyWithClients(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, _, fake := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 3)...)
+	batches := 0
+	dst := NewRouteCopyWithClients("destination", DefaultRegion, recordingRoute53{fake.cli, &batches}, fake.domains, fake.stscli)
+
+	result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batches == 0 || batches != len(result.Batches) {
+		t.Errorf("the client submitted %d batches, want the %d of the copy", batches, len(result.Batches))
+	}
+}
+
+func TestCopyZoneNotFound(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcServer.AddZone("example.org", false)
+
+	_, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	var lookup *ZoneLookupError
+	if !errors.As(err, &lookup) || !lookup.Source {
+		t.Fatalf("got %v, want a source ZoneLookupError", err)
+	}
+	var notFound *HostedZoneNotFound
+	if !errors.As(err, &notFound) || notFound.Zone != "example.com" {
+		t.Fatalf("got %v, want a HostedZoneNotFound for example.com", err)
+	}
+	if zones := dstServer.FindZone("example.com"); len(zones) != 0 {
+		t.Errorf("a destination zone was created for a missing source zone")
+	}
+}
+
+func TestDeleteRecordsAndZone(t *testing.T) {
+	ctx := context.Background()
+	server := fakeroute53.NewServer()
+	defer server.Close()
+	r := NewRouteCopyForTest("destination", server.URL)
+	zoneID := server.AddZone("example.com", false)
+	server.AddRecords(zoneID, hostRecords("example.com", fakeroute53.DefaultMaxRecords)...)
+
+	records, err := r.GetResourceRecords(ctx, zoneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.DeleteRecords(ctx, zoneID, "example.com", records, DefaultWaitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := server.Records(zoneID)
+	if len(left) != 2 {
+		t.Fatalf("%d record sets left, want the apex NS and SOA", len(left))
+	}
+
+	changeID, err := r.DeleteHostedZone(ctx, zoneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.WaitForChange(ctx, changeID, DefaultWaitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zones := server.FindZone("example.com"); len(zones) != 0 {
+		t.Errorf("zone still exists after being deleted")
+	}
+}
+
+func TestStreamZone(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 2*fakeroute53.DefaultMaxRecords)...)
+	// Each page holds 300 record sets, fewer than a batch, so the changes
+	// of one page wait for the next.
+	dstZoneID := dstServer.AddZone("example.com", false)
+	dstServer.AddRecords(dstZoneID, hostRecords("example.com", 10)[:9]...)
+
+	result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := srcServer.Calls(fakeroute53.OpListResourceRecordSets); calls != 3 {
+		t.Errorf("listed %d pages, want 3", calls)
+	}
+	want := 2*fakeroute53.DefaultMaxRecords - 9
+	if len(result.Changes) != want || len(result.Unchanged) != 9 {
+		t.Errorf("%d changes and %d unchanged, want %d and 9", len(result.Changes), len(result.Unchanged), want)
+	}
+	if want := len(SplitChanges(ctx, result.Changes)); len(result.Batches) != want {
+		t.Errorf("applied %d batches, want %d", len(result.Batches), want)
+	}
+	if copied := dstServer.Records(dstZoneID); len(copied) != 2*fakeroute53.DefaultMaxRecords+2 {
+		t.Errorf("destination holds %d record sets, want %d", len(copied), 2*fakeroute53.DefaultMaxRecords+2)
+	}
+
+	_, err = CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", Stream: true, DryRun: true})
+	if err == nil {
+		t.Error("a streamed dry run did not fail")
+	}
+}
+
+func TestCopyZoneDryRunAliases(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	// Both servers number their zones alike, so another zone keeps the
+	// destination zone id apart from the source one.
+	dstServer.AddZone("example.org", false)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 1)...)
+	srcServer.AddRecords(srcZoneID, rtypes.ResourceRecordSet{
+		Name: aws.String("www.example.com."),
+		Type: rtypes.RRTypeA,
+		AliasTarget: &rtypes.AliasTarget{
+			DNSName:      aws.String("host000.example.com."),
+			HostedZoneId: aws.String(srcZoneID),
+		},
+	})
+
+	_, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 0 || len(result.Unchanged) != 2 {
+		t.Errorf("dry run after a copy: %d changes and %d unchanged, want none and 2", len(result.Changes), len(result.Unchanged))
+	}
+}
@@ -2,27 +2,151 @@ package dns
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
 )
 
-func RemoveResourceRecordsWithTypes(records []rtypes.ResourceRecordSet, types []rtypes.RRType) []rtypes.ResourceRecordSet {
+type InvalidRecordType struct {
+	Type string
+}
+
+func (e *InvalidRecordType) Error() string {
+	valid := []string{}
+	for _, t := range rtypes.RRType("").Values() {
+		valid = append(valid, string(t))
+	}
+	return fmt.Sprintf("invalid record type: %s (valid types: %s)", e.Type, strings.Join(valid, ","))
+}
+
+// FilterOptions selects the record sets kept by FilterRecordSets.
+type FilterOptions struct {
+	// IncludeNames keeps only the record sets whose names match one of these
+	// patterns, or every record set when empty, see MatchName.
+	IncludeNames []string
+	// ExcludeNames drops the record sets whose names match one of these
+	// patterns, even when they match an include pattern.
+	ExcludeNames []string
+}
+
+// FilterRecordSets returns the records selected by opts. The patterns must be
+// valid, see ValidateNamePatterns. Use FilterRecordNames to also learn why
+// each other record was left out.
+func FilterRecordSets(records []rtypes.ResourceRecordSet, opts FilterOptions) []rtypes.ResourceRecordSet {
+	kept, _ := FilterRecordNames(records, opts.IncludeNames, opts.ExcludeNames)
+	return kept
+}
+
+// KeepResourceRecordsWithTypes returns only the records matching one of the
+// given types. An empty list of types keeps every record.
+func KeepResourceRecordsWithTypes(records []rtypes.ResourceRecordSet, types []rtypes.RRType) []rtypes.ResourceRecordSet {
+	if len(types) == 0 {
+		return records
+	}
+	filtered := []rtypes.ResourceRecordSet{}
+	for _, record := range records {
+		if typeInList(types, record.Type) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// ParseRecordTypes converts record type names such as "A" or "cname" into
+// RRTypes, returning an InvalidRecordType error for unknown names.
+func ParseRecordTypes(names []string) ([]rtypes.RRType, error) {
+	types := []rtypes.RRType{}
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		t := rtypes.RRType(name)
+		if !typeInList(rtypes.RRType("").Values(), t) {
+			return nil, &InvalidRecordType{Type: name}
+		}
+		if !typeInList(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// RemoveApexRecords removes the NS and SOA records at the apex of domain,
+// which are managed by Route53 and must not be copied between zones.
+func RemoveApexRecords(domain string, records []rtypes.ResourceRecordSet) []rtypes.ResourceRecordSet {
 	filtered := []rtypes.ResourceRecordSet{}
 	for _, record := range records {
-		if !typeInList(types, record.Type) {
+		if !isApexRecord(domain, record) {
 			filtered = append(filtered, record)
 		}
 	}
 	return filtered
 }
 
-func FindNSRecord(records []rtypes.ResourceRecordSet) (rtypes.ResourceRecordSet, error) {
+// RemoveDelegations removes the NS records delegating subdomains of domain
+// to other nameservers, and the DS records signing them.
+func RemoveDelegations(domain string, records []rtypes.ResourceRecordSet) []rtypes.ResourceRecordSet {
+	filtered := []rtypes.ResourceRecordSet{}
 	for _, record := range records {
-		if record.Type == rtypes.RRTypeNs {
-			return record, nil
+		if !isDelegation(domain, record) {
+			filtered = append(filtered, record)
 		}
 	}
-	return rtypes.ResourceRecordSet{}, fmt.Errorf("no NS record found")
+	return filtered
+}
+
+func isApexRecord(domain string, record rtypes.ResourceRecordSet) bool {
+	if record.Type != rtypes.RRTypeNs && record.Type != rtypes.RRTypeSoa {
+		return false
+	}
+	return sameDomain(aws.ToString(record.Name), domain)
+}
+
+// isDelegation reports whether record is part of the delegation of a
+// subdomain. Route53 only accepts DS records next to the NS records of a
+// delegation, so they go together.
+func isDelegation(domain string, record rtypes.ResourceRecordSet) bool {
+	if record.Type != rtypes.RRTypeNs && record.Type != rtypes.RRTypeDs {
+		return false
+	}
+	return !sameDomain(aws.ToString(record.Name), domain)
+}
+
+// acmValidationDomain is the domain ACM DNS validation CNAMEs point into.
+const acmValidationDomain = "acm-validations.aws."
+
+// IsValidationRecord reports whether record proves control of the domain to
+// a certificate authority for its own account, so it should not be copied:
+// an ACM validation CNAME, named with a leading underscore label and pointing
+// into acm-validations.aws, or an ACME _acme-challenge TXT record. Other
+// underscore records, such as _dmarc, are not validation records.
+func IsValidationRecord(record rtypes.ResourceRecordSet) bool {
+	name := strings.ToLower(DecodeName(aws.ToString(record.Name)))
+	label := strings.SplitN(name, ".", 2)[0]
+	switch record.Type {
+	case rtypes.RRTypeTxt:
+		return label == "_acme-challenge"
+	case rtypes.RRTypeCname:
+		if !strings.HasPrefix(label, "_") || len(record.ResourceRecords) == 0 {
+			return false
+		}
+		for _, rr := range record.ResourceRecords {
+			value := normalizeDomain(strings.ToLower(aws.ToString(rr.Value)))
+			if !strings.HasSuffix(value, "."+acmValidationDomain) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// sameDomain reports whether a and b name the same domain, comparing them
+// in the form Route53 lists zones by, see zoneLookupName.
+func sameDomain(a, b string) bool {
+	return zoneLookupName(a) == zoneLookupName(b)
 }
 
 func typeInList(types []rtypes.RRType, t rtypes.RRType) bool {
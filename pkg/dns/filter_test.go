@@ -0,0 +1,268 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestFilterRecordSets(t *testing.T) {
+	records := []rtypes.ResourceRecordSet{
+		recordSet("example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet(`\052.example.com.`, rtypes.RRTypeA, "192.0.2.2"),
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+		recordSet("API.Staging.example.com.", rtypes.RRTypeA, "192.0.2.4"),
+		recordSet("db.eu.staging.example.com.", rtypes.RRTypeA, "192.0.2.5"),
+	}
+	tests := []struct {
+		name string
+		opts FilterOptions
+		want []string
+	}{
+		{
+			name: "no patterns",
+			want: []string{"example.com.", `\052.example.com.`, "www.example.com.", "API.Staging.example.com.", "db.eu.staging.example.com."},
+		},
+		{
+			name: "glob within a label",
+			opts: FilterOptions{IncludeNames: []string{"*.staging.example.com"}},
+			want: []string{"API.Staging.example.com."},
+		},
+		{
+			name: "glob across labels",
+			opts: FilterOptions{IncludeNames: []string{"**.staging.example.com."}},
+			want: []string{"API.Staging.example.com.", "db.eu.staging.example.com."},
+		},
+		{
+			name: "octal escaped wildcard",
+			opts: FilterOptions{IncludeNames: []string{"*.example.com"}},
+			want: []string{`\052.example.com.`, "www.example.com."},
+		},
+		{
+			name: "question mark",
+			opts: FilterOptions{IncludeNames: []string{"ww?.example.com"}},
+			want: []string{"www.example.com."},
+		},
+		{
+			name: "exclude wins over include",
+			opts: FilterOptions{IncludeNames: []string{"**.example.com"}, ExcludeNames: []string{"**.staging.example.com"}},
+			want: []string{`\052.example.com.`, "www.example.com."},
+		},
+		{
+			name: "unanchored regular expression",
+			opts: FilterOptions{ExcludeNames: []string{`re:staging`}},
+			want: []string{"example.com.", `\052.example.com.`, "www.example.com."},
+		},
+		{
+			name: "anchored regular expression",
+			opts: FilterOptions{IncludeNames: []string{`re:^[a-z]+\.example\.com$`}},
+			want: []string{"www.example.com."},
+		},
+		{
+			name: "regular expression on the decoded wildcard",
+			opts: FilterOptions{IncludeNames: []string{`re:^\*\.`}},
+			want: []string{`\052.example.com.`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, rs := range FilterRecordSets(records, tt.opts) {
+				got = append(got, aws.ToString(rs.Name))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRecordNamesReasons(t *testing.T) {
+	records := []rtypes.ResourceRecordSet{
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet("test.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+		recordSet("mail.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+	}
+	kept, excluded := FilterRecordNames(records, []string{"www.example.com", "test.example.com"}, []string{"test.**"})
+	if len(kept) != 1 || aws.ToString(kept[0].Name) != "www.example.com." {
+		t.Fatalf("kept %v, want www.example.com.", kept)
+	}
+	reasons := map[string]string{}
+	for _, e := range excluded {
+		if e.Cause != ExcludedName {
+			t.Errorf("%s excluded for %s, want %s", aws.ToString(e.Record.Name), e.Cause, ExcludedName)
+		}
+		reasons[aws.ToString(e.Record.Name)] = e.Reason
+	}
+	if got := reasons["test.example.com."]; got != `matches exclude pattern "test.**"` {
+		t.Errorf("test.example.com. excluded because it %s", got)
+	}
+	if got := reasons["mail.example.com."]; got != "matches no include pattern" {
+		t.Errorf("mail.example.com. excluded because it %s", got)
+	}
+}
+
+func TestValidateNamePatterns(t *testing.T) {
+	if err := ValidateNamePatterns([]string{"*.example.com", "[", `re:^www\.`}); err != nil {
+		t.Errorf("valid patterns rejected: %s", err)
+	}
+	err := ValidateNamePatterns([]string{"*.example.com", "re:(www"})
+	var ip *InvalidNamePattern
+	if !errors.As(err, &ip) || ip.Pattern != "re:(www" {
+		t.Errorf("got %v, want an InvalidNamePattern for re:(www", err)
+	}
+}
+
+func TestInSubtree(t *testing.T) {
+	tests := []struct {
+		name string
+		root string
+		want bool
+	}{
+		{name: "api.example.com.", root: "api.example.com", want: true},
+		{name: "api.example.com", root: "api.example.com.", want: true},
+		{name: "API.Example.com.", root: "api.example.COM", want: true},
+		{name: "v1.api.example.com.", root: "api.example.com", want: true},
+		{name: "db.eu.api.example.com.", root: "api.example.com", want: true},
+		{name: "*.api.example.com.", root: "api.example.com", want: true},
+		{name: `\052.api.example.com.`, root: "api.example.com", want: true},
+		{name: `\052.api.example.com.`, root: "*.api.example.com", want: true},
+		{name: "myapi.example.com.", root: "api.example.com", want: false},
+		{name: "example.com.", root: "api.example.com", want: false},
+		{name: "api.example.net.", root: "api.example.com", want: false},
+		{name: "api.example.com.evil.net.", root: "api.example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" under "+tt.root, func(t *testing.T) {
+			if got := InSubtree(tt.name, tt.root); got != tt.want {
+				t.Errorf("InSubtree(%q, %q) = %v, want %v", tt.name, tt.root, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRecordSubtrees(t *testing.T) {
+	records := []rtypes.ResourceRecordSet{
+		recordSet("example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+		recordSet(`\052.api.example.com.`, rtypes.RRTypeA, "192.0.2.3"),
+		recordSet("v1.api.example.com.", rtypes.RRTypeTxt, `"v=1"`),
+		recordSet("myapi.example.com.", rtypes.RRTypeA, "192.0.2.4"),
+		recordSet("mail.example.com.", rtypes.RRTypeMx, "10 mx.example.com."),
+	}
+	kept, excluded := FilterRecordSubtrees(records, []string{"api.example.com.", "MAIL.example.com"})
+	want := `api.example.com. A, \052.api.example.com. A, v1.api.example.com. TXT, mail.example.com. MX`
+	if got := recordNames(kept); got != want {
+		t.Errorf("kept %s, want %s", got, want)
+	}
+	if got := recordNames(excludedRecords(excluded)); got != "example.com. A, myapi.example.com. A" {
+		t.Errorf("excluded %s", got)
+	}
+}
+
+func excludedRecords(excluded []ExcludedRecord) []rtypes.ResourceRecordSet {
+	records := []rtypes.ResourceRecordSet{}
+	for _, e := range excluded {
+		records = append(records, e.Record)
+	}
+	return records
+}
+
+func TestIsValidationRecord(t *testing.T) {
+	acm := "_3b5f6f2a1c9e8d7b6a5f4e3d2c1b0a99.abcdefghij.acm-validations.aws."
+	tests := []struct {
+		name   string
+		record rtypes.ResourceRecordSet
+		want   bool
+	}{
+		{name: "ACM validation CNAME", record: recordSet("_a79865eb4cd1a6ab990a45779b4e0b96.example.com.", rtypes.RRTypeCname, acm), want: true},
+		{name: "ACM validation CNAME of a subdomain", record: recordSet("_a79865eb4cd1a6ab990a45779b4e0b96.www.example.com.", rtypes.RRTypeCname, acm), want: true},
+		{name: "ACM validation CNAME in upper case without a dot", record: recordSet("_A79865EB.example.com.", rtypes.RRTypeCname, strings.ToUpper(strings.TrimSuffix(acm, "."))), want: true},
+		{name: "ACME challenge", record: recordSet("_acme-challenge.example.com.", rtypes.RRTypeTxt, `"gfj9Xq...Rg85nM"`), want: true},
+		{name: "ACME challenge of a subdomain", record: recordSet("_ACME-Challenge.www.example.com.", rtypes.RRTypeTxt, `"gfj9Xq...Rg85nM"`), want: true},
+		{name: "ACME challenge delegated with a CNAME", record: recordSet("_acme-challenge.example.com.", rtypes.RRTypeCname, "example.com.acme.example.net.")},
+		{name: "DMARC", record: recordSet("_dmarc.example.com.", rtypes.RRTypeTxt, `"v=DMARC1; p=none"`)},
+		{name: "DKIM CNAME", record: recordSet("s1._domainkey.example.com.", rtypes.RRTypeCname, "s1.domainkey.u123.wl.sendgrid.net.")},
+		{name: "underscore CNAME elsewhere", record: recordSet("_sip.example.com.", rtypes.RRTypeCname, "sip.example.net.")},
+		{name: "CNAME to acm-validations.aws without an underscore", record: recordSet("www.example.com.", rtypes.RRTypeCname, acm)},
+		{name: "CNAME to a look-alike domain", record: recordSet("_a79865eb.example.com.", rtypes.RRTypeCname, "_x.fake-acm-validations.aws.")},
+		{name: "ACME challenge label lower in the name", record: recordSet("www._acme-challenge.example.com.", rtypes.RRTypeTxt, `"token"`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidationRecord(tt.record); got != tt.want {
+				t.Errorf("IsValidationRecord(%s %s) = %t, want %t", aws.ToString(tt.record.Name), tt.record.Type, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipValidationRecords(t *testing.T) {
+	records := []rtypes.ResourceRecordSet{
+		recordSet("_a79865eb4cd1a6ab990a45779b4e0b96.example.com.", rtypes.RRTypeCname, "_3b5f6f2a.abcdefghij.acm-validations.aws."),
+		recordSet("_acme-challenge.example.com.", rtypes.RRTypeTxt, `"token"`),
+		recordSet("_dmarc.example.com.", rtypes.RRTypeTxt, `"v=DMARC1; p=none"`),
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+	}
+	r := &RouteCopy{}
+	var logs bytes.Buffer
+	w := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(w) })
+
+	changes, excluded := r.CreateChangesWithOptions(context.Background(), "example.com", records, ChangeOptions{SkipValidationRecords: true})
+	copied := []rtypes.ResourceRecordSet{}
+	for _, c := range changes {
+		copied = append(copied, *c.ResourceRecordSet)
+	}
+	if got := recordNames(copied); got != "_dmarc.example.com. TXT, www.example.com. A" {
+		t.Errorf("copied %s", got)
+	}
+	for _, e := range excluded {
+		if e.Cause != ExcludedValidation {
+			t.Errorf("%s excluded for %s, want %s", aws.ToString(e.Record.Name), e.Cause, ExcludedValidation)
+		}
+		if !strings.Contains(logs.String(), "Skipping certificate validation record "+aws.ToString(e.Record.Name)) {
+			t.Errorf("skipping %s is not logged", aws.ToString(e.Record.Name))
+		}
+	}
+	if len(excluded) != 2 {
+		t.Errorf("excluded %d records, want 2", len(excluded))
+	}
+
+	changes, _ = r.CreateChangesWithOptions(context.Background(), "example.com", records, ChangeOptions{})
+	if len(changes) != len(records) {
+		t.Errorf("copied %d records without the option, want all %d", len(changes), len(records))
+	}
+}
+
+func TestSameDomain(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "example.com", b: "example.com.", want: true},
+		{a: "Example.COM", b: "example.com.", want: true},
+		{a: "münchen-shop.de", b: "xn--mnchen-shop-thb.de.", want: true},
+		{a: "MÜNCHEN-shop.de.", b: "XN--MNCHEN-SHOP-THB.DE", want: true},
+		// Not a valid IDN, compared by its escaped form.
+		{a: `_dmarc.caf\303\251.example.com.`, b: "_DMARC.café.example.com", want: true},
+		{a: `\052.example.com.`, b: "*.example.com", want: true},
+		{a: `\052.example.com.`, b: "example.com", want: false},
+		{a: "www.example.com", b: "example.com", want: false},
+		{a: "münchen.de", b: "munchen.de", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := sameDomain(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameDomain(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
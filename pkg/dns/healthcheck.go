@@ -0,0 +1,313 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// SourceIDTag is the tag set on copied health checks pointing back to the
+// health check they were copied from, so re-runs reuse them.
+const SourceIDTag = "route53copy:source-id"
+
+// maxTagResources is the most resource ids ListTagsForResources accepts.
+const maxTagResources = 10
+
+// HealthCheckIDs returns the distinct health check ids referenced by changes.
+func HealthCheckIDs(changes []rtypes.Change) []string {
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, c := range changes {
+		id := aws.ToString(c.ResourceRecordSet.HealthCheckId)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// RewriteHealthCheckIDs returns a copy of changes with health check ids
+// replaced according to ids. Ids missing from the map are kept, and ids
+// mapped to "" are removed.
+func RewriteHealthCheckIDs(changes []rtypes.Change, ids map[string]string) []rtypes.Change {
+	rewritten := []rtypes.Change{}
+	for _, c := range changes {
+		if id, ok := ids[aws.ToString(c.ResourceRecordSet.HealthCheckId)]; ok {
+			rs := *c.ResourceRecordSet
+			rs.HealthCheckId = aws.String(id)
+			if id == "" {
+				rs.HealthCheckId = nil
+			}
+			c.ResourceRecordSet = &rs
+		}
+		rewritten = append(rewritten, c)
+	}
+	return rewritten
+}
+
+// HealthCheckCopier copies health checks from a source account to a
+// destination account, so the copied records can refer to health checks of
+// their own account, see RewriteHealthCheckIDs.
+type HealthCheckCopier struct {
+	// IDs maps the ids of the source health checks handled so far to the
+	// ids of their copies, or to "" for the ones that cannot be copied.
+	IDs map[string]string
+
+	src, dst *RouteCopy
+	// tagged and created map source ids to the destination health checks
+	// carrying their SourceIDTag, and to the ones created with their caller
+	// reference, see healthCheckCallerReference.
+	tagged  map[string]string
+	created map[string]string
+}
+
+// NewHealthCheckCopier returns a HealthCheckCopier from src to dst.
+func NewHealthCheckCopier(src, dst *RouteCopy) *HealthCheckCopier {
+	return &HealthCheckCopier{IDs: map[string]string{}, src: src, dst: dst}
+}
+
+// Copy creates the health checks with the given ids in the destination,
+// together with their tags, unless they are in IDs already. Health checks
+// copied by a previous run are reused: they are found through their
+// SourceIDTag, or through their caller reference when tagging them failed.
+//
+// Health checks monitoring a CloudWatch alarm cannot be copied, since the
+// alarm stays in the source account, and neither can calculated health
+// checks, which are made of other health checks. Both are mapped to "" with
+// a warning, so the copied records lose them.
+func (c *HealthCheckCopier) Copy(ctx context.Context, ids []string) error {
+	if c.tagged == nil {
+		err := c.findCopies(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		if _, ok := c.IDs[id]; ok {
+			continue
+		}
+		dstID, err := c.copy(ctx, id)
+		if err != nil {
+			return err
+		}
+		c.IDs[id] = dstID
+	}
+	return nil
+}
+
+func (c *HealthCheckCopier) copy(ctx context.Context, id string) (string, error) {
+	if dstID, ok := c.tagged[id]; ok {
+		logging.From(ctx).Infof("Health check %s was already copied as %s\n", id, dstID)
+		return dstID, nil
+	}
+
+	resp, err := c.src.cli.GetHealthCheck(ctx, &route53.GetHealthCheckInput{
+		HealthCheckId: aws.String(id),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get health check %s: %w", id, err)
+	}
+	cfg := *resp.HealthCheck.HealthCheckConfig
+
+	switch cfg.Type {
+	case rtypes.HealthCheckTypeCloudwatchMetric:
+		alarm := ""
+		if cfg.AlarmIdentifier != nil {
+			alarm = aws.ToString(cfg.AlarmIdentifier.Name)
+		}
+		logging.From(ctx).Warnf("Health check %s monitors the CloudWatch alarm %s of the source account and cannot be copied, leaving it out\n", id, alarm)
+		return "", nil
+	case rtypes.HealthCheckTypeCalculated:
+		logging.From(ctx).Warnf("Health check %s is calculated from the health checks %s and cannot be copied, leaving it out\n",
+			id, strings.Join(cfg.ChildHealthChecks, ", "))
+		return "", nil
+	}
+
+	tags, err := c.src.healthCheckTags(ctx, []string{id})
+	if err != nil {
+		return "", err
+	}
+
+	dstID, ok := c.created[id]
+	if ok {
+		logging.From(ctx).Infof("Health check %s was already created as %s, tagging it\n", id, dstID)
+	} else {
+		created, err := c.dst.cli.CreateHealthCheck(ctx, &route53.CreateHealthCheckInput{
+			CallerReference:   aws.String(healthCheckCallerReference(id)),
+			HealthCheckConfig: &cfg,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create health check copied from %s: %w", id, err)
+		}
+		dstID = aws.ToString(created.HealthCheck.Id)
+	}
+
+	addTags := []rtypes.Tag{}
+	for _, tag := range tags[id] {
+		if aws.ToString(tag.Key) != SourceIDTag {
+			addTags = append(addTags, tag)
+		}
+	}
+	addTags = append(addTags, rtypes.Tag{
+		Key:   aws.String(SourceIDTag),
+		Value: aws.String(id),
+	})
+	_, err = c.dst.cli.ChangeTagsForResource(ctx, &route53.ChangeTagsForResourceInput{
+		ResourceId:   aws.String(dstID),
+		ResourceType: rtypes.TagResourceTypeHealthcheck,
+		AddTags:      addTags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to tag health check %s: %w", dstID, err)
+	}
+
+	logging.From(ctx).Infof("Health check %s copied as %s\n", id, dstID)
+	return dstID, nil
+}
+
+// findCopies looks up the health checks of the destination copied from
+// source health checks, by their SourceIDTag and by their caller reference.
+func (c *HealthCheckCopier) findCopies(ctx context.Context) error {
+	c.tagged = map[string]string{}
+	c.created = map[string]string{}
+	ids := []string{}
+	paginator := route53.NewListHealthChecksPaginator(c.dst.cli, &route53.ListHealthChecksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, hc := range page.HealthChecks {
+			id := aws.ToString(hc.Id)
+			ids = append(ids, id)
+			if ref := aws.ToString(hc.CallerReference); strings.HasPrefix(ref, healthCheckCallerPrefix) {
+				c.created[strings.TrimPrefix(ref, healthCheckCallerPrefix)] = id
+			}
+		}
+	}
+
+	tags, err := c.dst.healthCheckTags(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for id, tagList := range tags {
+		for _, tag := range tagList {
+			if aws.ToString(tag.Key) == SourceIDTag {
+				c.tagged[aws.ToString(tag.Value)] = id
+			}
+		}
+	}
+	return nil
+}
+
+// healthCheckCallerPrefix starts the caller references of copied health
+// checks.
+const healthCheckCallerPrefix = "route53copy-"
+
+// healthCheckCallerReference returns the caller reference of the copy of
+// the source health check id, the same on every run.
+func healthCheckCallerReference(id string) string {
+	return healthCheckCallerPrefix + id
+}
+
+func (r *RouteCopy) healthCheckTags(ctx context.Context, ids []string) (map[string][]rtypes.Tag, error) {
+	tags := map[string][]rtypes.Tag{}
+	for start := 0; start < len(ids); start += maxTagResources {
+		end := start + maxTagResources
+		if end > len(ids) {
+			end = len(ids)
+		}
+		resp, err := r.cli.ListTagsForResources(ctx, &route53.ListTagsForResourcesInput{
+			ResourceIds:  ids[start:end],
+			ResourceType: rtypes.TagResourceTypeHealthcheck,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list health check tags: %w", err)
+		}
+		for _, set := range resp.ResourceTagSets {
+			tags[aws.ToString(set.ResourceId)] = set.Tags
+		}
+	}
+	return tags, nil
+}
+
+// MissingHealthChecks is returned when records refer to health checks that
+// do not exist in the destination account, see
+// CopyOptions.RequireHealthChecks.
+type MissingHealthChecks struct {
+	Profile string
+	IDs     []string
+	// Records are the names and types of the records referring to them.
+	Records []string
+}
+
+func (e *MissingHealthChecks) Error() string {
+	return fmt.Sprintf("health checks %s used by %s do not exist in %s",
+		strings.Join(e.IDs, ", "), strings.Join(e.Records, ", "), e.Profile)
+}
+
+// missingHealthChecks returns the health checks referenced by changes that
+// r does not have.
+func (r *RouteCopy) missingHealthChecks(ctx context.Context, changes []rtypes.Change) (map[string]bool, error) {
+	ids := HealthCheckIDs(changes)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	existing, err := r.HealthCheckIDSet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	missing := map[string]bool{}
+	for _, id := range ids {
+		if !existing[id] {
+			missing[id] = true
+		}
+	}
+	return missing, nil
+}
+
+// checkHealthChecks returns a MissingHealthChecks when changes refer to
+// health checks r does not have, or, with strip, a copy of changes without
+// those health checks.
+func (r *RouteCopy) checkHealthChecks(ctx context.Context, changes []rtypes.Change, strip bool) ([]rtypes.Change, error) {
+	missing, err := r.missingHealthChecks(ctx, changes)
+	if err != nil || len(missing) == 0 {
+		return changes, err
+	}
+
+	checked := []rtypes.Change{}
+	records := []string{}
+	for _, c := range changes {
+		id := aws.ToString(c.ResourceRecordSet.HealthCheckId)
+		if !missing[id] {
+			checked = append(checked, c)
+			continue
+		}
+		name := DecodeName(aws.ToString(c.ResourceRecordSet.Name)) + " " + string(c.ResourceRecordSet.Type)
+		if strip {
+			logging.From(ctx).Warnf("Removing health check %s from %s, it does not exist in %s\n", id, name, r.profile)
+			rs := *c.ResourceRecordSet
+			rs.HealthCheckId = nil
+			c.ResourceRecordSet = &rs
+		}
+		checked = append(checked, c)
+		records = append(records, name)
+	}
+	if strip {
+		return checked, nil
+	}
+	ids := []string{}
+	for _, id := range HealthCheckIDs(changes) {
+		if missing[id] {
+			ids = append(ids, id)
+		}
+	}
+	return changes, &MissingHealthChecks{Profile: r.profile, IDs: ids, Records: records}
+}
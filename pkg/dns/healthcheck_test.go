@@ -0,0 +1,159 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// sourceHealthChecks adds an HTTP health check tagged Name=web, one
+// monitoring a CloudWatch alarm and one calculated from the other two to
+// server, and returns their ids.
+func sourceHealthChecks(server *fakeroute53.Server) (string, string, string) {
+	web := server.AddHealthCheck(rtypes.HealthCheckConfig{
+		Type:                     rtypes.HealthCheckTypeHttps,
+		FullyQualifiedDomainName: aws.String("www.example.com"),
+		Port:                     aws.Int32(443),
+		ResourcePath:             aws.String("/health"),
+		RequestInterval:          aws.Int32(30),
+		FailureThreshold:         aws.Int32(3),
+	}, map[string]string{"Name": "web"})
+	alarm := server.AddHealthCheck(rtypes.HealthCheckConfig{
+		Type: rtypes.HealthCheckTypeCloudwatchMetric,
+		AlarmIdentifier: &rtypes.AlarmIdentifier{
+			Region: rtypes.CloudWatchRegionUsEast1,
+			Name:   aws.String("api-errors"),
+		},
+		InsufficientDataHealthStatus: rtypes.InsufficientDataHealthStatusLastKnownStatus,
+	}, nil)
+	calculated := server.AddHealthCheck(rtypes.HealthCheckConfig{
+		Type:              rtypes.HealthCheckTypeCalculated,
+		ChildHealthChecks: []string{web, alarm},
+		HealthThreshold:   aws.Int32(2),
+	}, nil)
+	return web, alarm, calculated
+}
+
+func TestHealthCheckCopier(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	web, alarm, calculated := sourceHealthChecks(srcServer)
+
+	copier := NewHealthCheckCopier(src, dst)
+	err := copier.Copy(ctx, []string{calculated, alarm, web})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copier.IDs[alarm] != "" || copier.IDs[calculated] != "" {
+		t.Errorf("the alarm and calculated health checks were copied as %q and %q", copier.IDs[alarm], copier.IDs[calculated])
+	}
+	copied := dstServer.HealthChecks()
+	if len(copied) != 1 {
+		t.Fatalf("the destination has %d health checks, want only the copy of %s", len(copied), web)
+	}
+	dstID := copier.IDs[web]
+	cfg, ok := copied[dstID]
+	if !ok {
+		t.Fatalf("%s was copied as %q, which does not exist", web, dstID)
+	}
+	if want := srcServer.HealthChecks()[web]; !reflect.DeepEqual(cfg, want) {
+		t.Errorf("copied config %+v, want %+v", cfg, want)
+	}
+	tags := dstServer.HealthCheckTags(dstID)
+	if tags["Name"] != "web" || tags[SourceIDTag] != web {
+		t.Errorf("copy tagged %v, want Name=web and %s=%s", tags, SourceIDTag, web)
+	}
+
+	creates := dstServer.Calls(fakeroute53.OpCreateHealthCheck)
+	rerun := NewHealthCheckCopier(src, dst)
+	err = rerun.Copy(ctx, []string{web, alarm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rerun.IDs[web] != dstID {
+		t.Errorf("the re-run mapped %s to %s, want the copy %s", web, rerun.IDs[web], dstID)
+	}
+	if got := dstServer.Calls(fakeroute53.OpCreateHealthCheck); got != creates {
+		t.Errorf("the re-run created %d health checks", got-creates)
+	}
+}
+
+func TestHealthCheckCopierRerunAfterTagFailure(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	web, _, _ := sourceHealthChecks(srcServer)
+
+	dstServer.Fail = func(operation string) string {
+		if operation == fakeroute53.OpChangeTagsForResource {
+			return "InvalidInput"
+		}
+		return ""
+	}
+	err := NewHealthCheckCopier(src, dst).Copy(ctx, []string{web})
+	if err == nil {
+		t.Fatal("tagging the copy did not fail")
+	}
+	if copied := dstServer.HealthChecks(); len(copied) != 1 {
+		t.Fatalf("the destination has %d health checks, want the untagged copy", len(copied))
+	}
+
+	dstServer.Fail = nil
+	creates := dstServer.Calls(fakeroute53.OpCreateHealthCheck)
+	copier := NewHealthCheckCopier(src, dst)
+	err = copier.Copy(ctx, []string{web})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dstServer.Calls(fakeroute53.OpCreateHealthCheck); got != creates {
+		t.Errorf("the re-run created %d health checks instead of tagging the first copy", got-creates)
+	}
+	copied := dstServer.HealthChecks()
+	if _, ok := copied[copier.IDs[web]]; len(copied) != 1 || !ok {
+		t.Fatalf("the destination has %d health checks and %s maps to %q, want the first copy", len(copied), web, copier.IDs[web])
+	}
+	if tags := dstServer.HealthCheckTags(copier.IDs[web]); tags[SourceIDTag] != web {
+		t.Errorf("the copy is tagged %v, want %s=%s", tags, SourceIDTag, web)
+	}
+}
+
+func TestCopyZoneHealthChecks(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	web, alarm, _ := sourceHealthChecks(srcServer)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	primary := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	primary.SetIdentifier = aws.String("primary")
+	primary.Failover = rtypes.ResourceRecordSetFailoverPrimary
+	primary.HealthCheckId = aws.String(web)
+	secondary := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.2")
+	secondary.SetIdentifier = aws.String("secondary")
+	secondary.Failover = rtypes.ResourceRecordSetFailoverSecondary
+	secondary.HealthCheckId = aws.String(alarm)
+	srcServer.AddRecords(srcZoneID, primary, secondary)
+	// Both servers number their resources alike, so another zone keeps the
+	// ids of the copies apart from the source ones.
+	dstServer.AddZone("example.org", false)
+
+	result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", CopyHealthChecks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := dstServer.Records(aws.ToString(result.DestinationZone.Id))
+	checks := map[string]string{}
+	for _, rs := range records {
+		if rs.SetIdentifier != nil {
+			checks[aws.ToString(rs.SetIdentifier)] = aws.ToString(rs.HealthCheckId)
+		}
+	}
+	copied := dstServer.HealthChecks()
+	if _, ok := copied[checks["primary"]]; !ok || checks["primary"] == web {
+		t.Errorf("the primary record refers to %q, want the copy of %s", checks["primary"], web)
+	}
+	if id, ok := checks["secondary"]; !ok || id != "" {
+		t.Errorf("the secondary record refers to %q, want its alarm health check removed", id)
+	}
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// TestEndpointEnv is the environment variable holding the URL of the Route53
+// emulator, such as moto, the integration tests run against:
+//
+//	moto_server -p 5000 &
+//	ROUTE53COPY_TEST_ENDPOINT=http://localhost:5000 go test -tags integration ./pkg/dns
+const TestEndpointEnv = "ROUTE53COPY_TEST_ENDPOINT"
+
+func TestCopyZoneEmulator(t *testing.T) {
+	endpoint := os.Getenv(TestEndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s is not set", TestEndpointEnv)
+	}
+	ctx := context.Background()
+	sharedConfig(t, "[profile emulator]\naws_access_key_id = AKIDTEST\naws_secret_access_key = secret\n", "")
+	r, err := NewRouteCopy(ctx, "emulator", WithEndpoint(endpoint, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The emulator may outlive the test, so every run uses its own zones.
+	domain := fmt.Sprintf("route53copy-%d.test", time.Now().UnixNano())
+	copied := "copy." + domain
+
+	srcZone, err := r.CreateZone(ctx, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deleteEmulatorZone(t, r, aws.ToString(srcZone.Id), domain) })
+	records := append(hostRecords(domain, 10), recordSet(domain+".", rtypes.RRTypeTxt, `"v=spf1 -all"`))
+	changes := []rtypes.Change{}
+	for i := range records {
+		changes = append(changes, rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &records[i]})
+	}
+	if _, err := r.UpdateRecords(ctx, aws.ToString(srcZone.Id), "", changes, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CopyZone(ctx, r, r, CopyOptions{Domain: domain, DestinationDomain: copied, RewriteValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deleteEmulatorZone(t, r, aws.ToString(result.DestinationZone.Id), copied) })
+	if !result.CreatedZone {
+		t.Errorf("the copy did not create %s", copied)
+	}
+	dstRecords, err := r.GetResourceRecords(ctx, aws.ToString(result.DestinationZone.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rs := range records {
+		name := strings.TrimSuffix(aws.ToString(rs.Name), domain+".") + copied + "."
+		if _, ok := findRecord(dstRecords, name); !ok {
+			t.Errorf("%s %s was not copied", name, rs.Type)
+		}
+	}
+}
+
+// deleteEmulatorZone deletes the records of a zone created by a test, and
+// the zone.
+func deleteEmulatorZone(t *testing.T, r *RouteCopy, zoneID, domain string) {
+	ctx := context.Background()
+	records, err := r.GetResourceRecords(ctx, zoneID)
+	if err == nil {
+		records = RemoveResourceRecordsWithTypes(records, []rtypes.RRType{rtypes.RRTypeNs, rtypes.RRTypeSoa}, KeepDelegations(domain))
+		_, err = r.DeleteRecords(ctx, zoneID, domain, records, time.Minute)
+	}
+	if err == nil {
+		_, err = r.DeleteHostedZone(ctx, zoneID)
+	}
+	if err != nil {
+		t.Logf("could not delete %s: %v", domain, err)
+	}
+}
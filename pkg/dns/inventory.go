@@ -0,0 +1,77 @@
+package dns
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// ZoneInfo summarizes a hosted zone of an account, see InventoryZones.
+type ZoneInfo struct {
+	Name    string `json:"name"`
+	ID      string `json:"id"`
+	Records int64  `json:"records"`
+	Private bool   `json:"private"`
+	// Registered reports whether the domain is registered in the account,
+	// and is nil when the registered domains could not be listed.
+	Registered *bool `json:"registered,omitempty"`
+	// DNSSEC is the signing status of a public zone, empty for private
+	// zones or when it could not be looked up.
+	DNSSEC string `json:"dnssec,omitempty"`
+}
+
+// ListRegisteredDomains returns the domains registered in the account.
+func (r *RouteCopy) ListRegisteredDomains(ctx context.Context) ([]string, error) {
+	return listRegisteredDomains(ctx, r.domains)
+}
+
+// InventoryZones returns every hosted zone of the account sorted by name,
+// with whether its domain is registered in the account and its DNSSEC
+// status. Failing to list the registered domains or to get the status of a
+// zone is only logged, since it needs other permissions than the zones.
+func (r *RouteCopy) InventoryZones(ctx context.Context) ([]ZoneInfo, error) {
+	zones, err := r.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var registered map[string]bool
+	domains, err := r.ListRegisteredDomains(ctx)
+	if err != nil {
+		logging.From(ctx).Warnf("Could not list the domains registered with %s: %s\n", r.profile, err)
+	} else {
+		registered = map[string]bool{}
+		for _, d := range domains {
+			registered[strings.ToLower(normalizeDomain(d))] = true
+		}
+	}
+
+	infos := []ZoneInfo{}
+	for _, zone := range zones {
+		name := aws.ToString(zone.Name)
+		info := ZoneInfo{
+			Name:    strings.TrimSuffix(DecodeName(name), "."),
+			ID:      shortZoneID(aws.ToString(zone.Id)),
+			Records: aws.ToInt64(zone.ResourceRecordSetCount),
+			Private: isPrivateZone(zone),
+		}
+		if registered != nil {
+			info.Registered = aws.Bool(registered[strings.ToLower(normalizeDomain(name))])
+		}
+		if !info.Private {
+			d, err := r.GetDNSSEC(ctx, aws.ToString(zone.Id))
+			if err != nil {
+				logging.From(ctx).Warnf("%s\n", err)
+			}
+			info.DNSSEC = d.Status
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
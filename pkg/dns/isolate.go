@@ -0,0 +1,158 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// RejectedChange is a change Route53 rejects on its own, found by
+// isolating the changes of a rejected batch, see SetIsolateRejected.
+type RejectedChange struct {
+	Change rtypes.Change
+	Reason string
+	// Batch is the index of the batch of the change in the batches given
+	// to ApplyBatches.
+	Batch int
+}
+
+// RejectedChanges is returned by ApplyChanges when it isolated the changes
+// Route53 rejects, or skipped failed batches, and applied all the others,
+// see SetIsolateRejected and SetContinueOnError.
+type RejectedChanges struct {
+	Applied  []rtypes.Change
+	Rejected []RejectedChange
+}
+
+func (e *RejectedChanges) Error() string {
+	names := []string{}
+	for _, r := range e.Rejected {
+		rs := r.Change.ResourceRecordSet
+		names = append(names, fmt.Sprintf("%s %s", DecodeName(aws.ToString(rs.Name)), rs.Type))
+	}
+	return fmt.Sprintf("%d changes failed, the other %d were applied: %s",
+		len(e.Rejected), len(e.Applied), strings.Join(names, ", "))
+}
+
+// SetIsolateRejected makes ApplyChanges submit the changes of a batch
+// Route53 rejects with an InvalidChangeBatch in halves, down to a single
+// record set, to apply every change it accepts and find the ones it
+// rejects. The record sets of a name and type are never split, see
+// GroupChanges.
+func (r *RouteCopy) SetIsolateRejected(isolate bool) {
+	r.isolateRejected = isolate
+}
+
+// SetContinueOnError makes ApplyChanges go on with the next batches when
+// Route53 fails a batch, instead of returning a BatchError. The changes of
+// the failed batches are returned in a RejectedChanges once every batch was
+// tried. Batches submitted but not in sync still stop it.
+func (r *RouteCopy) SetContinueOnError(continueOnError bool) {
+	r.continueOnError = continueOnError
+}
+
+// rejectBatch returns the changes of a failed batch as rejected with err.
+func rejectBatch(batch []rtypes.Change, err error) []RejectedChange {
+	reason := rejectionReason(err)
+	rejected := []RejectedChange{}
+	for _, c := range batch {
+		rejected = append(rejected, RejectedChange{Change: c, Reason: reason})
+	}
+	return rejected
+}
+
+// isolate applies the groups of a batch rejected with err, halving
+// them until the groups Route53 rejects on their own are left. It returns
+// the batches it applied and the rejected changes, or an error other than
+// an InvalidChangeBatch.
+func (r *RouteCopy) isolate(ctx context.Context, zoneId, comment string, groups [][]rtypes.Change, err error, maxWait time.Duration) ([]BatchResult, []RejectedChange, error) {
+	if len(groups) == 1 {
+		reason := rejectionReason(err)
+		rejected := []RejectedChange{}
+		for _, c := range groups[0] {
+			logging.From(ctx).Warnf("Route53 rejected %s %s: %s\n", DecodeName(aws.ToString(c.ResourceRecordSet.Name)), c.ResourceRecordSet.Type, reason)
+			rejected = append(rejected, RejectedChange{Change: c, Reason: reason})
+		}
+		return nil, rejected, nil
+	}
+
+	results := []BatchResult{}
+	rejected := []RejectedChange{}
+	mid := len(groups) / 2
+	for _, half := range [][][]rtypes.Change{groups[:mid], groups[mid:]} {
+		changes := []rtypes.Change{}
+		for _, g := range half {
+			changes = append(changes, g...)
+		}
+		result, err := r.submitChanges(ctx, zoneId, comment, changes, maxWait)
+		var icb *rtypes.InvalidChangeBatch
+		switch {
+		case errors.As(err, &icb):
+			more, rej, err := r.isolate(ctx, zoneId, comment, half, err, maxWait)
+			results = append(results, more...)
+			rejected = append(rejected, rej...)
+			if err != nil {
+				return results, rejected, err
+			}
+		case err != nil:
+			if result.ChangeInfo != nil {
+				results = append(results, result)
+			}
+			return results, rejected, err
+		default:
+			results = append(results, result)
+		}
+	}
+	return results, rejected, nil
+}
+
+// submitChanges submits changes as a single batch and waits up to maxWait
+// for it to be in sync.
+func (r *RouteCopy) submitChanges(ctx context.Context, zoneId, comment string, changes []rtypes.Change, maxWait time.Duration) (BatchResult, error) {
+	params := &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+		ChangeBatch: &rtypes.ChangeBatch{
+			Changes: changes,
+		},
+	}
+	if comment != "" {
+		params.ChangeBatch.Comment = aws.String(TruncateComment(comment))
+	}
+	start := time.Now()
+	resp, err := r.cli.ChangeResourceRecordSets(ctx, params)
+	r.observe(OpChangeRecords, start)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	result := BatchResult{ChangeInfo: resp.ChangeInfo, Changes: len(changes), Submitted: changes}
+	if resp.ChangeInfo.Status != rtypes.ChangeStatusInsync {
+		start := time.Now()
+		err = r.WaitForChange(ctx, aws.ToString(resp.ChangeInfo.Id), maxWait)
+		result.Waited = time.Since(start)
+		if err != nil {
+			return result, err
+		}
+		result.ChangeInfo.Status = rtypes.ChangeStatusInsync
+	}
+	return result, nil
+}
+
+// rejectionReason returns the messages of an InvalidChangeBatch, which
+// name the rejected record sets.
+func rejectionReason(err error) string {
+	var icb *rtypes.InvalidChangeBatch
+	if !errors.As(err, &icb) {
+		return err.Error()
+	}
+	if len(icb.Messages) == 0 {
+		return aws.ToString(icb.Message)
+	}
+	return strings.Join(icb.Messages, "; ")
+}
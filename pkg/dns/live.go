@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// LiveZoneOverwrite is returned by CopyZone when the destination zone is the
+// one the domain is publicly delegated to, and the copy would overwrite some
+// of its records.
+type LiveZoneOverwrite struct {
+	Zone    string
+	Updates []RecordSetUpdate
+}
+
+func (e *LiveZoneOverwrite) Error() string {
+	return fmt.Sprintf("the destination zone '%s' is the one serving the domain, the copy would overwrite %d of its records",
+		e.Zone, len(e.Updates))
+}
+
+// LiveZoneStream is returned by CopyZone with Stream when the destination
+// zone is the one the domain is publicly delegated to, since a streamed copy
+// only knows which of its records it overwrites as it applies them.
+type LiveZoneStream struct {
+	Zone string
+}
+
+func (e *LiveZoneStream) Error() string {
+	return fmt.Sprintf("the destination zone '%s' is the one serving the domain, a streamed copy cannot check which of its records it overwrites", e.Zone)
+}
+
+// IsLiveZone reports whether the parent zone delegates the zone to the
+// nameservers in its apex NS record, found in records. A domain that is not
+// delegated, or whose delegation cannot be looked up, is not live.
+func IsLiveZone(ctx context.Context, zone rtypes.HostedZone, records []rtypes.ResourceRecordSet) bool {
+	name := aws.ToString(zone.Name)
+	var apexNS *rtypes.ResourceRecordSet
+	for i, rs := range records {
+		if isApexRecord(name, rs) && rs.Type == rtypes.RRTypeNs {
+			apexNS = &records[i]
+		}
+	}
+	if apexNS == nil {
+		return false
+	}
+	ns, err := GetDelegationFor(name)
+	if err != nil {
+		logging.From(ctx).Debugf("Not checking whether '%s' is live, its delegation was not found: %s\n", name, err)
+		return false
+	}
+	return sameNameservers(ns, *apexNS)
+}
+
+// checkLiveOverwrite keeps the changes from overwriting records of the zone
+// serving the domain unless opts.ConfirmLiveOverwrite agrees. It reports
+// whether the copy may go on.
+func checkLiveOverwrite(ctx context.Context, zone rtypes.HostedZone, changes []rtypes.Change, existing []rtypes.ResourceRecordSet, opts CopyOptions) (bool, error) {
+	updates := PreviewChanges(changes, existing).Update
+	if len(updates) == 0 || !IsLiveZone(ctx, zone, existing) {
+		return true, nil
+	}
+	err := &LiveZoneOverwrite{Zone: aws.ToString(zone.Name), Updates: updates}
+	if opts.ConfirmLiveOverwrite == nil {
+		return false, err
+	}
+	logging.From(ctx).Warnf("%s\n", err)
+	return opts.ConfirmLiveOverwrite(ctx, *err)
+}
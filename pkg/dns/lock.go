@@ -0,0 +1,255 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// LockLabel is the label of the TXT record locking a domain, which is
+// written at _route53copy-lock.<domain> in the destination zone.
+const LockLabel = "_route53copy-lock"
+
+// DefaultLockExpiry is how long a lock is held when no expiry is given. A
+// lock past its expiry is stale and taken over by the next copy.
+const DefaultLockExpiry = 30 * time.Minute
+
+// lockTTL is the TTL of the lock record, which is never meant to be
+// resolved.
+const lockTTL = 60
+
+// Lock is an advisory lock on a domain, held by the owner running a copy
+// into it.
+type Lock struct {
+	Domain   string
+	Owner    string
+	Host     string
+	Acquired time.Time
+	Expires  time.Time
+
+	record rtypes.ResourceRecordSet
+}
+
+// Expired reports whether the lock is stale at now.
+func (l Lock) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+func (l Lock) String() string {
+	return fmt.Sprintf("%s@%s since %s until %s", l.Owner, l.Host,
+		l.Acquired.Format(time.RFC3339), l.Expires.Format(time.RFC3339))
+}
+
+// value formats the lock as the value of its TXT record.
+func (l Lock) value() string {
+	return fmt.Sprintf(`"owner=%s host=%s acquired=%s expires=%s"`, l.Owner, l.Host,
+		l.Acquired.UTC().Format(time.RFC3339), l.Expires.UTC().Format(time.RFC3339))
+}
+
+// ZoneLocked is returned by AcquireLock when another copy holds a lock on
+// the domain that has not expired.
+type ZoneLocked struct {
+	Lock Lock
+}
+
+func (e *ZoneLocked) Error() string {
+	return fmt.Sprintf("'%s' is locked by %s", e.Lock.Domain, e.Lock)
+}
+
+// LockOptions are the options used by AcquireLock.
+type LockOptions struct {
+	// Owner and Host identify who holds the lock. They default to the
+	// current user and the hostname.
+	Owner string
+	Host  string
+	// Expiry is how long the lock is held. Defaults to DefaultLockExpiry.
+	Expiry time.Duration
+	// Break takes over a lock that has not expired.
+	Break bool
+}
+
+// LockName returns the name of the TXT record locking domain.
+func LockName(domain string) string {
+	return normalizeDomain(LockLabel + "." + strings.TrimSuffix(domain, "."))
+}
+
+// IsLockRecord reports whether rs is the lock record of a domain, which is
+// never copied.
+func IsLockRecord(rs rtypes.ResourceRecordSet) bool {
+	return rs.Type == rtypes.RRTypeTxt && strings.HasPrefix(strings.ToLower(aws.ToString(rs.Name)), LockLabel+".")
+}
+
+// withoutLockRecords returns records without the lock records.
+func withoutLockRecords(records []rtypes.ResourceRecordSet) []rtypes.ResourceRecordSet {
+	kept := []rtypes.ResourceRecordSet{}
+	for _, rs := range records {
+		if !IsLockRecord(rs) {
+			kept = append(kept, rs)
+		}
+	}
+	return kept
+}
+
+// CheckLock returns the lock on domain in the zone, or nil when there is
+// none. A lock record that cannot be parsed is returned as expired.
+func (r *RouteCopy) CheckLock(ctx context.Context, zoneId, domain string) (*Lock, error) {
+	name := LockName(domain)
+	resp, err := r.cli.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneId),
+		StartRecordName: aws.String(name),
+		StartRecordType: rtypes.RRTypeTxt,
+		MaxItems:        aws.Int32(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, rs := range resp.ResourceRecordSets {
+		if rs.Type != rtypes.RRTypeTxt || !sameDomain(aws.ToString(rs.Name), name) {
+			continue
+		}
+		lock := parseLock(domain, rs)
+		return &lock, nil
+	}
+	return nil, nil
+}
+
+// AcquireLock locks domain by writing its lock record in the zone. It fails
+// with a ZoneLocked when another lock has not expired, unless opts.Break is
+// set. A stale lock is replaced in the same change batch that deletes it, so
+// two copies taking it over at once cannot both succeed.
+func (r *RouteCopy) AcquireLock(ctx context.Context, zoneId, domain string, opts LockOptions) (*Lock, error) {
+	opts = lockDefaults(opts)
+	current, err := r.CheckLock(ctx, zoneId, domain)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if current != nil && !current.Expired(now) && !opts.Break {
+		return nil, &ZoneLocked{Lock: *current}
+	}
+
+	lock := Lock{
+		Domain:   domain,
+		Owner:    opts.Owner,
+		Host:     opts.Host,
+		Acquired: now,
+		Expires:  now.Add(opts.Expiry),
+	}
+	lock.record = rtypes.ResourceRecordSet{
+		Name:            aws.String(LockName(domain)),
+		Type:            rtypes.RRTypeTxt,
+		TTL:             aws.Int64(lockTTL),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(lock.value())}},
+	}
+	changes := []rtypes.Change{}
+	if current != nil {
+		if current.Expired(now) {
+			logging.From(ctx).Warnf("Taking over the stale lock on '%s' held by %s\n", domain, current)
+		} else {
+			logging.From(ctx).Warnf("Breaking the lock on '%s' held by %s\n", domain, current)
+		}
+		changes = append(changes, recordSetChange(rtypes.ChangeActionDelete, current.record))
+	}
+	changes = append(changes, recordSetChange(rtypes.ChangeActionCreate, lock.record))
+
+	_, err = r.cli.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+		ChangeBatch: &rtypes.ChangeBatch{
+			Comment: aws.String("Locked by route53copy for " + lock.Owner),
+			Changes: changes,
+		},
+	})
+	var icb *rtypes.InvalidChangeBatch
+	if errors.As(err, &icb) {
+		// Another copy wrote or replaced the lock since it was checked.
+		other, cerr := r.CheckLock(ctx, zoneId, domain)
+		if cerr == nil && other != nil {
+			return nil, &ZoneLocked{Lock: *other}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	logging.From(ctx).Infof("Locked '%s' until %s\n", domain, lock.Expires.Format(time.RFC3339))
+	return &lock, nil
+}
+
+// ReleaseLock deletes the lock record written by AcquireLock. It fails when
+// the lock was broken or taken over in the meantime, leaving the new lock in
+// place.
+func (r *RouteCopy) ReleaseLock(ctx context.Context, zoneId string, lock *Lock) error {
+	_, err := r.cli.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+		ChangeBatch: &rtypes.ChangeBatch{
+			Comment: aws.String("Unlocked by route53copy for " + lock.Owner),
+			Changes: []rtypes.Change{recordSetChange(rtypes.ChangeActionDelete, lock.record)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("releasing the lock on '%s': %w", lock.Domain, err)
+	}
+	logging.From(ctx).Infof("Unlocked '%s'\n", lock.Domain)
+	return nil
+}
+
+func lockDefaults(opts LockOptions) LockOptions {
+	if opts.Owner == "" {
+		opts.Owner = "unknown"
+		if u, err := user.Current(); err == nil {
+			opts.Owner = u.Username
+		}
+	}
+	if opts.Host == "" {
+		opts.Host = "unknown"
+		if h, err := os.Hostname(); err == nil {
+			opts.Host = h
+		}
+	}
+	if opts.Expiry <= 0 {
+		opts.Expiry = DefaultLockExpiry
+	}
+	opts.Owner = lockField(opts.Owner)
+	opts.Host = lockField(opts.Host)
+	return opts
+}
+
+// lockField makes s a single word of the lock record value.
+func lockField(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, `"`, "")), "_")
+}
+
+// parseLock reads the lock of domain from its record. Fields that are
+// missing or invalid are left empty, so a lock without an expiry is stale.
+func parseLock(domain string, rs rtypes.ResourceRecordSet) Lock {
+	lock := Lock{Domain: domain, record: rs}
+	if len(rs.ResourceRecords) == 0 {
+		return lock
+	}
+	value := strings.Trim(aws.ToString(rs.ResourceRecords[0].Value), `"`)
+	for _, field := range strings.Fields(value) {
+		key, v, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "owner":
+			lock.Owner = v
+		case "host":
+			lock.Host = v
+		case "acquired":
+			lock.Acquired, _ = time.Parse(time.RFC3339, v)
+		case "expires":
+			lock.Expires, _ = time.Parse(time.RFC3339, v)
+		}
+	}
+	return lock
+}
@@ -0,0 +1,197 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+// lockRecord returns a lock record of example.com held by owner, expiring
+// at expires.
+func lockRecord(owner string, expires time.Time) rtypes.ResourceRecordSet {
+	return recordSet("_route53copy-lock.example.com.", rtypes.RRTypeTxt, fmt.Sprintf(`"owner=%s host=build-01 acquired=%s expires=%s"`,
+		owner, expires.Add(-DefaultLockExpiry).UTC().Format(time.RFC3339), expires.UTC().Format(time.RFC3339)))
+}
+
+func TestCheckLock(t *testing.T) {
+	ctx := context.Background()
+	_, _, server, r := fakeAccounts(t)
+	zoneID := server.AddZone("example.com", false)
+	// Listed right after the lock record, which must not be taken for it.
+	server.AddRecords(zoneID, recordSet("_route53copy-lock.example.com.", rtypes.RRTypeA, "192.0.2.1"))
+
+	lock, err := r.CheckLock(ctx, zoneID, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock != nil {
+		t.Fatalf("found lock %s, want none", lock)
+	}
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	server.AddRecords(zoneID, lockRecord("alice", expires))
+	lock, err = r.CheckLock(ctx, zoneID, "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock == nil || lock.Owner != "alice" || lock.Host != "build-01" || !lock.Expires.Equal(expires) ||
+		!lock.Acquired.Equal(expires.Add(-DefaultLockExpiry)) {
+		t.Fatalf("found lock %v, want the one of alice until %s", lock, expires)
+	}
+	if lock.Expired(expires.Add(-time.Second)) || !lock.Expired(expires) {
+		t.Error("the lock does not expire at its expiry")
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	now := time.Now()
+	invalid := recordSet("_route53copy-lock.example.com.", rtypes.RRTypeTxt, `"held"`)
+	tests := []struct {
+		name     string
+		existing *rtypes.ResourceRecordSet
+		brk      bool
+		// want is the warning logged, empty when the lock is refused.
+		want   string
+		locked bool
+	}{
+		{name: "unlocked"},
+		{name: "locked", existing: lockedBy("alice", now.Add(time.Minute)), locked: true},
+		{name: "stale lock taken over", existing: lockedBy("alice", now.Add(-time.Minute)), want: "Taking over the stale lock on 'example.com' held by alice@build-01"},
+		{name: "lock broken", existing: lockedBy("alice", now.Add(time.Minute)), brk: true, want: "Breaking the lock on 'example.com' held by alice@build-01"},
+		{name: "invalid lock taken over", existing: &invalid, want: "Taking over the stale lock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&buf)
+			t.Cleanup(func() { log.SetOutput(w) })
+			ctx := context.Background()
+			_, _, server, r := fakeAccounts(t)
+			zoneID := server.AddZone("example.com", false)
+			if tt.existing != nil {
+				server.AddRecords(zoneID, *tt.existing)
+			}
+
+			lock, err := r.AcquireLock(ctx, zoneID, "example.com", LockOptions{Owner: "bob smith", Host: "laptop", Break: tt.brk})
+			if tt.locked {
+				var zl *ZoneLocked
+				if !errors.As(err, &zl) || zl.Lock.Owner != "alice" {
+					t.Fatalf("got %v, want ZoneLocked by alice", err)
+				}
+				if calls := server.Calls(fakeroute53.OpChangeResourceRecordSets); calls != 0 {
+					t.Errorf("submitted %d changes to a locked domain", calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lock.Owner != "bob_smith" || lock.Host != "laptop" || lock.Expires.Sub(lock.Acquired) != DefaultLockExpiry {
+				t.Errorf("acquired %s, want bob_smith@laptop for %s", lock, DefaultLockExpiry)
+			}
+			current, err := r.CheckLock(ctx, zoneID, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current == nil || current.Owner != "bob_smith" || current.Expired(time.Now()) {
+				t.Errorf("the zone holds lock %v, want the one acquired", current)
+			}
+			if tt.want != "" && !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("got log:\n%s\nwant %q", buf.String(), tt.want)
+			}
+
+			if err := r.ReleaseLock(ctx, zoneID, lock); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := findRecord(server.Records(zoneID), LockName("example.com")); ok {
+				t.Error("the lock record was not deleted")
+			}
+		})
+	}
+}
+
+// lockedBy returns the lock record of lockRecord.
+func lockedBy(owner string, expires time.Time) *rtypes.ResourceRecordSet {
+	rs := lockRecord(owner, expires)
+	return &rs
+}
+
+func TestReleaseLockTakenOver(t *testing.T) {
+	ctx := context.Background()
+	_, _, server, r := fakeAccounts(t)
+	zoneID := server.AddZone("example.com", false)
+	lock, err := r.AcquireLock(ctx, zoneID, "example.com", LockOptions{Owner: "bob", Expiry: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.AcquireLock(ctx, zoneID, "example.com", LockOptions{Owner: "alice", Break: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.ReleaseLock(ctx, zoneID, lock); err == nil {
+		t.Fatal("released a lock that was taken over")
+	}
+	current, err := r.CheckLock(ctx, zoneID, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current == nil || current.Owner != "alice" {
+		t.Errorf("the zone holds lock %v, want the one of alice", current)
+	}
+}
+
+func TestCopyZoneLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *rtypes.ResourceRecordSet
+		wantErr  bool
+	}{
+		{name: "unlocked"},
+		{name: "stale lock", existing: lockedBy("alice", time.Now().Add(-time.Minute))},
+		{name: "locked", existing: lockedBy("alice", time.Now().Add(time.Minute)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			// A lock left in the source is not copied.
+			srcServer.AddRecords(srcZoneID, append(hostRecords("example.com", 3), lockRecord("carol", time.Now().Add(time.Hour)))...)
+			dstZoneID := dstServer.AddZone("example.com", false)
+			if tt.existing != nil {
+				dstServer.AddRecords(dstZoneID, *tt.existing)
+			}
+
+			result, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com", AllowLiveOverwrite: true, Lock: &LockOptions{Owner: "bob"}})
+			if tt.wantErr {
+				var zl *ZoneLocked
+				if !errors.As(err, &zl) {
+					t.Fatalf("got %v, want ZoneLocked", err)
+				}
+				if _, ok := findRecord(dstServer.Records(dstZoneID), "host000.example.com."); ok {
+					t.Error("copied into a locked zone")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Changes) != 3 {
+				t.Errorf("copied %d record sets, want the 3 hosts", len(result.Changes))
+			}
+			if lock, ok := findRecord(dstServer.Records(dstZoneID), LockName("example.com")); ok {
+				t.Errorf("the lock %s is left in the destination", aws.ToString(lock.ResourceRecords[0].Value))
+			}
+		})
+	}
+}
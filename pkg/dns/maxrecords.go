@@ -0,0 +1,40 @@
+package dns
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// DefaultMaxRecords is the largest zone copied or deleted unless a higher
+// limit is given, so pointing a tool at a huge zone by mistake fails fast
+// instead of spending a long time on the Route53 API.
+const DefaultMaxRecords = 10000
+
+// TooManyRecords is returned by CheckRecordCount when a zone has more
+// records than the limit.
+type TooManyRecords struct {
+	Zone    string
+	Records int64
+	Max     int64
+}
+
+func (e *TooManyRecords) Error() string {
+	return fmt.Sprintf("zone %s has %d records, more than the limit of %d", e.Zone, e.Records, e.Max)
+}
+
+// CheckRecordCount returns a TooManyRecords when zone has more than max
+// records. It uses the record count of the zone, so it fails before a single
+// record is listed. A max of zero disables the check.
+func CheckRecordCount(zone rtypes.HostedZone, max int64) error {
+	count := aws.ToInt64(zone.ResourceRecordSetCount)
+	if max <= 0 || count <= max {
+		return nil
+	}
+	return &TooManyRecords{
+		Zone:    DisplayDomain(denormalizeDomain(aws.ToString(zone.Name))),
+		Records: count,
+		Max:     max,
+	}
+}
@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+)
+
+// Operations observed by a Metrics.
+const (
+	// OpListRecords is a page of ListResourceRecordSets.
+	OpListRecords = "ListResourceRecordSets"
+	// OpChangeRecords is a ChangeResourceRecordSets batch submission.
+	OpChangeRecords = "ChangeResourceRecordSets"
+	// OpGetChange is a single poll of a change while waiting for it.
+	OpGetChange = "GetChange"
+	// OpWaitForChange is the whole wait for a change to be in sync.
+	OpWaitForChange = "WaitForChange"
+)
+
+// Metrics receives the duration of the Route53 calls made by a RouteCopy.
+// It may be called concurrently.
+type Metrics interface {
+	Observe(operation string, d time.Duration)
+}
+
+// NoMetrics discards the observations. It is the default Metrics of a
+// RouteCopy.
+type NoMetrics struct{}
+
+func (NoMetrics) Observe(string, time.Duration) {}
+
+// SetMetrics replaces the Metrics that receives the durations of r.
+func (r *RouteCopy) SetMetrics(m Metrics) {
+	r.metrics = m
+}
+
+// observe reports the time since start for operation.
+func (r *RouteCopy) observe(operation string, start time.Time) {
+	r.metrics.Observe(operation, time.Since(start))
+}
+
+// Timing is the count and durations of an operation.
+type Timing struct {
+	Operation string
+	Count     int
+	Total     time.Duration
+	Max       time.Duration
+}
+
+// Timings is a Metrics keeping the count and durations of each operation.
+type Timings struct {
+	mu  sync.Mutex
+	ops map[string]*Timing
+}
+
+func NewTimings() *Timings {
+	return &Timings{ops: map[string]*Timing{}}
+}
+
+func (t *Timings) Observe(operation string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	op, ok := t.ops[operation]
+	if !ok {
+		op = &Timing{Operation: operation}
+		t.ops[operation] = op
+	}
+	op.Count++
+	op.Total += d
+	if d > op.Max {
+		op.Max = d
+	}
+}
+
+// Summary returns the timing of each operation observed, by name.
+func (t *Timings) Summary() []Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	summary := []Timing{}
+	for _, op := range t.ops {
+		summary = append(summary, *op)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Operation < summary[j].Operation
+	})
+	return summary
+}
+
+// timedGetChange times each GetChange poll of a waiter.
+type timedGetChange struct {
+	r *RouteCopy
+}
+
+func (t timedGetChange) GetChange(ctx context.Context, params *route53.GetChangeInput, optFns ...func(*route53.Options)) (*route53.GetChangeOutput, error) {
+	defer t.r.observe(OpGetChange, time.Now())
+	return t.r.cli.GetChange(ctx, params, optFns...)
+}
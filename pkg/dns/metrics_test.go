@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics keeps every observation in order.
+type recordingMetrics struct {
+	mu        sync.Mutex
+	durations map[string][]time.Duration
+}
+
+func (m *recordingMetrics) Observe(operation string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.durations == nil {
+		m.durations = map[string][]time.Duration{}
+	}
+	m.durations[operation] = append(m.durations[operation], d)
+}
+
+func TestTimings(t *testing.T) {
+	timings := NewTimings()
+	timings.Observe(OpListRecords, 30*time.Millisecond)
+	timings.Observe(OpChangeRecords, 200*time.Millisecond)
+	timings.Observe(OpListRecords, 50*time.Millisecond)
+	timings.Observe(OpListRecords, 10*time.Millisecond)
+
+	want := []Timing{
+		{Operation: OpChangeRecords, Count: 1, Total: 200 * time.Millisecond, Max: 200 * time.Millisecond},
+		{Operation: OpListRecords, Count: 3, Total: 90 * time.Millisecond, Max: 50 * time.Millisecond},
+	}
+	if got := timings.Summary(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCopyZoneMetrics(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcServer.MaxRecords = 100
+	srcZoneID := srcServer.AddZone("example.com", false)
+	// With the apex NS and SOA, the 250 records take 3 pages.
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 250)...)
+	dstServer.AddZone("example.com", false)
+	srcMetrics := &recordingMetrics{}
+	src.SetMetrics(srcMetrics)
+	dstMetrics := &recordingMetrics{}
+	dst.SetMetrics(dstMetrics)
+
+	_, err := CopyZone(ctx, src, dst, CopyOptions{Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		metrics   *recordingMetrics
+		operation string
+		count     int
+	}{
+		{name: "source pages", metrics: srcMetrics, operation: OpListRecords, count: 3},
+		{name: "batches", metrics: dstMetrics, operation: OpChangeRecords, count: 1},
+		{name: "polls", metrics: dstMetrics, operation: OpGetChange, count: 1},
+		{name: "waits", metrics: dstMetrics, operation: OpWaitForChange, count: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			durations := tt.metrics.durations[tt.operation]
+			if len(durations) != tt.count {
+				t.Fatalf("observed %s %d times, want %d", tt.operation, len(durations), tt.count)
+			}
+			for _, d := range durations {
+				if d <= 0 {
+					t.Errorf("observed %s taking %s", tt.operation, d)
+				}
+			}
+		})
+	}
+	if _, ok := srcMetrics.durations[OpChangeRecords]; ok {
+		t.Error("the source observed change batches")
+	}
+}
@@ -0,0 +1,195 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DecodeName turns the \ooo octal escapes Route53 returns in record names,
+// such as \052 for a wildcard, into the RFC 1035 presentation format used by
+// zone files: printable characters are written as is, the ones with a special
+// meaning are escaped with a backslash and the others as \DDD in decimal.
+// Names without octal escapes are returned unchanged.
+func DecodeName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if v := octalEscape(name, i); v >= 0 {
+			writePresentation(&b, byte(v))
+			i += 3
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// EncodeName turns a name in presentation format into the form Route53
+// returns, escaping every character besides letters, digits, hyphens and
+// underscores as \ooo in octal. It reverses DecodeName.
+func EncodeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '\\' && decimalEscape(name, i) >= 0:
+			writeRoute53(&b, byte(decimalEscape(name, i)))
+			i += 3
+		case c == '\\' && i+1 < len(name):
+			writeRoute53(&b, name[i+1])
+			i++
+		case c == '.':
+			b.WriteByte(c)
+		default:
+			writeRoute53(&b, c)
+		}
+	}
+	return b.String()
+}
+
+// octalEscape returns the character of the \ooo escape starting at
+// name[i], or -1.
+func octalEscape(name string, i int) int {
+	return escapedValue(name, i, 8)
+}
+
+// decimalEscape returns the character of the \DDD escape starting at
+// name[i], or -1.
+func decimalEscape(name string, i int) int {
+	return escapedValue(name, i, 10)
+}
+
+func escapedValue(name string, i, base int) int {
+	if name[i] != '\\' || i+4 > len(name) {
+		return -1
+	}
+	v := 0
+	for _, c := range name[i+1 : i+4] {
+		if c < '0' || int(c-'0') >= base {
+			return -1
+		}
+		v = v*base + int(c-'0')
+	}
+	if v > 0xff {
+		return -1
+	}
+	return v
+}
+
+func writePresentation(b *strings.Builder, c byte) {
+	switch {
+	case strings.IndexByte(`. \"();@$`, c) >= 0:
+		b.WriteByte('\\')
+		b.WriteByte(c)
+	case c < ' ' || c > '~':
+		fmt.Fprintf(b, `\%03d`, c)
+	default:
+		b.WriteByte(c)
+	}
+}
+
+func writeRoute53(b *strings.Builder, c byte) {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		b.WriteByte(c)
+	default:
+		fmt.Fprintf(b, `\%03o`, c)
+	}
+}
+
+// idnaProfile is idna.Lookup that also rejects empty and overlong labels.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.Transitional(true), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// CanonicalDomain returns domain in the form Route53 and the registrar
+// expect it: internationalized labels, such as münchen-shop.de, are converted
+// to punycode, xn--mnchen-shop-thb.de, and lowercased. Names that are
+// already ASCII keep their case. An error is returned for names that are not
+// valid internationalized domain names, including malformed xn-- labels.
+func CanonicalDomain(domain string) (string, error) {
+	if isASCII(domain) && !hasPunycode(domain) {
+		return domain, nil
+	}
+	name := strings.TrimSuffix(domain, ".")
+	ascii, err := idnaProfile.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized domain name %s: %w", domain, err)
+	}
+	// Punycode labels must come back unchanged, rather than decode to
+	// another name such as xn--zz-.com to zz.com.
+	if isASCII(name) && !strings.EqualFold(ascii, name) {
+		return "", fmt.Errorf("invalid internationalized domain name %s: not in canonical punycode, expected %s", domain, ascii)
+	}
+	if strings.HasSuffix(domain, ".") {
+		ascii += "."
+	}
+	return ascii, nil
+}
+
+// UnicodeDomain returns the Unicode form of a domain with punycode labels,
+// or domain itself when it has none or they cannot be decoded.
+func UnicodeDomain(domain string) string {
+	if !hasPunycode(domain) {
+		return domain
+	}
+	if _, err := CanonicalDomain(domain); err != nil {
+		return domain
+	}
+	name, err := idna.Display.ToUnicode(domain)
+	if err != nil {
+		return domain
+	}
+	return name
+}
+
+// DisplayDomain returns domain followed by its Unicode form in parentheses
+// when it has punycode labels, so logs show both.
+func DisplayDomain(domain string) string {
+	name := UnicodeDomain(domain)
+	if name == domain {
+		return domain
+	}
+	return fmt.Sprintf("%s (%s)", domain, name)
+}
+
+// hasPunycode reports whether a label of domain is in punycode.
+func hasPunycode(domain string) bool {
+	for _, label := range strings.Split(strings.ToLower(domain), ".") {
+		if strings.HasPrefix(label, "xn--") {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneLookupName returns the name of a hosted zone in the form Route53 lists
+// zones by: lowercase, fully qualified, with the octal escapes of DecodeName
+// and internationalized labels in punycode, see CanonicalDomain. Names that
+// are not valid IDNs, such as ones with underscores, are only lowercased.
+func zoneLookupName(domain string) string {
+	name := strings.ToLower(strings.TrimSuffix(DecodeName(domain), "."))
+	if !isASCII(name) {
+		if ascii, err := CanonicalDomain(name); err == nil {
+			name = ascii
+		}
+	}
+	return normalizeDomain(EncodeName(name))
+}
+
+// registrarDomain returns domain in the form the registrar APIs expect it:
+// lowercase, in punycode and without the trailing dot.
+func registrarDomain(domain string) string {
+	return denormalizeDomain(zoneLookupName(domain))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "www.example.com.", want: "www.example.com."},
+		{name: "WWW.Example.COM.", want: "WWW.Example.COM."},
+		{name: `\052.example.com.`, want: "*.example.com."},
+		{name: `\052.Dev.Example.com.`, want: "*.Dev.Example.com."},
+		{name: `\100.example.com.`, want: `\@.example.com.`},
+		{name: `my\040host.example.com.`, want: `my\ host.example.com.`},
+		{name: `a\056b.example.com.`, want: `a\.b.example.com.`},
+		{name: `back\134slash.example.com.`, want: `back\\slash.example.com.`},
+		{name: `tab\011.example.com.`, want: `tab\009.example.com.`},
+		{name: `caf\303\251.example.com.`, want: `caf\195\169.example.com.`},
+		// Escapes that are not octal are left alone.
+		{name: `\09a.example.com.`, want: `\09a.example.com.`},
+		{name: `\777.example.com.`, want: `\777.example.com.`},
+		{name: `end\05`, want: `end\05`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeName(tt.name); got != tt.want {
+				t.Errorf("DecodeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "www.example.com.", want: "www.example.com."},
+		{name: "WWW.Example.COM.", want: "WWW.Example.COM."},
+		{name: "_dmarc.my-host.example.com.", want: "_dmarc.my-host.example.com."},
+		{name: "*.example.com.", want: `\052.example.com.`},
+		{name: `\@.example.com.`, want: `\100.example.com.`},
+		{name: "@.example.com.", want: `\100.example.com.`},
+		{name: `my\ host.example.com.`, want: `my\040host.example.com.`},
+		{name: `a\.b.example.com.`, want: `a\056b.example.com.`},
+		{name: `back\\slash.example.com.`, want: `back\134slash.example.com.`},
+		{name: `tab\009.example.com.`, want: `tab\011.example.com.`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EncodeName(tt.name)
+			if got != tt.want {
+				t.Fatalf("EncodeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+			// Route53 names come back from their presentation format.
+			if back := EncodeName(DecodeName(got)); back != got {
+				t.Errorf("EncodeName(DecodeName(%q)) = %q", got, back)
+			}
+		})
+	}
+}
+
+func TestZoneLookupName(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{domain: "example.com", want: "example.com."},
+		{domain: "Example.COM.", want: "example.com."},
+		{domain: "münchen-shop.de", want: "xn--mnchen-shop-thb.de."},
+		{domain: "MÜNCHEN-Shop.de.", want: "xn--mnchen-shop-thb.de."},
+		{domain: "XN--mnchen-shop-thb.de", want: "xn--mnchen-shop-thb.de."},
+		// Not a valid IDN, only lowercased and escaped.
+		{domain: "_Dmarc.café.example.com", want: `_dmarc.caf\303\251.example.com.`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := zoneLookupName(tt.domain); got != tt.want {
+				t.Errorf("zoneLookupName(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalDomain(t *testing.T) {
+	tests := []struct {
+		domain  string
+		want    string
+		wantErr string
+	}{
+		{domain: "münchen-shop.de", want: "xn--mnchen-shop-thb.de"},
+		{domain: "MÜNCHEN-Shop.de.", want: "xn--mnchen-shop-thb.de."},
+		{domain: "bücher.example.COM", want: "xn--bcher-kva.example.com"},
+		// ASCII names keep their case, as other arguments do.
+		{domain: "Example.COM", want: "Example.COM"},
+		{domain: "xn--mnchen-shop-thb.de", want: "xn--mnchen-shop-thb.de"},
+		{domain: "XN--MNCHEN-SHOP-THB.de.", want: "xn--mnchen-shop-thb.de."},
+		{domain: "", want: ""},
+		{domain: "xn--zz-.com", wantErr: "invalid internationalized domain name xn--zz-.com"},
+		{domain: "xn--a.com", wantErr: "invalid internationalized domain name xn--a.com"},
+		{domain: "bad_label.münchen.de", wantErr: "invalid internationalized domain name bad_label.münchen.de"},
+		{domain: "münchen..de", wantErr: "invalid internationalized domain name"},
+		{domain: strings.Repeat("ü", 60) + ".de", wantErr: "invalid internationalized domain name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := CanonicalDomain(tt.domain)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %q and %v, want an error with %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalDomain(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+			if again, err := CanonicalDomain(got); err != nil || again != got {
+				t.Errorf("CanonicalDomain(%q) = %q and %v, want it unchanged", got, again, err)
+			}
+		})
+	}
+}
+
+func TestUnicodeDomain(t *testing.T) {
+	tests := []struct {
+		domain  string
+		want    string
+		display string
+	}{
+		{domain: "xn--mnchen-shop-thb.de", want: "münchen-shop.de", display: "xn--mnchen-shop-thb.de (münchen-shop.de)"},
+		{domain: "XN--Bcher-kva.example.com.", want: "bücher.example.com.", display: "XN--Bcher-kva.example.com. (bücher.example.com.)"},
+		{domain: "example.com", want: "example.com", display: "example.com"},
+		// Labels that cannot be decoded are kept.
+		{domain: "xn--zz-.com", want: "xn--zz-.com", display: "xn--zz-.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got := UnicodeDomain(tt.domain)
+			if got != tt.want {
+				t.Errorf("UnicodeDomain(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+			if display := DisplayDomain(tt.domain); display != tt.display {
+				t.Errorf("DisplayDomain(%q) = %q, want %q", tt.domain, display, tt.display)
+			}
+			if got == tt.domain {
+				return
+			}
+			if back, err := CanonicalDomain(got); err != nil || !strings.EqualFold(back, tt.domain) {
+				t.Errorf("CanonicalDomain(%q) = %q and %v, want %q back", got, back, err, tt.domain)
+			}
+		})
+	}
+}
+
+func TestRegistrarDomain(t *testing.T) {
+	for domain, want := range map[string]string{
+		"münchen-shop.de.":       "xn--mnchen-shop-thb.de",
+		"XN--MNCHEN-SHOP-THB.DE": "xn--mnchen-shop-thb.de",
+		"Example.COM.":           "example.com",
+	} {
+		if got := registrarDomain(domain); got != want {
+			t.Errorf("registrarDomain(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Statuses of a RecordOutcome.
+const (
+	// OutcomeApplied changes are in sync in Route53.
+	OutcomeApplied = "applied"
+	// OutcomePending changes were submitted but not seen in sync.
+	OutcomePending = "pending"
+	// OutcomeFailed changes were in the batch Route53 rejected.
+	OutcomeFailed = "failed"
+	// OutcomeNotApplied changes were not submitted since the run stopped
+	// before reaching them.
+	OutcomeNotApplied = "not applied"
+	// OutcomeSkipped changes were left out of their batch, such as deletes
+	// of records that were already gone.
+	OutcomeSkipped = "skipped"
+	// OutcomeDryRun changes would have been applied without --dry.
+	OutcomeDryRun = "dry run"
+)
+
+// RecordOutcome is what happened to a single change.
+type RecordOutcome struct {
+	Change rtypes.Change
+	// Previous is the destination record set the change overwrites or
+	// deletes, if any.
+	Previous *rtypes.ResourceRecordSet
+	// ChangeID is the id of the batch the change was submitted in.
+	ChangeID string
+	Status   string
+	// OriginalTTL is the source TTL of a change whose TTL was overridden or
+	// clamped, see WithOriginalTTLs.
+	OriginalTTL int64
+	// Error is why Route53 rejected a failed change, when it is known, see
+	// RejectedChanges.
+	Error string
+}
+
+// RecordOutcomes matches changes with the existing destination record sets
+// they replace and with the batches that submitted them. err is the error
+// that stopped the run, if any.
+func RecordOutcomes(changes []rtypes.Change, existing []rtypes.ResourceRecordSet, batches []BatchResult, err error, dryRun bool) []RecordOutcome {
+	existingByKey := map[string]rtypes.ResourceRecordSet{}
+	for _, rs := range existing {
+		existingByKey[recordSetKey(rs)] = rs
+	}
+	batchByKey := map[string]BatchResult{}
+	for _, b := range batches {
+		for _, c := range b.Submitted {
+			batchByKey[recordSetKey(*c.ResourceRecordSet)] = b
+		}
+	}
+	failed := map[string]bool{}
+	reasons := map[string]string{}
+	var be *BatchError
+	if errors.As(err, &be) {
+		for _, c := range be.Failed {
+			failed[recordSetKey(*c.ResourceRecordSet)] = true
+		}
+	}
+	var rc *RejectedChanges
+	if errors.As(err, &rc) {
+		for _, r := range rc.Rejected {
+			failed[recordSetKey(*r.Change.ResourceRecordSet)] = true
+			reasons[recordSetKey(*r.Change.ResourceRecordSet)] = r.Reason
+		}
+	}
+
+	outcomes := []RecordOutcome{}
+	for _, c := range changes {
+		key := recordSetKey(*c.ResourceRecordSet)
+		outcome := RecordOutcome{Change: c}
+		if rs, ok := existingByKey[key]; ok {
+			outcome.Previous = &rs
+		} else if c.Action == rtypes.ChangeActionDelete {
+			outcome.Previous = c.ResourceRecordSet
+		}
+
+		b, submitted := batchByKey[key]
+		switch {
+		case dryRun:
+			outcome.Status = OutcomeDryRun
+		case submitted:
+			outcome.ChangeID = aws.ToString(b.ChangeInfo.Id)
+			outcome.Status = OutcomePending
+			if b.ChangeInfo.Status == rtypes.ChangeStatusInsync {
+				outcome.Status = OutcomeApplied
+			}
+		case failed[key]:
+			outcome.Status = OutcomeFailed
+			outcome.Error = reasons[key]
+		case err != nil:
+			outcome.Status = OutcomeNotApplied
+		default:
+			outcome.Status = OutcomeSkipped
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+// WithOriginalTTLs sets the OriginalTTL of the outcomes whose TTL is in
+// ttlChanges.
+func WithOriginalTTLs(outcomes []RecordOutcome, ttlChanges []TTLChange) []RecordOutcome {
+	original := map[string]int64{}
+	for _, t := range ttlChanges {
+		original[recordSetKey(t.Record)] = t.Original
+	}
+	for i, o := range outcomes {
+		outcomes[i].OriginalTTL = original[recordSetKey(*o.Change.ResourceRecordSet)]
+	}
+	return outcomes
+}
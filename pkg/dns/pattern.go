@@ -0,0 +1,176 @@
+package dns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// ExcludedRecord is a record set left out of a copy and why. Cause is one
+// of the Excluded constants, and Reason details it.
+type ExcludedRecord struct {
+	Record rtypes.ResourceRecordSet
+	Cause  string
+	Reason string
+}
+
+// regexPrefix marks a name pattern as a regular expression rather than a
+// glob.
+const regexPrefix = "re:"
+
+// InvalidNamePattern is returned by ValidateNamePatterns for a regular
+// expression that does not compile.
+type InvalidNamePattern struct {
+	Pattern string
+	Err     error
+}
+
+func (e *InvalidNamePattern) Error() string {
+	return fmt.Sprintf("invalid name pattern %q: %s", e.Pattern, e.Err)
+}
+
+func (e *InvalidNamePattern) Unwrap() error {
+	return e.Err
+}
+
+// ValidateNamePatterns checks that the regular expressions among patterns
+// compile. Glob patterns are always valid.
+func ValidateNamePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, regexPrefix) {
+			continue
+		}
+		_, err := regexp.Compile(strings.TrimPrefix(p, regexPrefix))
+		if err != nil {
+			return &InvalidNamePattern{Pattern: p, Err: err}
+		}
+	}
+	return nil
+}
+
+// MatchName reports whether the record name matches the glob pattern.
+// Matching ignores case, trailing dots and the octal escapes of Route53, see
+// DecodeName. A "*" matches within a single label, "**" matches across labels
+// and "?" matches one character of a label.
+//
+// A pattern starting with "re:" is a regular expression instead, matched
+// case-insensitively anywhere in the decoded name without its trailing dot;
+// anchor it with ^ and $ to match the whole name. It must be valid, see
+// ValidateNamePatterns.
+func MatchName(pattern, name string) bool {
+	return compileNamePattern(pattern).MatchString(strings.TrimSuffix(DecodeName(name), "."))
+}
+
+func compileNamePattern(pattern string) *regexp.Regexp {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		return regexp.MustCompile("(?i)" + strings.TrimPrefix(pattern, regexPrefix))
+	}
+	pattern = strings.TrimSuffix(pattern, ".")
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^.]*")
+		case pattern[i] == '?':
+			b.WriteString("[^.]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// FilterRecordNames keeps the records whose names match one of the include
+// patterns, or every record when there are none, and then drops the records
+// matching one of the exclude patterns, see MatchName. Exclude patterns win
+// over include patterns.
+func FilterRecordNames(records []rtypes.ResourceRecordSet, include, exclude []string) ([]rtypes.ResourceRecordSet, []ExcludedRecord) {
+	includes := compileNamePatterns(include)
+	excludes := compileNamePatterns(exclude)
+
+	kept := []rtypes.ResourceRecordSet{}
+	excluded := []ExcludedRecord{}
+	for _, record := range records {
+		name := strings.TrimSuffix(DecodeName(aws.ToString(record.Name)), ".")
+		if i := matchingPattern(excludes, name); i >= 0 {
+			excluded = append(excluded, ExcludedRecord{
+				Record: record,
+				Cause:  ExcludedName,
+				Reason: fmt.Sprintf("matches exclude pattern %q", exclude[i]),
+			})
+			continue
+		}
+		if len(includes) > 0 && matchingPattern(includes, name) < 0 {
+			excluded = append(excluded, ExcludedRecord{
+				Record: record,
+				Cause:  ExcludedName,
+				Reason: "matches no include pattern",
+			})
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept, excluded
+}
+
+func compileNamePatterns(patterns []string) []*regexp.Regexp {
+	compiled := []*regexp.Regexp{}
+	for _, p := range patterns {
+		compiled = append(compiled, compileNamePattern(p))
+	}
+	return compiled
+}
+
+func matchingPattern(patterns []*regexp.Regexp, name string) int {
+	for i, p := range patterns {
+		if p.MatchString(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// InSubtree reports whether name is root or one of its subdomains, including
+// wildcard records such as *.root. Matching ignores case, trailing dots and
+// the octal escapes of Route53.
+func InSubtree(name, root string) bool {
+	name = strings.ToLower(strings.TrimSuffix(DecodeName(name), "."))
+	root = strings.ToLower(strings.TrimSuffix(DecodeName(root), "."))
+	return name == root || strings.HasSuffix(name, "."+root)
+}
+
+// FilterRecordSubtrees keeps the records whose names are one of names or a
+// subdomain of them.
+func FilterRecordSubtrees(records []rtypes.ResourceRecordSet, names []string) ([]rtypes.ResourceRecordSet, []ExcludedRecord) {
+	kept := []rtypes.ResourceRecordSet{}
+	excluded := []ExcludedRecord{}
+	for _, record := range records {
+		if inAnySubtree(aws.ToString(record.Name), names) {
+			kept = append(kept, record)
+			continue
+		}
+		excluded = append(excluded, ExcludedRecord{
+			Record: record,
+			Cause:  ExcludedSubtree,
+			Reason: "not under any of the given names",
+		})
+	}
+	return kept, excluded
+}
+
+func inAnySubtree(name string, roots []string) bool {
+	for _, root := range roots {
+		if InSubtree(name, root) {
+			return true
+		}
+	}
+	return false
+}
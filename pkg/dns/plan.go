@@ -0,0 +1,159 @@
+package dns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Plan is the set of changes a dry run computed for a destination zone,
+// to be applied later with exactly those changes.
+type Plan struct {
+	Domain            string          `json:"domain"`
+	SourceProfile     string          `json:"source_profile"`
+	SourceZoneID      string          `json:"source_zone_id"`
+	DestinationZoneID string          `json:"destination_zone_id"`
+	DestinationZone   string          `json:"destination_zone"`
+	Timestamp         time.Time       `json:"timestamp"`
+	Changes           []rtypes.Change `json:"changes"`
+	// Existing are the destination record sets the changes replaced when
+	// the plan was written, to detect a stale plan.
+	Existing []rtypes.ResourceRecordSet `json:"existing"`
+	// Hash is the SHA-256 of the zones and changes, see PlanHash.
+	Hash string `json:"hash"`
+}
+
+// StalePlan is returned by CheckPlan when the destination records replaced
+// by the plan changed since it was written. Diff goes from the records at
+// planning time to the current ones.
+type StalePlan struct {
+	Zone string
+	Diff Diff
+}
+
+func (e *StalePlan) Error() string {
+	return fmt.Sprintf("plan is stale: %d records of '%s' it replaces were created, %d changed and %d deleted since it was written",
+		len(e.Diff.Delete), e.Zone, len(e.Diff.Update), len(e.Diff.Create))
+}
+
+// NewPlan returns the plan for the changes of a dry run of CopyZone with
+// CollectExisting set. Aliases are pointed at the destination zone.
+func NewPlan(result CopyResult, domain, sourceProfile string) Plan {
+	srcZoneID := aws.ToString(result.SourceZone.Id)
+	dstZoneID := aws.ToString(result.DestinationZone.Id)
+	changes := RewriteAliasZoneIDs(result.Changes, srcZoneID, dstZoneID)
+	p := Plan{
+		Domain:            domain,
+		SourceProfile:     sourceProfile,
+		SourceZoneID:      shortZoneID(srcZoneID),
+		DestinationZoneID: shortZoneID(dstZoneID),
+		DestinationZone:   aws.ToString(result.DestinationZone.Name),
+		Timestamp:         time.Now().UTC(),
+		Changes:           changes,
+		Existing:          replacedRecordSets(changes, result.Existing),
+	}
+	p.Hash = PlanHash(p)
+	return p
+}
+
+// replacedRecordSets returns the record sets of existing the changes replace.
+func replacedRecordSets(changes []rtypes.Change, existing []rtypes.ResourceRecordSet) []rtypes.ResourceRecordSet {
+	keys := map[string]bool{}
+	for _, c := range changes {
+		keys[recordSetKey(*c.ResourceRecordSet)] = true
+	}
+	replaced := []rtypes.ResourceRecordSet{}
+	for _, rs := range existing {
+		if keys[recordSetKey(rs)] {
+			replaced = append(replaced, rs)
+		}
+	}
+	return replaced
+}
+
+// PlanHash returns the SHA-256 of the zones and changes of a plan, so a
+// plan edited after it was written is detected.
+func PlanHash(p Plan) string {
+	data, _ := json.Marshal(struct {
+		SourceZoneID      string
+		DestinationZoneID string
+		Changes           []rtypes.Change
+	}{p.SourceZoneID, p.DestinationZoneID, p.Changes})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckPlan returns the destination zone of the plan, after checking that it
+// still exists under the same name. A StalePlan is returned, along with the
+// zone, when the record sets the plan replaces changed since.
+func (r *RouteCopy) CheckPlan(ctx context.Context, p Plan) (rtypes.HostedZone, error) {
+	zone, err := r.GetHostedZoneByID(ctx, p.DestinationZoneID)
+	if err != nil {
+		return zone, err
+	}
+	if !sameDomain(aws.ToString(zone.Name), p.DestinationZone) {
+		return zone, fmt.Errorf("zone %s is '%s', but the plan was written for '%s'",
+			p.DestinationZoneID, aws.ToString(zone.Name), p.DestinationZone)
+	}
+
+	records, err := r.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return zone, err
+	}
+	diff := DiffRecordSets(p.Existing, replacedRecordSets(p.Changes, records))
+	if !diff.Empty() {
+		return zone, &StalePlan{Zone: p.DestinationZone, Diff: diff}
+	}
+	return zone, nil
+}
+
+func WritePlan(w io.Writer, p Plan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func ReadPlan(r io.Reader) (Plan, error) {
+	p := Plan{}
+	err := json.NewDecoder(r).Decode(&p)
+	if err != nil {
+		return p, fmt.Errorf("invalid plan: %w", err)
+	}
+	if p.DestinationZoneID == "" {
+		return p, fmt.Errorf("invalid plan: missing destination zone id")
+	}
+	if p.Hash != PlanHash(p) {
+		return p, fmt.Errorf("invalid plan: its changes do not match its hash")
+	}
+	return p, nil
+}
+
+// WritePlanFile writes the plan to the file name, replacing it.
+func WritePlanFile(name string, p Plan) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = WritePlan(f, p)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func ReadPlanFile(name string) (Plan, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer f.Close()
+	return ReadPlan(f)
+}
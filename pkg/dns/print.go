@@ -1,27 +1,91 @@
 package dns
 
 import (
+	"io"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/olekukonko/tablewriter"
 )
 
-func PrintResourceRecords(records []rtypes.ResourceRecordSet) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Name", "Type", "Value"})
-
-	for _, record := range records {
-		values := ""
-		for i, v := range record.ResourceRecords {
-			values += aws.ToString(v.Value)
-			if i != len(record.ResourceRecords)-1 {
-				values += "\n"
-			}
+// newTable returns a table writing to w. It does not wrap its cells, since
+// wrapping would break TXT values at their spaces.
+func newTable(w io.Writer) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	return table
+}
+
+func PrintDiff(diff Diff, prune bool) {
+	table := newTable(os.Stdout)
+	table.SetHeader([]string{"Action", "Name", "Type", "Value"})
+
+	for _, record := range diff.Create {
+		table.Append([]string{"CREATE", DecodeName(aws.ToString(record.Name)), string(record.Type), recordValues(record)})
+	}
+	for _, u := range diff.Update {
+		table.Append([]string{"UPDATE", DecodeName(aws.ToString(u.To.Name)), string(u.To.Type), recordValues(u.From) + "\n=>\n" + recordValues(u.To)})
+	}
+	action := "KEEP"
+	if prune {
+		action = "DELETE"
+	}
+	for _, record := range diff.Delete {
+		table.Append([]string{action, DecodeName(aws.ToString(record.Name)), string(record.Type), recordValues(record)})
+	}
+
+	table.Render()
+}
+
+// PrintDrift prints the differences between zone a and zone b, as computed
+// by DiffRecordSets(a, b), with the fields that differ.
+func PrintDrift(diff Diff, a, b string) {
+	table := newTable(os.Stdout)
+	table.SetHeader([]string{"Drift", "Name", "Type", "Fields", "Value"})
+
+	for _, record := range diff.Create {
+		table.Append([]string{"only in " + a, DecodeName(aws.ToString(record.Name)), string(record.Type), "", recordValues(record)})
+	}
+	for _, record := range diff.Delete {
+		table.Append([]string{"only in " + b, DecodeName(aws.ToString(record.Name)), string(record.Type), "", recordValues(record)})
+	}
+	for _, u := range diff.Update {
+		table.Append([]string{"different", DecodeName(aws.ToString(u.To.Name)), string(u.To.Type),
+			strings.Join(DifferentFields(u.To, u.From), "\n"), recordValues(u.To) + "\n=>\n" + recordValues(u.From)})
+	}
+
+	table.Render()
+}
+
+// PrintChangePreview prints the records a copy creates and the ones it
+// overwrites. With color, overwrites are shown in red and additions in green.
+func PrintChangePreview(preview Diff, color bool) {
+	table := newTable(os.Stdout)
+	table.SetHeader([]string{"Action", "Name", "Type", "Value"})
+
+	row := func(cells []string, fg int) {
+		if !color {
+			table.Append(cells)
+			return
 		}
-		table.Append([]string{aws.ToString(record.Name), string(record.Type), values})
+		colors := []tablewriter.Colors{{tablewriter.Bold, fg}, {}, {}, {}}
+		table.Rich(cells, colors)
+	}
+	for _, u := range preview.Update {
+		row([]string{"OVERWRITE", DecodeName(aws.ToString(u.To.Name)), string(u.To.Type), recordValues(u.From) + "\n=>\n" + recordValues(u.To)}, tablewriter.FgRedColor)
+	}
+	for _, record := range preview.Create {
+		row([]string{"CREATE", DecodeName(aws.ToString(record.Name)), string(record.Type), recordValues(record)}, tablewriter.FgGreenColor)
 	}
 
 	table.Render()
 }
+
+func recordValues(record rtypes.ResourceRecordSet) string {
+	if record.AliasTarget != nil {
+		return "ALIAS " + aws.ToString(record.AliasTarget.DNSName)
+	}
+	return strings.Join(DisplayValues(record), "\n")
+}
@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+)
+
+// DefaultProbeTimeout bounds each query of ProbeNameservers when no timeout
+// is given.
+const DefaultProbeTimeout = 5 * time.Second
+
+// DefaultProbeConcurrency is the number of queries ProbeNameservers sends at
+// once when no concurrency is given.
+const DefaultProbeConcurrency = 8
+
+// ProbeOptions are the options used by ProbeNameservers.
+type ProbeOptions struct {
+	// Sample is the number of record sets queried, spread over the zone.
+	// Zero queries all of them.
+	Sample int
+	// SkipTypes leaves out record sets of these types.
+	SkipTypes []rtypes.RRType
+	// Concurrency is the number of queries sent at once. Defaults to
+	// DefaultProbeConcurrency.
+	Concurrency int
+	// Timeout bounds each query. Defaults to DefaultProbeTimeout.
+	Timeout time.Duration
+}
+
+// ProbeNameservers queries every nameserver directly for a sample of the
+// record sets and returns the answers that are empty or differ from the
+// record set, or that failed. Alias records and records with a routing
+// policy are skipped, since their answers depend on the resolver.
+// Nameservers are host names or host:port addresses, port 53 by default.
+func ProbeNameservers(ctx context.Context, nameservers []string, records []rtypes.ResourceRecordSet, opts ProbeOptions) []DNSMismatch {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultProbeConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultProbeTimeout
+	}
+
+	type probe struct {
+		record rtypes.ResourceRecordSet
+		server string
+	}
+	probes := []probe{}
+	for _, rs := range sampleRecords(probeableRecords(records, opts.SkipTypes), opts.Sample) {
+		for _, ns := range nameservers {
+			probes = append(probes, probe{record: rs, server: withDefaultPort(strings.TrimSuffix(ns, "."))})
+		}
+	}
+
+	c := &dns.Client{Timeout: opts.Timeout}
+	results := make([]*DNSMismatch, len(probes))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				p := probes[i]
+				qctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+				answers, err := queryValues(qctx, c, p.server, p.record)
+				cancel()
+				if err != nil || !sameValues(answers, normalizedValues(p.record.Type, p.record.ResourceRecords)) {
+					results[i] = &DNSMismatch{Record: p.record, Nameserver: p.server, Answers: answers, Err: err}
+				}
+			}
+		}()
+	}
+	for i := range probes {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	mismatches := []DNSMismatch{}
+	for _, m := range results {
+		if m != nil {
+			mismatches = append(mismatches, *m)
+		}
+	}
+	return mismatches
+}
+
+// probeableRecords returns the record sets whose answers can be compared
+// with their values, leaving out the types in skip.
+func probeableRecords(records []rtypes.ResourceRecordSet, skip []rtypes.RRType) []rtypes.ResourceRecordSet {
+	skipped := map[rtypes.RRType]bool{}
+	for _, t := range skip {
+		skipped[t] = true
+	}
+	simple := []rtypes.ResourceRecordSet{}
+	for _, rs := range records {
+		if rs.AliasTarget == nil && rs.SetIdentifier == nil && len(rs.ResourceRecords) > 0 && !skipped[rs.Type] {
+			simple = append(simple, rs)
+		}
+	}
+	return simple
+}
+
+// sampleRecords returns up to sample record sets spread evenly over
+// records, or all of them when sample is zero.
+func sampleRecords(records []rtypes.ResourceRecordSet, sample int) []rtypes.ResourceRecordSet {
+	if sample <= 0 || len(records) <= sample {
+		return records
+	}
+	step := len(records) / sample
+	sampled := []rtypes.ResourceRecordSet{}
+	for i := 0; i < len(records) && len(sampled) < sample; i += step {
+		sampled = append(sampled, records[i])
+	}
+	return sampled
+}
@@ -0,0 +1,225 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+)
+
+// zoneServer is a nameserver answering from its records, counting the
+// queries it is answering at once.
+type zoneServer struct {
+	mu      sync.Mutex
+	records []dns.RR
+	// silent are the names it never answers for.
+	silent   map[string]bool
+	inFlight int32
+	peak     int32
+	queries  int32
+}
+
+func (s *zoneServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	atomic.AddInt32(&s.queries, 1)
+	q := req.Question[0]
+	s.mu.Lock()
+	if n > s.peak {
+		s.peak = n
+	}
+	silent := s.silent[strings.ToLower(q.Name)]
+	s.mu.Unlock()
+	if silent {
+		return
+	}
+	// Long enough for the other workers to send their queries.
+	time.Sleep(5 * time.Millisecond)
+
+	m := &dns.Msg{}
+	m.SetReply(req)
+	m.Authoritative = true
+	for _, rr := range s.records {
+		if strings.EqualFold(rr.Header().Name, q.Name) && rr.Header().Rrtype == q.Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+// newZoneServer serves records, in zone file format, on a local address.
+func newZoneServer(t *testing.T, records ...string) (*zoneServer, string) {
+	s := &zoneServer{silent: map[string]bool{}}
+	for _, r := range records {
+		s.records = append(s.records, rr(t, r))
+	}
+	return s, localDNS(t, s.ServeDNS)
+}
+
+func TestProbeNameservers(t *testing.T) {
+	records := []rtypes.ResourceRecordSet{
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1", "192.0.2.2"),
+		recordSet("example.com.", rtypes.RRTypeMx, "10 mail.example.com."),
+		recordSet("example.com.", rtypes.RRTypeTxt, `"v=spf1 -all"`),
+		recordSet("api.example.com.", rtypes.RRTypeCname, "api.example.net."),
+		recordSet("new.example.com.", rtypes.RRTypeA, "192.0.2.9"),
+	}
+	served := []string{
+		"www.example.com. 300 IN A 192.0.2.2",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"example.com. 300 IN MX 10 mail.example.com.",
+		`example.com. 300 IN TXT "v=spf1 -all"`,
+		"API.example.com. 300 IN CNAME api.example.net.",
+	}
+	_, good := newZoneServer(t, served...)
+	// The stale nameserver still has the old MX and not the new record.
+	_, stale := newZoneServer(t, append(served[:2:2], served[3:]...)...)
+
+	tests := []struct {
+		name        string
+		nameservers []string
+		opts        ProbeOptions
+		// want are the name, type and nameserver of the mismatches.
+		want []string
+	}{
+		{
+			name:        "one nameserver",
+			nameservers: []string{good + "."},
+			want:        []string{"new.example.com. A " + good},
+		},
+		{
+			name:        "every nameserver",
+			nameservers: []string{good, stale},
+			want:        []string{"example.com. MX " + stale, "new.example.com. A " + good, "new.example.com. A " + stale},
+		},
+		{
+			name:        "skip types",
+			nameservers: []string{good, stale},
+			opts:        ProbeOptions{SkipTypes: []rtypes.RRType{rtypes.RRTypeA, rtypes.RRTypeMx}},
+		},
+		{
+			name:        "sample",
+			nameservers: []string{stale},
+			opts:        ProbeOptions{Sample: 2},
+			// www and the TXT record, the MX is not sampled.
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mismatches := ProbeNameservers(context.Background(), tt.nameservers, records, tt.opts)
+			got := []string{}
+			for _, m := range mismatches {
+				got = append(got, fmt.Sprintf("%s %s %s", aws.ToString(m.Record.Name), m.Record.Type, m.Nameserver))
+				if m.Err != nil {
+					t.Errorf("querying %s: %s", aws.ToString(m.Record.Name), m.Err)
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("got mismatches %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeNameserversTimeout(t *testing.T) {
+	server, addr := newZoneServer(t, "www.example.com. 300 IN A 192.0.2.1")
+	server.mu.Lock()
+	server.silent["slow.example.com."] = true
+	server.mu.Unlock()
+	records := []rtypes.ResourceRecordSet{
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+		recordSet("slow.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+	}
+
+	start := time.Now()
+	mismatches := ProbeNameservers(context.Background(), []string{addr}, records, ProbeOptions{Timeout: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("probing took %s with a timeout of 100ms", elapsed)
+	}
+	if len(mismatches) != 1 || aws.ToString(mismatches[0].Record.Name) != "slow.example.com." || mismatches[0].Err == nil {
+		t.Fatalf("got %+v, want the query of slow.example.com. to time out", mismatches)
+	}
+}
+
+func TestProbeNameserversConcurrency(t *testing.T) {
+	records := hostRecords("example.com", 24)
+	served := []string{}
+	for _, rs := range records {
+		for _, v := range rs.ResourceRecords {
+			served = append(served, fmt.Sprintf("%s 300 IN A %s", aws.ToString(rs.Name), aws.ToString(v.Value)))
+		}
+	}
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			server, addr := newZoneServer(t, served...)
+
+			mismatches := ProbeNameservers(context.Background(), []string{addr}, records, ProbeOptions{Concurrency: concurrency})
+			if len(mismatches) != 0 {
+				t.Errorf("got %d mismatches: %+v", len(mismatches), mismatches[0])
+			}
+			if queries := atomic.LoadInt32(&server.queries); queries != 24 {
+				t.Errorf("sent %d queries, want 24", queries)
+			}
+			server.mu.Lock()
+			peak := server.peak
+			server.mu.Unlock()
+			if peak > int32(concurrency) || concurrency > 1 && peak < 2 {
+				t.Errorf("sent up to %d queries at once, want up to %d", peak, concurrency)
+			}
+		})
+	}
+}
+
+func TestProbeNameserversCanceled(t *testing.T) {
+	server, addr := newZoneServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ProbeNameservers(ctx, []string{addr}, hostRecords("example.com", 10), ProbeOptions{})
+	if queries := atomic.LoadInt32(&server.queries); queries != 0 {
+		t.Errorf("sent %d queries after the context was canceled", queries)
+	}
+}
+
+func TestSampleRecords(t *testing.T) {
+	alias := recordSet("alias.example.com.", rtypes.RRTypeA)
+	alias.AliasTarget = &rtypes.AliasTarget{DNSName: aws.String("lb.example.net."), HostedZoneId: aws.String("Z1")}
+	weighted := recordSet("weighted.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	weighted.SetIdentifier = aws.String("blue")
+	records := append(hostRecords("example.com", 10), alias, weighted, recordSet("example.com.", rtypes.RRTypeTxt, `"v=1"`))
+
+	probed := probeableRecords(records, []rtypes.RRType{rtypes.RRTypeTxt})
+	if len(probed) != 10 {
+		t.Fatalf("got %d probeable records, want the 10 hosts", len(probed))
+	}
+	tests := []struct {
+		sample int
+		want   []string
+	}{
+		{sample: 0, want: []string{"000", "001", "002", "003", "004", "005", "006", "007", "008", "009"}},
+		{sample: 3, want: []string{"000", "003", "006"}},
+		{sample: 4, want: []string{"000", "002", "004", "006"}},
+		{sample: 20, want: []string{"000", "001", "002", "003", "004", "005", "006", "007", "008", "009"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.sample), func(t *testing.T) {
+			got := []string{}
+			for _, rs := range sampleRecords(probed, tt.sample) {
+				got = append(got, strings.TrimSuffix(strings.TrimPrefix(aws.ToString(rs.Name), "host"), ".example.com."))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("sampled %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
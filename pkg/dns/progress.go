@@ -0,0 +1,38 @@
+package dns
+
+import "github.com/pedrokiefer/route53copy/pkg/logging"
+
+const (
+	PhaseFetch  = "fetching records"
+	PhaseSubmit = "submitting batches"
+	PhaseSync   = "waiting for sync"
+)
+
+// Progress receives progress events from long running RouteCopy operations.
+type Progress interface {
+	// Update reports that done out of total items of phase are complete.
+	// total is zero when it is not known upfront.
+	Update(phase string, done, total int)
+	// Done reports that phase has finished.
+	Done(phase string)
+}
+
+// LogProgress reports batch progress through the standard logger. It is the
+// default Progress of a RouteCopy.
+type LogProgress struct{}
+
+func (LogProgress) Update(phase string, done, total int) {
+	switch phase {
+	case PhaseSubmit:
+		logging.Infof("batch %d/%d submitted, waiting for sync\n", done, total)
+	case PhaseSync:
+		logging.Infof("batch %d/%d in sync\n", done, total)
+	}
+}
+
+func (LogProgress) Done(phase string) {}
+
+// SetProgress replaces the Progress that receives events from r.
+func (r *RouteCopy) SetProgress(p Progress) {
+	r.progress = p
+}
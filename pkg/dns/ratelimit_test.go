@@ -0,0 +1,36 @@
+package dns
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+	ctx := context.Background()
+	start := time.Now()
+	var wg sync.WaitGroup
+	// The first call is not delayed, and the next 10 are 10ms apart even
+	// when sent at once.
+	for i := 0; i < 11; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("11 calls took %s, want at least 100ms at 100 calls per second", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.Wait(cancelled); err == nil {
+		t.Error("a cancelled wait returned no error")
+	}
+}
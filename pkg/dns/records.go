@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// MaxPrintedTXT is the length in characters of the longest TXT or SPF value
+// printed as is by PrintResourceRecords. Longer values, such as DKIM keys,
+// are cut.
+const MaxPrintedTXT = 100
+
+// RemoveTypesOptions are the options used by RemoveResourceRecordsWithTypes.
+type RemoveTypesOptions struct {
+	// Delegations of subdomains of this domain are kept, see
+	// KeepDelegations.
+	DelegationsOf string
+}
+
+// KeepDelegations keeps the NS records delegating subdomains of domain, and
+// the DS records signing them, even when their type is removed.
+func KeepDelegations(domain string) func(*RemoveTypesOptions) {
+	return func(o *RemoveTypesOptions) {
+		o.DelegationsOf = domain
+	}
+}
+
+// RemoveResourceRecordsWithTypes returns the records whose type is not one
+// of types.
+func RemoveResourceRecordsWithTypes(records []rtypes.ResourceRecordSet, types []rtypes.RRType, optFns ...func(*RemoveTypesOptions)) []rtypes.ResourceRecordSet {
+	opts := RemoveTypesOptions{}
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	filtered := []rtypes.ResourceRecordSet{}
+	for _, record := range records {
+		if !typeInList(types, record.Type) ||
+			(opts.DelegationsOf != "" && isDelegation(opts.DelegationsOf, record)) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// FindNSRecord returns the NS record at the apex of domain. NS records
+// delegating subdomains are skipped, wherever they are listed.
+func FindNSRecord(domain string, records []rtypes.ResourceRecordSet) (rtypes.ResourceRecordSet, error) {
+	for _, record := range records {
+		if record.Type == rtypes.RRTypeNs && sameDomain(aws.ToString(record.Name), domain) {
+			return record, nil
+		}
+	}
+	return rtypes.ResourceRecordSet{}, fmt.Errorf("no NS record found at the apex of %s", domain)
+}
+
+// PrintResourceRecords prints the records to stdout, see
+// FprintResourceRecords.
+func PrintResourceRecords(records []rtypes.ResourceRecordSet) {
+	FprintResourceRecords(os.Stdout, records)
+}
+
+// FprintResourceRecords writes a table of the records to w, sorted by name
+// and then type. TXT and SPF values longer than MaxPrintedTXT are cut.
+func FprintResourceRecords(w io.Writer, records []rtypes.ResourceRecordSet) {
+	table := newTable(w)
+	table.SetHeader([]string{"Name", "Type", "Value"})
+
+	for _, record := range SortResourceRecords(records) {
+		table.Append([]string{DecodeName(aws.ToString(record.Name)), string(record.Type), printedValues(record)})
+	}
+
+	table.Render()
+}
+
+// SortResourceRecords returns a copy of the records sorted by name, ignoring
+// case, and then type. Records with the same name and type, such as the ones
+// of a routing policy, keep their order.
+func SortResourceRecords(records []rtypes.ResourceRecordSet) []rtypes.ResourceRecordSet {
+	sorted := append([]rtypes.ResourceRecordSet{}, records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a := strings.ToLower(DecodeName(aws.ToString(sorted[i].Name)))
+		b := strings.ToLower(DecodeName(aws.ToString(sorted[j].Name)))
+		if a != b {
+			return a < b
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+	return sorted
+}
+
+// printedValues is recordValues with the TXT and SPF values longer than
+// MaxPrintedTXT cut, followed by their length.
+func printedValues(record rtypes.ResourceRecordSet) string {
+	if !isTXTType(record.Type) || record.AliasTarget != nil {
+		return recordValues(record)
+	}
+	values := []string{}
+	for _, v := range DisplayValues(record) {
+		values = append(values, truncateValue(v, MaxPrintedTXT))
+	}
+	return strings.Join(values, "\n")
+}
+
+// truncateValue cuts value to max characters, never within one, followed
+// by its length in bytes.
+func truncateValue(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return fmt.Sprintf("%s... (%d bytes)", string(runes[:max]), len(value))
+}
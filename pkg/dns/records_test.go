@@ -0,0 +1,169 @@
+package dns
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestFindNSRecord(t *testing.T) {
+	apex := recordSet("example.com.", rtypes.RRTypeNs, "ns-1.awsdns-01.org.", "ns-2.awsdns-02.com.")
+	delegation := recordSet("sub.example.com.", rtypes.RRTypeNs, "ns1.other.net.")
+	tests := []struct {
+		name    string
+		domain  string
+		records []rtypes.ResourceRecordSet
+		want    string
+	}{
+		{
+			name:    "apex only",
+			domain:  "example.com",
+			records: []rtypes.ResourceRecordSet{apex},
+			want:    "example.com.",
+		},
+		{
+			name:    "delegation listed first",
+			domain:  "example.com",
+			records: []rtypes.ResourceRecordSet{delegation, recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"), apex},
+			want:    "example.com.",
+		},
+		{
+			name:    "domain with a trailing dot and upper case",
+			domain:  "Example.COM.",
+			records: []rtypes.ResourceRecordSet{delegation, apex},
+			want:    "example.com.",
+		},
+		{
+			name:    "delegations only",
+			domain:  "example.com",
+			records: []rtypes.ResourceRecordSet{delegation},
+		},
+		{
+			name:   "no records",
+			domain: "example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, err := FindNSRecord(tt.domain, tt.records)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("found %s, want an error", aws.ToString(ns.Name))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.ToString(ns.Name) != tt.want || len(ns.ResourceRecords) != 2 {
+				t.Errorf("found %s with %d values, want the apex NS of %s", aws.ToString(ns.Name), len(ns.ResourceRecords), tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveResourceRecordsWithTypes(t *testing.T) {
+	records := []rtypes.ResourceRecordSet{
+		recordSet("sub.example.com.", rtypes.RRTypeNs, "ns1.other.net."),
+		recordSet("sub.example.com.", rtypes.RRTypeDs, "12345 13 2 ABCDEF"),
+		recordSet("example.com.", rtypes.RRTypeNs, "ns-1.awsdns-01.org."),
+		recordSet("example.com.", rtypes.RRTypeSoa, "ns-1.awsdns-01.org. hostmaster.example.com. 1 7200 900 1209600 86400"),
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+	}
+	tests := []struct {
+		name   string
+		types  []rtypes.RRType
+		optFns []func(*RemoveTypesOptions)
+		want   []string
+	}{
+		{
+			name: "no types",
+			want: []string{"sub.example.com. NS", "sub.example.com. DS", "example.com. NS", "example.com. SOA", "www.example.com. A"},
+		},
+		{
+			name:  "apex types",
+			types: []rtypes.RRType{rtypes.RRTypeNs, rtypes.RRTypeSoa},
+			want:  []string{"sub.example.com. DS", "www.example.com. A"},
+		},
+		{
+			name:   "apex types keeping delegations",
+			types:  []rtypes.RRType{rtypes.RRTypeNs, rtypes.RRTypeSoa, rtypes.RRTypeDs},
+			optFns: []func(*RemoveTypesOptions){KeepDelegations("example.com")},
+			want:   []string{"sub.example.com. NS", "sub.example.com. DS", "www.example.com. A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, rs := range RemoveResourceRecordsWithTypes(records, tt.types, tt.optFns...) {
+				got = append(got, aws.ToString(rs.Name)+" "+string(rs.Type))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFprintResourceRecords(t *testing.T) {
+	dkim := "v=DKIM1; k=rsa; p=" + strings.Repeat("A", 300)
+	// 150 characters of 2 bytes each, cut after 99 of them past the quote.
+	accents := strings.Repeat("é", 150)
+	tests := []struct {
+		name    string
+		records []rtypes.ResourceRecordSet
+		// want are the lines holding a record, in order.
+		want []string
+	}{
+		{
+			name: "sorted by name ignoring case, then type",
+			records: []rtypes.ResourceRecordSet{
+				recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+				recordSet("API.example.com.", rtypes.RRTypeTxt, `"v=1"`),
+				recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.2"),
+			},
+			want: []string{"api.example.com. | A    | 192.0.2.2", "API.example.com. | TXT  | \"v=1\"", "www.example.com. | A    | 192.0.2.1"},
+		},
+		{
+			name: "escaped names decoded",
+			records: []rtypes.ResourceRecordSet{
+				recordSet(`\100.example.com.`, rtypes.RRTypeTxt, `"at"`),
+				recordSet(`\052.example.com.`, rtypes.RRTypeA, "192.0.2.1"),
+			},
+			want: []string{"| *.example.com.  | A ", `| \@.example.com. | TXT `},
+		},
+		{
+			name:    "long TXT value cut",
+			records: []rtypes.ResourceRecordSet{recordSet("key._domainkey.example.com.", rtypes.RRTypeTxt, `"`+dkim+`"`)},
+			want:    []string{`"` + dkim[:MaxPrintedTXT-1] + "... (320 bytes)"},
+		},
+		{
+			name:    "long multibyte TXT value cut between characters",
+			records: []rtypes.ResourceRecordSet{recordSet("note.example.com.", rtypes.RRTypeTxt, `"`+accents+`"`)},
+			want:    []string{`"` + strings.Repeat("é", MaxPrintedTXT-1) + "... (302 bytes)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			FprintResourceRecords(&buf, tt.records)
+			lines := []string{}
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "|") && !strings.Contains(line, "NAME") {
+					lines = append(lines, line)
+				}
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("got %d records:\n%s", len(lines), buf.String())
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(lines[i], want) {
+					t.Errorf("line %d is %q, want it to hold %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
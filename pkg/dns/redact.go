@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// redactedPrefix starts the stand-in of a redacted value, see RedactValue.
+const redactedPrefix = "redacted:"
+
+var redacting int32
+
+// SetRedaction turns redacting TXT and SPF values on or off wherever record
+// values are displayed: the printed tables, the logs and the reports.
+// Values written to zones, backups, plans and exports are never redacted.
+func SetRedaction(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&redacting, v)
+}
+
+// Redacting reports whether TXT and SPF values are redacted, see
+// SetRedaction.
+func Redacting() bool {
+	return atomic.LoadInt32(&redacting) == 1
+}
+
+// RedactValue returns a stand-in for a value of type t: the first 8 hex
+// digits of the SHA-256 of the value, normalized with NormalizeTXT for TXT
+// and SPF, so equal values, and the same value in both zones, get the same
+// stand-in.
+func RedactValue(t rtypes.RRType, value string) string {
+	return redactedValue(normalizeValue(t, value))
+}
+
+func redactedValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return redactedPrefix + hex.EncodeToString(sum[:])[:8]
+}
+
+// displayInvalidTXT returns an invalid TXT value as it is displayed. It
+// cannot be normalized, which would parse it again, so it is redacted as is,
+// the stand-in RedactValue returns for it.
+func displayInvalidTXT(value string) string {
+	if !Redacting() {
+		return value
+	}
+	return redactedValue(value)
+}
+
+// DisplayValue returns a value of type t as it is displayed: redacted with
+// RedactValue for TXT and SPF when redaction is on, and as is otherwise.
+func DisplayValue(t rtypes.RRType, value string) string {
+	if !Redacting() || !isTXTType(t) {
+		return value
+	}
+	return RedactValue(t, value)
+}
+
+// DisplayValues returns the values of the record set as they are displayed,
+// see DisplayValue.
+func DisplayValues(rs rtypes.ResourceRecordSet) []string {
+	values := []string{}
+	for _, rr := range rs.ResourceRecords {
+		values = append(values, DisplayValue(rs.Type, aws.ToString(rr.Value)))
+	}
+	return values
+}
@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// secretToken is a TXT value that must never be displayed with redaction
+// on.
+const secretToken = "google-site-verification=s3cr3t-t0k3n"
+
+func TestRedactValue(t *testing.T) {
+	sum := sha256.Sum256([]byte(`"` + secretToken + `"`))
+	want := "redacted:" + hex.EncodeToString(sum[:])[:8]
+	tests := []struct {
+		name  string
+		t     rtypes.RRType
+		value string
+	}{
+		{name: "TXT", t: rtypes.RRTypeTxt, value: `"` + secretToken + `"`},
+		{name: "SPF", t: rtypes.RRTypeSpf, value: `"` + secretToken + `"`},
+		{name: "unquoted", t: rtypes.RRTypeTxt, value: secretToken},
+		{name: "escaped", t: rtypes.RRTypeTxt, value: `"google-site-verification\075s3cr3t-t0k3n"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactValue(tt.t, tt.value); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+	if RedactValue(rtypes.RRTypeTxt, `"other"`) == want {
+		t.Error("different values get the same stand-in")
+	}
+}
+
+func TestDisplayValue(t *testing.T) {
+	t.Cleanup(func() { SetRedaction(false) })
+	txt := `"` + secretToken + `"`
+	tests := []struct {
+		name   string
+		redact bool
+		t      rtypes.RRType
+		value  string
+		want   string
+	}{
+		{name: "TXT", redact: true, t: rtypes.RRTypeTxt, value: txt, want: RedactValue(rtypes.RRTypeTxt, txt)},
+		{name: "SPF", redact: true, t: rtypes.RRTypeSpf, value: txt, want: RedactValue(rtypes.RRTypeSpf, txt)},
+		{name: "A", redact: true, t: rtypes.RRTypeA, value: "192.0.2.1", want: "192.0.2.1"},
+		{name: "CNAME", redact: true, t: rtypes.RRTypeCname, value: "www.example.net.", want: "www.example.net."},
+		{name: "redaction off", t: rtypes.RRTypeTxt, value: txt, want: txt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRedaction(tt.redact)
+			if got := DisplayValue(tt.t, tt.value); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactedOutput(t *testing.T) {
+	SetRedaction(true)
+	t.Cleanup(func() { SetRedaction(false) })
+	txt := recordSet("example.com.", rtypes.RRTypeTxt, `"`+secretToken+`"`)
+	spf := recordSet("example.com.", rtypes.RRTypeSpf, `"v=spf1 include:`+secretToken+` -all"`)
+	changed := recordSet("example.com.", rtypes.RRTypeTxt, `"`+secretToken+`-2"`)
+	www := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	diff := Diff{
+		Create: []rtypes.ResourceRecordSet{spf, www},
+		Update: []RecordSetUpdate{{From: txt, To: changed}},
+		Delete: []rtypes.ResourceRecordSet{txt},
+	}
+	tests := []struct {
+		name  string
+		print func()
+	}{
+		{name: "records", print: func() { PrintResourceRecords([]rtypes.ResourceRecordSet{txt, spf, www}) }},
+		{name: "diff", print: func() { PrintDiff(diff, true) }},
+		{name: "drift", print: func() { PrintDrift(diff, "a", "b") }},
+		{name: "change preview", print: func() { PrintChangePreview(diff, false) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStdout(t, tt.print)
+			if strings.Contains(out, secretToken) {
+				t.Fatalf("a TXT value is shown:\n%s", out)
+			}
+			for _, want := range []string{"example.com.", "TXT", "SPF", "192.0.2.1", RedactValue(rtypes.RRTypeTxt, `"`+secretToken+`"`)} {
+				if !strings.Contains(out, want) {
+					t.Errorf("missing %q in:\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := SubstituteValue(rtypes.RRTypeTxt, `"`+secretToken, nil)
+		if err == nil || strings.Contains(err.Error(), secretToken) {
+			t.Errorf("got %v, want an error not showing the value", err)
+		}
+		_, err = ParseTXT(`"` + secretToken)
+		if err == nil || strings.Contains(err.Error(), secretToken) {
+			t.Errorf("got %v, want an error not showing the value", err)
+		}
+	})
+}
+
+// captureStdout returns what print writes to stdout.
+func captureStdout(t *testing.T, print func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan []byte)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.Bytes()
+	}()
+	print()
+	w.Close()
+	return string(<-done)
+}
@@ -0,0 +1,65 @@
+package dns
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// RewriteDomainName replaces the from suffix of name with to. Names outside
+// of the from domain are returned unchanged.
+func RewriteDomainName(name, from, to string) string {
+	from = normalizeDomain(from)
+	to = normalizeDomain(to)
+	n := normalizeDomain(name)
+	lower := strings.ToLower(n)
+	if lower == strings.ToLower(from) {
+		return to
+	}
+	if strings.HasSuffix(lower, "."+strings.ToLower(from)) {
+		return n[:len(n)-len(from)] + to
+	}
+	return name
+}
+
+// renameRecordSet returns a copy of recordSet moved from the from domain to
+// the to domain. Alias targets inside the zone always follow the rename,
+// while values are only rewritten when rewriteValues is set.
+func renameRecordSet(recordSet rtypes.ResourceRecordSet, from, to string, rewriteValues bool) rtypes.ResourceRecordSet {
+	recordSet.Name = aws.String(RewriteDomainName(aws.ToString(recordSet.Name), from, to))
+
+	if recordSet.AliasTarget != nil {
+		alias := *recordSet.AliasTarget
+		alias.DNSName = aws.String(RewriteDomainName(aws.ToString(alias.DNSName), from, to))
+		recordSet.AliasTarget = &alias
+	}
+
+	if rewriteValues && len(recordSet.ResourceRecords) > 0 {
+		records := []rtypes.ResourceRecord{}
+		for _, rr := range recordSet.ResourceRecords {
+			records = append(records, rtypes.ResourceRecord{
+				Value: aws.String(rewriteValue(recordSet.Type, aws.ToString(rr.Value), from, to)),
+			})
+		}
+		recordSet.ResourceRecords = records
+	}
+	return recordSet
+}
+
+// rewriteValue rewrites the domain name part of CNAME, NS, MX and SRV values.
+func rewriteValue(t rtypes.RRType, value, from, to string) string {
+	switch t {
+	case rtypes.RRTypeCname, rtypes.RRTypeNs:
+		return RewriteDomainName(value, from, to)
+	case rtypes.RRTypeMx, rtypes.RRTypeSrv:
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return value
+		}
+		last := len(fields) - 1
+		fields[last] = RewriteDomainName(fields[last], from, to)
+		return strings.Join(fields, " ")
+	}
+	return value
+}
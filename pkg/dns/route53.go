@@ -4,77 +4,396 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/aws/aws-sdk-go-v2/service/route53domains"
 	rdtypes "github.com/aws/aws-sdk-go-v2/service/route53domains/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
 )
 
 type RouteCopy struct {
-	cli     *route53.Client
-	domains *route53domains.Client
+	profile   string
+	side      string
+	region    string
+	accountID string
+	cli       Route53API
+	domains   Route53DomainsAPI
+	stscli    STSAPI
+	progress  Progress
+	metrics   Metrics
+	// isolateRejected and continueOnError are set by SetIsolateRejected
+	// and SetContinueOnError.
+	isolateRejected bool
+	continueOnError bool
+
+	// mu guards accountID, since concurrent copies share the clients.
+	mu sync.Mutex
 }
 
 type HostedZoneNotFound struct {
-	Zone string
+	Zone    string
+	Private bool
 }
 
 func (e *HostedZoneNotFound) Error() string {
+	if e.Private {
+		return fmt.Sprintf("private hosted zone not found: %s", e.Zone)
+	}
 	return fmt.Sprintf("hosted zone not found: %s", e.Zone)
 }
 
-func NewRouteCopy(ctx context.Context, profile string) *RouteCopy {
-	if r := os.Getenv("AWS_REGION"); r == "" {
-		os.Setenv("AWS_REGION", "us-east-1")
+type AmbiguousHostedZone struct {
+	Zone       string
+	Candidates []rtypes.HostedZone
+}
+
+func (e *AmbiguousHostedZone) Error() string {
+	candidates := []string{}
+	for _, zone := range e.Candidates {
+		comment := ""
+		if zone.Config != nil {
+			comment = aws.ToString(zone.Config.Comment)
+		}
+		candidates = append(candidates, fmt.Sprintf("%s (%q, %d records)",
+			aws.ToString(zone.Id), comment, aws.ToInt64(zone.ResourceRecordSetCount)))
 	}
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithSharedConfigProfile(profile))
+	return fmt.Sprintf("multiple hosted zones match %s: %s", e.Zone, strings.Join(candidates, ", "))
+}
+
+// ZoneOptions select which hosted zone is looked up and how a missing zone
+// is created.
+type ZoneOptions struct {
+	// Private selects private hosted zones instead of public ones.
+	Private bool
+	// VPCID and VPCRegion are the VPC a new private zone is associated
+	// with. VPCRegion defaults to the client region.
+	VPCID     string
+	VPCRegion string
+	// Comment is the comment of a new zone, "Created by route53copy" when
+	// empty, see CopiedZoneComment.
+	Comment string
+	// DelegationSetID is the reusable delegation set a new public zone uses
+	// for its nameservers.
+	DelegationSetID string
+	// MaxWait is how long to wait for a new zone to be in sync. Defaults to
+	// DefaultWaitTimeout.
+	MaxWait time.Duration
+}
+
+// WithPrivateZone selects private hosted zones.
+func WithPrivateZone(private bool) func(*ZoneOptions) {
+	return func(o *ZoneOptions) {
+		o.Private = private
+	}
+}
+
+// WithVPC sets the VPC a new private hosted zone is associated with.
+func WithVPC(id, region string) func(*ZoneOptions) {
+	return func(o *ZoneOptions) {
+		o.VPCID = id
+		o.VPCRegion = region
+	}
+}
+
+// WithComment sets the comment of a new zone.
+func WithComment(comment string) func(*ZoneOptions) {
+	return func(o *ZoneOptions) {
+		o.Comment = comment
+	}
+}
+
+// WithWaitTimeout sets how long to wait for a new zone to be in sync.
+func WithWaitTimeout(maxWait time.Duration) func(*ZoneOptions) {
+	return func(o *ZoneOptions) {
+		o.MaxWait = maxWait
+	}
+}
+
+// WithDelegationSet creates new zones with a reusable delegation set.
+func WithDelegationSet(id string) func(*ZoneOptions) {
+	return func(o *ZoneOptions) {
+		o.DelegationSetID = id
+	}
+}
+
+// maxZoneComment is the longest hosted zone comment Route53 accepts, in
+// characters.
+const maxZoneComment = 256
+
+// copiedZoneCommentDate matches the date CopiedZoneComment ends with.
+var copiedZoneCommentDate = regexp.MustCompile(` on (\d{4}-\d{2}-\d{2})\)?$`)
+
+// CopiedZoneComment returns the comment for a zone copied on date from a
+// zone of profile with the given comment. The comment is shortened to leave
+// room for the note telling where the zone comes from.
+func CopiedZoneComment(comment, profile string, date time.Time) string {
+	note := fmt.Sprintf("copied from %s by route53copy on %s", profile, date.Format("2006-01-02"))
+	if comment == "" {
+		return strings.ToUpper(note[:1]) + note[1:]
+	}
+	runes := []rune(comment)
+	if room := maxZoneComment - utf8.RuneCountInString(note) - len(" ()"); len(runes) > room && room > 0 {
+		comment = strings.TrimSpace(string(runes[:room]))
+	}
+	return comment + " (" + note + ")"
+}
+
+func newZoneOptions(optFns []func(*ZoneOptions)) ZoneOptions {
+	options := ZoneOptions{}
+	for _, fn := range optFns {
+		fn(&options)
+	}
+	return options
+}
+
+func NewRouteCopy(ctx context.Context, profile string, optFns ...func(*ConfigOptions)) (*RouteCopy, error) {
+	cfg, err := LoadConfig(ctx, profile, optFns...)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	r := NewRouteCopyWithClients(profile, cfg.Region,
+		route53.NewFromConfig(cfg),
+		route53domains.NewFromConfig(cfg),
+		sts.NewFromConfig(cfg),
+	)
+	r.side = newConfigOptions(optFns).Side
+	return r, nil
+}
+
+// NewRouteCopyWithClients returns a RouteCopy using the given clients, for
+// callers that build their own clients or use fakes. The profile is only
+// used in messages and change comments.
+func NewRouteCopyWithClients(profile, region string, cli Route53API, domains Route53DomainsAPI, stscli STSAPI) *RouteCopy {
 	return &RouteCopy{
-		cli:     route53.NewFromConfig(cfg),
-		domains: route53domains.NewFromConfig(cfg),
+		profile:  profile,
+		region:   region,
+		cli:      cli,
+		domains:  domains,
+		stscli:   stscli,
+		progress: LogProgress{},
+		metrics:  NoMetrics{},
 	}
 }
 
-func (r *RouteCopy) GetHostedZone(ctx context.Context, domain string) (rtypes.HostedZone, error) {
-	params := &route53.ListHostedZonesByNameInput{
-		DNSName:  aws.String(domain),
-		MaxItems: aws.Int32(1),
+// Profile returns the name of the profile the clients were created for.
+func (r *RouteCopy) Profile() string {
+	return r.profile
+}
+
+func (r *RouteCopy) GetAccountID(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.accountID != "" {
+		return r.accountID, nil
+	}
+	i, err := r.stscli.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
 	}
-	resp, err := r.cli.ListHostedZonesByName(ctx, params)
+	r.accountID = aws.ToString(i.Account)
+	return r.accountID, nil
+}
+
+// CheckCredentials verifies the profile credentials with a cheap STS call,
+// so invalid credentials fail before any record is read.
+func (r *RouteCopy) CheckCredentials(ctx context.Context) error {
+	_, err := r.GetAccountID(ctx)
+	if err != nil {
+		return &ProfileError{Profile: r.profile, Side: r.side, Err: err}
+	}
+	return nil
+}
+
+type SameAccountError struct {
+	AccountID          string
+	SourceProfile      string
+	DestinationProfile string
+}
+
+func (e *SameAccountError) Error() string {
+	return fmt.Sprintf("source profile '%s' (account %s) and destination profile '%s' (account %s) refer to the same account",
+		e.SourceProfile, e.AccountID, e.DestinationProfile, e.AccountID)
+}
+
+// CheckDifferentAccounts returns a SameAccountError when src and dst
+// credentials belong to the same AWS account.
+func CheckDifferentAccounts(ctx context.Context, src, dst *RouteCopy) error {
+	srcAccount, err := src.GetAccountID(ctx)
+	if err != nil {
+		return &ProfileError{Profile: src.profile, Side: src.side, Err: err}
+	}
+	dstAccount, err := dst.GetAccountID(ctx)
+	if err != nil {
+		return &ProfileError{Profile: dst.profile, Side: dst.side, Err: err}
+	}
+	if srcAccount == dstAccount {
+		return &SameAccountError{
+			AccountID:          srcAccount,
+			SourceProfile:      src.profile,
+			DestinationProfile: dst.profile,
+		}
+	}
+	return nil
+}
+
+func (r *RouteCopy) GetHostedZone(ctx context.Context, domain string, optFns ...func(*ZoneOptions)) (rtypes.HostedZone, error) {
+	options := newZoneOptions(optFns)
+	matches, err := r.GetHostedZones(ctx, domain, optFns...)
 	if err != nil {
 		return rtypes.HostedZone{}, err
 	}
 
-	if len(resp.HostedZones) == 0 {
-		return rtypes.HostedZone{}, &HostedZoneNotFound{Zone: domain}
+	switch len(matches) {
+	case 0:
+		return rtypes.HostedZone{}, &HostedZoneNotFound{Zone: domain, Private: options.Private}
+	case 1:
+		return matches[0], nil
+	}
+	return rtypes.HostedZone{}, &AmbiguousHostedZone{Zone: domain, Candidates: matches}
+}
+
+// GetHostedZones returns every public hosted zone of domain, or every
+// private one with WithPrivateZone, for callers choosing between them when
+// GetHostedZone returns an AmbiguousHostedZone.
+func (r *RouteCopy) GetHostedZones(ctx context.Context, domain string, optFns ...func(*ZoneOptions)) ([]rtypes.HostedZone, error) {
+	options := newZoneOptions(optFns)
+	zones, err := r.listHostedZonesByName(ctx, domain)
+	if err != nil {
+		return nil, err
 	}
+	matches := []rtypes.HostedZone{}
+	for _, zone := range zones {
+		if isPrivateZone(zone) == options.Private {
+			matches = append(matches, zone)
+		}
+	}
+	return matches, nil
+}
 
-	zone := resp.HostedZones[0]
-	if *zone.Name != normalizeDomain(domain) {
-		return rtypes.HostedZone{}, &HostedZoneNotFound{Zone: domain}
+// FindEnclosingZone returns the closest hosted zone containing domain,
+// walking up its labels from domain itself. A HostedZoneNotFound for domain
+// is returned when no zone encloses it.
+func (r *RouteCopy) FindEnclosingZone(ctx context.Context, domain string, optFns ...func(*ZoneOptions)) (rtypes.HostedZone, error) {
+	options := newZoneOptions(optFns)
+	labels := strings.Split(strings.Trim(domain, "."), ".")
+	for i := range labels {
+		name := strings.Join(labels[i:], ".")
+		zone, err := r.GetHostedZone(ctx, name, optFns...)
+		var e *HostedZoneNotFound
+		if errors.As(err, &e) {
+			continue
+		}
+		return zone, err
+	}
+	return rtypes.HostedZone{}, &HostedZoneNotFound{Zone: domain, Private: options.Private}
+}
+
+// GetHostedZoneByID returns the hosted zone with the given id, bypassing the
+// name lookup.
+func (r *RouteCopy) GetHostedZoneByID(ctx context.Context, zoneId string) (rtypes.HostedZone, error) {
+	resp, err := r.cli.GetHostedZone(ctx, &route53.GetHostedZoneInput{
+		Id: aws.String(zoneId),
+	})
+	if err != nil {
+		var nshz *rtypes.NoSuchHostedZone
+		if errors.As(err, &nshz) {
+			return rtypes.HostedZone{}, &HostedZoneNotFound{Zone: zoneId}
+		}
+		return rtypes.HostedZone{}, err
+	}
+	return *resp.HostedZone, nil
+}
+
+// listHostedZonesByName returns every hosted zone named exactly domain,
+// ignoring case. Internationalized names are looked up by their punycode
+// form, which is how Route53 stores them.
+func (r *RouteCopy) listHostedZonesByName(ctx context.Context, domain string) ([]rtypes.HostedZone, error) {
+	name := zoneLookupName(domain)
+	params := &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(name),
+	}
+
+	zones := []rtypes.HostedZone{}
+	for {
+		resp, err := r.cli.ListHostedZonesByName(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		// Zones are listed by name from the given one, so the first other
+		// name ends the matches. Zones sorting before it, such as the
+		// parent domain, are never listed.
+		for _, zone := range resp.HostedZones {
+			if zoneLookupName(aws.ToString(zone.Name)) != name {
+				return zones, nil
+			}
+			zones = append(zones, zone)
+		}
+		if !resp.IsTruncated {
+			return zones, nil
+		}
+		params.DNSName = resp.NextDNSName
+		params.HostedZoneId = resp.NextHostedZoneId
 	}
-	return zone, nil
 }
 
-func (r *RouteCopy) CreateZone(ctx context.Context, domain string) (rtypes.HostedZone, error) {
+// ListAllZones returns every hosted zone in the account.
+func (r *RouteCopy) ListAllZones(ctx context.Context) ([]rtypes.HostedZone, error) {
+	zones := []rtypes.HostedZone{}
+	paginator := route53.NewListHostedZonesPaginator(r.cli, &route53.ListHostedZonesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, page.HostedZones...)
+	}
+	return zones, nil
+}
+
+func isPrivateZone(zone rtypes.HostedZone) bool {
+	return zone.Config != nil && zone.Config.PrivateZone
+}
+
+func (r *RouteCopy) CreateZone(ctx context.Context, domain string, optFns ...func(*ZoneOptions)) (rtypes.HostedZone, error) {
+	options := newZoneOptions(optFns)
+	comment := options.Comment
+	if comment == "" {
+		comment = "Created by route53copy"
+	}
 	params := &route53.CreateHostedZoneInput{
-		Name:            aws.String(normalizeDomain(domain)),
+		Name:            aws.String(zoneLookupName(domain)),
 		CallerReference: aws.String(fmt.Sprintf("%s-%d", domain, time.Now().Unix())),
 		HostedZoneConfig: &rtypes.HostedZoneConfig{
-			Comment:     aws.String("Created by route53copy"),
-			PrivateZone: false,
+			Comment:     aws.String(comment),
+			PrivateZone: options.Private,
 		},
 	}
+	if options.DelegationSetID != "" {
+		params.DelegationSetId = aws.String(options.DelegationSetID)
+	}
+	if options.Private {
+		if options.VPCID == "" {
+			return rtypes.HostedZone{}, fmt.Errorf("creating private hosted zone %s requires a VPC, use --vpc", domain)
+		}
+		region := options.VPCRegion
+		if region == "" {
+			region = r.region
+		}
+		params.VPC = &rtypes.VPC{
+			VPCId:     aws.String(options.VPCID),
+			VPCRegion: rtypes.VPCRegion(region),
+		}
+	}
 	resp, err := r.cli.CreateHostedZone(ctx, params)
 	if err != nil {
 		return rtypes.HostedZone{}, err
@@ -82,11 +401,15 @@ func (r *RouteCopy) CreateZone(ctx context.Context, domain string) (rtypes.Hoste
 
 	if resp.ChangeInfo.Status != rtypes.ChangeStatusInsync {
 		start := time.Now()
-		err := r.WaitForChange(ctx, aws.ToString(resp.ChangeInfo.Id), 1*time.Minute)
+		maxWait := options.MaxWait
+		if maxWait == 0 {
+			maxWait = DefaultWaitTimeout
+		}
+		err := r.WaitForChange(ctx, aws.ToString(resp.ChangeInfo.Id), maxWait)
 		if err != nil {
 			return *resp.HostedZone, fmt.Errorf("error waiting for change to be in-sync: %s", err)
 		}
-		log.Printf("Waited %s for zone '%s' to be in-sync", time.Since(start), domain)
+		logging.From(ctx).Infof("Waited %s for zone '%s' to be in-sync", time.Since(start), domain)
 
 		zone, err := r.cli.GetHostedZone(ctx, &route53.GetHostedZoneInput{
 			Id: resp.HostedZone.Id,
@@ -100,24 +423,99 @@ func (r *RouteCopy) CreateZone(ctx context.Context, domain string) (rtypes.Hoste
 	return *resp.HostedZone, nil
 }
 
+// UpdateZoneComment replaces the comment of the zone.
+func (r *RouteCopy) UpdateZoneComment(ctx context.Context, zoneId, comment string) error {
+	_, err := r.cli.UpdateHostedZoneComment(ctx, &route53.UpdateHostedZoneCommentInput{
+		Id:      aws.String(zoneId),
+		Comment: aws.String(comment),
+	})
+	return err
+}
+
+// DefaultWaitTimeout is how long to wait for a change to be in sync when no
+// other timeout is given.
+const DefaultWaitTimeout = 10 * time.Minute
+
+// ChangeTimeout is returned when a change is still pending after the maximum
+// wait. The change is usually applied later, which can be waited for with
+// WaitForChange.
+type ChangeTimeout struct {
+	Profile  string
+	ChangeID string
+	Waited   time.Duration
+}
+
+func (e *ChangeTimeout) Error() string {
+	return fmt.Sprintf("change %s is not in sync after %s", e.ChangeID, e.Waited)
+}
+
+// NewRouteCopyForTest returns a RouteCopy whose clients send every request
+// to endpoint with fake credentials, such as a fakeroute53.Server. Throttled
+// calls are not retried.
+func NewRouteCopyForTest(profile, endpoint string) *RouteCopy {
+	cfg := aws.Config{
+		Region:                      DefaultRegion,
+		Credentials:                 credentials.NewStaticCredentialsProvider("AKIDTEST", "secret", ""),
+		EndpointResolverWithOptions: endpointResolver(endpoint),
+		Retryer: func() aws.Retryer {
+			return aws.NopRetryer{}
+		},
+		APIOptions: []func(*middleware.Stack) error{addDebugMiddleware},
+	}
+	return NewRouteCopyWithClients(profile, cfg.Region,
+		route53.NewFromConfig(cfg),
+		route53domains.NewFromConfig(cfg),
+		sts.NewFromConfig(cfg),
+	)
+}
+
+// WaitForChange waits up to maxWait for a change to be in sync, returning a
+// ChangeTimeout when it is still pending.
 func (r *RouteCopy) WaitForChange(ctx context.Context, changeId string, maxWait time.Duration) error {
-	waiter := route53.NewResourceRecordSetsChangedWaiter(r.cli, func(rrscwo *route53.ResourceRecordSetsChangedWaiterOptions) {
-		rrscwo.MinDelay = 15 * time.Second
+	defer r.observe(OpWaitForChange, time.Now())
+	waiter := route53.NewResourceRecordSetsChangedWaiter(timedGetChange{r}, func(rrscwo *route53.ResourceRecordSetsChangedWaiterOptions) {
+		rrscwo.MinDelay = waitMinDelay(maxWait)
 	})
-	return waiter.Wait(ctx, &route53.GetChangeInput{
+	err := waiter.Wait(ctx, &route53.GetChangeInput{
 		Id: aws.String(changeId),
 	}, maxWait)
+	if err != nil && ctx.Err() == nil && waitTimedOut(err) {
+		return &ChangeTimeout{Profile: r.profile, ChangeID: shortChangeID(changeId), Waited: maxWait}
+	}
+	return err
+}
+
+// waitMinDelay polls short waits more often than the 15 seconds used for
+// long ones, so they still get a few attempts.
+func waitMinDelay(maxWait time.Duration) time.Duration {
+	delay := maxWait / 8
+	if delay < time.Second {
+		return time.Second
+	}
+	if delay > 15*time.Second {
+		return 15 * time.Second
+	}
+	return delay
+}
+
+func waitTimedOut(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "exceeded max wait time")
+}
+
+// shortChangeID strips the "/change/" prefix Route53 adds to change ids.
+func shortChangeID(changeId string) string {
+	return strings.TrimPrefix(changeId, "/change/")
 }
 
-func (r *RouteCopy) GetOrCreateZone(ctx context.Context, domain string) (rtypes.HostedZone, error) {
+func (r *RouteCopy) GetOrCreateZone(ctx context.Context, domain string, optFns ...func(*ZoneOptions)) (rtypes.HostedZone, error) {
 	var zone rtypes.HostedZone
 	var err error
-	zone, err = r.GetHostedZone(ctx, domain)
+	zone, err = r.GetHostedZone(ctx, domain, optFns...)
 	if err != nil {
 		var e *HostedZoneNotFound
 		if errors.As(err, &e) {
-			log.Printf("Destination profile does not contain %s, creating it\n", domain)
-			zone, err = r.CreateZone(ctx, domain)
+			logging.From(ctx).Infof("Destination profile does not contain %s, creating it\n", DisplayDomain(domain))
+			zone, err = r.CreateZone(ctx, domain, optFns...)
 			if err != nil {
 				return zone, err
 			}
@@ -128,60 +526,76 @@ func (r *RouteCopy) GetOrCreateZone(ctx context.Context, domain string) (rtypes.
 	return zone, nil
 }
 
+// GetResourceRecords returns every record set of the zone, see
+// ForEachResourceRecord.
 func (r *RouteCopy) GetResourceRecords(ctx context.Context, zoneId string) ([]rtypes.ResourceRecordSet, error) {
+	records := []rtypes.ResourceRecordSet{}
+	err := r.ForEachResourceRecord(ctx, zoneId, func(page []rtypes.ResourceRecordSet) error {
+		records = append(records, page...)
+		return nil
+	})
+	return records, err
+}
+
+// ForEachResourceRecord calls fn with the record sets of each page of the
+// zone as it is listed, without holding the whole zone in memory. It stops
+// at the first error fn returns.
+func (r *RouteCopy) ForEachResourceRecord(ctx context.Context, zoneId string, fn func([]rtypes.ResourceRecordSet) error) error {
 	params := &route53.ListResourceRecordSetsInput{
 		HostedZoneId: aws.String(zoneId),
 	}
 	paginator := NewListResourceRecordSetsPaginator(r.cli, params)
 
-	records := []rtypes.ResourceRecordSet{}
+	fetched := 0
 	for paginator.HasMorePages() {
+		start := time.Now()
 		page, err := paginator.NextPage(ctx)
+		r.observe(OpListRecords, start)
+		if err != nil {
+			return err
+		}
+		err = fn(page.ResourceRecordSets)
 		if err != nil {
-			return records, err
+			return err
 		}
-		records = append(records, page.ResourceRecordSets...)
+		fetched += len(page.ResourceRecordSets)
+		logging.From(ctx).Debugf("Fetched %d record sets from zone %s\n", fetched, zoneId)
+		r.progress.Update(PhaseFetch, fetched, 0)
 	}
+	r.progress.Done(PhaseFetch)
+	return nil
+}
+
+// DeleteOptions controls which record sets DeleteRecordsWithOptions deletes.
+type DeleteOptions struct {
+	// SkipDelegations keeps NS records delegating subdomains. The apex NS
+	// and SOA records are always kept.
+	SkipDelegations bool
+	// Comment is the comment of the change batches. Defaults to "Deleting
+	// records of <domain>".
+	Comment string
+}
 
-	return records, nil
+func (r *RouteCopy) DeleteRecords(ctx context.Context, zoneId, domain string, records []rtypes.ResourceRecordSet, maxWait time.Duration) ([]BatchResult, error) {
+	return r.DeleteRecordsWithOptions(ctx, zoneId, domain, records, maxWait, DeleteOptions{})
 }
 
-func (r *RouteCopy) DeleteRecords(ctx context.Context, zoneId string, records []rtypes.ResourceRecordSet) (string, error) {
+func (r *RouteCopy) DeleteRecordsWithOptions(ctx context.Context, zoneId, domain string, records []rtypes.ResourceRecordSet, maxWait time.Duration, opts DeleteOptions) ([]BatchResult, error) {
 	changes := []rtypes.Change{}
 	for _, record := range records {
-		if record.Type == rtypes.RRTypeNs || record.Type == rtypes.RRTypeSoa {
+		if isApexRecord(domain, record) {
 			continue
 		}
-		changes = append(changes, rtypes.Change{
-			Action: rtypes.ChangeActionDelete,
-			ResourceRecordSet: &rtypes.ResourceRecordSet{
-				Name:                    record.Name,
-				Type:                    record.Type,
-				AliasTarget:             record.AliasTarget,
-				Failover:                record.Failover,
-				GeoLocation:             record.GeoLocation,
-				HealthCheckId:           record.HealthCheckId,
-				MultiValueAnswer:        record.MultiValueAnswer,
-				Region:                  record.Region,
-				ResourceRecords:         record.ResourceRecords,
-				SetIdentifier:           record.SetIdentifier,
-				TTL:                     record.TTL,
-				TrafficPolicyInstanceId: record.TrafficPolicyInstanceId,
-				Weight:                  record.Weight,
-			},
-		})
-	}
-	params := &route53.ChangeResourceRecordSetsInput{
-		HostedZoneId: aws.String(zoneId),
-		ChangeBatch: &rtypes.ChangeBatch{
-			Changes: changes,
-		},
+		if opts.SkipDelegations && isDelegation(domain, record) {
+			continue
+		}
+		changes = append(changes, recordSetChange(rtypes.ChangeActionDelete, record))
 	}
-	ch, err := r.cli.ChangeResourceRecordSets(ctx, params)
-	if err != nil {
-		return "", err
+	comment := opts.Comment
+	if comment == "" {
+		comment = "Deleting records of " + normalizeDomain(domain)
 	}
-	return aws.ToString(ch.ChangeInfo.Id), nil
+	return r.ApplyChanges(ctx, zoneId, comment, changes, maxWait)
 }
 
 func (r *RouteCopy) DeleteHostedZone(ctx context.Context, zoneId string) (string, error) {
@@ -194,51 +608,116 @@ func (r *RouteCopy) DeleteHostedZone(ctx context.Context, zoneId string) (string
 	return aws.ToString(dhz.ChangeInfo.Id), nil
 }
 
-func (r *RouteCopy) GetNSRecords(ctx context.Context, zoneId string) (rtypes.ResourceRecordSet, error) {
+// GetNSRecords returns the NS record at the apex of the zone of domain, see
+// FindNSRecord.
+func (r *RouteCopy) GetNSRecords(ctx context.Context, zoneId, domain string) (rtypes.ResourceRecordSet, error) {
 	records, err := r.GetResourceRecords(ctx, zoneId)
 	if err != nil {
 		return rtypes.ResourceRecordSet{}, err
 	}
+	return FindNSRecord(domain, records)
+}
 
-	for _, r := range records {
-		if r.Type != rtypes.RRTypeNs {
-			continue
-		}
-		return r, nil
-	}
+// ChangeOptions controls which record sets CreateChangesWithOptions turns
+// into changes.
+type ChangeOptions struct {
+	// Types restricts the changes to record sets of the given types. When
+	// empty, record sets of every type are included.
+	Types []rtypes.RRType
+	// ExcludeTypes leaves out record sets of the given types, on top of
+	// Types.
+	ExcludeTypes []rtypes.RRType
+	// DestinationDomain, when set to a name other than the source domain,
+	// moves every record name from the source domain to this domain.
+	DestinationDomain string
+	// RewriteValues also moves CNAME, NS, MX and SRV values pointing inside
+	// the source domain to the destination domain.
+	RewriteValues bool
+	// SkipDelegations leaves out NS records delegating subdomains. The apex
+	// NS and SOA records are never copied.
+	SkipDelegations bool
+	// SkipValidationRecords leaves out the certificate validation records
+	// of the source account, see IsValidationRecord.
+	SkipValidationRecords bool
+	// TTL overrides or clamps the TTLs of the changes, see ApplyTTLOptions.
+	TTL TTLOptions
+}
 
-	return rtypes.ResourceRecordSet{}, fmt.Errorf("no NS records found")
+func (r *RouteCopy) CreateChanges(ctx context.Context, domain string, recordSets []rtypes.ResourceRecordSet) []rtypes.Change {
+	changes, _ := r.CreateChangesWithOptions(ctx, domain, recordSets, ChangeOptions{})
+	return changes
 }
 
-func (r *RouteCopy) CreateChanges(domain string, recordSets []rtypes.ResourceRecordSet) []rtypes.Change {
+// CreateChangesWithOptions returns the changes copying recordSets, and the
+// record sets left out and why. Record sets created by traffic policy
+// instances are always left out, see IsTrafficPolicyRecord.
+func (r *RouteCopy) CreateChangesWithOptions(ctx context.Context, domain string, recordSets []rtypes.ResourceRecordSet, opts ChangeOptions) ([]rtypes.Change, []ExcludedRecord) {
 	domain = normalizeDomain(domain)
 	var changes []rtypes.Change
+	excluded := []ExcludedRecord{}
+	exclude := func(recordSet rtypes.ResourceRecordSet, cause, reason string) {
+		excluded = append(excluded, ExcludedRecord{Record: recordSet, Cause: cause, Reason: reason})
+	}
 	for _, recordSet := range recordSets {
-		if (recordSet.Type == rtypes.RRTypeNs || recordSet.Type == rtypes.RRTypeSoa) && *recordSet.Name == domain {
+		if isApexRecord(domain, recordSet) {
+			exclude(recordSet, ExcludedApex, "the destination zone has its own")
+			continue
+		}
+		if len(opts.Types) > 0 && !typeInList(opts.Types, recordSet.Type) {
+			exclude(recordSet, ExcludedType, fmt.Sprintf("type is not one of %s", typesToString(opts.Types)))
+			continue
+		}
+		if typeInList(opts.ExcludeTypes, recordSet.Type) {
+			exclude(recordSet, ExcludedType, fmt.Sprintf("type is one of %s", typesToString(opts.ExcludeTypes)))
+			continue
+		}
+		if opts.SkipDelegations && isDelegation(domain, recordSet) {
+			exclude(recordSet, ExcludedDelegation, "delegates a subdomain")
+			continue
+		}
+		if opts.SkipValidationRecords && IsValidationRecord(recordSet) {
+			logging.From(ctx).Infof("Skipping certificate validation record %s %s\n", DecodeName(aws.ToString(recordSet.Name)), recordSet.Type)
+			exclude(recordSet, ExcludedValidation, "validates a certificate of the source account")
+			continue
+		}
+		if IsTrafficPolicyRecord(recordSet) {
+			exclude(recordSet, ExcludedTrafficPolicy, fmt.Sprintf("created by traffic policy instance %s", aws.ToString(recordSet.TrafficPolicyInstanceId)))
 			continue
 		}
-		change := rtypes.Change{
-			Action: rtypes.ChangeActionUpsert,
-			ResourceRecordSet: &rtypes.ResourceRecordSet{
-				Name:                    recordSet.Name,
-				Type:                    recordSet.Type,
-				AliasTarget:             recordSet.AliasTarget,
-				Failover:                recordSet.Failover,
-				GeoLocation:             recordSet.GeoLocation,
-				HealthCheckId:           recordSet.HealthCheckId,
-				MultiValueAnswer:        recordSet.MultiValueAnswer,
-				Region:                  recordSet.Region,
-				ResourceRecords:         recordSet.ResourceRecords,
-				SetIdentifier:           recordSet.SetIdentifier,
-				TTL:                     recordSet.TTL,
-				TrafficPolicyInstanceId: recordSet.TrafficPolicyInstanceId,
-				Weight:                  recordSet.Weight,
-			},
+		if opts.DestinationDomain != "" {
+			recordSet = renameRecordSet(recordSet, domain, opts.DestinationDomain, opts.RewriteValues)
 		}
+		change := recordSetChange(rtypes.ChangeActionUpsert, recordSet)
 		changes = append(changes, change)
 	}
-	return changes
+	if !opts.TTL.Empty() {
+		changes, _ = ApplyTTLOptions(changes, opts.TTL)
+	}
+	return changes, excluded
+}
 
+// recordSetChange copies every field of recordSet that ChangeResourceRecordSets
+// accepts into a change.
+func recordSetChange(action rtypes.ChangeAction, recordSet rtypes.ResourceRecordSet) rtypes.Change {
+	return rtypes.Change{
+		Action: action,
+		ResourceRecordSet: &rtypes.ResourceRecordSet{
+			Name:                    recordSet.Name,
+			Type:                    recordSet.Type,
+			AliasTarget:             recordSet.AliasTarget,
+			CidrRoutingConfig:       recordSet.CidrRoutingConfig,
+			Failover:                recordSet.Failover,
+			GeoLocation:             recordSet.GeoLocation,
+			HealthCheckId:           recordSet.HealthCheckId,
+			MultiValueAnswer:        recordSet.MultiValueAnswer,
+			Region:                  recordSet.Region,
+			ResourceRecords:         recordSet.ResourceRecords,
+			SetIdentifier:           recordSet.SetIdentifier,
+			TTL:                     recordSet.TTL,
+			TrafficPolicyInstanceId: recordSet.TrafficPolicyInstanceId,
+			Weight:                  recordSet.Weight,
+		},
+	}
 }
 
 func normalizeDomain(domain string) string {
@@ -257,49 +736,73 @@ func denormalizeDomain(domain string) string {
 	}
 }
 
-func (r *RouteCopy) UpdateRecords(ctx context.Context, sourceProfile, zoneId string, changes []rtypes.Change) (*rtypes.ChangeInfo, error) {
-	params := &route53.ChangeResourceRecordSetsInput{
-		HostedZoneId: aws.String(zoneId),
-		ChangeBatch: &rtypes.ChangeBatch{
-			Changes: changes,
-			Comment: aws.String("Importing ALL records from " + sourceProfile),
-		},
-	}
-	resp, err := r.cli.ChangeResourceRecordSets(ctx, params)
-	if err != nil {
-		return nil, err
-	}
-	return resp.ChangeInfo, nil
+// UpdateRecords applies changes with the given change batch comment, see
+// ApplyChanges.
+func (r *RouteCopy) UpdateRecords(ctx context.Context, zoneId, comment string, changes []rtypes.Change, maxWait time.Duration) ([]BatchResult, error) {
+	return r.ApplyChanges(ctx, zoneId, comment, changes, maxWait)
 }
 
-func (r *RouteCopy) UpdateNSRecords(ctx context.Context, domain, zoneId string) (bool, error) {
-	nsRecords, err := r.GetNSRecords(ctx, zoneId)
+// UpdateNSRecords points the registrar nameservers of domain at the zone.
+// When maxWait is not zero it also waits for the registrar to complete the
+// change.
+func (r *RouteCopy) UpdateNSRecords(ctx context.Context, domain, zoneId string, maxWait time.Duration) (bool, error) {
+	return r.UpdateNSRecordsWithOptions(ctx, domain, zoneId, NSUpdateOptions{OperationWait: maxWait})
+}
+
+// NSUpdateOptions are the options used by UpdateNSRecordsWithOptions.
+type NSUpdateOptions struct {
+	// OperationWait is how long to wait for the registrar to complete the
+	// change. Zero does not wait.
+	OperationWait time.Duration
+	// DelegationWait is how long to wait for the parent zone to delegate
+	// to the zone nameservers, see WaitForDelegation. Zero does not check.
+	DelegationWait time.Duration
+}
+
+// UpdateNSRecordsWithOptions points the registrar nameservers of domain at
+// the zone and reports whether they changed. The delegation is checked even
+// when the registrar already had the zone nameservers.
+func (r *RouteCopy) UpdateNSRecordsWithOptions(ctx context.Context, domain, zoneId string, opts NSUpdateOptions) (bool, error) {
+	nsRecords, err := r.GetNSRecords(ctx, zoneId, domain)
 	if err != nil {
 		return false, err
 	}
 	ddo, err := r.domains.GetDomainDetail(ctx, &route53domains.GetDomainDetailInput{
-		DomainName: aws.String(domain),
+		DomainName: aws.String(registrarDomain(domain)),
 	})
 	if err != nil {
 		return false, err
 	}
 
-	if MatchNSRecords(ddo.Nameservers, nsRecords) {
-		return false, nil
-	}
-
-	newNS := nameserversFromRecords(nsRecords)
+	updated := false
+	if !MatchNSRecords(ddo.Nameservers, nsRecords) {
+		udno, err := r.domains.UpdateDomainNameservers(ctx, &route53domains.UpdateDomainNameserversInput{
+			DomainName:  aws.String(registrarDomain(domain)),
+			Nameservers: nameserversFromRecords(nsRecords),
+		})
+		if err != nil {
+			return false, err
+		}
+		updated = true
+		logging.From(ctx).Infof("Updated NS records for %s: %s\n", DisplayDomain(registrarDomain(domain)), aws.ToString(udno.OperationId))
 
-	udno, err := r.domains.UpdateDomainNameservers(ctx, &route53domains.UpdateDomainNameserversInput{
-		DomainName:  aws.String(domain),
-		Nameservers: newNS,
-	})
+		if opts.OperationWait > 0 {
+			logging.From(ctx).Infof("Waiting up to %s for the registrar to apply the nameservers\n", opts.OperationWait)
+			_, err := waitForOperation(ctx, r.domains, aws.ToString(udno.OperationId), opts.OperationWait)
+			if err != nil {
+				return true, err
+			}
+		}
+	}
 
-	if err != nil {
-		return false, err
+	if opts.DelegationWait > 0 {
+		logging.From(ctx).Infof("Waiting up to %s for the parent zone to delegate %s to the new nameservers\n", opts.DelegationWait, domain)
+		err := WaitForDelegation(ctx, domain, nsRecords, opts.DelegationWait)
+		if err != nil {
+			return updated, err
+		}
 	}
-	log.Printf("Updated NS records for %s: %s", domain, aws.ToString(udno.OperationId))
-	return true, nil
+	return updated, nil
 }
 
 func MatchNSRecords(ns []rdtypes.Nameserver, rs rtypes.ResourceRecordSet) bool {
@@ -312,9 +815,11 @@ func MatchNSRecords(ns []rdtypes.Nameserver, rs rtypes.ResourceRecordSet) bool {
 	return true
 }
 
+// findInList reports whether name is one of the nameservers, ignoring case
+// and whether either side is in punycode.
 func findInList(ns []rdtypes.Nameserver, name string) bool {
 	for _, n := range ns {
-		if aws.ToString(n.Name) == name {
+		if sameDomain(aws.ToString(n.Name), name) {
 			return true
 		}
 	}
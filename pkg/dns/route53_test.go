@@ -0,0 +1,720 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53domains"
+	rdtypes "github.com/aws/aws-sdk-go-v2/service/route53domains/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+func TestListHostedZonesByName(t *testing.T) {
+	server := fakeroute53.NewServer()
+	defer server.Close()
+	// Pages of two zones split the zones named example.com.
+	server.MaxZones = 2
+	want := []string{}
+	for _, name := range []string{"example.com", "aexample.com", "example.com", "myexample.com", "sub.example.com", "example.com", "example.org"} {
+		id := server.AddZone(name, false)
+		if name == "example.com" {
+			want = append(want, "/hostedzone/"+id)
+		}
+	}
+	r := NewRouteCopyForTest("source", server.URL)
+
+	tests := []struct {
+		domain string
+		want   []string
+		pages  int
+	}{
+		{"example.com", want, 2},
+		{"EXAMPLE.com.", want, 2},
+		{"myexample.com", []string{"/hostedzone/" + server.FindZone("myexample.com")[0]}, 1},
+		// Neither the zones of the parent domain nor the ones whose names
+		// end with the domain match.
+		{"xample.com", nil, 1},
+		{"com", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			before := server.Calls(fakeroute53.OpListHostedZonesByName)
+			zones, err := r.listHostedZonesByName(context.Background(), tt.domain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, z := range zones {
+				ids = append(ids, aws.ToString(z.Id))
+			}
+			if len(tt.want) == 0 && len(ids) == 0 {
+				ids = nil
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("got zones %v, want %v", ids, tt.want)
+			}
+			if pages := server.Calls(fakeroute53.OpListHostedZonesByName) - before; pages != tt.pages {
+				t.Errorf("listed %d pages, want %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestGetHostedZone(t *testing.T) {
+	server := fakeroute53.NewServer()
+	defer server.Close()
+	server.MaxZones = 1
+	// Zones sorting right before and after the ones looked up.
+	for _, name := range []string{"example.com.br", "a.sub.example.com", "sub.example.co", "sub-example.com", "xn--mnchen-shop-thc.de"} {
+		server.AddZone(name, false)
+	}
+	sub := "/hostedzone/" + server.AddZone("sub.example.com", false)
+	idn := "/hostedzone/" + server.AddZone("xn--mnchen-shop-thb.de", false)
+	public := "/hostedzone/" + server.AddZone("example.net", false)
+	private := "/hostedzone/" + server.AddZone("example.net", true)
+	duplicates := []string{"/hostedzone/" + server.AddZone("example.org", false), "/hostedzone/" + server.AddZone("example.org", false)}
+	r := NewRouteCopyForTest("destination", server.URL)
+
+	tests := []struct {
+		domain  string
+		private bool
+		// want is the zone id, none when no zone is named domain.
+		want []string
+	}{
+		{domain: "example.com"},
+		{domain: "example.com.br.", want: []string{"/hostedzone/" + server.FindZone("example.com.br")[0]}},
+		{domain: "sub.example.com", want: []string{sub}},
+		{domain: "SUB.Example.COM.", want: []string{sub}},
+		{domain: "example.sub.com"},
+		{domain: "münchen-shop.de", want: []string{idn}},
+		{domain: "München-Shop.DE.", want: []string{idn}},
+		{domain: "xn--mnchen-shop-thb.de", want: []string{idn}},
+		{domain: "example.net", want: []string{public}},
+		{domain: "example.net", private: true, want: []string{private}},
+		{domain: "example.org", private: true},
+		{domain: "example.org", want: duplicates},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s private %t", tt.domain, tt.private), func(t *testing.T) {
+			zone, err := r.GetHostedZone(context.Background(), tt.domain, WithPrivateZone(tt.private))
+			switch len(tt.want) {
+			case 0:
+				var notFound *HostedZoneNotFound
+				if !errors.As(err, &notFound) || notFound.Zone != tt.domain || notFound.Private != tt.private {
+					t.Fatalf("got zone %s and %v, want HostedZoneNotFound for %s", aws.ToString(zone.Id), err, tt.domain)
+				}
+			case 1:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if aws.ToString(zone.Id) != tt.want[0] {
+					t.Errorf("got zone %s (%s), want %s", aws.ToString(zone.Id), aws.ToString(zone.Name), tt.want[0])
+				}
+			default:
+				var ambiguous *AmbiguousHostedZone
+				if !errors.As(err, &ambiguous) {
+					t.Fatalf("got zone %s and %v, want AmbiguousHostedZone", aws.ToString(zone.Id), err)
+				}
+				ids := []string{}
+				for _, z := range ambiguous.Candidates {
+					ids = append(ids, aws.ToString(z.Id))
+				}
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("got candidates %v, want %v", ids, tt.want)
+				}
+				for _, id := range tt.want {
+					if !strings.Contains(err.Error(), strings.TrimPrefix(id, "/hostedzone/")) {
+						t.Errorf("the error %q does not list zone %s", err, id)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFindEnclosingZone(t *testing.T) {
+	server := fakeroute53.NewServer()
+	defer server.Close()
+	parent := "/hostedzone/" + server.AddZone("example.com", false)
+	sub := "/hostedzone/" + server.AddZone("sub.example.com", false)
+	private := "/hostedzone/" + server.AddZone("internal.example.com", true)
+	server.AddZone("xample.com", false)
+	r := NewRouteCopyForTest("destination", server.URL)
+
+	tests := []struct {
+		domain  string
+		private bool
+		// want is the zone id, none when no zone encloses the domain.
+		want string
+	}{
+		{domain: "example.com", want: parent},
+		{domain: "example.com.", want: parent},
+		{domain: "internal.example.com", want: parent},
+		{domain: "internal.example.com.", want: parent},
+		{domain: "db.eu.Internal.Example.com.", want: parent},
+		{domain: "sub.example.com", want: sub},
+		{domain: "a.b.sub.example.com.", want: sub},
+		{domain: "db.internal.example.com", private: true, want: private},
+		{domain: "www.example.com", private: true},
+		// Only whole labels are walked.
+		{domain: "ample.com"},
+		{domain: "example.org."},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s private %t", tt.domain, tt.private), func(t *testing.T) {
+			zone, err := r.FindEnclosingZone(context.Background(), tt.domain, WithPrivateZone(tt.private))
+			if tt.want == "" {
+				var notFound *HostedZoneNotFound
+				if !errors.As(err, &notFound) || notFound.Zone != tt.domain || notFound.Private != tt.private {
+					t.Fatalf("got zone %s and %v, want HostedZoneNotFound for %s", aws.ToString(zone.Id), err, tt.domain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.ToString(zone.Id) != tt.want {
+				t.Errorf("got zone %s (%s), want %s", aws.ToString(zone.Id), aws.ToString(zone.Name), tt.want)
+			}
+		})
+	}
+}
+
+func TestCopiedZoneComment(t *testing.T) {
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		comment string
+		want    string
+	}{
+		{
+			name: "empty",
+			want: "Copied from prod by route53copy on 2024-05-01",
+		},
+		{
+			name:    "short",
+			comment: "Zone de l'équipe",
+			want:    "Zone de l'équipe (copied from prod by route53copy on 2024-05-01)",
+		},
+		{
+			name:    "long non-ASCII",
+			comment: strings.Repeat("é", 300),
+			want:    strings.Repeat("é", 208) + " (copied from prod by route53copy on 2024-05-01)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CopiedZoneComment(tt.comment, "prod", date)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || utf8.RuneCountInString(got) > maxZoneComment {
+				t.Errorf("got %d characters, want a valid comment of at most %d", utf8.RuneCountInString(got), maxZoneComment)
+			}
+		})
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		// code is the error code GetCallerIdentity fails with.
+		code string
+		want string
+	}{
+		{name: "valid"},
+		{name: "invalid", code: "InvalidClientTokenId", want: "credentials of source profile 'prod' are invalid"},
+		{name: "expired", code: "ExpiredToken", want: "credentials of source profile 'prod' expired"},
+		{name: "other", code: "AccessDenied", want: "source profile 'prod' not found or credentials invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeroute53.NewServer()
+			t.Cleanup(server.Close)
+			server.Fail = func(operation string) string {
+				if operation == fakeroute53.OpGetCallerIdentity {
+					return tt.code
+				}
+				return ""
+			}
+			r := NewRouteCopyForTest("prod", server.URL)
+			r.side = "source"
+
+			err := r.CheckCredentials(context.Background())
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var pe *ProfileError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v, want a ProfileError", err)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to start with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// failingSTS fails every GetCallerIdentity call with err.
+type failingSTS struct {
+	err error
+}
+
+func (s failingSTS) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return nil, s.err
+}
+
+func TestCheckCredentialsHints(t *testing.T) {
+	// callerIdentity wraps err as the SDK does when the credentials of a
+	// GetCallerIdentity call cannot be retrieved.
+	callerIdentity := func(err error) error {
+		return &smithy.OperationError{
+			ServiceID:     "STS",
+			OperationName: "GetCallerIdentity",
+			Err:           fmt.Errorf("failed to refresh cached credentials, %w", err),
+		}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "expired SSO token",
+			err:  callerIdentity(&ssocreds.InvalidTokenError{Err: errors.New("the SSO session has expired or is invalid")}),
+			want: "SSO session for destination profile 'dst' expired, run `aws sso login --profile dst`",
+		},
+		{
+			name: "SSO call failed",
+			err: callerIdentity(&smithy.OperationError{
+				ServiceID:     "SSO",
+				OperationName: "GetRoleCredentials",
+				Err:           &smithy.GenericAPIError{Code: "UnauthorizedException", Message: "Session token not found or invalid"},
+			}),
+			want: "SSO session for destination profile 'dst' expired, run `aws sso login --profile dst`",
+		},
+		{
+			name: "missing MFA",
+			err:  callerIdentity(config.AssumeRoleTokenProviderNotSetError{}),
+			want: "destination profile 'dst' assumes a role requiring an MFA code",
+		},
+		{
+			name: "cannot assume role",
+			err: callerIdentity(&smithy.OperationError{
+				ServiceID:     "STS",
+				OperationName: "AssumeRole",
+				Err:           &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform sts:AssumeRole"},
+			}),
+			want: "destination profile 'dst' cannot assume its role",
+		},
+		{
+			name: "expired credentials",
+			err:  &smithy.OperationError{ServiceID: "STS", OperationName: "GetCallerIdentity", Err: &smithy.GenericAPIError{Code: "ExpiredTokenException"}},
+			want: "credentials of destination profile 'dst' expired",
+		},
+		{
+			name: "other",
+			err:  errors.New("connection refused"),
+			want: "destination profile 'dst' not found or credentials invalid: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouteCopyWithClients("dst", DefaultRegion, nil, nil, failingSTS{err: tt.err})
+			r.side = "destination"
+
+			err := r.CheckCredentials(context.Background())
+			var pe *ProfileError
+			if !errors.As(err, &pe) || pe.Profile != "dst" || pe.Side != "destination" {
+				t.Fatalf("got %v, want a ProfileError of the destination profile dst", err)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to start with %q", err, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("the error does not wrap the SDK error")
+			}
+		})
+	}
+}
+
+func TestCheckCredentialsAssumeRoleDenied(t *testing.T) {
+	ctx := context.Background()
+	sharedConfig(t, "[profile base]\naws_access_key_id = AKIDBASE\naws_secret_access_key = secret\n", "")
+	server := fakeroute53.NewServer()
+	t.Cleanup(server.Close)
+	server.Fail = func(operation string) string {
+		if operation == fakeroute53.OpAssumeRole {
+			return "AccessDenied"
+		}
+		return ""
+	}
+	r, err := NewRouteCopy(ctx, "base", WithSide("source"), WithEndpoint(server.URL, false), WithMaxRetries(0),
+		WithAssumeRole("arn:aws:iam::210987654321:role/dns-admin", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.CheckCredentials(ctx)
+	want := "source profile 'base' cannot assume its role"
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got %v, want it to start with %q", err, want)
+	}
+}
+
+func TestCheckDifferentAccounts(t *testing.T) {
+	tests := []struct {
+		name       string
+		dstAccount string
+		// fail is the account whose GetCallerIdentity fails.
+		fail string
+		want string
+	}{
+		{name: "different accounts", dstAccount: "210987654321"},
+		{name: "same account", dstAccount: "123456789012",
+			want: "source profile 'source' (account 123456789012) and destination profile 'destination' (account 123456789012) refer to the same account"},
+		{name: "source STS failure", dstAccount: "210987654321", fail: "source", want: "profile 'source' not found or credentials invalid"},
+		{name: "destination STS failure", dstAccount: "210987654321", fail: "destination", want: "profile 'destination' not found or credentials invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			dstServer.Account = tt.dstAccount
+			failing := map[string]*fakeroute53.Server{"source": srcServer, "destination": dstServer}[tt.fail]
+			if failing != nil {
+				failing.Fail = func(operation string) string {
+					if operation == fakeroute53.OpGetCallerIdentity {
+						return "AccessDenied"
+					}
+					return ""
+				}
+			}
+
+			err := CheckDifferentAccounts(context.Background(), src, dst)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("got %v, want %q", err, tt.want)
+			}
+			var same *SameAccountError
+			if errors.As(err, &same) != (tt.fail == "") {
+				t.Errorf("got %T, want a SameAccountError only when both accounts are known", err)
+			}
+		})
+	}
+}
+
+// delegationZone returns the record sets of a zone with its apex NS and SOA
+// records, two delegated subdomains and a host. Some names lack the trailing
+// dot or differ in case.
+func delegationZone() []rtypes.ResourceRecordSet {
+	return []rtypes.ResourceRecordSet{
+		recordSet("example.com.", rtypes.RRTypeNs, "ns-1.awsdns-01.org.", "ns-2.awsdns-02.com."),
+		recordSet("Example.com", rtypes.RRTypeSoa, "ns-1.awsdns-01.org. hostmaster.example.com. 1 7200 900 1209600 86400"),
+		recordSet("dev.example.com.", rtypes.RRTypeNs, "ns1.dev-dns.net.", "ns2.dev-dns.net."),
+		recordSet("dev.example.com.", rtypes.RRTypeDs, "12345 13 2 ABCDEF0123456789"),
+		recordSet("Staging.example.com", rtypes.RRTypeNs, "ns1.staging-dns.net."),
+		recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+	}
+}
+
+func TestDelegationRecords(t *testing.T) {
+	tests := []struct {
+		name            string
+		domain          string
+		skipDelegations bool
+		// want are the record sets copied, and deleted, in order.
+		want []string
+	}{
+		{
+			name:   "delegations included",
+			domain: "example.com",
+			want:   []string{"dev.example.com. NS", "dev.example.com. DS", "Staging.example.com NS", "www.example.com. A"},
+		},
+		{
+			name:   "domain with a trailing dot and upper case",
+			domain: "EXAMPLE.com.",
+			want:   []string{"dev.example.com. NS", "dev.example.com. DS", "Staging.example.com NS", "www.example.com. A"},
+		},
+		{
+			name:            "delegations skipped",
+			domain:          "example.com",
+			skipDelegations: true,
+			want:            []string{"www.example.com. A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			server := fakeroute53.NewServer()
+			t.Cleanup(server.Close)
+			r := NewRouteCopyForTest("prod", server.URL)
+
+			changes, _ := r.CreateChangesWithOptions(ctx, tt.domain, delegationZone(), ChangeOptions{SkipDelegations: tt.skipDelegations})
+			copied := []string{}
+			for _, c := range changes {
+				copied = append(copied, aws.ToString(c.ResourceRecordSet.Name)+" "+string(c.ResourceRecordSet.Type))
+			}
+			if !reflect.DeepEqual(copied, tt.want) {
+				t.Errorf("copied %v, want %v", copied, tt.want)
+			}
+
+			// Deleting the same record sets leaves the zone with its apex
+			// records, and the delegations when they are skipped.
+			zoneID := server.AddZone("example.com", false)
+			server.AddRecords(zoneID, delegationZone()[2:]...)
+			records, err := r.GetResourceRecords(ctx, zoneID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := r.DeleteRecordsWithOptions(ctx, zoneID, tt.domain, records, time.Minute, DeleteOptions{SkipDelegations: tt.skipDelegations})
+			if err != nil {
+				t.Fatal(err)
+			}
+			deleted := 0
+			for _, b := range results {
+				deleted += b.Changes
+			}
+			if deleted != len(tt.want) {
+				t.Errorf("deleted %d record sets, want %d", deleted, len(tt.want))
+			}
+			left := []string{}
+			for _, rs := range server.Records(zoneID) {
+				left = append(left, aws.ToString(rs.Name)+" "+string(rs.Type))
+			}
+			kept := []string{"example.com. NS", "example.com. SOA"}
+			if tt.skipDelegations {
+				kept = append(kept, "dev.example.com. DS", "dev.example.com. NS", "staging.example.com. NS")
+			}
+			sort.Strings(left)
+			sort.Strings(kept)
+			if !reflect.DeepEqual(left, kept) {
+				t.Errorf("the zone is left with %v, want %v", left, kept)
+			}
+		})
+	}
+}
+
+func TestCreateChangesApex(t *testing.T) {
+	records := func(zone string) []rtypes.ResourceRecordSet {
+		return []rtypes.ResourceRecordSet{
+			recordSet(zone, rtypes.RRTypeNs, "ns-1.awsdns-01.org."),
+			recordSet(zone, rtypes.RRTypeSoa, "ns-1.awsdns-01.org. hostmaster."+zone+" 1 7200 900 1209600 86400"),
+			recordSet(`\052.`+zone, rtypes.RRTypeA, "192.0.2.1"),
+			recordSet("dev."+zone, rtypes.RRTypeNs, "ns1.other.net."),
+		}
+	}
+	tests := []struct {
+		name   string
+		domain string
+		zone   string
+	}{
+		{name: "upper case", domain: "EXAMPLE.Com", zone: "example.com."},
+		{name: "unicode", domain: "münchen-shop.de", zone: "xn--mnchen-shop-thb.de."},
+		{name: "upper case unicode", domain: "MÜNCHEN-Shop.DE.", zone: "xn--mnchen-shop-thb.de."},
+		{name: "punycode", domain: "xn--mnchen-shop-thb.de", zone: "xn--mnchen-shop-thb.de."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RouteCopy{}
+			changes, excluded := r.CreateChangesWithOptions(context.Background(), tt.domain, records(tt.zone), ChangeOptions{})
+			copied := []string{}
+			for _, c := range changes {
+				copied = append(copied, aws.ToString(c.ResourceRecordSet.Name)+" "+string(c.ResourceRecordSet.Type))
+			}
+			want := []string{`\052.` + tt.zone + " A", "dev." + tt.zone + " NS"}
+			if !reflect.DeepEqual(copied, want) {
+				t.Errorf("copied %v, want %v", copied, want)
+			}
+			for _, e := range excluded {
+				if e.Cause != ExcludedApex {
+					t.Errorf("%s %s excluded for %s, want %s", aws.ToString(e.Record.Name), e.Record.Type, e.Cause, ExcludedApex)
+				}
+			}
+			if len(excluded) != 2 {
+				t.Errorf("excluded %d records, want the apex NS and SOA", len(excluded))
+			}
+		})
+	}
+}
+
+func TestMatchNSRecords(t *testing.T) {
+	records := recordSet("example.com.", rtypes.RRTypeNs, "ns-1.awsdns-01.org.", "ns1.xn--mnchen-shop-thb.de.")
+	tests := []struct {
+		name        string
+		nameservers []string
+		want        bool
+	}{
+		{name: "same names", nameservers: []string{"ns-1.awsdns-01.org", "ns1.xn--mnchen-shop-thb.de"}, want: true},
+		{name: "other order", nameservers: []string{"ns1.xn--mnchen-shop-thb.de", "ns-1.awsdns-01.org"}, want: true},
+		{name: "upper case and trailing dots", nameservers: []string{"NS-1.AWSDNS-01.ORG.", "NS1.XN--MNCHEN-SHOP-THB.DE."}, want: true},
+		{name: "unicode", nameservers: []string{"ns-1.awsdns-01.org", "ns1.münchen-shop.de"}, want: true},
+		{name: "one missing", nameservers: []string{"ns-1.awsdns-01.org"}},
+		{name: "another one", nameservers: []string{"ns-1.awsdns-01.org", "ns1.munchen-shop.de"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := []rdtypes.Nameserver{}
+			for _, name := range tt.nameservers {
+				ns = append(ns, rdtypes.Nameserver{Name: aws.String(name)})
+			}
+			if got := MatchNSRecords(ns, records); got != tt.want {
+				t.Errorf("MatchNSRecords(%v) = %t, want %t", tt.nameservers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateZoneDelegationSet(t *testing.T) {
+	tests := []struct {
+		name string
+		// delegationSet is set to create the zone with a reusable
+		// delegation set, unknown for one that does not exist.
+		delegationSet string
+		wantErr       bool
+	}{
+		{name: "without a delegation set"},
+		{name: "with a delegation set", delegationSet: "reusable"},
+		{name: "with an unknown delegation set", delegationSet: "unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			server := fakeroute53.NewServer()
+			t.Cleanup(server.Close)
+			r := NewRouteCopyForTest("prod", server.URL)
+			id := ""
+			switch tt.delegationSet {
+			case "reusable":
+				id = "/delegationset/" + server.AddDelegationSet()
+			case "unknown":
+				id = "N0000DOESNOTEXIST"
+			}
+
+			zone, err := r.CreateZone(ctx, "example.com", WithComment("Zone of the team"), WithDelegationSet(id))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				if zones := server.FindZone("example.com"); len(zones) != 0 {
+					t.Errorf("created zones %v", zones)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := aws.ToString(zone.Config.Comment); got != "Zone of the team" {
+				t.Errorf("got comment %q, want Zone of the team", got)
+			}
+			actual, err := r.ZoneDelegationSetID(ctx, aws.ToString(zone.Id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != shortDelegationSetID(id) {
+				t.Errorf("the zone uses delegation set %q, want %q", actual, shortDelegationSetID(id))
+			}
+			ns, err := r.GetNSRecords(ctx, aws.ToString(zone.Id), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			whiteLabel := strings.Contains(aws.ToString(ns.ResourceRecords[0].Value), "white-label")
+			if whiteLabel != (id != "") {
+				t.Errorf("the zone is served by %s, want the delegation set nameservers only when one is given", aws.ToString(ns.ResourceRecords[0].Value))
+			}
+		})
+	}
+}
+
+func TestUpdateZoneComment(t *testing.T) {
+	ctx := context.Background()
+	server := fakeroute53.NewServer()
+	t.Cleanup(server.Close)
+	r := NewRouteCopyForTest("prod", server.URL)
+	zoneID := server.AddZone("example.com", false)
+
+	err := r.UpdateZoneComment(ctx, zoneID, "Moved from prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone, err := r.GetHostedZone(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.ToString(zone.Config.Comment); got != "Moved from prod" {
+		t.Errorf("got comment %q, want Moved from prod", got)
+	}
+}
+
+// registrarStub is a Route53 Domains client for one registered domain,
+// recording the domain names it is called with.
+type registrarStub struct {
+	Route53DomainsAPI
+	nameservers []string
+	called      []string
+}
+
+func (c *registrarStub) GetDomainDetail(ctx context.Context, params *route53domains.GetDomainDetailInput, optFns ...func(*route53domains.Options)) (*route53domains.GetDomainDetailOutput, error) {
+	c.called = append(c.called, "GetDomainDetail "+aws.ToString(params.DomainName))
+	out := &route53domains.GetDomainDetailOutput{DomainName: params.DomainName}
+	for _, ns := range c.nameservers {
+		out.Nameservers = append(out.Nameservers, rdtypes.Nameserver{Name: aws.String(ns)})
+	}
+	return out, nil
+}
+
+func (c *registrarStub) UpdateDomainNameservers(ctx context.Context, params *route53domains.UpdateDomainNameserversInput, optFns ...func(*route53domains.Options)) (*route53domains.UpdateDomainNameserversOutput, error) {
+	c.called = append(c.called, "UpdateDomainNameservers "+aws.ToString(params.DomainName))
+	c.nameservers = nil
+	for _, ns := range params.Nameservers {
+		c.nameservers = append(c.nameservers, aws.ToString(ns.Name))
+	}
+	return &route53domains.UpdateDomainNameserversOutput{OperationId: aws.String("op-1")}, nil
+}
+
+func TestUpdateNSRecordsIDN(t *testing.T) {
+	for _, domain := range []string{"münchen-shop.de", "xn--mnchen-shop-thb.de", "XN--MNCHEN-SHOP-THB.DE."} {
+		t.Run(domain, func(t *testing.T) {
+			_, _, server, fake := fakeAccounts(t)
+			zoneID := server.AddZone("xn--mnchen-shop-thb.de", false)
+			registrar := &registrarStub{nameservers: []string{"ns1.old-registrar.net"}}
+			r := NewRouteCopyWithClients("destination", DefaultRegion, fake.cli, registrar, fake.stscli)
+
+			updated, err := r.UpdateNSRecords(context.Background(), domain, zoneID, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ns, ok := findRecord(server.Records(zoneID), "xn--mnchen-shop-thb.de.")
+			if !ok {
+				t.Fatal("no apex NS record")
+			}
+			if !updated || len(registrar.nameservers) == 0 || registrar.nameservers[0]+"." != aws.ToString(ns.ResourceRecords[0].Value) {
+				t.Errorf("updated %t to %v, want the nameservers of the zone", updated, registrar.nameservers)
+			}
+			want := []string{"GetDomainDetail xn--mnchen-shop-thb.de", "UpdateDomainNameservers xn--mnchen-shop-thb.de"}
+			if !reflect.DeepEqual(registrar.called, want) {
+				t.Errorf("called %v, want %v", registrar.called, want)
+			}
+		})
+	}
+}
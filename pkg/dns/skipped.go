@@ -0,0 +1,85 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Causes of an ExcludedRecord, in the order SkippedBreakdown lists them.
+const (
+	ExcludedApex          = "apex NS/SOA"
+	ExcludedLock          = "copy lock"
+	ExcludedSubtree       = "not under the given names"
+	ExcludedName          = "excluded by name patterns"
+	ExcludedType          = "not of the given types"
+	ExcludedDelegation    = "subdomain delegations"
+	ExcludedValidation    = "certificate validation records"
+	ExcludedAlias         = "aliases to other source zones"
+	ExcludedTrafficPolicy = "managed by traffic policies"
+	ExcludedSelection     = "not selected"
+)
+
+var exclusionCauses = []string{
+	ExcludedApex,
+	ExcludedLock,
+	ExcludedSubtree,
+	ExcludedName,
+	ExcludedType,
+	ExcludedDelegation,
+	ExcludedValidation,
+	ExcludedAlias,
+	ExcludedTrafficPolicy,
+	ExcludedSelection,
+}
+
+// smallCopyRatio is the share of the source records below which a copy is
+// reported as suspiciously small.
+const smallCopyRatio = 0.5
+
+// EmptyCopy is returned when the filters of a copy leave no records out of
+// a source zone holding some, see CopyOptions.AllowEmpty.
+type EmptyCopy struct {
+	Domain string
+	// Skipped is the SkippedBreakdown of the records left out.
+	Skipped string
+}
+
+func (e *EmptyCopy) Error() string {
+	return fmt.Sprintf("no records of '%s' left to copy, %s", e.Domain, e.Skipped)
+}
+
+// SkippedBreakdown counts the excluded records by cause, such as
+// "skipped 14: 2 apex NS/SOA, 12 not of the given types".
+func SkippedBreakdown(excluded []ExcludedRecord) string {
+	counts := map[string]int{}
+	for _, e := range excluded {
+		counts[e.Cause]++
+	}
+	parts := []string{}
+	for _, cause := range exclusionCauses {
+		if counts[cause] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[cause], cause))
+		}
+	}
+	if len(parts) == 0 {
+		return "skipped none"
+	}
+	return fmt.Sprintf("skipped %d: %s", len(excluded), strings.Join(parts, ", "))
+}
+
+// checkCopySize returns an EmptyCopy when there are no changes although the
+// source zone holds records besides its apex NS and SOA, and reports whether
+// the changes are fewer than smallCopyRatio of those records.
+func checkCopySize(domain string, zone rtypes.HostedZone, changes []rtypes.Change, excluded []ExcludedRecord) (bool, error) {
+	records := aws.ToInt64(zone.ResourceRecordSetCount) - 2
+	if records <= 0 {
+		return false, nil
+	}
+	if len(changes) == 0 {
+		return false, &EmptyCopy{Domain: domain, Skipped: SkippedBreakdown(excluded)}
+	}
+	return float64(len(changes)) < smallCopyRatio*float64(records), nil
+}
@@ -0,0 +1,143 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// SOA is the rdata of a SOA record, as Route53 formats it:
+// "mname rname serial refresh retry expire minimum".
+type SOA struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	// Minimum is the TTL of negative answers, RFC 2308.
+	Minimum uint32
+}
+
+// ParseSOA parses the value of a SOA record.
+func ParseSOA(value string) (SOA, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 7 {
+		return SOA{}, fmt.Errorf("invalid SOA '%s': expected 7 fields, found %d", value, len(fields))
+	}
+	numbers := make([]uint32, 5)
+	for i, f := range fields[2:] {
+		n, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return SOA{}, fmt.Errorf("invalid SOA '%s': field %d is not a number: %s", value, i+3, f)
+		}
+		numbers[i] = uint32(n)
+	}
+	return SOA{
+		MName:   fields[0],
+		RName:   fields[1],
+		Serial:  numbers[0],
+		Refresh: numbers[1],
+		Retry:   numbers[2],
+		Expire:  numbers[3],
+		Minimum: numbers[4],
+	}, nil
+}
+
+// String formats the SOA as the value of a record.
+func (s SOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", s.MName, s.RName, s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum)
+}
+
+// WithTimers returns s with the refresh, retry, expire and minimum values of
+// other. The names and serial of s are kept, since they belong to its zone.
+func (s SOA) WithTimers(other SOA) SOA {
+	s.Refresh = other.Refresh
+	s.Retry = other.Retry
+	s.Expire = other.Expire
+	s.Minimum = other.Minimum
+	return s
+}
+
+// SameTimers reports whether s and other have the same refresh, retry,
+// expire and minimum values.
+func (s SOA) SameTimers(other SOA) bool {
+	return s.WithTimers(other) == s
+}
+
+// apexSOA returns the SOA record set of the zone domain in records.
+func apexSOA(domain string, records []rtypes.ResourceRecordSet) (rtypes.ResourceRecordSet, SOA, error) {
+	for _, rs := range records {
+		if rs.Type != rtypes.RRTypeSoa || !sameDomain(aws.ToString(rs.Name), domain) {
+			continue
+		}
+		if len(rs.ResourceRecords) != 1 {
+			return rs, SOA{}, fmt.Errorf("the SOA of '%s' has %d values", domain, len(rs.ResourceRecords))
+		}
+		soa, err := ParseSOA(aws.ToString(rs.ResourceRecords[0].Value))
+		return rs, soa, err
+	}
+	return rtypes.ResourceRecordSet{}, SOA{}, fmt.Errorf("no SOA record found for '%s'", domain)
+}
+
+// SOAValuesChange returns the change setting the refresh, retry, expire and
+// minimum values of the destination SOA to the ones of the source SOA, or
+// nil when they are the same. The destination names and serial are kept,
+// as they refer to the destination nameservers.
+func SOAValuesChange(srcDomain string, srcRecords []rtypes.ResourceRecordSet, dstDomain string, dstRecords []rtypes.ResourceRecordSet) (*rtypes.Change, error) {
+	_, srcSOA, err := apexSOA(srcDomain, srcRecords)
+	if err != nil {
+		return nil, err
+	}
+	dstRecord, dstSOA, err := apexSOA(dstDomain, dstRecords)
+	if err != nil {
+		return nil, err
+	}
+	if dstSOA.SameTimers(srcSOA) {
+		return nil, nil
+	}
+	dstRecord.ResourceRecords = []rtypes.ResourceRecord{{Value: aws.String(dstSOA.WithTimers(srcSOA).String())}}
+	return &rtypes.Change{
+		Action:            rtypes.ChangeActionUpsert,
+		ResourceRecordSet: &dstRecord,
+	}, nil
+}
+
+// copySOAValues adds the change adopting the source SOA values in the
+// destination zone to changes, see SOAValuesChange.
+func copySOAValues(ctx context.Context, dst *RouteCopy, zone rtypes.HostedZone, opts CopyOptions, srcRecords []rtypes.ResourceRecordSet, changes []rtypes.Change) ([]rtypes.Change, error) {
+	dstRecords, err := dst.GetResourceRecords(ctx, aws.ToString(zone.Id))
+	if err != nil {
+		return changes, err
+	}
+	change, err := SOAValuesChange(opts.Domain, srcRecords, aws.ToString(zone.Name), dstRecords)
+	if err != nil {
+		return changes, err
+	}
+	if change == nil {
+		logging.From(ctx).Infof("The SOA of '%s' already has the source refresh, retry, expire and minimum values\n", opts.DestinationDomain)
+		return changes, nil
+	}
+	_, dstSOA, _ := apexSOA(aws.ToString(zone.Name), dstRecords)
+	logging.From(ctx).Infof("Changing the SOA of '%s' from '%s' to '%s'\n", opts.DestinationDomain,
+		dstSOA, aws.ToString(change.ResourceRecordSet.ResourceRecords[0].Value))
+	return append(changes, *change), nil
+}
+
+// warnSOADifferences warns when the SOA values of the destination zone,
+// which are not copied, differ from the source ones.
+func warnSOADifferences(ctx context.Context, srcDomain string, srcRecords []rtypes.ResourceRecordSet, dstDomain string, dstRecords []rtypes.ResourceRecordSet) {
+	change, err := SOAValuesChange(srcDomain, srcRecords, dstDomain, dstRecords)
+	if err != nil || change == nil {
+		return
+	}
+	_, srcSOA, _ := apexSOA(srcDomain, srcRecords)
+	_, dstSOA, _ := apexSOA(dstDomain, dstRecords)
+	logging.From(ctx).Warnf("The SOA of '%s' caches negative answers for %ds and the source one for %ds, "+
+		"the destination SOA is '%s' and the source '%s'\n", dstDomain, dstSOA.Minimum, srcSOA.Minimum, dstSOA, srcSOA)
+}
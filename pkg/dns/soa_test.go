@@ -0,0 +1,205 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestParseSOA(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    SOA
+		wantErr string
+	}{
+		{
+			name:  "route53 default",
+			value: "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400",
+			want:  SOA{MName: "ns-1.awsdns-01.org.", RName: "awsdns-hostmaster.amazon.com.", Serial: 1, Refresh: 7200, Retry: 900, Expire: 1209600, Minimum: 86400},
+		},
+		{
+			name:  "extra spaces",
+			value: "  ns1.example.com.  hostmaster.example.com.\t4294967295 3600 600 604800 60 ",
+			want:  SOA{MName: "ns1.example.com.", RName: "hostmaster.example.com.", Serial: 4294967295, Refresh: 3600, Retry: 600, Expire: 604800, Minimum: 60},
+		},
+		{name: "missing field", value: "ns1.example.com. hostmaster.example.com. 1 3600 600 604800", wantErr: "expected 7 fields, found 6"},
+		{name: "extra field", value: "ns1.example.com. hostmaster.example.com. 1 3600 600 604800 60 60", wantErr: "expected 7 fields, found 8"},
+		{name: "not a number", value: "ns1.example.com. hostmaster.example.com. 1 1h 600 604800 60", wantErr: "field 4 is not a number: 1h"},
+		{name: "negative", value: "ns1.example.com. hostmaster.example.com. 1 3600 600 604800 -60", wantErr: "field 7 is not a number"},
+		{name: "overflow", value: "ns1.example.com. hostmaster.example.com. 4294967296 3600 600 604800 60", wantErr: "field 3 is not a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSOA(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if want := strings.Join(strings.Fields(tt.value), " "); got.String() != want {
+				t.Errorf("formatted %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSOATimers(t *testing.T) {
+	src := SOA{MName: "ns1.example.com.", RName: "hostmaster.example.com.", Serial: 2024010101, Refresh: 3600, Retry: 600, Expire: 604800, Minimum: 60}
+	dst := SOA{MName: "ns-1.awsdns-01.org.", RName: "awsdns-hostmaster.amazon.com.", Serial: 1, Refresh: 7200, Retry: 900, Expire: 1209600, Minimum: 86400}
+
+	got := dst.WithTimers(src)
+	want := SOA{MName: "ns-1.awsdns-01.org.", RName: "awsdns-hostmaster.amazon.com.", Serial: 1, Refresh: 3600, Retry: 600, Expire: 604800, Minimum: 60}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if dst.SameTimers(src) {
+		t.Error("different timers reported the same")
+	}
+	if !got.SameTimers(src) {
+		t.Error("the same timers with different names reported different")
+	}
+}
+
+func TestSOAValuesChange(t *testing.T) {
+	srcRecords := []rtypes.ResourceRecordSet{
+		recordSet("sub.example.com.", rtypes.RRTypeSoa, "ns1.other.net. hostmaster.other.net. 1 1 1 1 1"),
+		recordSet("example.com.", rtypes.RRTypeSoa, "ns1.example.com. hostmaster.example.com. 2024010101 3600 600 604800 60"),
+	}
+	tests := []struct {
+		name       string
+		dstRecords []rtypes.ResourceRecordSet
+		// want is the value of the changed SOA, empty for no change.
+		want    string
+		wantErr string
+	}{
+		{
+			name:       "route53 defaults",
+			dstRecords: []rtypes.ResourceRecordSet{recordSet("example.net.", rtypes.RRTypeSoa, "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400")},
+			want:       "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 3600 600 604800 60",
+		},
+		{
+			name:       "same timers",
+			dstRecords: []rtypes.ResourceRecordSet{recordSet("example.net.", rtypes.RRTypeSoa, "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 3600 600 604800 60")},
+		},
+		{
+			name:       "no destination SOA",
+			dstRecords: []rtypes.ResourceRecordSet{recordSet("example.net.", rtypes.RRTypeNs, "ns-1.awsdns-01.org.")},
+			wantErr:    "no SOA record found for 'example.net.'",
+		},
+		{
+			name:       "invalid destination SOA",
+			dstRecords: []rtypes.ResourceRecordSet{recordSet("example.net.", rtypes.RRTypeSoa, "ns-1.awsdns-01.org. 1 7200 900 1209600 86400")},
+			wantErr:    "expected 7 fields",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change, err := SOAValuesChange("example.com", srcRecords, "example.net.", tt.dstRecords)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if change != nil {
+					t.Errorf("got a change to %s", aws.ToString(change.ResourceRecordSet.ResourceRecords[0].Value))
+				}
+				return
+			}
+			if change == nil {
+				t.Fatal("no change")
+			}
+			rs := change.ResourceRecordSet
+			if change.Action != rtypes.ChangeActionUpsert || aws.ToString(rs.Name) != "example.net." || aws.ToInt64(rs.TTL) != 300 {
+				t.Errorf("got %s of %s with TTL %d, want an UPSERT of the destination SOA", change.Action, aws.ToString(rs.Name), aws.ToInt64(rs.TTL))
+			}
+			if got := aws.ToString(rs.ResourceRecords[0].Value); got != tt.want {
+				t.Errorf("got SOA %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyZoneSOAValues(t *testing.T) {
+	tests := []struct {
+		name          string
+		copySOAValues bool
+		dryRun        bool
+		// wantMinimum is the minimum of the destination SOA after the copy.
+		wantMinimum uint32
+		wantWarning bool
+	}{
+		{name: "copied", copySOAValues: true, wantMinimum: 60},
+		{name: "copied in a dry run", copySOAValues: true, dryRun: true, wantMinimum: 86400},
+		{name: "not copied", wantMinimum: 86400, wantWarning: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := log.Writer()
+			log.SetOutput(&buf)
+			t.Cleanup(func() { log.SetOutput(w) })
+			ctx := context.Background()
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			srcServer.AddRecords(srcZoneID,
+				recordSet("example.com.", rtypes.RRTypeSoa, "ns1.example.com. hostmaster.example.com. 2024010101 3600 600 604800 60"),
+				recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1"),
+			)
+			dstZoneID := dstServer.AddZone("example.com", false)
+			_, before, err := apexSOA("example.com", dstServer.Records(dstZoneID))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := CopyZone(ctx, src, dst, CopyOptions{
+				Domain:          "example.com",
+				CopySOAValues:   tt.copySOAValues,
+				DryRun:          tt.dryRun,
+				CollectExisting: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, after, err := apexSOA("example.com", dstServer.Records(dstZoneID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Minimum != tt.wantMinimum {
+				t.Errorf("the destination SOA is %s, want minimum %d", after, tt.wantMinimum)
+			}
+			if after.MName != before.MName || after.RName != before.RName || after.Serial != before.Serial {
+				t.Errorf("the destination SOA is %s, want the names and serial of %s", after, before)
+			}
+			soaChanges := 0
+			for _, c := range result.Changes {
+				if c.ResourceRecordSet.Type == rtypes.RRTypeSoa {
+					soaChanges++
+				}
+			}
+			if want := map[bool]int{true: 1}[tt.copySOAValues]; soaChanges != want {
+				t.Errorf("got %d SOA changes, want %d", soaChanges, want)
+			}
+			if warned := strings.Contains(buf.String(), "caches negative answers for 86400s and the source one for 60s"); warned != tt.wantWarning {
+				t.Errorf("warned: %t, want %t:\n%s", warned, tt.wantWarning, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// Status of a batch in a CopyState.
+const (
+	// BatchPending is a batch that was not submitted.
+	BatchPending = "pending"
+	// BatchSubmitted is a batch Route53 accepted that was not seen in sync.
+	BatchSubmitted = "submitted"
+	// BatchInSync is a batch that was applied.
+	BatchInSync = "insync"
+	// BatchRejected is a batch with changes Route53 rejected. Its changes
+	// are the rejected ones, the others were applied.
+	BatchRejected = "rejected"
+)
+
+// CopyState records the change batches of a copy and which of them were
+// applied, so a copy that failed midway can be resumed with the batches
+// that were not submitted, see CopyOptions.StateFile.
+type CopyState struct {
+	Domain            string `json:"domain"`
+	SourceZoneID      string `json:"source_zone_id"`
+	DestinationZoneID string `json:"destination_zone_id"`
+	// SourceHash is the hash of the changes computed from the source zone,
+	// see ChangesHash.
+	SourceHash string       `json:"source_hash"`
+	Updated    time.Time    `json:"updated"`
+	Batches    []StateBatch `json:"batches"`
+}
+
+// StateBatch is a change batch of a CopyState.
+type StateBatch struct {
+	Status   string          `json:"status"`
+	ChangeID string          `json:"change_id,omitempty"`
+	Changes  []rtypes.Change `json:"changes"`
+}
+
+// StaleState is returned when resuming a copy whose source zone changed
+// since its state file was written, or that wrote to another zone.
+type StaleState struct {
+	File   string
+	Reason string
+}
+
+func (e *StaleState) Error() string {
+	return fmt.Sprintf("cannot resume from %s: %s", e.File, e.Reason)
+}
+
+// ExistingState is returned when a copy finds the state file of an earlier
+// copy that did not complete.
+type ExistingState struct {
+	File string
+}
+
+func (e *ExistingState) Error() string {
+	return fmt.Sprintf("%s holds the state of an earlier copy that did not complete", e.File)
+}
+
+// DefaultStateFile is the state file of a copy of domain when none is given.
+func DefaultStateFile(domain string) string {
+	return ".route53copy-state-" + strings.TrimSuffix(strings.ToLower(domain), ".") + ".json"
+}
+
+// ChangesHash returns the SHA-256 of changes, to detect that the source
+// zone changed between a copy and its resume.
+func ChangesHash(changes []rtypes.Change) string {
+	data, _ := json.Marshal(changes)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Remaining returns the index of the first batch that was not applied, and
+// the number of batches. Batches after it may have been applied already.
+func (s CopyState) Remaining() (int, int) {
+	for i, b := range s.Batches {
+		if b.Status != BatchInSync {
+			return i, len(s.Batches)
+		}
+	}
+	return len(s.Batches), len(s.Batches)
+}
+
+// WriteStateFile writes the state to the file name, replacing it
+// atomically, so the file always holds a complete state.
+func WriteStateFile(name string, s CopyState) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(s)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// ReadStateFile reads a state written by WriteStateFile.
+func ReadStateFile(name string) (CopyState, error) {
+	s := CopyState{}
+	f, err := os.Open(name)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&s)
+	if err != nil {
+		return s, fmt.Errorf("invalid state file %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// newCopyState returns the state of a copy about to apply changes, or the
+// state read from opts.StateFile when resuming, after waiting for the
+// batches it submitted to be in sync.
+func newCopyState(ctx context.Context, dst *RouteCopy, opts CopyOptions, srcZoneID, dstZoneID, sourceHash string, changes []rtypes.Change) (CopyState, error) {
+	if !opts.Resume {
+		_, err := os.Stat(opts.StateFile)
+		if err == nil {
+			return CopyState{}, &ExistingState{File: opts.StateFile}
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return CopyState{}, err
+		}
+		s := CopyState{
+			Domain:            opts.DestinationDomain,
+			SourceZoneID:      shortZoneID(srcZoneID),
+			DestinationZoneID: shortZoneID(dstZoneID),
+			SourceHash:        sourceHash,
+			Batches:           []StateBatch{},
+		}
+		for _, batch := range SplitChanges(ctx, changes) {
+			s.Batches = append(s.Batches, StateBatch{Status: BatchPending, Changes: batch})
+		}
+		return s, nil
+	}
+
+	s, err := ReadStateFile(opts.StateFile)
+	if err != nil {
+		return s, err
+	}
+	switch {
+	case s.DestinationZoneID != shortZoneID(dstZoneID):
+		return s, &StaleState{File: opts.StateFile, Reason: fmt.Sprintf("it was written for zone %s, not %s", s.DestinationZoneID, shortZoneID(dstZoneID))}
+	case s.SourceHash != sourceHash:
+		return s, &StaleState{File: opts.StateFile, Reason: "the source records changed since it was written"}
+	}
+	for i, b := range s.Batches {
+		if b.Status != BatchSubmitted {
+			continue
+		}
+		logging.From(ctx).Infof("Waiting for batch %d/%d, submitted as change %s\n", i+1, len(s.Batches), b.ChangeID)
+		err := dst.WaitForChange(ctx, b.ChangeID, opts.MaxWait)
+		if err != nil {
+			return s, err
+		}
+		s.Batches[i].Status = BatchInSync
+	}
+	return s, nil
+}
+
+// applyWithState applies the batches of state that were not applied,
+// writing it to opts.StateFile after each batch, and removes the file once
+// every batch is in sync.
+func applyWithState(ctx context.Context, dst *RouteCopy, zoneID, comment string, state CopyState, opts CopyOptions) ([]BatchResult, error) {
+	first, total := state.Remaining()
+	if first > 0 {
+		logging.From(ctx).Infof("Resuming at batch %d/%d, %d batches were already applied\n", first+1, total, first)
+	}
+	write := func() error {
+		state.Updated = time.Now().UTC()
+		return WriteStateFile(opts.StateFile, state)
+	}
+	err := write()
+	if err != nil {
+		return nil, fmt.Errorf("writing the state file: %w", err)
+	}
+
+	// pending holds the index in state of each batch submitted.
+	pending := []int{}
+	batches := [][]rtypes.Change{}
+	for i, b := range state.Batches {
+		if b.Status != BatchInSync {
+			pending = append(pending, i)
+			batches = append(batches, b.Changes)
+		}
+	}
+	results, err := dst.ApplyBatches(ctx, zoneID, comment, batches, opts.MaxWait, func(i int, changeID string, status rtypes.ChangeStatus) {
+		b := &state.Batches[pending[i]]
+		b.ChangeID = changeID
+		switch status {
+		case rtypes.ChangeStatusInsync:
+			b.Status = BatchInSync
+		case ChangeStatusRejected:
+			b.Status = BatchRejected
+		default:
+			b.Status = BatchSubmitted
+		}
+		if werr := write(); werr != nil {
+			logging.From(ctx).Warnf("Could not write the state file %s: %s\n", opts.StateFile, werr)
+		}
+	})
+	var rc *RejectedChanges
+	if errors.As(err, &rc) {
+		// Only the rejected changes of a batch are left to resume with.
+		left := map[int][]rtypes.Change{}
+		for _, r := range rc.Rejected {
+			left[pending[r.Batch]] = append(left[pending[r.Batch]], r.Change)
+		}
+		for i, changes := range left {
+			state.Batches[i].Changes = changes
+		}
+		if werr := write(); werr != nil {
+			logging.From(ctx).Warnf("Could not write the state file %s: %s\n", opts.StateFile, werr)
+		}
+	}
+	if err != nil {
+		return results, err
+	}
+	err = os.Remove(opts.StateFile)
+	if err != nil {
+		logging.From(ctx).Warnf("Could not remove the state file %s: %s\n", opts.StateFile, err)
+	}
+	return results, nil
+}
+
+// resumedChanges returns the changes of the batches of state that were not
+// applied.
+func resumedChanges(state CopyState) []rtypes.Change {
+	changes := []rtypes.Change{}
+	for _, b := range state.Batches {
+		if b.Status != BatchInSync {
+			changes = append(changes, b.Changes...)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,211 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/fakeroute53"
+)
+
+func TestResumeRejectedBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		isolate bool
+		// retried is the number of changes the resumed copy submits.
+		retried int
+	}{
+		// The failed batch is left out whole, and retried whole.
+		{name: "continue on error", retried: 500},
+		// Only the rejected record of the batch is retried.
+		{name: "isolate rejected", isolate: true, retried: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srcServer, src, dstServer, dst := fakeAccounts(t)
+			srcZoneID := srcServer.AddZone("example.com", false)
+			// An upsert counts twice towards the 1000 records of a batch, so
+			// the 1200 record sets take 3 batches.
+			srcServer.AddRecords(srcZoneID, hostRecords("example.com", 1200)...)
+			dstZoneID := dstServer.AddZone("example.com", false)
+			rejected := "host700.example.com."
+			dstServer.Reject = func(rs rtypes.ResourceRecordSet) string {
+				if aws.ToString(rs.Name) == rejected {
+					return "RRSet " + rejected + " is rejected by the test"
+				}
+				return ""
+			}
+			dst.SetContinueOnError(!tt.isolate)
+			dst.SetIsolateRejected(tt.isolate)
+			opts := CopyOptions{Domain: "example.com", StateFile: filepath.Join(t.TempDir(), "state.json")}
+
+			_, err := CopyZone(ctx, src, dst, opts)
+			var rc *RejectedChanges
+			if !errors.As(err, &rc) {
+				t.Fatalf("got %v, want RejectedChanges", err)
+			}
+			state, err := ReadStateFile(opts.StateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := []string{}
+			for _, b := range state.Batches {
+				statuses = append(statuses, b.Status)
+			}
+			if len(statuses) != 3 || statuses[0] != BatchInSync || statuses[1] != BatchRejected || statuses[2] != BatchInSync {
+				t.Fatalf("got batches %v, want the second one rejected and the others in sync", statuses)
+			}
+			if len(state.Batches[1].Changes) != tt.retried {
+				t.Errorf("the state file keeps %d changes of the rejected batch, want %d", len(state.Batches[1].Changes), tt.retried)
+			}
+			if _, ok := findRecord(dstServer.Records(dstZoneID), rejected); ok {
+				t.Fatalf("%s was copied", rejected)
+			}
+
+			dstServer.Reject = nil
+			calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets)
+			opts.Resume = true
+			result, err := CopyZone(ctx, src, dst, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Changes) != tt.retried {
+				t.Errorf("the resumed copy applied %d changes, want %d", len(result.Changes), tt.retried)
+			}
+			if got := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets) - calls; got != 1 {
+				t.Errorf("the resumed copy submitted %d batches, want 1", got)
+			}
+			if _, ok := findRecord(dstServer.Records(dstZoneID), rejected); !ok {
+				t.Errorf("%s was not copied by the resumed copy", rejected)
+			}
+			if _, err := os.Stat(opts.StateFile); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("the state file was not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestResumeAfterFailedBatch(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 2400)...)
+	dstZoneID := dstServer.AddZone("example.com", false)
+	// The third batch fails, as it would with expired credentials.
+	const failed = 3
+	submitted := 0
+	dstServer.Fail = func(operation string) string {
+		if operation != fakeroute53.OpChangeResourceRecordSets {
+			return ""
+		}
+		submitted++
+		if submitted == failed {
+			return "ExpiredToken"
+		}
+		return ""
+	}
+	opts := CopyOptions{Domain: "example.com", StateFile: filepath.Join(t.TempDir(), "state.json")}
+
+	_, err := CopyZone(ctx, src, dst, opts)
+	if err == nil {
+		t.Fatal("the copy did not fail")
+	}
+	state, err := ReadStateFile(opts.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, total := state.Remaining()
+	if first != failed-1 || total < failed+1 {
+		t.Fatalf("the state file resumes at batch %d of %d, want %d", first+1, total, failed)
+	}
+	for i, b := range state.Batches[first:] {
+		if b.Status != BatchPending {
+			t.Errorf("batch %d is %s, want %s", first+i+1, b.Status, BatchPending)
+		}
+	}
+
+	t.Run("without resume", func(t *testing.T) {
+		_, err := CopyZone(ctx, src, dst, opts)
+		var es *ExistingState
+		if !errors.As(err, &es) {
+			t.Errorf("got %v, want ExistingState", err)
+		}
+	})
+
+	dstServer.Fail = nil
+	calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets)
+	opts.Resume = true
+	result, err := CopyZone(ctx, src, dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets) - calls; got != total-first {
+		t.Errorf("the resumed copy submitted %d batches, want the %d remaining ones", got, total-first)
+	}
+	remaining := 0
+	for _, b := range state.Batches[first:] {
+		remaining += len(b.Changes)
+	}
+	if len(result.Changes) != remaining {
+		t.Errorf("the resumed copy applied %d changes, want %d", len(result.Changes), remaining)
+	}
+	copied := 0
+	for _, rs := range dstServer.Records(dstZoneID) {
+		if strings.HasPrefix(aws.ToString(rs.Name), "host") {
+			copied++
+		}
+	}
+	if copied != 2400 {
+		t.Errorf("the destination holds %d of the 2400 records", copied)
+	}
+	if _, err := os.Stat(opts.StateFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the state file was not removed: %v", err)
+	}
+}
+
+func TestResumeStaleState(t *testing.T) {
+	ctx := context.Background()
+	srcServer, src, dstServer, dst := fakeAccounts(t)
+	srcZoneID := srcServer.AddZone("example.com", false)
+	srcServer.AddRecords(srcZoneID, hostRecords("example.com", 1200)...)
+	dstServer.AddZone("example.com", false)
+	dstServer.Fail = func(operation string) string {
+		if operation == fakeroute53.OpChangeResourceRecordSets {
+			return "ExpiredToken"
+		}
+		return ""
+	}
+	opts := CopyOptions{Domain: "example.com", StateFile: filepath.Join(t.TempDir(), "state.json")}
+	if _, err := CopyZone(ctx, src, dst, opts); err == nil {
+		t.Fatal("the copy did not fail")
+	}
+	dstServer.Fail = nil
+
+	srcServer.AddRecords(srcZoneID, recordSet("new.example.com.", rtypes.RRTypeA, "192.0.2.99"))
+	calls := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets)
+	opts.Resume = true
+	_, err := CopyZone(ctx, src, dst, opts)
+	var ss *StaleState
+	if !errors.As(err, &ss) {
+		t.Fatalf("got %v, want StaleState", err)
+	}
+	if got := dstServer.Calls(fakeroute53.OpChangeResourceRecordSets) - calls; got != 0 {
+		t.Errorf("submitted %d batches from a stale state", got)
+	}
+}
+
+// findRecord returns the record set named name in records.
+func findRecord(records []rtypes.ResourceRecordSet, name string) (rtypes.ResourceRecordSet, bool) {
+	for _, rs := range records {
+		if aws.ToString(rs.Name) == name {
+			return rs, true
+		}
+	}
+	return rtypes.ResourceRecordSet{}, false
+}
@@ -0,0 +1,264 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// streamConflicts returns the options set in opts that a streamed copy
+// cannot honor, since they need every change before the first one is
+// applied.
+func streamConflicts(opts CopyOptions) []string {
+	conflicts := []string{}
+	for _, o := range []struct {
+		name string
+		set  bool
+	}{
+		{"DryRun", opts.DryRun},
+		{"StateFile", opts.StateFile != ""},
+		{"Resume", opts.Resume},
+		{"SelectChanges", opts.SelectChanges != nil},
+		{"Confirm", opts.Confirm != nil},
+		{"Dealias", opts.Dealias},
+		{"SkipUnresolvableAliases", opts.SkipUnresolvableAliases},
+		{"CopyHealthChecks", opts.CopyHealthChecks},
+		{"CopyCidrCollections", opts.CopyCidrCollections},
+		{"CopySOAValues", copySOA(opts)},
+		{"FailOnTrafficPolicy", opts.FailOnTrafficPolicy},
+	} {
+		if o.set {
+			conflicts = append(conflicts, o.name)
+		}
+	}
+	return conflicts
+}
+
+// streamZone is CopyZone with opts.Stream: the source records are listed a
+// page at a time, and the changes of each page are applied before the next
+// one is listed.
+func streamZone(ctx context.Context, src, dst *RouteCopy, opts CopyOptions) (CopyResult, error) {
+	opts = copyDefaults(opts)
+	result := CopyResult{}
+	if conflicts := streamConflicts(opts); len(conflicts) > 0 {
+		return result, fmt.Errorf("a streamed copy cannot be used with %s", strings.Join(conflicts, ", "))
+	}
+	err := checkSubtree(opts)
+	if err != nil {
+		return result, err
+	}
+
+	srcZone, err := sourceZone(ctx, src, opts)
+	if err != nil {
+		return result, &ZoneLookupError{Source: true, Err: err}
+	}
+	result.SourceZone = srcZone
+	err = CheckRecordCount(srcZone, opts.MaxRecords)
+	if err != nil {
+		return result, err
+	}
+	srcZoneID := aws.ToString(srcZone.Id)
+	if !opts.Private {
+		result.SourceDNSSEC = sourceDNSSEC(ctx, src, srcZoneID, opts)
+	}
+
+	zone, created, err := destinationZone(ctx, src, dst, opts, srcZone, true)
+	if err != nil {
+		return result, &ZoneLookupError{Err: err}
+	}
+	result.DestinationZone = zone
+	result.CreatedZone = created
+
+	unlock, err := lockZone(ctx, dst, zone, opts)
+	if err != nil {
+		if result.CreatedZone {
+			result.ZoneDeleted = cleanupCreatedZone(ctx, dst, zone, opts)
+		}
+		return result, err
+	}
+	defer unlock()
+
+	err = streamRecords(ctx, src, dst, srcZone, opts, &result)
+	return result, completeCopy(ctx, src, dst, srcZoneID, opts, unlock, &result, err)
+}
+
+// streamRecords applies the changes of each page of source records to the
+// destination zone in result. The changes that do not fill a batch wait for
+// the next page, so the batches are as full as those of a copy listing the
+// whole zone first, and the record sets of a group, see GroupChanges, stay
+// in the same batch.
+func streamRecords(ctx context.Context, src, dst *RouteCopy, srcZone rtypes.HostedZone, opts CopyOptions, result *CopyResult) error {
+	zone := result.DestinationZone
+	dstZoneID := aws.ToString(zone.Id)
+	if opts.SyncComment {
+		err := syncComment(ctx, src, dst, srcZone, zone, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A zone created by the copy holds nothing to compare with.
+	if !result.CreatedZone {
+		existing, err := dst.GetResourceRecords(ctx, dstZoneID)
+		if err != nil {
+			return err
+		}
+		result.Existing = existing
+		if !opts.Private && !opts.AllowLiveOverwrite && IsLiveZone(ctx, zone, existing) {
+			return &LiveZoneStream{Zone: aws.ToString(zone.Name)}
+		}
+	}
+	if opts.Backup != nil {
+		backup, err := dst.BackupZone(ctx, zone)
+		if err != nil {
+			return err
+		}
+		err = opts.Backup(ctx, backup)
+		if err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	apply := func(batches [][]rtypes.Change) error {
+		comment := "Importing ALL records from " + src.profile
+		if opts.Comment != nil {
+			changes := []rtypes.Change{}
+			for _, batch := range batches {
+				changes = append(changes, batch...)
+			}
+			comment = opts.Comment(ctx, changes)
+		}
+		batchResults, err := dst.ApplyBatches(ctx, dstZoneID, comment, batches, opts.MaxWait, nil)
+		result.Batches = append(result.Batches, batchResults...)
+		return err
+	}
+	pending := []rtypes.Change{}
+	err := src.ForEachResourceRecord(ctx, aws.ToString(srcZone.Id), func(page []rtypes.ResourceRecordSet) error {
+		changes, err := pageChanges(ctx, src, dst, aws.ToString(srcZone.Id), page, opts, result)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, changes...)
+		batches := SplitChanges(ctx, pending)
+		if len(batches) < 2 {
+			return nil
+		}
+		pending = batches[len(batches)-1]
+		return apply(batches[:len(batches)-1])
+	})
+	if err == nil && len(pending) > 0 {
+		err = apply(SplitChanges(ctx, pending))
+	}
+	if err != nil {
+		var be *BatchError
+		if errors.As(err, &be) {
+			logAppliedChanges(ctx, be.Applied)
+		}
+		return err
+	}
+	switch {
+	case len(result.Changes) == 0 && len(result.Unchanged) > 0:
+		logging.From(ctx).Summaryf("The records of '%s' are already up to date in '%s'\n", opts.Domain, opts.DestinationDomain)
+		return nil
+	case len(result.Changes) == 0:
+		logging.From(ctx).Summaryf("No records to copy for '%s'\n", opts.Domain)
+		return nil
+	}
+	logging.From(ctx).Summaryf("%d records in '%s' were copied from %s to %s and are in sync after %s\n",
+		len(result.Changes), opts.DestinationDomain, src.profile, dst.profile, time.Since(start))
+
+	if opts.Verify || opts.VerifyDNS {
+		v, err := verifyCopy(ctx, dst, zone, opts, result.Changes)
+		if err != nil {
+			return err
+		}
+		result.Verification = &v
+		if !v.OK() {
+			return &VerificationFailed{Verification: v}
+		}
+		logging.From(ctx).Summaryf("All copied records verified\n")
+	}
+	return nil
+}
+
+// pageChanges returns the changes copying a page of source records, filtered
+// like those of SnapshotSource and compared with the destination records
+// like those of CopyZoneFrom. The record sets it leaves out are added to
+// result.
+func pageChanges(ctx context.Context, src, dst *RouteCopy, srcZoneID string, page []rtypes.ResourceRecordSet, opts CopyOptions, result *CopyResult) ([]rtypes.Change, error) {
+	for _, rs := range page {
+		if IsLockRecord(rs) {
+			result.Excluded = append(result.Excluded, ExcludedRecord{Record: rs, Cause: ExcludedLock, Reason: "locks the zone during a copy"})
+		}
+	}
+	recordSets := withoutLockRecords(page)
+	var excluded []ExcludedRecord
+	if opts.Subtree != "" {
+		recordSets, excluded = FilterRecordSubtrees(recordSets, []string{opts.Subtree})
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+	if len(opts.Names) > 0 {
+		recordSets, excluded = FilterRecordSubtrees(recordSets, opts.Names)
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		recordSets, excluded = FilterRecordNames(recordSets, opts.Include, opts.Exclude)
+		result.Excluded = append(result.Excluded, excluded...)
+	}
+
+	changes, excluded := src.CreateChangesWithOptions(ctx, opts.recordsDomain(), recordSets, ChangeOptions{
+		Types:                 opts.Types,
+		ExcludeTypes:          opts.ExcludeTypes,
+		DestinationDomain:     opts.DestinationDomain,
+		RewriteValues:         opts.RewriteValues,
+		SkipDelegations:       opts.SkipDelegations,
+		SkipValidationRecords: opts.SkipValidationRecords,
+	})
+	result.Excluded = append(result.Excluded, excluded...)
+	if !opts.TTL.Empty() {
+		var ttlChanges []TTLChange
+		changes, ttlChanges = ApplyTTLOptions(changes, opts.TTL)
+		result.TTLChanges = append(result.TTLChanges, ttlChanges...)
+	}
+	var err error
+	if len(opts.Substitutions) > 0 {
+		changes, _, err = ApplySubstitutions(changes, opts.Substitutions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.StripHealthChecks || opts.RequireHealthChecks {
+		changes, err = dst.checkHealthChecks(ctx, changes, opts.StripHealthChecks)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = dst.checkCidrCollections(ctx, changes)
+	if err != nil {
+		return nil, err
+	}
+	changes = RewriteAliasZoneIDs(changes, srcZoneID, aws.ToString(result.DestinationZone.Id))
+
+	if !opts.UpsertUnchanged && !result.CreatedZone {
+		var unchanged []rtypes.Change
+		changes, unchanged = withoutUnchanged(ctx, changes, result.Existing)
+		result.Unchanged = append(result.Unchanged, unchanged...)
+	}
+	if opts.NoOverwrite {
+		var conflicts []Conflict
+		changes, conflicts = createOnly(ctx, changes, result.Existing)
+		result.Conflicts = append(result.Conflicts, conflicts...)
+		if len(conflicts) > 0 && !opts.SkipConflicts {
+			return nil, &OverwriteConflicts{Conflicts: result.Conflicts}
+		}
+	}
+	result.Changes = append(result.Changes, changes...)
+	return changes, nil
+}
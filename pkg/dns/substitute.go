@@ -0,0 +1,220 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+)
+
+// RewriteRule replaces every occurrence of Old with New in the values of
+// copied record sets, see ApplySubstitutions. When Old starts with "re:",
+// the rest is a regular expression and New may refer to its groups as $1,
+// see regexp.Regexp.ReplaceAllString. Regular expressions are not anchored,
+// so use ^ and $ to match whole values. Rules with a regular expression must
+// be created with ParseRewriteRule.
+type RewriteRule struct {
+	Old string
+	New string
+
+	re *regexp.Regexp
+}
+
+func (r RewriteRule) String() string {
+	return r.Old + "=" + r.New
+}
+
+// ParseRewriteRule reads a rule written as old=new. The first = separates
+// them, so New may contain more, but a regular expression may not. An error
+// is returned for regular expressions that do not compile.
+func ParseRewriteRule(s string) (RewriteRule, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" || from == regexPrefix {
+		return RewriteRule{}, fmt.Errorf("invalid rewrite rule %q, expected old=new", s)
+	}
+	rule := RewriteRule{Old: from, New: to}
+	if strings.HasPrefix(from, regexPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(from, regexPrefix))
+		if err != nil {
+			return RewriteRule{}, fmt.Errorf("invalid rewrite rule %q: %w", s, err)
+		}
+		rule.re = re
+	}
+	return rule, nil
+}
+
+// RewriteRules is a list of rules applied in order. It implements
+// pflag.Value, so a rule that does not parse fails the flag parsing.
+type RewriteRules []RewriteRule
+
+func (r *RewriteRules) String() string {
+	rules := []string{}
+	for _, rule := range *r {
+		rules = append(rules, rule.String())
+	}
+	return strings.Join(rules, ",")
+}
+
+// Set appends the rule s, see ParseRewriteRule.
+func (r *RewriteRules) Set(s string) error {
+	rule, err := ParseRewriteRule(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+func (r *RewriteRules) Type() string {
+	return "stringArray"
+}
+
+// ReadRewriteRuleFile reads one old=new rule per line of file, in order.
+// Blank lines and lines starting with # are skipped.
+func ReadRewriteRuleFile(file string) ([]RewriteRule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := []RewriteRule{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParseRewriteRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SubstitutedRecord is a record set whose values were changed by
+// ApplySubstitutions.
+type SubstitutedRecord struct {
+	From rtypes.ResourceRecordSet
+	To   rtypes.ResourceRecordSet
+}
+
+// ApplySubstitutions returns a copy of changes with subs applied, in order,
+// to the values and alias target names of the record sets, and the record
+// sets that changed. Record names are left as is. A substituted value that
+// is no longer valid for the type of its record set fails with an error.
+func ApplySubstitutions(changes []rtypes.Change, subs []RewriteRule) ([]rtypes.Change, []SubstitutedRecord, error) {
+	if len(subs) == 0 {
+		return changes, nil, nil
+	}
+	updated := []rtypes.Change{}
+	substituted := []SubstitutedRecord{}
+	for _, c := range changes {
+		rs, changed, err := substituteRecordSet(*c.ResourceRecordSet, subs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("substituting values of %s %s: %w",
+				DecodeName(aws.ToString(c.ResourceRecordSet.Name)), c.ResourceRecordSet.Type, err)
+		}
+		if changed {
+			substituted = append(substituted, SubstitutedRecord{From: *c.ResourceRecordSet, To: rs})
+			c.ResourceRecordSet = &rs
+		}
+		updated = append(updated, c)
+	}
+	return updated, substituted, nil
+}
+
+func substituteRecordSet(rs rtypes.ResourceRecordSet, subs []RewriteRule) (rtypes.ResourceRecordSet, bool, error) {
+	changed := false
+	if rs.AliasTarget != nil {
+		name := aws.ToString(rs.AliasTarget.DNSName)
+		target := substitute(name, subs)
+		if target != name {
+			if _, ok := dns.IsDomainName(target); !ok || strings.ContainsAny(target, " \t") {
+				return rs, false, fmt.Errorf("alias target %q is not a valid name", target)
+			}
+			alias := *rs.AliasTarget
+			alias.DNSName = aws.String(target)
+			rs.AliasTarget = &alias
+			changed = true
+		}
+	}
+
+	records := []rtypes.ResourceRecord{}
+	for _, rr := range rs.ResourceRecords {
+		value := aws.ToString(rr.Value)
+		v, err := SubstituteValue(rs.Type, value, subs)
+		if err != nil {
+			return rs, false, err
+		}
+		if v != value {
+			changed = true
+		}
+		records = append(records, rtypes.ResourceRecord{Value: aws.String(v)})
+	}
+	if len(records) > 0 {
+		rs.ResourceRecords = records
+	}
+	return rs, changed, nil
+}
+
+// SubstituteValue applies subs, in order, to a ResourceRecord value of type
+// t and checks that the result is still valid for t. TXT and SPF values are
+// substituted in the unescaped text of each character-string, so quotes and
+// escapes in subs match the text itself, and the result is quoted again and
+// split at MaxCharacterString. A match spanning two character-strings is not
+// replaced.
+func SubstituteValue(t rtypes.RRType, value string, subs []RewriteRule) (string, error) {
+	if isTXTType(t) {
+		strs, err := ParseTXT(value)
+		if err != nil {
+			return "", err
+		}
+		replaced := []string{}
+		changed := false
+		for _, s := range strs {
+			r := substitute(s, subs)
+			if r != s {
+				changed = true
+			}
+			replaced = append(replaced, SplitTXT(r)...)
+		}
+		if !changed {
+			return value, nil
+		}
+		return FormatTXT(replaced), nil
+	}
+
+	v := substitute(value, subs)
+	if v == value {
+		return value, nil
+	}
+	_, err := dns.NewRR(fmt.Sprintf(". 300 IN %s %s", t, v))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid %s value: %w", DisplayValue(t, v), t, err)
+	}
+	return v, nil
+}
+
+func substitute(s string, subs []RewriteRule) string {
+	for _, sub := range subs {
+		if sub.re != nil {
+			s = sub.re.ReplaceAllString(s, sub.New)
+			continue
+		}
+		s = strings.ReplaceAll(s, sub.Old, sub.New)
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestRewriteRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		t     rtypes.RRType
+		value string
+		want  string
+	}{
+		{
+			name:  "literal",
+			rules: []string{"old-lb-1234.us-east-1.elb.amazonaws.com=new-lb-5678.us-east-1.elb.amazonaws.com"},
+			t:     rtypes.RRTypeCname,
+			value: "old-lb-1234.us-east-1.elb.amazonaws.com.",
+			want:  "new-lb-5678.us-east-1.elb.amazonaws.com.",
+		},
+		{
+			name:  "literal dots are not wildcards",
+			rules: []string{"10.0.0=10.1.0"},
+			t:     rtypes.RRTypeA,
+			value: "10.0.100.1",
+			want:  "10.0.100.1",
+		},
+		{
+			name:  "unanchored regular expression",
+			rules: []string{`re:10\.0=10.9`},
+			t:     rtypes.RRTypeTxt,
+			value: `"10.0.0.10 10.0.1.1"`,
+			want:  `"10.9.0.10 10.9.1.1"`,
+		},
+		{
+			name:  "anchored regular expression",
+			rules: []string{`re:^10\.0\.=10.9.`},
+			t:     rtypes.RRTypeTxt,
+			value: `"10.0.0.10 10.0.1.1"`,
+			want:  `"10.9.0.10 10.0.1.1"`,
+		},
+		{
+			name:  "anchored at both ends",
+			rules: []string{`re:^www$=web`},
+			t:     rtypes.RRTypeTxt,
+			value: `"www.example.com"`,
+			want:  `"www.example.com"`,
+		},
+		{
+			name:  "capture groups",
+			rules: []string{`re:^10\.0\.(\d+)\.(\d+)$=10.1.$2.$1`},
+			t:     rtypes.RRTypeA,
+			value: "10.0.3.4",
+			want:  "10.1.4.3",
+		},
+		{
+			name:  "named capture group",
+			rules: []string{`re:^(?P<host>[a-z]+)-old\.=${host}-new.`},
+			t:     rtypes.RRTypeCname,
+			value: "api-old.example.com.",
+			want:  "api-new.example.com.",
+		},
+		{
+			name:  "rules in order",
+			rules: []string{"blue=green", "green=red"},
+			t:     rtypes.RRTypeCname,
+			value: "blue.example.com.",
+			want:  "red.example.com.",
+		},
+		{
+			name:  "new value with =",
+			rules: []string{"spf1 -all=spf1 a=mail.example.com -all"},
+			t:     rtypes.RRTypeTxt,
+			value: `"v=spf1 -all"`,
+			want:  `"v=spf1 a=mail.example.com -all"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules RewriteRules
+			for _, r := range tt.rules {
+				if err := rules.Set(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := SubstituteValue(tt.t, tt.value, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRewriteRuleInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"old",
+		"=new",
+		"re:=new",
+		"re:(unclosed=new",
+		"re:[a-=new",
+		`re:a\=new`,
+		"re:*=new",
+	} {
+		t.Run(rule, func(t *testing.T) {
+			var rules RewriteRules
+			if err := rules.Set(rule); err == nil {
+				t.Errorf("%q parsed as %v", rule, rules)
+			}
+			if len(rules) != 0 {
+				t.Errorf("an invalid rule was added: %v", rules)
+			}
+		})
+	}
+}
+
+func TestApplySubstitutions(t *testing.T) {
+	alias := rtypes.ResourceRecordSet{
+		Name: aws.String("www.example.com."),
+		Type: rtypes.RRTypeA,
+		AliasTarget: &rtypes.AliasTarget{
+			DNSName:      aws.String("old-lb-1234.us-east-1.elb.amazonaws.com."),
+			HostedZoneId: aws.String("Z35SXDOTRQ7X7K"),
+		},
+	}
+	a := recordSet("old.example.com.", rtypes.RRTypeA, "10.0.0.1")
+	changes := []rtypes.Change{
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &alias},
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &a},
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &rtypes.ResourceRecordSet{
+			Name: aws.String("mail.example.com."), Type: rtypes.RRTypeA, TTL: aws.Int64(300),
+			ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String("192.0.2.1")}},
+		}},
+	}
+	var rules RewriteRules
+	for _, r := range []string{"old-lb-1234=new-lb-5678", `re:^10\.0\.=10.1.`, "old=new"} {
+		if err := rules.Set(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updated, substituted, err := ApplySubstitutions(changes, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(substituted) != 2 {
+		t.Fatalf("got %d substituted records, want the alias and the A record", len(substituted))
+	}
+	if got := aws.ToString(updated[0].ResourceRecordSet.AliasTarget.DNSName); got != "new-lb-5678.us-east-1.elb.amazonaws.com." {
+		t.Errorf("alias target is %s", got)
+	}
+	if got := aws.ToString(alias.AliasTarget.DNSName); got != "old-lb-1234.us-east-1.elb.amazonaws.com." {
+		t.Errorf("the original alias target was changed to %s", got)
+	}
+	rs := updated[1].ResourceRecordSet
+	if aws.ToString(rs.Name) != "old.example.com." || aws.ToString(rs.ResourceRecords[0].Value) != "10.1.0.1" {
+		t.Errorf("got %s %s, want the value rewritten and the name kept", aws.ToString(rs.Name), aws.ToString(rs.ResourceRecords[0].Value))
+	}
+
+	var invalid RewriteRules
+	_ = invalid.Set("192.0.2.1=not-an-address")
+	if _, _, err := ApplySubstitutions(changes, invalid); err == nil {
+		t.Error("an A value rewritten to a name was accepted")
+	}
+}
@@ -0,0 +1,291 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// TrafficPolicyRecords is returned when the source zone holds record sets
+// created by traffic policy instances, see CopyOptions.FailOnTrafficPolicy.
+type TrafficPolicyRecords struct {
+	Domain string
+	// Records are the names and types of the record sets with their traffic
+	// policy instance id.
+	Records []string
+}
+
+func (e *TrafficPolicyRecords) Error() string {
+	return fmt.Sprintf("%d records of '%s' are managed by traffic policies: %s",
+		len(e.Records), e.Domain, strings.Join(e.Records, ", "))
+}
+
+// IsTrafficPolicyRecord reports whether rs was created by a traffic policy
+// instance. ChangeResourceRecordSets cannot create or update those.
+func IsTrafficPolicyRecord(rs rtypes.ResourceRecordSet) bool {
+	return aws.ToString(rs.TrafficPolicyInstanceId) != ""
+}
+
+// trafficPolicyRecords lists the excluded record sets managed by traffic
+// policies as "name type (instance id)".
+func trafficPolicyRecords(excluded []ExcludedRecord) []string {
+	records := []string{}
+	for _, e := range excluded {
+		if e.Cause != ExcludedTrafficPolicy {
+			continue
+		}
+		records = append(records, fmt.Sprintf("%s %s (%s)", DecodeName(aws.ToString(e.Record.Name)), e.Record.Type,
+			aws.ToString(e.Record.TrafficPolicyInstanceId)))
+	}
+	return records
+}
+
+// checkTrafficPolicyRecords warns about the excluded record sets managed by
+// traffic policies, or returns a TrafficPolicyRecords with fail.
+func checkTrafficPolicyRecords(ctx context.Context, domain string, excluded []ExcludedRecord, fail bool) error {
+	records := trafficPolicyRecords(excluded)
+	if len(records) == 0 {
+		return nil
+	}
+	if fail {
+		return &TrafficPolicyRecords{Domain: domain, Records: records}
+	}
+	logging.From(ctx).Warnf("Not copying %d records of '%s' managed by traffic policies, copy their instances with route53copy traffic-policies:\n", len(records), domain)
+	for _, r := range records {
+		logging.From(ctx).Warnf("  %s\n", r)
+	}
+	return nil
+}
+
+// TrafficPolicyCopy counts what CopyTrafficPolicies did, or would do in a
+// dry run.
+type TrafficPolicyCopy struct {
+	// PoliciesCreated are the policy versions created in the destination,
+	// PoliciesReused the ones it already held with the same name and
+	// document.
+	PoliciesCreated int
+	PoliciesReused  int
+	// InstancesCreated are the instances created in the destination zone,
+	// InstancesSkipped the ones it already held.
+	InstancesCreated int
+	InstancesSkipped int
+}
+
+// policyVersion is a traffic policy id and version.
+type policyVersion struct {
+	id      string
+	version int32
+}
+
+// CopyTrafficPolicies creates the traffic policy instances of the source
+// zone srcZoneID in the zone dstZoneID of r, together with the policy
+// versions they use. A policy version r already holds under the same name
+// with the same document is reused, so re-runs do not duplicate policies;
+// a policy r holds under the same name with other documents gets a new
+// version. Instances r already holds with the same name and type are left
+// as they are. With dryRun nothing is created.
+func (r *RouteCopy) CopyTrafficPolicies(ctx context.Context, src *RouteCopy, srcZoneID, dstZoneID string, dryRun bool) (TrafficPolicyCopy, error) {
+	result := TrafficPolicyCopy{}
+	instances, err := src.TrafficPolicyInstances(ctx, srcZoneID)
+	if err != nil {
+		return result, err
+	}
+	if len(instances) == 0 {
+		return result, nil
+	}
+	existing, err := r.TrafficPolicyInstances(ctx, dstZoneID)
+	if err != nil {
+		return result, err
+	}
+	existingInstances := map[string]rtypes.TrafficPolicyInstance{}
+	for _, i := range existing {
+		existingInstances[trafficPolicyInstanceKey(i)] = i
+	}
+	policies, err := r.trafficPolicies(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	copied := map[policyVersion]policyVersion{}
+	for _, instance := range instances {
+		name := DecodeName(aws.ToString(instance.Name))
+		if dst, ok := existingInstances[trafficPolicyInstanceKey(instance)]; ok {
+			logging.From(ctx).Infof("Traffic policy instance %s %s already exists as %s\n", name, instance.TrafficPolicyType, aws.ToString(dst.Id))
+			result.InstancesSkipped++
+			continue
+		}
+
+		srcPolicy := policyVersion{aws.ToString(instance.TrafficPolicyId), aws.ToInt32(instance.TrafficPolicyVersion)}
+		dstPolicy, ok := copied[srcPolicy]
+		if !ok {
+			dstPolicy, err = r.copyTrafficPolicy(ctx, src, srcPolicy, policies, dryRun, &result)
+			if err != nil {
+				return result, err
+			}
+			copied[srcPolicy] = dstPolicy
+		}
+
+		if dryRun {
+			logging.From(ctx).Infof("Not creating traffic policy instance %s %s since this is a dry run\n", name, instance.TrafficPolicyType)
+			result.InstancesCreated++
+			continue
+		}
+		created, err := r.cli.CreateTrafficPolicyInstance(ctx, &route53.CreateTrafficPolicyInstanceInput{
+			HostedZoneId:         aws.String(dstZoneID),
+			Name:                 instance.Name,
+			TTL:                  instance.TTL,
+			TrafficPolicyId:      aws.String(dstPolicy.id),
+			TrafficPolicyVersion: aws.Int32(dstPolicy.version),
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to create traffic policy instance %s: %w", name, err)
+		}
+		logging.From(ctx).Infof("Traffic policy instance %s %s created as %s\n", name, instance.TrafficPolicyType,
+			aws.ToString(created.TrafficPolicyInstance.Id))
+		result.InstancesCreated++
+	}
+	return result, nil
+}
+
+// copyTrafficPolicy returns the version of a policy of r with the name and
+// document of the source policy version, creating it unless dryRun is set.
+// policies are the policy versions of r by name, and get the created ones.
+func (r *RouteCopy) copyTrafficPolicy(ctx context.Context, src *RouteCopy, srcPolicy policyVersion, policies map[string][]rtypes.TrafficPolicy, dryRun bool, result *TrafficPolicyCopy) (policyVersion, error) {
+	resp, err := src.cli.GetTrafficPolicy(ctx, &route53.GetTrafficPolicyInput{
+		Id:      aws.String(srcPolicy.id),
+		Version: aws.Int32(srcPolicy.version),
+	})
+	if err != nil {
+		return policyVersion{}, fmt.Errorf("failed to get traffic policy %s version %d: %w", srcPolicy.id, srcPolicy.version, err)
+	}
+	policy := *resp.TrafficPolicy
+	name := aws.ToString(policy.Name)
+
+	hash := trafficPolicyDocumentHash(aws.ToString(policy.Document))
+	for _, p := range policies[name] {
+		if trafficPolicyDocumentHash(aws.ToString(p.Document)) == hash {
+			logging.From(ctx).Infof("Traffic policy %s version %d was already copied as %s version %d\n",
+				name, srcPolicy.version, aws.ToString(p.Id), aws.ToInt32(p.Version))
+			result.PoliciesReused++
+			return policyVersion{aws.ToString(p.Id), aws.ToInt32(p.Version)}, nil
+		}
+	}
+
+	result.PoliciesCreated++
+	if dryRun {
+		logging.From(ctx).Infof("Not copying traffic policy %s version %d since this is a dry run\n", name, srcPolicy.version)
+		return policyVersion{}, nil
+	}
+	var created *rtypes.TrafficPolicy
+	if len(policies[name]) > 0 {
+		id := aws.ToString(policies[name][0].Id)
+		out, err := r.cli.CreateTrafficPolicyVersion(ctx, &route53.CreateTrafficPolicyVersionInput{
+			Id:       aws.String(id),
+			Document: policy.Document,
+			Comment:  policy.Comment,
+		})
+		if err != nil {
+			return policyVersion{}, fmt.Errorf("failed to create a version of traffic policy %s: %w", name, err)
+		}
+		created = out.TrafficPolicy
+	} else {
+		out, err := r.cli.CreateTrafficPolicy(ctx, &route53.CreateTrafficPolicyInput{
+			Name:     policy.Name,
+			Document: policy.Document,
+			Comment:  policy.Comment,
+		})
+		if err != nil {
+			return policyVersion{}, fmt.Errorf("failed to create traffic policy %s: %w", name, err)
+		}
+		created = out.TrafficPolicy
+	}
+	policies[name] = append(policies[name], *created)
+	logging.From(ctx).Infof("Traffic policy %s version %d copied as %s version %d\n",
+		name, srcPolicy.version, aws.ToString(created.Id), aws.ToInt32(created.Version))
+	return policyVersion{aws.ToString(created.Id), aws.ToInt32(created.Version)}, nil
+}
+
+// TrafficPolicyInstances returns the traffic policy instances creating
+// records in the zone.
+func (r *RouteCopy) TrafficPolicyInstances(ctx context.Context, zoneID string) ([]rtypes.TrafficPolicyInstance, error) {
+	instances := []rtypes.TrafficPolicyInstance{}
+	input := &route53.ListTrafficPolicyInstancesByHostedZoneInput{HostedZoneId: aws.String(zoneID)}
+	for {
+		resp, err := r.cli.ListTrafficPolicyInstancesByHostedZone(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list traffic policy instances: %w", err)
+		}
+		instances = append(instances, resp.TrafficPolicyInstances...)
+		if !resp.IsTruncated {
+			return instances, nil
+		}
+		input.TrafficPolicyInstanceNameMarker = resp.TrafficPolicyInstanceNameMarker
+		input.TrafficPolicyInstanceTypeMarker = resp.TrafficPolicyInstanceTypeMarker
+	}
+}
+
+// trafficPolicies returns every version of the traffic policies of r, by
+// policy name.
+func (r *RouteCopy) trafficPolicies(ctx context.Context) (map[string][]rtypes.TrafficPolicy, error) {
+	policies := map[string][]rtypes.TrafficPolicy{}
+	input := &route53.ListTrafficPoliciesInput{}
+	for {
+		resp, err := r.cli.ListTrafficPolicies(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list traffic policies: %w", err)
+		}
+		for _, summary := range resp.TrafficPolicySummaries {
+			versions, err := r.trafficPolicyVersions(ctx, aws.ToString(summary.Id))
+			if err != nil {
+				return nil, err
+			}
+			name := aws.ToString(summary.Name)
+			policies[name] = append(policies[name], versions...)
+		}
+		if !resp.IsTruncated {
+			return policies, nil
+		}
+		input.TrafficPolicyIdMarker = resp.TrafficPolicyIdMarker
+	}
+}
+
+func (r *RouteCopy) trafficPolicyVersions(ctx context.Context, id string) ([]rtypes.TrafficPolicy, error) {
+	versions := []rtypes.TrafficPolicy{}
+	input := &route53.ListTrafficPolicyVersionsInput{Id: aws.String(id)}
+	for {
+		resp, err := r.cli.ListTrafficPolicyVersions(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the versions of traffic policy %s: %w", id, err)
+		}
+		versions = append(versions, resp.TrafficPolicies...)
+		if !resp.IsTruncated {
+			return versions, nil
+		}
+		input.TrafficPolicyVersionMarker = resp.TrafficPolicyVersionMarker
+	}
+}
+
+func trafficPolicyInstanceKey(i rtypes.TrafficPolicyInstance) string {
+	return strings.ToLower(normalizeDomain(aws.ToString(i.Name))) + " " + string(i.TrafficPolicyType)
+}
+
+// trafficPolicyDocumentHash hashes a policy document without its
+// insignificant whitespace, which Route53 may not keep as given.
+func trafficPolicyDocumentHash(document string) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(document)); err != nil {
+		compact.Reset()
+		compact.WriteString(document)
+	}
+	sum := sha256.Sum256(compact.Bytes())
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// TTLOptions changes the TTLs of copied record sets. Alias record sets have
+// no TTL and are left as is. Zero values are not applied.
+type TTLOptions struct {
+	// Override sets every TTL to this many seconds.
+	Override int64
+	// Min and Max clamp the TTLs when there is no Override.
+	Min int64
+	Max int64
+}
+
+// Empty reports whether the options leave the TTLs as is.
+func (o TTLOptions) Empty() bool {
+	return o.Override == 0 && o.Min == 0 && o.Max == 0
+}
+
+// Validate rejects negative values and contradicting options.
+func (o TTLOptions) Validate() error {
+	if o.Override < 0 || o.Min < 0 || o.Max < 0 {
+		return errors.New("TTLs must be positive")
+	}
+	if o.Override > 0 && (o.Min > 0 || o.Max > 0) {
+		return errors.New("a TTL override cannot be combined with a minimum or maximum TTL")
+	}
+	if o.Min > 0 && o.Max > 0 && o.Min > o.Max {
+		return fmt.Errorf("minimum TTL %d is above maximum TTL %d", o.Min, o.Max)
+	}
+	return nil
+}
+
+// apply returns the TTL for a record set with ttl.
+func (o TTLOptions) apply(ttl int64) int64 {
+	if o.Override > 0 {
+		return o.Override
+	}
+	if o.Min > 0 && ttl < o.Min {
+		return o.Min
+	}
+	if o.Max > 0 && ttl > o.Max {
+		return o.Max
+	}
+	return ttl
+}
+
+// TTLChange is a record set whose TTL was changed by ApplyTTLOptions, with
+// the TTL it had before, so it can be restored with RestoreTTLs.
+type TTLChange struct {
+	Record   rtypes.ResourceRecordSet
+	Original int64
+}
+
+// TransformRecords returns a copy of records with the TTLs changed
+// according to opts, and the record sets whose TTL changed, with the TTL
+// they had before. Alias record sets are returned as they are.
+func TransformRecords(records []rtypes.ResourceRecordSet, opts TTLOptions) ([]rtypes.ResourceRecordSet, []TTLChange) {
+	updated := []rtypes.ResourceRecordSet{}
+	ttlChanges := []TTLChange{}
+	for _, rs := range records {
+		changed, ok := transformRecord(rs, opts)
+		if ok {
+			ttlChanges = append(ttlChanges, TTLChange{Record: changed, Original: aws.ToInt64(rs.TTL)})
+		}
+		updated = append(updated, changed)
+	}
+	return updated, ttlChanges
+}
+
+// ApplyTTLOptions returns a copy of changes with the TTLs of the record sets
+// they create or upsert changed as TransformRecords does, and the record sets
+// whose TTL changed.
+func ApplyTTLOptions(changes []rtypes.Change, opts TTLOptions) ([]rtypes.Change, []TTLChange) {
+	updated := []rtypes.Change{}
+	ttlChanges := []TTLChange{}
+	for _, c := range changes {
+		if c.Action == rtypes.ChangeActionDelete {
+			updated = append(updated, c)
+			continue
+		}
+		rs := c.ResourceRecordSet
+		if changed, ok := transformRecord(*rs, opts); ok {
+			c.ResourceRecordSet = &changed
+			ttlChanges = append(ttlChanges, TTLChange{Record: changed, Original: aws.ToInt64(rs.TTL)})
+		}
+		updated = append(updated, c)
+	}
+	return updated, ttlChanges
+}
+
+// transformRecord returns rs with its TTL changed according to opts, and
+// whether it changed.
+func transformRecord(rs rtypes.ResourceRecordSet, opts TTLOptions) (rtypes.ResourceRecordSet, bool) {
+	if rs.AliasTarget != nil || rs.TTL == nil {
+		return rs, false
+	}
+	ttl := opts.apply(aws.ToInt64(rs.TTL))
+	if ttl == aws.ToInt64(rs.TTL) {
+		return rs, false
+	}
+	rs.TTL = aws.Int64(ttl)
+	return rs, true
+}
+
+// warnApexTTLs warns that the apex NS and SOA records keep their TTLs, and
+// that negative answers stay cached for the SOA minimum TTL, which limits
+// how quickly a copy can be rolled back.
+func warnApexTTLs(ctx context.Context, domain string, records []rtypes.ResourceRecordSet, opts TTLOptions) {
+	ttl := opts.Override
+	if ttl == 0 {
+		ttl = opts.Max
+	}
+	if ttl == 0 {
+		return
+	}
+	for _, rs := range records {
+		if rs.Type != rtypes.RRTypeSoa || !sameDomain(aws.ToString(rs.Name), domain) || len(rs.ResourceRecords) == 0 {
+			continue
+		}
+		fields := strings.Fields(aws.ToString(rs.ResourceRecords[0].Value))
+		minimum, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+		if err != nil || minimum <= ttl {
+			return
+		}
+		logging.From(ctx).Warnf("The apex NS and SOA records of '%s' keep their TTLs, and its SOA caches negative answers for %ds, "+
+			"longer than the %ds TTL of the copied records\n", domain, minimum, ttl)
+	}
+}
+
+// RestoreTTLs sets the record sets of the zone back to the original TTLs of
+// ttlChanges, leaving their values as they are now. Record sets that no
+// longer exist or already have their original TTL are skipped.
+func (r *RouteCopy) RestoreTTLs(ctx context.Context, zoneId string, ttlChanges []TTLChange, maxWait time.Duration) ([]BatchResult, error) {
+	records, err := r.GetResourceRecords(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]rtypes.ResourceRecordSet{}
+	for _, rs := range records {
+		byKey[recordSetKey(rs)] = rs
+	}
+
+	changes := []rtypes.Change{}
+	for _, t := range ttlChanges {
+		rs, ok := byKey[recordSetKey(t.Record)]
+		if !ok {
+			logging.From(ctx).Warnf("Not restoring the TTL of %s %s, it no longer exists\n", DecodeName(aws.ToString(t.Record.Name)), t.Record.Type)
+			continue
+		}
+		if rs.AliasTarget != nil || aws.ToInt64(rs.TTL) == t.Original {
+			continue
+		}
+		rs.TTL = aws.Int64(t.Original)
+		changes = append(changes, recordSetChange(rtypes.ChangeActionUpsert, rs))
+	}
+	if len(changes) == 0 {
+		logging.From(ctx).Infof("All TTLs in zone %s are already restored\n", zoneId)
+		return nil, nil
+	}
+	logging.From(ctx).Infof("Restoring the TTLs of %d records in zone %s\n", len(changes), zoneId)
+	return r.ApplyChanges(ctx, zoneId, "Restoring TTLs", changes, maxWait)
+}
@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestTransformRecords(t *testing.T) {
+	short := recordSet("short.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	short.TTL = aws.Int64(30)
+	long := recordSet("long.example.com.", rtypes.RRTypeA, "192.0.2.2")
+	long.TTL = aws.Int64(86400)
+	alias := rtypes.ResourceRecordSet{
+		Name: aws.String("cdn.example.com."),
+		Type: rtypes.RRTypeA,
+		AliasTarget: &rtypes.AliasTarget{
+			DNSName:      aws.String("d111111abcdef8.cloudfront.net."),
+			HostedZoneId: aws.String("Z2FDTNDATAQYW2"),
+		},
+	}
+	records := []rtypes.ResourceRecordSet{short, long, alias}
+	tests := []struct {
+		name string
+		opts TTLOptions
+		// want are the TTLs of short and long, alias keeps none.
+		want    [2]int64
+		changed int
+	}{
+		{name: "no options", want: [2]int64{30, 86400}},
+		{name: "override", opts: TTLOptions{Override: 60}, want: [2]int64{60, 60}, changed: 2},
+		{name: "minimum", opts: TTLOptions{Min: 300}, want: [2]int64{300, 86400}, changed: 1},
+		{name: "maximum", opts: TTLOptions{Max: 3600}, want: [2]int64{30, 3600}, changed: 1},
+		{name: "minimum and maximum", opts: TTLOptions{Min: 60, Max: 3600}, want: [2]int64{60, 3600}, changed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, ttlChanges := TransformRecords(records, tt.opts)
+			if len(updated) != len(records) {
+				t.Fatalf("got %d records, want %d", len(updated), len(records))
+			}
+			got := [2]int64{aws.ToInt64(updated[0].TTL), aws.ToInt64(updated[1].TTL)}
+			if got != tt.want {
+				t.Errorf("got TTLs %v, want %v", got, tt.want)
+			}
+			if updated[2].TTL != nil || updated[2].AliasTarget == nil {
+				t.Errorf("the alias record was changed: %+v", updated[2])
+			}
+			if len(ttlChanges) != tt.changed {
+				t.Fatalf("got %d TTL changes, want %d", len(ttlChanges), tt.changed)
+			}
+			for _, c := range ttlChanges {
+				original := short
+				if aws.ToString(c.Record.Name) == aws.ToString(long.Name) {
+					original = long
+				}
+				if c.Original != aws.ToInt64(original.TTL) {
+					t.Errorf("%s was %ds, got original TTL %d", aws.ToString(c.Record.Name), aws.ToInt64(original.TTL), c.Original)
+				}
+			}
+			if aws.ToInt64(records[0].TTL) != 30 || aws.ToInt64(records[1].TTL) != 86400 {
+				t.Error("the given records were changed")
+			}
+		})
+	}
+}
+
+func TestApplyTTLOptionsSkipsDeletes(t *testing.T) {
+	upsert := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	stale := recordSet("old.example.com.", rtypes.RRTypeA, "192.0.2.2")
+	changes := []rtypes.Change{
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &upsert},
+		{Action: rtypes.ChangeActionDelete, ResourceRecordSet: &stale},
+	}
+	updated, ttlChanges := ApplyTTLOptions(changes, TTLOptions{Override: 60})
+	if got := aws.ToInt64(updated[0].ResourceRecordSet.TTL); got != 60 {
+		t.Errorf("the upsert has TTL %d, want 60", got)
+	}
+	// A delete must match the record set as it is.
+	if got := aws.ToInt64(updated[1].ResourceRecordSet.TTL); got != 300 {
+		t.Errorf("the delete has TTL %d, want 300", got)
+	}
+	if len(ttlChanges) != 1 || ttlChanges[0].Original != 300 {
+		t.Errorf("got TTL changes %+v, want the upsert from 300", ttlChanges)
+	}
+}
@@ -0,0 +1,215 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// MaxCharacterString is the length in bytes of the longest character-string
+// of a TXT record, RFC 1035. Longer data, such as DKIM keys, is split into
+// several character-strings that the reader concatenates.
+const MaxCharacterString = 255
+
+// isTXTType reports whether records of type t hold character-strings.
+func isTXTType(t rtypes.RRType) bool {
+	return t == rtypes.RRTypeTxt || t == rtypes.RRTypeSpf
+}
+
+// SplitTXT splits data into character-strings of at most
+// MaxCharacterString bytes. Empty data is a single empty string.
+func SplitTXT(data string) []string {
+	strs := []string{}
+	for len(data) > MaxCharacterString {
+		strs = append(strs, data[:MaxCharacterString])
+		data = data[MaxCharacterString:]
+	}
+	return append(strs, data)
+}
+
+// QuoteTXT quotes a character-string the way Route53 expects it in
+// ResourceRecord values: quotes and backslashes are escaped with a
+// backslash, and control and non-ASCII bytes with \DDD octal escapes.
+func QuoteTXT(s string) string {
+	return `"` + escapeTXT(s, 8) + `"`
+}
+
+// FormatTXT returns the ResourceRecord value of the character-strings, each
+// quoted with QuoteTXT and separated by a space.
+func FormatTXT(strs []string) string {
+	quoted := []string{}
+	for _, s := range strs {
+		quoted = append(quoted, QuoteTXT(s))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ParseTXT returns the unescaped character-strings of a ResourceRecord
+// value, see QuoteTXT. Strings are quoted or separated by spaces.
+func ParseTXT(value string) ([]string, error) {
+	return parseTXT(value, 8)
+}
+
+// escapeTXT escapes quotes, backslashes, and control and non-ASCII bytes of
+// s. Route53 writes the \DDD escapes in octal, base 8, while zone files
+// write them in decimal, base 10.
+func escapeTXT(s string, base int) string {
+	format := "\\%03o"
+	if base == 10 {
+		format = "\\%03d"
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, format, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseTXT reads the character-strings of value, with \DDD escapes in base,
+// see escapeTXT. A backslash followed by anything else escapes the next
+// character.
+func parseTXT(value string, base int) ([]string, error) {
+	strs := []string{}
+	i := 0
+	for {
+		for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+			i++
+		}
+		if i == len(value) {
+			return strs, nil
+		}
+
+		quoted := value[i] == '"'
+		if quoted {
+			i++
+		}
+		var b strings.Builder
+		closed := false
+		for i < len(value) {
+			c := value[i]
+			if quoted && c == '"' {
+				i++
+				closed = true
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+			if c != '\\' {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			if i+1 == len(value) {
+				return nil, fmt.Errorf("invalid TXT value %s: trailing backslash", displayInvalidTXT(value))
+			}
+			if n, ok := numericEscape(value[i+1:], base); ok {
+				b.WriteByte(n)
+				i += 4
+				continue
+			}
+			b.WriteByte(value[i+1])
+			i += 2
+		}
+		if quoted && !closed {
+			return nil, fmt.Errorf("invalid TXT value %s: missing closing quote", displayInvalidTXT(value))
+		}
+		strs = append(strs, b.String())
+	}
+}
+
+// numericEscape returns the byte of the \DDD escape in base at the start of
+// s, which does not include the backslash.
+func numericEscape(s string, base int) (byte, bool) {
+	if len(s) < 3 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range s[:3] {
+		if c < '0' || int(c-'0') >= base {
+			return 0, false
+		}
+		n = n*base + int(c-'0')
+	}
+	if n > 0xff {
+		return 0, false
+	}
+	return byte(n), true
+}
+
+// NormalizeTXT returns the TXT value in the form Route53 returns it: every
+// character-string quoted with QuoteTXT, and the ones longer than
+// MaxCharacterString split. Values that cannot be parsed are returned as
+// they are.
+func NormalizeTXT(value string) string {
+	strs, err := ParseTXT(value)
+	if err != nil || len(strs) == 0 {
+		return value
+	}
+	split := []string{}
+	for _, s := range strs {
+		split = append(split, SplitTXT(s)...)
+	}
+	return FormatTXT(split)
+}
+
+// zoneFileTXT returns the character-strings of a ResourceRecord value in
+// the presentation format of zone files, see escapeTXT.
+func zoneFileTXT(value string) ([]string, error) {
+	strs, err := ParseTXT(value)
+	if err != nil {
+		return nil, err
+	}
+	escaped := []string{}
+	for _, s := range strs {
+		escaped = append(escaped, escapeTXT(s, 10))
+	}
+	return escaped, nil
+}
+
+// txtFromZoneFile returns the ResourceRecord value of character-strings in
+// the presentation format of zone files, see zoneFileTXT.
+func txtFromZoneFile(strs []string) (string, error) {
+	unescaped := []string{}
+	for _, s := range strs {
+		parsed, err := parseTXT(`"`+s+`"`, 10)
+		if err != nil {
+			return "", err
+		}
+		unescaped = append(unescaped, parsed...)
+	}
+	return FormatTXT(unescaped), nil
+}
+
+// normalizeValue normalizes the values of TXT and SPF records, see
+// NormalizeTXT, and the domain names of CNAME, NS, PTR, MX and SRV values,
+// which are lowercased and fully qualified. Other values are returned as
+// they are.
+func normalizeValue(t rtypes.RRType, value string) string {
+	switch t {
+	case rtypes.RRTypeCname, rtypes.RRTypeNs, rtypes.RRTypePtr:
+		return strings.ToLower(normalizeDomain(value))
+	case rtypes.RRTypeMx, rtypes.RRTypeSrv:
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return value
+		}
+		last := len(fields) - 1
+		fields[last] = strings.ToLower(normalizeDomain(fields[last]))
+		return strings.Join(fields, " ")
+	}
+	if !isTXTType(t) {
+		return value
+	}
+	return NormalizeTXT(value)
+}
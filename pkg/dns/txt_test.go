@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitTXT(t *testing.T) {
+	tests := []struct {
+		data string
+		want []int
+	}{
+		{"", []int{0}},
+		{strings.Repeat("a", MaxCharacterString), []int{MaxCharacterString}},
+		{strings.Repeat("a", MaxCharacterString+1), []int{MaxCharacterString, 1}},
+		{strings.Repeat("a", 1700), []int{255, 255, 255, 255, 255, 255, 170}},
+	}
+	for _, tt := range tests {
+		strs := SplitTXT(tt.data)
+		lengths := []int{}
+		for _, s := range strs {
+			lengths = append(lengths, len(s))
+		}
+		if !reflect.DeepEqual(lengths, tt.want) {
+			t.Errorf("SplitTXT of %d bytes: got lengths %v, want %v", len(tt.data), lengths, tt.want)
+		}
+		if strings.Join(strs, "") != tt.data {
+			t.Errorf("SplitTXT of %d bytes does not join back to the data", len(tt.data))
+		}
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"v=spf1 -all", `"v=spf1 -all"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\path`, `"C:\\path"`},
+		{"a\tb", `"a\011b"`},
+		{"café", `"caf\303\251"`},
+	}
+	for _, tt := range tests {
+		got := QuoteTXT(tt.s)
+		if got != tt.want {
+			t.Errorf("QuoteTXT(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+		strs, err := ParseTXT(got)
+		if err != nil {
+			t.Errorf("ParseTXT(%s): %s", got, err)
+			continue
+		}
+		if len(strs) != 1 || strs[0] != tt.s {
+			t.Errorf("ParseTXT(%s) = %q, want %q", got, strs, tt.s)
+		}
+	}
+}
+
+func TestParseTXT(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   string
+	}{
+		{value: `"v=spf1 include:_spf.example.com" "~all"`, want: []string{"v=spf1 include:_spf.example.com", "~all"}},
+		{value: `unquoted strings`, want: []string{"unquoted", "strings"}},
+		{value: `""`, want: []string{""}},
+		{value: `"a\"b\\c"`, want: []string{`a"b\c`}},
+		{value: `"caf\303\251"`, want: []string{"café"}},
+		// Fewer than three digits is not an octal escape.
+		{value: `"\30x"`, want: []string{"30x"}},
+		{value: `"trailing\`, err: "trailing backslash"},
+		{value: `"unclosed`, err: "missing closing quote"},
+	}
+	for _, tt := range tests {
+		got, err := ParseTXT(tt.value)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseTXT(%s): got error %v, want %s", tt.value, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTXT(%s): %s", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTXT(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTXT(t *testing.T) {
+	dkim := "v=DKIM1; k=rsa; p=" + strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8A", 53)[:1700-len("v=DKIM1; k=rsa; p=")]
+	split := FormatTXT(SplitTXT(dkim))
+	tests := []struct {
+		value string
+		want  string
+	}{
+		// A DKIM key given as one string is split into character-strings.
+		{QuoteTXT(dkim), split},
+		{split, split},
+		{`unquoted`, `"unquoted"`},
+		{`"a\"b\\c"`, `"a\"b\\c"`},
+		// Values that cannot be parsed are kept as they are.
+		{`"unclosed`, `"unclosed`},
+	}
+	for _, tt := range tests {
+		got := NormalizeTXT(tt.value)
+		if got != tt.want {
+			t.Errorf("NormalizeTXT(%.40s...) = %.40s..., want %.40s...", tt.value, got, tt.want)
+		}
+	}
+
+	strs, err := ParseTXT(split)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strs) != 7 || strings.Join(strs, "") != dkim {
+		t.Errorf("the split DKIM key parses into %d strings that do not join back to the key", len(strs))
+	}
+}
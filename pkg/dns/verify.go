@@ -0,0 +1,141 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+)
+
+// Verification holds the differences found between the changes applied to
+// a zone and the records the zone ended up with.
+type Verification struct {
+	// Missing holds record sets that were changed but are not in the zone.
+	Missing []rtypes.ResourceRecordSet
+	// Different pairs the record sets in the zone with the intended ones
+	// when their contents differ.
+	Different []RecordSetUpdate
+	// DNSMismatches holds the answers from the zone nameservers that did not
+	// match the intended record sets.
+	DNSMismatches []DNSMismatch
+}
+
+// DNSMismatch is an answer from a nameserver that differs from the record
+// set it was queried for.
+type DNSMismatch struct {
+	Record     rtypes.ResourceRecordSet
+	Nameserver string
+	Answers    []string
+	Err        error
+}
+
+// OK reports whether no differences were found.
+func (v Verification) OK() bool {
+	return len(v.Missing) == 0 && len(v.Different) == 0 && len(v.DNSMismatches) == 0
+}
+
+type VerificationFailed struct {
+	Verification Verification
+}
+
+func (e *VerificationFailed) Error() string {
+	return fmt.Sprintf("verification failed: %d records missing, %d records differ, %d DNS answers differ",
+		len(e.Verification.Missing), len(e.Verification.Different), len(e.Verification.DNSMismatches))
+}
+
+// VerifyChanges compares the record sets created or updated by changes with
+// the record sets found in the zone. Value order is ignored.
+func VerifyChanges(changes []rtypes.Change, records []rtypes.ResourceRecordSet) Verification {
+	actual := map[string]rtypes.ResourceRecordSet{}
+	for _, rs := range records {
+		actual[recordSetKey(rs)] = rs
+	}
+
+	v := Verification{}
+	for _, c := range changes {
+		if c.Action == rtypes.ChangeActionDelete {
+			continue
+		}
+		intended := *c.ResourceRecordSet
+		rs, ok := actual[recordSetKey(intended)]
+		if !ok {
+			v.Missing = append(v.Missing, intended)
+			continue
+		}
+		if !CompareRecordSets(intended, rs) {
+			v.Different = append(v.Different, RecordSetUpdate{From: rs, To: intended})
+		}
+	}
+	return v
+}
+
+// ZoneNameservers returns the nameservers in the apex NS record of domain.
+func ZoneNameservers(domain string, records []rtypes.ResourceRecordSet) []string {
+	for _, rs := range records {
+		if rs.Type == rtypes.RRTypeNs && sameDomain(aws.ToString(rs.Name), domain) {
+			return sortedValues(rs.ResourceRecords)
+		}
+	}
+	return nil
+}
+
+// VerifyDNS queries the first nameserver for up to sample of the record sets
+// and returns the ones whose answers differ, see ProbeNameservers.
+func VerifyDNS(ctx context.Context, nameservers []string, records []rtypes.ResourceRecordSet, sample int) []DNSMismatch {
+	if len(nameservers) == 0 {
+		return nil
+	}
+	return ProbeNameservers(ctx, nameservers[:1], records, ProbeOptions{Sample: sample, Concurrency: 1})
+}
+
+func queryValues(ctx context.Context, c *dns.Client, server string, rs rtypes.ResourceRecordSet) ([]string, error) {
+	qtype, ok := dns.StringToType[string(rs.Type)]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %s", rs.Type)
+	}
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(DecodeName(aws.ToString(rs.Name))), qtype)
+
+	r, _, err := c.ExchangeContext(ctx, m, server)
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("query returned %s", dns.RcodeToString[r.Rcode])
+	}
+
+	values := []string{}
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		values = append(values, answerValue(rr))
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// answerValue returns the value of rr as Route53 writes it, see rrValue.
+func answerValue(rr dns.RR) string {
+	value, err := rrValue(rr)
+	if err != nil {
+		return strings.TrimPrefix(rr.String(), rr.Header().String())
+	}
+	return value
+}
+
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
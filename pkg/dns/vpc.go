@@ -0,0 +1,219 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// VPCAssociations describes what CopyVPCAssociations did.
+type VPCAssociations struct {
+	// Associated are the VPCs associated with the destination zone, and
+	// Existing the ones that already were.
+	Associated []rtypes.VPC
+	Existing   []rtypes.VPC
+	Failed     []VPCAssociationFailure
+}
+
+// VPCAssociationFailure is a VPC that could not be associated.
+type VPCAssociationFailure struct {
+	VPC rtypes.VPC
+	Err error
+}
+
+// VPCAssociationError is returned by CopyVPCAssociations when some VPCs could
+// not be associated with the destination zone.
+type VPCAssociationError struct {
+	Zone         string
+	Associations VPCAssociations
+}
+
+func (e *VPCAssociationError) Error() string {
+	failed := []string{}
+	for _, f := range e.Associations.Failed {
+		failed = append(failed, fmt.Sprintf("%s (%s)", vpcString(f.VPC), f.Err))
+	}
+	return fmt.Sprintf("could not associate %d VPCs with zone %s: %s; associated: %s",
+		len(e.Associations.Failed), e.Zone, strings.Join(failed, ", "), vpcsString(e.Associations.Associated))
+}
+
+// ListVPCAssociations returns the VPCs associated with a private zone.
+func (r *RouteCopy) ListVPCAssociations(ctx context.Context, zoneId string) ([]rtypes.VPC, error) {
+	resp, err := r.cli.GetHostedZone(ctx, &route53.GetHostedZoneInput{
+		Id: aws.String(zoneId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.VPCs, nil
+}
+
+// CreateVPCAssociationAuthorization allows the account owning vpc to
+// associate it with the zone, which r owns.
+func (r *RouteCopy) CreateVPCAssociationAuthorization(ctx context.Context, zoneId string, vpc rtypes.VPC) error {
+	_, err := r.cli.CreateVPCAssociationAuthorization(ctx, &route53.CreateVPCAssociationAuthorizationInput{
+		HostedZoneId: aws.String(zoneId),
+		VPC:          &vpc,
+	})
+	return err
+}
+
+// AssociateVPCWithHostedZone associates vpc, owned by the account of r, with
+// the zone, and waits for the change to be in sync. A zone of another account
+// must authorize the association first, see
+// CreateVPCAssociationAuthorization.
+func (r *RouteCopy) AssociateVPCWithHostedZone(ctx context.Context, zoneId string, vpc rtypes.VPC, maxWait time.Duration) error {
+	resp, err := r.cli.AssociateVPCWithHostedZone(ctx, &route53.AssociateVPCWithHostedZoneInput{
+		HostedZoneId: aws.String(zoneId),
+		VPC:          &vpc,
+		Comment:      aws.String("Associated by route53copy"),
+	})
+	if err != nil {
+		return err
+	}
+	if resp.ChangeInfo.Status == rtypes.ChangeStatusInsync {
+		return nil
+	}
+	return r.WaitForChange(ctx, aws.ToString(resp.ChangeInfo.Id), maxWait)
+}
+
+// ParseVPC parses a VPC given as "vpc-id" or "vpc-id:region". A VPC without
+// region is in the client region.
+func ParseVPC(s string) (rtypes.VPC, error) {
+	id, region, _ := strings.Cut(s, ":")
+	if !strings.HasPrefix(id, "vpc-") {
+		return rtypes.VPC{}, fmt.Errorf("invalid VPC '%s', expected vpc-id or vpc-id:region", s)
+	}
+	return rtypes.VPC{VPCId: aws.String(id), VPCRegion: rtypes.VPCRegion(region)}, nil
+}
+
+// AssociateVPCs associates vpcs, owned by the account of r, with the zone,
+// skipping the ones already associated. A VPCAssociationError is returned
+// when some VPCs could not be associated.
+func (r *RouteCopy) AssociateVPCs(ctx context.Context, zoneId string, vpcs []rtypes.VPC, maxWait time.Duration) (VPCAssociations, error) {
+	result := VPCAssociations{}
+	zoneVPCs, err := r.ListVPCAssociations(ctx, zoneId)
+	if err != nil {
+		return result, err
+	}
+	existing := map[string]bool{}
+	for _, vpc := range zoneVPCs {
+		existing[vpcString(vpc)] = true
+	}
+
+	for _, vpc := range vpcs {
+		if vpc.VPCRegion == "" {
+			vpc.VPCRegion = rtypes.VPCRegion(r.region)
+		}
+		if existing[vpcString(vpc)] {
+			result.Existing = append(result.Existing, vpc)
+			continue
+		}
+		err := r.AssociateVPCWithHostedZone(ctx, zoneId, vpc, maxWait)
+		if err != nil {
+			logging.From(ctx).Errorf("Failed to associate VPC %s: %s\n", vpcString(vpc), err)
+			result.Failed = append(result.Failed, VPCAssociationFailure{VPC: vpc, Err: err})
+			continue
+		}
+		logging.From(ctx).Infof("Associated VPC %s\n", vpcString(vpc))
+		result.Associated = append(result.Associated, vpc)
+	}
+	if len(result.Failed) > 0 {
+		return result, &VPCAssociationError{Zone: zoneId, Associations: result}
+	}
+	return result, nil
+}
+
+// vpcsString lists vpcs as region/id, or "none".
+func vpcsString(vpcs []rtypes.VPC) string {
+	if len(vpcs) == 0 {
+		return "none"
+	}
+	names := []string{}
+	for _, vpc := range vpcs {
+		names = append(names, vpcString(vpc))
+	}
+	return strings.Join(names, ", ")
+}
+
+// vpcFlags returns the --vpc flags associating vpcs, see ParseVPC.
+func vpcFlags(vpcs []rtypes.VPC) string {
+	flags := []string{}
+	for _, vpc := range vpcs {
+		flags = append(flags, fmt.Sprintf("--vpc %s:%s", aws.ToString(vpc.VPCId), vpc.VPCRegion))
+	}
+	return strings.Join(flags, " ")
+}
+
+// CopyVPCAssociations associates the VPCs of the source zone with the
+// destination zone, skipping the ones already associated. A VPC of the
+// destination account is associated directly. Otherwise dst authorizes the
+// association and src, which should own the VPC, associates it. A
+// VPCAssociationError is returned when some VPCs could not be associated.
+func CopyVPCAssociations(ctx context.Context, src, dst *RouteCopy, srcZoneID, dstZoneID string, dryRun bool, maxWait time.Duration) (VPCAssociations, error) {
+	result := VPCAssociations{}
+	srcVPCs, err := src.ListVPCAssociations(ctx, srcZoneID)
+	if err != nil {
+		return result, err
+	}
+	dstVPCs, err := dst.ListVPCAssociations(ctx, dstZoneID)
+	if err != nil {
+		return result, err
+	}
+	existing := map[string]bool{}
+	for _, vpc := range dstVPCs {
+		existing[vpcString(vpc)] = true
+	}
+
+	for _, vpc := range srcVPCs {
+		if existing[vpcString(vpc)] {
+			result.Existing = append(result.Existing, vpc)
+			continue
+		}
+		if dryRun {
+			logging.From(ctx).Infof("Not associating VPC %s since this is a dry run\n", vpcString(vpc))
+			continue
+		}
+		err := associateVPC(ctx, src, dst, dstZoneID, vpc, maxWait)
+		if err != nil {
+			logging.From(ctx).Errorf("Failed to associate VPC %s: %s\n", vpcString(vpc), err)
+			result.Failed = append(result.Failed, VPCAssociationFailure{VPC: vpc, Err: err})
+			continue
+		}
+		logging.From(ctx).Infof("Associated VPC %s\n", vpcString(vpc))
+		result.Associated = append(result.Associated, vpc)
+	}
+	logging.From(ctx).Infof("%d VPCs associated, %d already associated, %d failed\n",
+		len(result.Associated), len(result.Existing), len(result.Failed))
+	if len(result.Failed) > 0 {
+		return result, &VPCAssociationError{Zone: dstZoneID, Associations: result}
+	}
+	return result, nil
+}
+
+// associateVPC associates vpc with the zone of dst, authorizing src to do it
+// when dst does not own the VPC.
+func associateVPC(ctx context.Context, src, dst *RouteCopy, zoneId string, vpc rtypes.VPC, maxWait time.Duration) error {
+	err := dst.AssociateVPCWithHostedZone(ctx, zoneId, vpc, maxWait)
+	var na *rtypes.NotAuthorizedException
+	if !errors.As(err, &na) {
+		return err
+	}
+	logging.From(ctx).Debugf("VPC %s is not in the account of %s, authorizing %s to associate it\n", vpcString(vpc), dst.profile, src.profile)
+	err = dst.CreateVPCAssociationAuthorization(ctx, zoneId, vpc)
+	if err != nil {
+		return fmt.Errorf("authorizing the association: %w", err)
+	}
+	return src.AssociateVPCWithHostedZone(ctx, zoneId, vpc, maxWait)
+}
+
+func vpcString(vpc rtypes.VPC) string {
+	return fmt.Sprintf("%s/%s", vpc.VPCRegion, aws.ToString(vpc.VPCId))
+}
@@ -0,0 +1,274 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// aliasAnnotation prefixes the comment used to keep alias records in a zone
+// file, since alias records have no standard representation.
+const aliasAnnotation = ";ALIAS"
+
+type UnsupportedRecordTypes struct {
+	Types []string
+}
+
+func (e *UnsupportedRecordTypes) Error() string {
+	return fmt.Sprintf("zone file contains record types not supported by Route53: %s", strings.Join(e.Types, ","))
+}
+
+// RoutingPolicyConflict is returned when importing a zone file would write
+// simple record sets over record sets of the zone that use a routing policy.
+type RoutingPolicyConflict struct {
+	RecordSets []string
+}
+
+func (e *RoutingPolicyConflict) Error() string {
+	return fmt.Sprintf("the zone has record sets with a routing policy that the zone file would overwrite: %s",
+		strings.Join(e.RecordSets, ", "))
+}
+
+// WriteZoneFile writes records as an RFC 1035 zone file for domain. Alias
+// records are written as ";ALIAS name type target hosted-zone-id
+// evaluate-target-health" comments. Zone files have no place for routing
+// policies or health checks, so they are dropped with a warning naming each
+// record set that used them.
+func WriteZoneFile(w io.Writer, domain string, records []rtypes.ResourceRecordSet) error {
+	for _, rs := range RoutingPolicyRecordSets(records) {
+		logging.Warnf("The routing policy of %s cannot be written to a zone file, it is dropped\n", rs)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s\n", normalizeDomain(domain))
+
+	for _, record := range records {
+		if record.AliasTarget != nil {
+			fmt.Fprintf(bw, "%s %s %s %s %s %s\n",
+				aliasAnnotation,
+				DecodeName(aws.ToString(record.Name)),
+				record.Type,
+				aws.ToString(record.AliasTarget.DNSName),
+				aws.ToString(record.AliasTarget.HostedZoneId),
+				strconv.FormatBool(record.AliasTarget.EvaluateTargetHealth),
+			)
+			continue
+		}
+
+		rrs, err := recordSetToRRs(record)
+		if err != nil {
+			return err
+		}
+		for _, rr := range rrs {
+			fmt.Fprintln(bw, rr.String())
+		}
+	}
+	return bw.Flush()
+}
+
+// RoutingPolicyRecordSets returns the record sets of records that use a
+// routing policy or a health check, as "name type" or "name type
+// set-identifier".
+func RoutingPolicyRecordSets(records []rtypes.ResourceRecordSet) []string {
+	names := []string{}
+	for _, rs := range records {
+		if !hasRoutingPolicy(rs) {
+			continue
+		}
+		name := DecodeName(aws.ToString(rs.Name)) + " " + string(rs.Type)
+		if rs.SetIdentifier != nil {
+			name += " " + aws.ToString(rs.SetIdentifier)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// RoutingPolicyConflicts returns the record sets of existing that use a
+// routing policy and have the name and type of a record set of records, as
+// RoutingPolicyRecordSets does. Record sets read from a zone file are simple
+// ones, and upserting them would replace or conflict with those.
+func RoutingPolicyConflicts(records, existing []rtypes.ResourceRecordSet) []string {
+	keys := map[string]bool{}
+	for _, rs := range records {
+		keys[nameTypeKey(rs)] = true
+	}
+	conflicts := []rtypes.ResourceRecordSet{}
+	for _, rs := range existing {
+		if keys[nameTypeKey(rs)] && hasRoutingPolicy(rs) {
+			conflicts = append(conflicts, rs)
+		}
+	}
+	return RoutingPolicyRecordSets(conflicts)
+}
+
+func nameTypeKey(rs rtypes.ResourceRecordSet) string {
+	return strings.ToLower(normalizeDomain(DecodeName(aws.ToString(rs.Name)))) + "|" + string(rs.Type)
+}
+
+// hasRoutingPolicy reports whether rs uses any of the record set fields a zone
+// file cannot represent.
+func hasRoutingPolicy(rs rtypes.ResourceRecordSet) bool {
+	return rs.SetIdentifier != nil ||
+		rs.Weight != nil ||
+		rs.Region != "" ||
+		rs.Failover != "" ||
+		rs.GeoLocation != nil ||
+		rs.MultiValueAnswer != nil ||
+		rs.HealthCheckId != nil ||
+		rs.CidrRoutingConfig != nil
+}
+
+func recordSetToRRs(record rtypes.ResourceRecordSet) ([]dns.RR, error) {
+	rrs := []dns.RR{}
+	for _, value := range record.ResourceRecords {
+		if isTXTType(record.Type) {
+			rr, err := txtRecordToRR(record, aws.ToString(value.Value))
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s %s: %w", aws.ToString(record.Name), record.Type, err)
+			}
+			rrs = append(rrs, rr)
+			continue
+		}
+		line := fmt.Sprintf("%s %d IN %s %s",
+			DecodeName(aws.ToString(record.Name)), aws.ToInt64(record.TTL), record.Type, aws.ToString(value.Value))
+		rr, err := dns.NewRR(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s: %w", aws.ToString(record.Name), record.Type, err)
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// txtRecordToRR converts a TXT or SPF value without going through the zone
+// file parser, which would read its octal escapes as decimal ones.
+func txtRecordToRR(record rtypes.ResourceRecordSet, value string) (dns.RR, error) {
+	strs, err := zoneFileTXT(value)
+	if err != nil {
+		return nil, err
+	}
+	hdr := dns.RR_Header{
+		Name:   dns.Fqdn(DecodeName(aws.ToString(record.Name))),
+		Class:  dns.ClassINET,
+		Ttl:    uint32(aws.ToInt64(record.TTL)),
+		Rrtype: dns.StringToType[string(record.Type)],
+	}
+	if record.Type == rtypes.RRTypeSpf {
+		return &dns.SPF{Hdr: hdr, Txt: strs}, nil
+	}
+	return &dns.TXT{Hdr: hdr, Txt: strs}, nil
+}
+
+// ReadZoneFile parses a zone file for domain into record sets, grouping
+// records with the same name and type into a single record set. Alias
+// comments written by WriteZoneFile are read back as alias record sets.
+func ReadZoneFile(r io.Reader, domain string) ([]rtypes.ResourceRecordSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []rtypes.ResourceRecordSet{}
+	index := map[string]int{}
+	unsupported := map[string]bool{}
+
+	zp := dns.NewZoneParser(bytes.NewReader(data), normalizeDomain(domain), "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		hdr := rr.Header()
+		t := rtypes.RRType(dns.TypeToString[hdr.Rrtype])
+		if !typeInList(rtypes.RRType("").Values(), t) {
+			unsupported[string(t)] = true
+			continue
+		}
+
+		value, err := rrValue(rr)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(hdr.Name) + "|" + string(t)
+		if i, ok := index[key]; ok {
+			records[i].ResourceRecords = append(records[i].ResourceRecords, rtypes.ResourceRecord{
+				Value: aws.String(value),
+			})
+			continue
+		}
+		index[key] = len(records)
+		records = append(records, rtypes.ResourceRecordSet{
+			Name: aws.String(EncodeName(hdr.Name)),
+			Type: t,
+			TTL:  aws.Int64(int64(hdr.Ttl)),
+			ResourceRecords: []rtypes.ResourceRecord{
+				{Value: aws.String(value)},
+			},
+		})
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(unsupported) > 0 {
+		types := []string{}
+		for t := range unsupported {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return nil, &UnsupportedRecordTypes{Types: types}
+	}
+
+	aliases, err := readAliasAnnotations(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(records, aliases...), nil
+}
+
+// rrValue returns the value of rr as a ResourceRecord value, converting the
+// decimal escapes of TXT and SPF strings to the octal ones of Route53.
+func rrValue(rr dns.RR) (string, error) {
+	switch t := rr.(type) {
+	case *dns.TXT:
+		return txtFromZoneFile(t.Txt)
+	case *dns.SPF:
+		return txtFromZoneFile(t.Txt)
+	}
+	return strings.TrimPrefix(rr.String(), rr.Header().String()), nil
+}
+
+func readAliasAnnotations(data []byte) ([]rtypes.ResourceRecordSet, error) {
+	records := []rtypes.ResourceRecordSet{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, aliasAnnotation+" ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, aliasAnnotation))
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("invalid alias annotation: %s", line)
+		}
+		evaluate, err := strconv.ParseBool(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias annotation: %s", line)
+		}
+		records = append(records, rtypes.ResourceRecordSet{
+			Name: aws.String(EncodeName(fields[0])),
+			Type: rtypes.RRType(fields[1]),
+			AliasTarget: &rtypes.AliasTarget{
+				DNSName:              aws.String(fields[2]),
+				HostedZoneId:         aws.String(fields[3]),
+				EvaluateTargetHealth: evaluate,
+			},
+		})
+	}
+	return records, scanner.Err()
+}
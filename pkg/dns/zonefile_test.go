@@ -0,0 +1,156 @@
+package dns
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestZoneFileRoundTrip(t *testing.T) {
+	longTXT := `"` + strings.Repeat("a", 255) + `" "` + strings.Repeat("b", 45) + `"`
+	tests := []struct {
+		name   string
+		record rtypes.ResourceRecordSet
+	}{
+		{"A", recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1", "192.0.2.2")},
+		{"AAAA", recordSet("www.example.com.", rtypes.RRTypeAaaa, "2001:db8::1")},
+		{"CNAME", recordSet("api.example.com.", rtypes.RRTypeCname, "www.example.com.")},
+		{"MX", recordSet("example.com.", rtypes.RRTypeMx, "10 mail.example.com.", "20 mail2.example.com.")},
+		{"SRV", recordSet("_sip._tcp.example.com.", rtypes.RRTypeSrv, "10 5 5060 sip.example.com.")},
+		{"CAA", recordSet("example.com.", rtypes.RRTypeCaa, `0 issue "letsencrypt.org"`, `128 iodef "mailto:security@example.com"`)},
+		{"TXT over 255 bytes", recordSet("example.com.", rtypes.RRTypeTxt, longTXT)},
+		{"alias", rtypes.ResourceRecordSet{
+			Name: aws.String("cdn.example.com."),
+			Type: rtypes.RRTypeA,
+			AliasTarget: &rtypes.AliasTarget{
+				DNSName:              aws.String("d111111abcdef8.cloudfront.net."),
+				HostedZoneId:         aws.String("Z2FDTNDATAQYW2"),
+				EvaluateTargetHealth: true,
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteZoneFile(&buf, "example.com", []rtypes.ResourceRecordSet{tt.record})
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err := ReadZoneFile(&buf, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("read %d record sets, want 1", len(records))
+			}
+			if !reflect.DeepEqual(records[0], tt.record) {
+				t.Errorf("read back\n%s\nwant\n%s", recordString(records[0]), recordString(tt.record))
+			}
+		})
+	}
+}
+
+// recordSet returns a record set of name and type with a TTL of 300 and
+// values.
+func recordSet(name string, t rtypes.RRType, values ...string) rtypes.ResourceRecordSet {
+	rs := rtypes.ResourceRecordSet{Name: aws.String(name), Type: t, TTL: aws.Int64(300)}
+	for _, v := range values {
+		rs.ResourceRecords = append(rs.ResourceRecords, rtypes.ResourceRecord{Value: aws.String(v)})
+	}
+	return rs
+}
+
+func recordString(rs rtypes.ResourceRecordSet) string {
+	var buf bytes.Buffer
+	_ = WriteZoneFile(&buf, "example.com", []rtypes.ResourceRecordSet{rs})
+	return buf.String()
+}
+
+func TestRoutingPolicyRecordSets(t *testing.T) {
+	weighted := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	weighted.SetIdentifier = aws.String("blue")
+	weighted.Weight = aws.Int64(0)
+	checked := recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.2")
+	checked.HealthCheckId = aws.String("abcdef11-2222-3333-4444-555555fedcba")
+	geoAlias := rtypes.ResourceRecordSet{
+		Name:          aws.String("cdn.example.com."),
+		Type:          rtypes.RRTypeA,
+		SetIdentifier: aws.String("eu"),
+		GeoLocation:   &rtypes.GeoLocation{ContinentCode: aws.String("EU")},
+		AliasTarget: &rtypes.AliasTarget{
+			DNSName:      aws.String("d111111abcdef8.cloudfront.net."),
+			HostedZoneId: aws.String("Z2FDTNDATAQYW2"),
+		},
+	}
+	records := []rtypes.ResourceRecordSet{
+		recordSet("mail.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+		weighted,
+		checked,
+		geoAlias,
+	}
+
+	got := RoutingPolicyRecordSets(records)
+	want := []string{"www.example.com. A blue", "api.example.com. A", "cdn.example.com. A eu"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteZoneFile(&buf, "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadZoneFile(&buf, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(records) {
+		t.Errorf("read %d record sets, want the %d written without their routing policies", len(read), len(records))
+	}
+}
+
+func TestRoutingPolicyConflicts(t *testing.T) {
+	blue := recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.1")
+	blue.SetIdentifier = aws.String("blue")
+	blue.Weight = aws.Int64(90)
+	green := recordSet("WWW.example.com", rtypes.RRTypeA, "192.0.2.2")
+	green.SetIdentifier = aws.String("green")
+	green.Weight = aws.Int64(10)
+	existing := []rtypes.ResourceRecordSet{
+		blue,
+		green,
+		recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.3"),
+	}
+	tests := []struct {
+		name    string
+		records []rtypes.ResourceRecordSet
+		want    []string
+	}{
+		{
+			name:    "weighted sets of the same name and type",
+			records: []rtypes.ResourceRecordSet{recordSet("www.example.com.", rtypes.RRTypeA, "192.0.2.9")},
+			want:    []string{"www.example.com. A blue", "WWW.example.com A green"},
+		},
+		{
+			name:    "another type",
+			records: []rtypes.ResourceRecordSet{recordSet("www.example.com.", rtypes.RRTypeAaaa, "2001:db8::1")},
+			want:    []string{},
+		},
+		{
+			name:    "simple existing set",
+			records: []rtypes.ResourceRecordSet{recordSet("api.example.com.", rtypes.RRTypeA, "192.0.2.9")},
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RoutingPolicyConflicts(tt.records, existing)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1287 @@
+// Package fakeroute53 is an in-memory Route53 endpoint for tests. It serves
+// the REST API calls route53copy makes with an httptest server, so the real
+// SDK clients, their pagination and their error handling are exercised
+// without AWS credentials.
+package fakeroute53
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Operations counted by Calls.
+const (
+	OpCreateHostedZone         = "CreateHostedZone"
+	OpDeleteHostedZone         = "DeleteHostedZone"
+	OpGetHostedZone            = "GetHostedZone"
+	OpUpdateHostedZoneComment  = "UpdateHostedZoneComment"
+	OpListHostedZones          = "ListHostedZones"
+	OpListHostedZonesByName    = "ListHostedZonesByName"
+	OpListResourceRecordSets   = "ListResourceRecordSets"
+	OpChangeResourceRecordSets = "ChangeResourceRecordSets"
+	OpGetChange                = "GetChange"
+	OpGetDNSSEC                = "GetDNSSEC"
+	OpGetCallerIdentity        = "GetCallerIdentity"
+	OpAssumeRole               = "AssumeRole"
+	OpListCidrCollections      = "ListCidrCollections"
+	OpCreateCidrCollection     = "CreateCidrCollection"
+	OpChangeCidrCollection     = "ChangeCidrCollection"
+	OpListCidrBlocks           = "ListCidrBlocks"
+	OpCreateHealthCheck        = "CreateHealthCheck"
+	OpGetHealthCheck           = "GetHealthCheck"
+	OpListHealthChecks         = "ListHealthChecks"
+	OpListTagsForResources     = "ListTagsForResources"
+	OpChangeTagsForResource    = "ChangeTagsForResource"
+)
+
+const (
+	// DefaultMaxRecords and DefaultMaxZones are the page sizes Route53 uses
+	// for records and zones.
+	DefaultMaxRecords = 300
+	DefaultMaxZones   = 100
+
+	maxRecordsPerBatch    = 1000
+	maxValueCharsPerBatch = 32000
+)
+
+// Server is a fake Route53 endpoint. Its fields may be changed before the
+// requests they affect.
+type Server struct {
+	// URL is the endpoint of the server, see dns.NewRouteCopyForTest.
+	URL string
+	// MaxRecords and MaxZones cap the pages of the list calls, besides the
+	// maxitems the client asks for.
+	MaxRecords int
+	MaxZones   int
+	// PendingPolls is how many GetChange calls see a change PENDING before
+	// it is INSYNC.
+	PendingPolls int
+	// Account is the account returned by GetCallerIdentity.
+	Account string
+	// Reject, when set, returns the message a change of a record set is
+	// rejected with, or nothing to apply it.
+	Reject func(rs rtypes.ResourceRecordSet) string
+	// Fail, when set, returns the error code a call of operation fails
+	// with, or nothing to serve it.
+	Fail func(operation string) string
+
+	srv   *httptest.Server
+	mu    sync.Mutex
+	zones map[string]*zone
+	// delegationSets are the nameservers of the reusable delegation sets
+	// by id.
+	delegationSets map[string][]string
+	changes        map[string]*change
+	collections    map[string]*cidrCollection
+	healthChecks   map[string]*healthCheck
+	calls          map[string]int
+	// assumedRoles are the AssumeRole calls, and roleKeys the roles by the
+	// access key of the credentials they returned.
+	assumedRoles []AssumedRole
+	roleKeys     map[string]AssumedRole
+	nextID       int
+}
+
+// AssumedRole is an AssumeRole call and the access key it was signed with.
+type AssumedRole struct {
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	AccessKeyID string
+}
+
+type zone struct {
+	id              string
+	name            string
+	callerReference string
+	comment         string
+	private         bool
+	vpcs            []xmlVPC
+	delegationSetID string
+	nameServers     []string
+	records         []rtypes.ResourceRecordSet
+}
+
+type cidrCollection struct {
+	id              string
+	name            string
+	callerReference string
+	version         int64
+	// blocks are the CIDR blocks by location.
+	blocks map[string][]string
+}
+
+type healthCheck struct {
+	id              string
+	callerReference string
+	config          xmlHealthCheckConfig
+	tags            []xmlTag
+}
+
+type change struct {
+	id          string
+	comment     string
+	status      rtypes.ChangeStatus
+	submittedAt time.Time
+	polls       int
+}
+
+// apiError is an error response of the Route53 API.
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+// NewServer starts a fake Route53 endpoint without zones. It must be closed
+// with Close.
+func NewServer() *Server {
+	s := &Server{
+		MaxRecords:     DefaultMaxRecords,
+		MaxZones:       DefaultMaxZones,
+		Account:        "123456789012",
+		zones:          map[string]*zone{},
+		delegationSets: map[string][]string{},
+		changes:        map[string]*change{},
+		collections:    map[string]*cidrCollection{},
+		healthChecks:   map[string]*healthCheck{},
+		calls:          map[string]int{},
+		roleKeys:       map[string]AssumedRole{},
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// AddZone creates a zone with its apex NS and SOA records, as
+// CreateHostedZone does, and returns its id without the "/hostedzone/"
+// prefix.
+func (s *Server) AddZone(name string, private bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z := s.addZone(name, "", private)
+	if private {
+		z.vpcs = []xmlVPC{{VPCRegion: "us-east-1", VPCId: "vpc-" + z.id}}
+	}
+	return z.id
+}
+
+// AddRecords adds record sets to a zone, replacing the ones with the same
+// name, type and set identifier. It panics when the zone does not exist.
+func (s *Server) AddRecords(zoneID string, records ...rtypes.ResourceRecordSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[shortID(zoneID)]
+	if !ok {
+		panic("fakeroute53: no zone " + zoneID)
+	}
+	for _, rs := range records {
+		rs.Name = aws.String(normalizeName(aws.ToString(rs.Name)))
+		if i := z.find(rs); i >= 0 {
+			z.records[i] = rs
+			continue
+		}
+		z.records = append(z.records, rs)
+	}
+	z.sort()
+}
+
+// Records returns the record sets of a zone, in the order Route53 lists
+// them, or nil when the zone does not exist.
+func (s *Server) Records(zoneID string) []rtypes.ResourceRecordSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[shortID(zoneID)]
+	if !ok {
+		return nil
+	}
+	return append([]rtypes.ResourceRecordSet{}, z.records...)
+}
+
+// SetComment sets the comment of a zone. It panics when the zone does not
+// exist.
+func (s *Server) SetComment(zoneID, comment string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[shortID(zoneID)]
+	if !ok {
+		panic("fakeroute53: no zone " + zoneID)
+	}
+	z.comment = comment
+}
+
+// AddDelegationSet creates a reusable delegation set and returns its id
+// without the "/delegationset/" prefix.
+func (s *Server) AddDelegationSet() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := fmt.Sprintf("N%012d", s.nextID)
+	for i := 1; i <= 4; i++ {
+		s.delegationSets[id] = append(s.delegationSets[id], fmt.Sprintf("ns%d.white-label-%d.test", i, s.nextID))
+	}
+	return id
+}
+
+// FindZone returns the ids of the zones named name.
+func (s *Server) FindZone(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := []string{}
+	for _, z := range s.sortedZones() {
+		if z.name == normalizeName(name) {
+			ids = append(ids, z.id)
+		}
+	}
+	return ids
+}
+
+// AddCidrCollection creates a CIDR collection with blocks by location and
+// returns its id.
+func (s *Server) AddCidrCollection(name string, blocks map[string][]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.addCidrCollection(name, "")
+	for location, b := range blocks {
+		c.blocks[location] = append([]string{}, b...)
+	}
+	return c.id
+}
+
+// CidrCollection returns the id and the blocks by location of the CIDR
+// collection named name, or an empty id when there is none.
+func (s *Server) CidrCollection(name string) (string, map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.collections {
+		if c.name != name {
+			continue
+		}
+		blocks := map[string][]string{}
+		for location, b := range c.blocks {
+			blocks[location] = append([]string{}, b...)
+		}
+		return c.id, blocks
+	}
+	return "", nil
+}
+
+// AddHealthCheck creates a health check with tags and returns its id.
+func (s *Server) AddHealthCheck(cfg rtypes.HealthCheckConfig, tags map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hc := s.addHealthCheck("", toXMLHealthCheckConfig(cfg))
+	keys := []string{}
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hc.tags = append(hc.tags, xmlTag{Key: key, Value: tags[key]})
+	}
+	return hc.id
+}
+
+// HealthChecks returns the configs of the health checks by id.
+func (s *Server) HealthChecks() map[string]rtypes.HealthCheckConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := map[string]rtypes.HealthCheckConfig{}
+	for id, hc := range s.healthChecks {
+		configs[id] = fromXMLHealthCheckConfig(hc.config)
+	}
+	return configs
+}
+
+// HealthCheckTags returns the tags of a health check, or nil when it does
+// not exist.
+func (s *Server) HealthCheckTags(id string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hc, ok := s.healthChecks[id]
+	if !ok {
+		return nil
+	}
+	tags := map[string]string{}
+	for _, tag := range hc.tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+// AssumedRoles returns the AssumeRole calls in order.
+func (s *Server) AssumedRoles() []AssumedRole {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AssumedRole{}, s.assumedRoles...)
+}
+
+// Calls returns how many times operation was called.
+func (s *Server) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[operation]
+}
+
+func (s *Server) addZone(name, callerReference string, private bool) *zone {
+	s.nextID++
+	z := &zone{
+		id:              fmt.Sprintf("Z%012d", s.nextID),
+		name:            normalizeName(name),
+		callerReference: callerReference,
+		private:         private,
+	}
+	nameServers := []string{}
+	for i := 1; i <= 4; i++ {
+		nameServers = append(nameServers, fmt.Sprintf("ns-%d.fake-route53-%d.test", s.nextID*4+i, i))
+	}
+	z.setNameServers(nameServers)
+	s.zones[z.id] = z
+	return z
+}
+
+// setNameServers sets the nameservers of a new zone, and its apex NS and SOA
+// records.
+func (z *zone) setNameServers(nameServers []string) {
+	z.nameServers = nameServers
+	values := []rtypes.ResourceRecord{}
+	for _, ns := range z.nameServers {
+		values = append(values, rtypes.ResourceRecord{Value: aws.String(ns + ".")})
+	}
+	z.records = []rtypes.ResourceRecordSet{
+		{Name: aws.String(z.name), Type: rtypes.RRTypeNs, TTL: aws.Int64(172800), ResourceRecords: values},
+		{Name: aws.String(z.name), Type: rtypes.RRTypeSoa, TTL: aws.Int64(900), ResourceRecords: []rtypes.ResourceRecord{{
+			Value: aws.String(z.nameServers[0] + ". hostmaster.fake-route53.test. 1 7200 900 1209600 86400"),
+		}}},
+	}
+}
+
+func (s *Server) addCidrCollection(name, callerReference string) *cidrCollection {
+	s.nextID++
+	c := &cidrCollection{
+		id:              fmt.Sprintf("%08d-0000-4000-8000-000000000000", s.nextID),
+		name:            name,
+		callerReference: callerReference,
+		version:         1,
+		blocks:          map[string][]string{},
+	}
+	s.collections[c.id] = c
+	return c
+}
+
+func (s *Server) addHealthCheck(callerReference string, config xmlHealthCheckConfig) *healthCheck {
+	s.nextID++
+	hc := &healthCheck{
+		id:              fmt.Sprintf("%08d-0000-4000-8000-00000000000c", s.nextID),
+		callerReference: callerReference,
+		config:          config,
+	}
+	s.healthChecks[hc.id] = hc
+	return hc
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.URL.Path == "/" && req.Method == http.MethodPost {
+		_ = req.ParseForm()
+		switch req.PostForm.Get("Action") {
+		case "GetCallerIdentity":
+			if err := s.call(OpGetCallerIdentity); err != nil {
+				writeXML(w, err.status, errorResponse{Xmlns: stsNamespace, Type: "Sender", Code: err.code, Message: err.message, RequestId: s.requestID()})
+				return
+			}
+			arn := fmt.Sprintf("arn:aws:iam::%s:user/fake", s.Account)
+			if role, ok := s.roleKeys[accessKeyID(req)]; ok {
+				arn = fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", s.Account, roleName(role.RoleARN), role.SessionName)
+			}
+			writeXML(w, http.StatusOK, getCallerIdentityResponse{
+				Xmlns:   stsNamespace,
+				Arn:     arn,
+				UserId:  "FAKE",
+				Account: s.Account,
+			})
+			return
+		case "AssumeRole":
+			if err := s.call(OpAssumeRole); err != nil {
+				writeXML(w, err.status, errorResponse{Xmlns: stsNamespace, Type: "Sender", Code: err.code, Message: err.message, RequestId: s.requestID()})
+				return
+			}
+			s.assumeRole(w, req)
+			return
+		}
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/2013-04-01/"), "/")
+	// The client does not strip the prefix of change ids, which Route53
+	// accepts in the path.
+	path = strings.NewReplacer("hostedzone//hostedzone/", "hostedzone/", "change//change/", "change/").Replace(path)
+	parts := strings.Split(path, "/")
+	var err *apiError
+	switch {
+	case path == "hostedzone" && req.Method == http.MethodGet:
+		err = s.listHostedZones(w, req)
+	case path == "hostedzone" && req.Method == http.MethodPost:
+		err = s.createHostedZone(w, req)
+	case path == "hostedzonesbyname" && req.Method == http.MethodGet:
+		err = s.listHostedZonesByName(w, req)
+	case len(parts) == 2 && parts[0] == "hostedzone" && req.Method == http.MethodGet:
+		err = s.getHostedZone(w, parts[1])
+	case len(parts) == 2 && parts[0] == "hostedzone" && req.Method == http.MethodPost:
+		err = s.updateHostedZoneComment(w, req, parts[1])
+	case len(parts) == 2 && parts[0] == "hostedzone" && req.Method == http.MethodDelete:
+		err = s.deleteHostedZone(w, parts[1])
+	case len(parts) == 3 && parts[0] == "hostedzone" && parts[2] == "rrset" && req.Method == http.MethodGet:
+		err = s.listResourceRecordSets(w, req, parts[1])
+	case len(parts) == 3 && parts[0] == "hostedzone" && parts[2] == "rrset" && req.Method == http.MethodPost:
+		err = s.changeResourceRecordSets(w, req, parts[1])
+	case len(parts) == 3 && parts[0] == "hostedzone" && parts[2] == "dnssec" && req.Method == http.MethodGet:
+		err = s.getDNSSEC(w, parts[1])
+	case len(parts) == 2 && parts[0] == "change" && req.Method == http.MethodGet:
+		err = s.getChange(w, parts[1])
+	case path == "cidrcollection" && req.Method == http.MethodGet:
+		err = s.listCidrCollections(w)
+	case path == "cidrcollection" && req.Method == http.MethodPost:
+		err = s.createCidrCollection(w, req)
+	case len(parts) == 2 && parts[0] == "cidrcollection" && req.Method == http.MethodPost:
+		err = s.changeCidrCollection(w, req, parts[1])
+	case len(parts) == 3 && parts[0] == "cidrcollection" && parts[2] == "cidrblocks" && req.Method == http.MethodGet:
+		err = s.listCidrBlocks(w, parts[1])
+	case path == "healthcheck" && req.Method == http.MethodGet:
+		err = s.listHealthChecks(w)
+	case path == "healthcheck" && req.Method == http.MethodPost:
+		err = s.createHealthCheck(w, req)
+	case len(parts) == 2 && parts[0] == "healthcheck" && req.Method == http.MethodGet:
+		err = s.getHealthCheck(w, parts[1])
+	case path == "tags/healthcheck" && req.Method == http.MethodPost:
+		err = s.listTagsForResources(w, req)
+	case len(parts) == 3 && parts[0] == "tags" && parts[1] == "healthcheck" && req.Method == http.MethodPost:
+		err = s.changeTagsForResource(w, req, parts[2])
+	default:
+		err = &apiError{http.StatusNotFound, "UnknownOperation", fmt.Sprintf("%s %s is not implemented by the fake", req.Method, req.URL.Path)}
+	}
+	if err != nil {
+		writeXML(w, err.status, errorResponse{Xmlns: namespace, Type: "Sender", Code: err.code, Message: err.message, RequestId: s.requestID()})
+	}
+}
+
+func (s *Server) listHostedZones(w http.ResponseWriter, req *http.Request) *apiError {
+	if err := s.call(OpListHostedZones); err != nil {
+		return err
+	}
+	zones := s.sortedZones()
+	sort.Slice(zones, func(i, j int) bool { return zones[i].id < zones[j].id })
+
+	marker := req.URL.Query().Get("marker")
+	start := sort.Search(len(zones), func(i int) bool { return zones[i].id >= marker })
+	end, truncated := pageEnd(start, len(zones), maxItems(req, s.MaxZones))
+	resp := listHostedZonesResponse{Xmlns: namespace, Marker: marker, MaxItems: end - start, IsTruncated: truncated}
+	for _, z := range zones[start:end] {
+		resp.HostedZones = append(resp.HostedZones, z.xml())
+	}
+	if truncated {
+		resp.NextMarker = zones[end].id
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) listHostedZonesByName(w http.ResponseWriter, req *http.Request) *apiError {
+	if err := s.call(OpListHostedZonesByName); err != nil {
+		return err
+	}
+	zones := s.sortedZones()
+
+	query := req.URL.Query()
+	dnsName, zoneID := query.Get("dnsname"), query.Get("hostedzoneid")
+	start := 0
+	if dnsName != "" {
+		key := sortName(normalizeName(dnsName))
+		start = sort.Search(len(zones), func(i int) bool {
+			k := sortName(zones[i].name)
+			return k > key || k == key && zones[i].id >= zoneID
+		})
+	}
+	end, truncated := pageEnd(start, len(zones), maxItems(req, s.MaxZones))
+	resp := listHostedZonesByNameResponse{Xmlns: namespace, DNSName: dnsName, HostedZoneId: zoneID, MaxItems: end - start, IsTruncated: truncated}
+	for _, z := range zones[start:end] {
+		resp.HostedZones = append(resp.HostedZones, z.xml())
+	}
+	if truncated {
+		resp.NextDNSName = zones[end].name
+		resp.NextHostedZoneId = zones[end].id
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) createHostedZone(w http.ResponseWriter, req *http.Request) *apiError {
+	if err := s.call(OpCreateHostedZone); err != nil {
+		return err
+	}
+	var body createHostedZoneRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	for _, z := range s.zones {
+		if z.callerReference != "" && z.callerReference == body.CallerReference {
+			return &apiError{http.StatusConflict, "HostedZoneAlreadyExists", "A hosted zone has already been created with the specified caller reference."}
+		}
+	}
+	private := body.HostedZoneConfig != nil && body.HostedZoneConfig.PrivateZone
+	if private && body.VPC == nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", "A private hosted zone requires a VPC."}
+	}
+	if !private && body.VPC != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", "A public hosted zone cannot be associated with a VPC."}
+	}
+
+	var nameServers []string
+	if body.DelegationSetId != "" {
+		if private {
+			return &apiError{http.StatusBadRequest, "InvalidInput", "A private hosted zone cannot use a reusable delegation set."}
+		}
+		var ok bool
+		nameServers, ok = s.delegationSets[strings.TrimPrefix(body.DelegationSetId, "/delegationset/")]
+		if !ok {
+			return &apiError{http.StatusBadRequest, "NoSuchDelegationSet", "No delegation set found with ID: " + body.DelegationSetId}
+		}
+	}
+
+	z := s.addZone(body.Name, body.CallerReference, private)
+	if body.HostedZoneConfig != nil {
+		z.comment = body.HostedZoneConfig.Comment
+	}
+	if nameServers != nil {
+		z.delegationSetID = strings.TrimPrefix(body.DelegationSetId, "/delegationset/")
+		z.setNameServers(nameServers)
+	}
+	resp := createHostedZoneResponse{
+		Xmlns:      namespace,
+		HostedZone: z.xml(),
+		ChangeInfo: toXMLChangeInfo(*s.newChange("")),
+	}
+	if private {
+		z.vpcs = []xmlVPC{*body.VPC}
+		resp.VPC = body.VPC
+	} else {
+		resp.DelegationSet = z.xmlDelegationSet()
+	}
+	w.Header().Set("Location", s.URL+"/2013-04-01/hostedzone/"+z.id)
+	writeXML(w, http.StatusCreated, resp)
+	return nil
+}
+
+func (s *Server) getHostedZone(w http.ResponseWriter, id string) *apiError {
+	if err := s.call(OpGetHostedZone); err != nil {
+		return err
+	}
+	z, err := s.zone(id)
+	if err != nil {
+		return err
+	}
+	resp := getHostedZoneResponse{Xmlns: namespace, HostedZone: z.xml(), VPCs: z.vpcs}
+	if !z.private {
+		resp.DelegationSet = z.xmlDelegationSet()
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) updateHostedZoneComment(w http.ResponseWriter, req *http.Request, id string) *apiError {
+	if err := s.call(OpUpdateHostedZoneComment); err != nil {
+		return err
+	}
+	z, err := s.zone(id)
+	if err != nil {
+		return err
+	}
+	var body updateHostedZoneCommentRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	z.comment = body.Comment
+	writeXML(w, http.StatusOK, updateHostedZoneCommentResponse{Xmlns: namespace, HostedZone: z.xml()})
+	return nil
+}
+
+func (s *Server) deleteHostedZone(w http.ResponseWriter, id string) *apiError {
+	if err := s.call(OpDeleteHostedZone); err != nil {
+		return err
+	}
+	z, err := s.zone(id)
+	if err != nil {
+		return err
+	}
+	for _, rs := range z.records {
+		if !z.isApex(rs) {
+			return &apiError{http.StatusBadRequest, "HostedZoneNotEmpty",
+				"The specified hosted zone contains non-required resource record sets and so cannot be deleted."}
+		}
+	}
+	delete(s.zones, z.id)
+	writeXML(w, http.StatusOK, deleteHostedZoneResponse{Xmlns: namespace, ChangeInfo: toXMLChangeInfo(*s.newChange(""))})
+	return nil
+}
+
+func (s *Server) listResourceRecordSets(w http.ResponseWriter, req *http.Request, id string) *apiError {
+	if err := s.call(OpListResourceRecordSets); err != nil {
+		return err
+	}
+	z, err := s.zone(id)
+	if err != nil {
+		return err
+	}
+
+	query := req.URL.Query()
+	start := 0
+	if name := query.Get("name"); name != "" {
+		key := recordKey(normalizeName(name), query.Get("type"), query.Get("identifier"))
+		start = sort.Search(len(z.records), func(i int) bool {
+			return recordSetKey(z.records[i]) >= key
+		})
+	} else if query.Get("type") != "" {
+		return &apiError{http.StatusBadRequest, "InvalidInput", "The type parameter requires the name parameter."}
+	}
+	end, truncated := pageEnd(start, len(z.records), maxItems(req, s.MaxRecords))
+	resp := listResourceRecordSetsResponse{Xmlns: namespace, MaxItems: end - start, IsTruncated: truncated}
+	for _, rs := range z.records[start:end] {
+		resp.ResourceRecordSets = append(resp.ResourceRecordSets, toXMLRecordSet(rs))
+	}
+	if truncated {
+		next := z.records[end]
+		resp.NextRecordName = aws.ToString(next.Name)
+		resp.NextRecordType = string(next.Type)
+		resp.NextRecordIdentifier = aws.ToString(next.SetIdentifier)
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) changeResourceRecordSets(w http.ResponseWriter, req *http.Request, id string) *apiError {
+	if err := s.call(OpChangeResourceRecordSets); err != nil {
+		return err
+	}
+	z, err := s.zone(id)
+	if err != nil {
+		return err
+	}
+	var body changeResourceRecordSetsRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	if len(body.Changes) == 0 {
+		return &apiError{http.StatusBadRequest, "InvalidInput", "The request must contain at least one change."}
+	}
+
+	messages := batchLimitMessages(body.Changes)
+	records := append([]rtypes.ResourceRecordSet{}, z.records...)
+	for _, c := range body.Changes {
+		rs := fromXMLRecordSet(c.ResourceRecordSet)
+		rs.Name = aws.String(normalizeName(aws.ToString(rs.Name)))
+		if cidr := rs.CidrRoutingConfig; cidr != nil && s.collections[aws.ToString(cidr.CollectionId)] == nil {
+			messages = append(messages, fmt.Sprintf("CIDR collection %s of %s does not exist", aws.ToString(cidr.CollectionId), describe(rs)))
+			continue
+		}
+		if s.Reject != nil {
+			if message := s.Reject(rs); message != "" {
+				messages = append(messages, message)
+				continue
+			}
+		}
+		var message string
+		records, message = z.apply(records, rtypes.ChangeAction(c.Action), rs)
+		if message != "" {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) > 0 {
+		writeXML(w, http.StatusBadRequest, invalidChangeBatchResponse{Xmlns: namespace, Messages: messages, RequestId: s.requestID()})
+		return nil
+	}
+
+	z.records = records
+	z.sort()
+	writeXML(w, http.StatusOK, changeResourceRecordSetsResponse{Xmlns: namespace, ChangeInfo: toXMLChangeInfo(*s.newChange(body.Comment))})
+	return nil
+}
+
+func (s *Server) getChange(w http.ResponseWriter, id string) *apiError {
+	if err := s.call(OpGetChange); err != nil {
+		return err
+	}
+	c, ok := s.changes[strings.TrimPrefix(id, "/change/")]
+	if !ok {
+		return &apiError{http.StatusNotFound, "NoSuchChange", "A change with the specified change ID does not exist."}
+	}
+	c.polls++
+	if c.polls > s.PendingPolls {
+		c.status = rtypes.ChangeStatusInsync
+	}
+	writeXML(w, http.StatusOK, getChangeResponse{Xmlns: namespace, ChangeInfo: toXMLChangeInfo(*c)})
+	return nil
+}
+
+// getDNSSEC reports every zone as not signed.
+func (s *Server) getDNSSEC(w http.ResponseWriter, id string) *apiError {
+	if err := s.call(OpGetDNSSEC); err != nil {
+		return err
+	}
+	if _, err := s.zone(id); err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, getDNSSECResponse{Xmlns: namespace, ServeSignature: "NOT_SIGNING"})
+	return nil
+}
+
+// listCidrCollections lists every collection in a single page.
+func (s *Server) listCidrCollections(w http.ResponseWriter) *apiError {
+	if err := s.call(OpListCidrCollections); err != nil {
+		return err
+	}
+	resp := listCidrCollectionsResponse{Xmlns: namespace}
+	for _, c := range s.sortedCidrCollections() {
+		resp.CidrCollections = append(resp.CidrCollections, c.xml())
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) createCidrCollection(w http.ResponseWriter, req *http.Request) *apiError {
+	if err := s.call(OpCreateCidrCollection); err != nil {
+		return err
+	}
+	var body createCidrCollectionRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	for _, c := range s.collections {
+		if c.name == body.Name || c.callerReference == body.CallerReference {
+			return &apiError{http.StatusConflict, "CidrCollectionAlreadyExistsException", "A CIDR collection with this name or caller reference already exists."}
+		}
+	}
+	c := s.addCidrCollection(body.Name, body.CallerReference)
+	w.Header().Set("Location", s.URL+"/2013-04-01/cidrcollection/"+c.id)
+	writeXML(w, http.StatusCreated, createCidrCollectionResponse{Xmlns: namespace, Collection: c.xml()})
+	return nil
+}
+
+func (s *Server) changeCidrCollection(w http.ResponseWriter, req *http.Request, id string) *apiError {
+	if err := s.call(OpChangeCidrCollection); err != nil {
+		return err
+	}
+	c, err := s.cidrCollection(id)
+	if err != nil {
+		return err
+	}
+	var body changeCidrCollectionRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	if body.CollectionVersion != nil && *body.CollectionVersion != c.version {
+		return &apiError{http.StatusConflict, "CidrCollectionVersionMismatchException", "The CIDR collection version does not match."}
+	}
+	for _, change := range body.Changes {
+		blocks := c.blocks[change.LocationName]
+		for _, cidr := range change.CidrList {
+			i := indexOf(blocks, cidr)
+			switch {
+			case change.Action == string(rtypes.CidrCollectionChangeActionPut) && i < 0:
+				blocks = append(blocks, cidr)
+			case change.Action == string(rtypes.CidrCollectionChangeActionDeleteIfExists) && i >= 0:
+				blocks = append(blocks[:i:i], blocks[i+1:]...)
+			}
+		}
+		c.blocks[change.LocationName] = blocks
+	}
+	c.version++
+	writeXML(w, http.StatusOK, changeCidrCollectionResponse{Xmlns: namespace, Id: s.newChange("").id})
+	return nil
+}
+
+// listCidrBlocks lists every block of a collection in a single page.
+func (s *Server) listCidrBlocks(w http.ResponseWriter, id string) *apiError {
+	if err := s.call(OpListCidrBlocks); err != nil {
+		return err
+	}
+	c, err := s.cidrCollection(id)
+	if err != nil {
+		return err
+	}
+	locations := []string{}
+	for location := range c.blocks {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	resp := listCidrBlocksResponse{Xmlns: namespace}
+	for _, location := range locations {
+		for _, cidr := range c.blocks[location] {
+			resp.CidrBlocks = append(resp.CidrBlocks, xmlCidrBlock{CidrBlock: cidr, LocationName: location})
+		}
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+// listHealthChecks lists every health check in a single page.
+func (s *Server) listHealthChecks(w http.ResponseWriter) *apiError {
+	if err := s.call(OpListHealthChecks); err != nil {
+		return err
+	}
+	ids := []string{}
+	for id := range s.healthChecks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	resp := listHealthChecksResponse{Xmlns: namespace, MaxItems: len(ids)}
+	for _, id := range ids {
+		resp.HealthChecks = append(resp.HealthChecks, s.healthChecks[id].xml())
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+// createHealthCheck returns the existing health check for a caller
+// reference used before with the same config, as Route53 does.
+func (s *Server) createHealthCheck(w http.ResponseWriter, req *http.Request) *apiError {
+	if err := s.call(OpCreateHealthCheck); err != nil {
+		return err
+	}
+	var body createHealthCheckRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	for _, hc := range s.healthChecks {
+		if hc.callerReference != body.CallerReference {
+			continue
+		}
+		if !reflect.DeepEqual(hc.config, body.HealthCheckConfig) {
+			return &apiError{http.StatusConflict, "HealthCheckAlreadyExists", "A health check with this caller reference and a different config already exists."}
+		}
+		writeXML(w, http.StatusCreated, createHealthCheckResponse{Xmlns: namespace, HealthCheck: hc.xml()})
+		return nil
+	}
+	for _, child := range body.HealthCheckConfig.ChildHealthChecks {
+		if _, ok := s.healthChecks[child]; !ok {
+			return &apiError{http.StatusNotFound, "NoSuchHealthCheck", "No health check exists with the ID " + child}
+		}
+	}
+	hc := s.addHealthCheck(body.CallerReference, body.HealthCheckConfig)
+	w.Header().Set("Location", s.URL+"/2013-04-01/healthcheck/"+hc.id)
+	writeXML(w, http.StatusCreated, createHealthCheckResponse{Xmlns: namespace, HealthCheck: hc.xml()})
+	return nil
+}
+
+func (s *Server) getHealthCheck(w http.ResponseWriter, id string) *apiError {
+	if err := s.call(OpGetHealthCheck); err != nil {
+		return err
+	}
+	hc, err := s.healthCheck(id)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, getHealthCheckResponse{Xmlns: namespace, HealthCheck: hc.xml()})
+	return nil
+}
+
+func (s *Server) listTagsForResources(w http.ResponseWriter, req *http.Request) *apiError {
+	if err := s.call(OpListTagsForResources); err != nil {
+		return err
+	}
+	var body listTagsForResourcesRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	resp := listTagsForResourcesResponse{Xmlns: namespace}
+	for _, id := range body.ResourceIds {
+		hc, err := s.healthCheck(id)
+		if err != nil {
+			return err
+		}
+		resp.ResourceTagSets = append(resp.ResourceTagSets, xmlResourceTagSet{ResourceType: "healthcheck", ResourceId: id, Tags: hc.tags})
+	}
+	writeXML(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) changeTagsForResource(w http.ResponseWriter, req *http.Request, id string) *apiError {
+	if err := s.call(OpChangeTagsForResource); err != nil {
+		return err
+	}
+	hc, err := s.healthCheck(id)
+	if err != nil {
+		return err
+	}
+	var body changeTagsForResourceRequest
+	if err := xml.NewDecoder(req.Body).Decode(&body); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidInput", err.Error()}
+	}
+	tags := []xmlTag{}
+	for _, tag := range hc.tags {
+		if indexOf(body.RemoveTagKeys, tag.Key) < 0 && !hasTag(body.AddTags, tag.Key) {
+			tags = append(tags, tag)
+		}
+	}
+	hc.tags = append(tags, body.AddTags...)
+	writeXML(w, http.StatusOK, changeTagsForResourceResponse{Xmlns: namespace})
+	return nil
+}
+
+func (s *Server) healthCheck(id string) (*healthCheck, *apiError) {
+	hc, ok := s.healthChecks[id]
+	if !ok {
+		return nil, &apiError{http.StatusNotFound, "NoSuchHealthCheck", "No health check exists with the ID " + id}
+	}
+	return hc, nil
+}
+
+// call counts a call of operation, and returns the error it fails with when
+// Fail says so.
+func (s *Server) call(operation string) *apiError {
+	s.calls[operation]++
+	if s.Fail == nil {
+		return nil
+	}
+	if code := s.Fail(operation); code != "" {
+		return &apiError{http.StatusBadRequest, code, operation + " failed by the test"}
+	}
+	return nil
+}
+
+func (s *Server) cidrCollection(id string) (*cidrCollection, *apiError) {
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, &apiError{http.StatusNotFound, "NoSuchCidrCollectionException", "No CIDR collection found with ID: " + id}
+	}
+	return c, nil
+}
+
+func (s *Server) sortedCidrCollections() []*cidrCollection {
+	collections := []*cidrCollection{}
+	for _, c := range s.collections {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].name < collections[j].name })
+	return collections
+}
+
+func (s *Server) zone(id string) (*zone, *apiError) {
+	z, ok := s.zones[shortID(id)]
+	if !ok {
+		return nil, &apiError{http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: " + shortID(id)}
+	}
+	return z, nil
+}
+
+// sortedZones returns the zones in the order ListHostedZonesByName lists
+// them.
+func (s *Server) sortedZones() []*zone {
+	zones := []*zone{}
+	for _, z := range s.zones {
+		zones = append(zones, z)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		ki, kj := sortName(zones[i].name), sortName(zones[j].name)
+		return ki < kj || ki == kj && zones[i].id < zones[j].id
+	})
+	return zones
+}
+
+func (s *Server) newChange(comment string) *change {
+	s.nextID++
+	c := &change{
+		id:          fmt.Sprintf("C%012d", s.nextID),
+		comment:     comment,
+		status:      rtypes.ChangeStatusPending,
+		submittedAt: time.Now().UTC(),
+	}
+	s.changes[c.id] = c
+	return c
+}
+
+// assumeRole returns temporary credentials for the role, which
+// GetCallerIdentity reports as the assumed role.
+func (s *Server) assumeRole(w http.ResponseWriter, req *http.Request) {
+	role := AssumedRole{
+		RoleARN:     req.PostForm.Get("RoleArn"),
+		ExternalID:  req.PostForm.Get("ExternalId"),
+		SessionName: req.PostForm.Get("RoleSessionName"),
+		AccessKeyID: accessKeyID(req),
+	}
+	s.assumedRoles = append(s.assumedRoles, role)
+	s.nextID++
+	key := fmt.Sprintf("ASIAFAKE%012d", s.nextID)
+	s.roleKeys[key] = role
+	writeXML(w, http.StatusOK, assumeRoleResponse{
+		Xmlns:           stsNamespace,
+		AccessKeyId:     key,
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		Arn:             fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", s.Account, roleName(role.RoleARN), role.SessionName),
+		AssumedRoleId:   "AROAFAKE:" + role.SessionName,
+		RequestId:       s.requestID(),
+	})
+}
+
+// roleName returns the name of the role in arn.
+func roleName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// accessKeyID returns the access key req is signed with.
+func accessKeyID(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	i := strings.Index(auth, "Credential=")
+	if i < 0 {
+		return ""
+	}
+	return strings.SplitN(auth[i+len("Credential="):], "/", 2)[0]
+}
+
+func (s *Server) requestID() string {
+	s.nextID++
+	return fmt.Sprintf("fake-%d", s.nextID)
+}
+
+func (z *zone) xml() xmlHostedZone {
+	return xmlHostedZone{
+		Id:                     "/hostedzone/" + z.id,
+		Name:                   z.name,
+		CallerReference:        z.callerReference,
+		Config:                 &xmlZoneConfig{Comment: z.comment, PrivateZone: z.private},
+		ResourceRecordSetCount: int64(len(z.records)),
+	}
+}
+
+func (z *zone) xmlDelegationSet() *xmlDelegationSet {
+	return &xmlDelegationSet{Id: z.delegationSetID, NameServers: z.nameServers}
+}
+
+func (c *cidrCollection) xml() xmlCidrCollection {
+	return xmlCidrCollection{
+		Arn:     "arn:aws:route53:::cidrcollection/" + c.id,
+		Id:      c.id,
+		Name:    c.name,
+		Version: c.version,
+	}
+}
+
+func (hc *healthCheck) xml() xmlHealthCheck {
+	return xmlHealthCheck{
+		Id:                 hc.id,
+		CallerReference:    hc.callerReference,
+		HealthCheckConfig:  hc.config,
+		HealthCheckVersion: 1,
+	}
+}
+
+func (z *zone) isApex(rs rtypes.ResourceRecordSet) bool {
+	return aws.ToString(rs.Name) == z.name && (rs.Type == rtypes.RRTypeNs || rs.Type == rtypes.RRTypeSoa)
+}
+
+func (z *zone) find(rs rtypes.ResourceRecordSet) int {
+	return findRecordSet(z.records, rs)
+}
+
+func (z *zone) sort() {
+	sort.Slice(z.records, func(i, j int) bool {
+		return recordSetKey(z.records[i]) < recordSetKey(z.records[j])
+	})
+}
+
+// apply applies a change to records, or returns the message Route53 rejects
+// it with.
+func (z *zone) apply(records []rtypes.ResourceRecordSet, action rtypes.ChangeAction, rs rtypes.ResourceRecordSet) ([]rtypes.ResourceRecordSet, string) {
+	name := aws.ToString(rs.Name)
+	if name != z.name && !strings.HasSuffix(name, "."+z.name) {
+		return records, fmt.Sprintf("RRSet with DNS name %s is not permitted in zone %s", name, z.name)
+	}
+	i := findRecordSet(records, rs)
+	switch action {
+	case rtypes.ChangeActionCreate:
+		if i >= 0 {
+			return records, fmt.Sprintf("Tried to create resource record set %s but it already exists", describe(rs))
+		}
+		return append(records, rs), ""
+	case rtypes.ChangeActionUpsert:
+		if i >= 0 {
+			records[i] = rs
+			return records, ""
+		}
+		return append(records, rs), ""
+	case rtypes.ChangeActionDelete:
+		if i < 0 {
+			return records, fmt.Sprintf("Tried to delete resource record set %s but it was not found", describe(rs))
+		}
+		if !sameValues(records[i], rs) {
+			return records, fmt.Sprintf("Tried to delete resource record set %s but the values provided do not match the current values", describe(rs))
+		}
+		return append(records[:i:i], records[i+1:]...), ""
+	}
+	return records, fmt.Sprintf("Invalid action %s", action)
+}
+
+// batchLimitMessages returns the messages for a batch above the Route53
+// limits, where an UPSERT counts twice.
+func batchLimitMessages(changes []xmlChange) []string {
+	records, chars := 0, 0
+	for _, c := range changes {
+		r, n := len(c.ResourceRecordSet.ResourceRecords), 0
+		if r == 0 {
+			r = 1
+		}
+		for _, v := range c.ResourceRecordSet.ResourceRecords {
+			n += len(v.Value)
+		}
+		if c.Action == string(rtypes.ChangeActionUpsert) {
+			r, n = r*2, n*2
+		}
+		records += r
+		chars += n
+	}
+	messages := []string{}
+	if records > maxRecordsPerBatch {
+		messages = append(messages, fmt.Sprintf("Number of records limit of %d exceeded.", maxRecordsPerBatch))
+	}
+	if chars > maxValueCharsPerBatch {
+		messages = append(messages, fmt.Sprintf("Number of characters in the values limit of %d exceeded.", maxValueCharsPerBatch))
+	}
+	return messages
+}
+
+func findRecordSet(records []rtypes.ResourceRecordSet, rs rtypes.ResourceRecordSet) int {
+	key := recordSetKey(rs)
+	for i, r := range records {
+		if recordSetKey(r) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func sameValues(a, b rtypes.ResourceRecordSet) bool {
+	if aws.ToInt64(a.TTL) != aws.ToInt64(b.TTL) || len(a.ResourceRecords) != len(b.ResourceRecords) {
+		return false
+	}
+	if (a.AliasTarget == nil) != (b.AliasTarget == nil) {
+		return false
+	}
+	if a.AliasTarget != nil && !strings.EqualFold(normalizeName(aws.ToString(a.AliasTarget.DNSName)), normalizeName(aws.ToString(b.AliasTarget.DNSName))) {
+		return false
+	}
+	values := func(rs rtypes.ResourceRecordSet) []string {
+		v := []string{}
+		for _, rr := range rs.ResourceRecords {
+			v = append(v, aws.ToString(rr.Value))
+		}
+		sort.Strings(v)
+		return v
+	}
+	va, vb := values(a), values(b)
+	for i := range va {
+		if va[i] != vb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOf(values []string, v string) int {
+	for i, value := range values {
+		if value == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasTag(tags []xmlTag, key string) bool {
+	for _, tag := range tags {
+		if tag.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(rs rtypes.ResourceRecordSet) string {
+	if rs.SetIdentifier != nil {
+		return fmt.Sprintf("[name='%s', type='%s', set-identifier='%s']", aws.ToString(rs.Name), rs.Type, aws.ToString(rs.SetIdentifier))
+	}
+	return fmt.Sprintf("[name='%s', type='%s']", aws.ToString(rs.Name), rs.Type)
+}
+
+// recordSetKey sorts record sets the way Route53 lists them: by name with
+// the labels reversed, then by type and set identifier.
+func recordSetKey(rs rtypes.ResourceRecordSet) string {
+	return recordKey(aws.ToString(rs.Name), string(rs.Type), aws.ToString(rs.SetIdentifier))
+}
+
+func recordKey(name, rrType, setIdentifier string) string {
+	return sortName(name) + "\x00" + rrType + "\x00" + setIdentifier
+}
+
+func sortName(name string) string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// normalizeName lowercases a name, adds the trailing dot and escapes the
+// wildcard label as Route53 returns them.
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	if strings.HasPrefix(name, "*.") {
+		name = `\052` + name[1:]
+	}
+	return name
+}
+
+func shortID(id string) string {
+	return strings.TrimPrefix(id, "/hostedzone/")
+}
+
+func maxItems(req *http.Request, limit int) int {
+	n, err := strconv.Atoi(req.URL.Query().Get("maxitems"))
+	if err != nil || n <= 0 || n > limit {
+		return limit
+	}
+	return n
+}
+
+func pageEnd(start, total, max int) (int, bool) {
+	if start+max >= total {
+		return total, false
+	}
+	return start + max, true
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
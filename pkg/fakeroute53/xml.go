@@ -0,0 +1,523 @@
+package fakeroute53
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// The XML documents of the Route53 REST API, as far as the fake implements
+// them.
+
+const namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+
+// stsNamespace is the namespace of the STS responses, see
+// getCallerIdentityResponse.
+const stsNamespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+
+type xmlHostedZone struct {
+	Id                     string
+	Name                   string
+	CallerReference        string
+	Config                 *xmlZoneConfig `xml:"Config,omitempty"`
+	ResourceRecordSetCount int64
+}
+
+type xmlZoneConfig struct {
+	Comment     string `xml:"Comment,omitempty"`
+	PrivateZone bool
+}
+
+type xmlVPC struct {
+	VPCRegion string `xml:"VPCRegion,omitempty"`
+	VPCId     string `xml:"VPCId,omitempty"`
+}
+
+type xmlDelegationSet struct {
+	Id          string   `xml:"Id,omitempty"`
+	NameServers []string `xml:"NameServers>NameServer"`
+}
+
+type xmlChangeInfo struct {
+	Id          string
+	Status      string
+	SubmittedAt string
+	Comment     string `xml:"Comment,omitempty"`
+}
+
+type xmlAliasTarget struct {
+	HostedZoneId         string
+	DNSName              string
+	EvaluateTargetHealth bool
+}
+
+type xmlGeoLocation struct {
+	ContinentCode   string `xml:"ContinentCode,omitempty"`
+	CountryCode     string `xml:"CountryCode,omitempty"`
+	SubdivisionCode string `xml:"SubdivisionCode,omitempty"`
+}
+
+type xmlCidrRoutingConfig struct {
+	CollectionId string
+	LocationName string
+}
+
+type xmlRecordSet struct {
+	Name                    string
+	Type                    string
+	SetIdentifier           string                `xml:"SetIdentifier,omitempty"`
+	Weight                  *int64                `xml:"Weight,omitempty"`
+	Region                  string                `xml:"Region,omitempty"`
+	GeoLocation             *xmlGeoLocation       `xml:"GeoLocation,omitempty"`
+	Failover                string                `xml:"Failover,omitempty"`
+	MultiValueAnswer        *bool                 `xml:"MultiValueAnswer,omitempty"`
+	TTL                     *int64                `xml:"TTL,omitempty"`
+	ResourceRecords         []xmlResourceRecord   `xml:"ResourceRecords>ResourceRecord,omitempty"`
+	AliasTarget             *xmlAliasTarget       `xml:"AliasTarget,omitempty"`
+	HealthCheckId           string                `xml:"HealthCheckId,omitempty"`
+	TrafficPolicyInstanceId string                `xml:"TrafficPolicyInstanceId,omitempty"`
+	CidrRoutingConfig       *xmlCidrRoutingConfig `xml:"CidrRoutingConfig,omitempty"`
+}
+
+// xmlResourceRecord holds one value, each in its own ResourceRecord
+// element as Route53 lists them.
+type xmlResourceRecord struct {
+	Value string
+}
+
+type xmlChange struct {
+	Action            string
+	ResourceRecordSet xmlRecordSet
+}
+
+type changeResourceRecordSetsRequest struct {
+	Comment string      `xml:"ChangeBatch>Comment"`
+	Changes []xmlChange `xml:"ChangeBatch>Changes>Change"`
+}
+
+type xmlCidrCollection struct {
+	Arn     string
+	Id      string
+	Name    string
+	Version int64
+}
+
+type xmlCidrBlock struct {
+	CidrBlock    string
+	LocationName string
+}
+
+type xmlCidrCollectionChange struct {
+	LocationName string
+	Action       string
+	CidrList     []string `xml:"CidrList>Cidr"`
+}
+
+type createCidrCollectionRequest struct {
+	Name            string
+	CallerReference string
+}
+
+type changeCidrCollectionRequest struct {
+	CollectionVersion *int64
+	Changes           []xmlCidrCollectionChange `xml:"Changes>member"`
+}
+
+type xmlAlarmIdentifier struct {
+	Region string
+	Name   string
+}
+
+type xmlHealthCheckConfig struct {
+	IPAddress                    string              `xml:"IPAddress,omitempty"`
+	Port                         *int32              `xml:"Port,omitempty"`
+	Type                         string              `xml:"Type"`
+	ResourcePath                 string              `xml:"ResourcePath,omitempty"`
+	FullyQualifiedDomainName     string              `xml:"FullyQualifiedDomainName,omitempty"`
+	SearchString                 string              `xml:"SearchString,omitempty"`
+	RequestInterval              *int32              `xml:"RequestInterval,omitempty"`
+	FailureThreshold             *int32              `xml:"FailureThreshold,omitempty"`
+	MeasureLatency               *bool               `xml:"MeasureLatency,omitempty"`
+	Inverted                     *bool               `xml:"Inverted,omitempty"`
+	Disabled                     *bool               `xml:"Disabled,omitempty"`
+	HealthThreshold              *int32              `xml:"HealthThreshold,omitempty"`
+	ChildHealthChecks            []string            `xml:"ChildHealthChecks>ChildHealthCheck,omitempty"`
+	EnableSNI                    *bool               `xml:"EnableSNI,omitempty"`
+	Regions                      []string            `xml:"Regions>Region,omitempty"`
+	AlarmIdentifier              *xmlAlarmIdentifier `xml:"AlarmIdentifier,omitempty"`
+	InsufficientDataHealthStatus string              `xml:"InsufficientDataHealthStatus,omitempty"`
+}
+
+type xmlHealthCheck struct {
+	Id                 string
+	CallerReference    string
+	HealthCheckConfig  xmlHealthCheckConfig
+	HealthCheckVersion int64
+}
+
+type xmlTag struct {
+	Key   string
+	Value string `xml:"Value,omitempty"`
+}
+
+type xmlResourceTagSet struct {
+	ResourceType string
+	ResourceId   string
+	Tags         []xmlTag `xml:"Tags>Tag"`
+}
+
+type createHealthCheckRequest struct {
+	CallerReference   string
+	HealthCheckConfig xmlHealthCheckConfig
+}
+
+type listTagsForResourcesRequest struct {
+	ResourceIds []string `xml:"ResourceIds>ResourceId"`
+}
+
+type changeTagsForResourceRequest struct {
+	AddTags       []xmlTag `xml:"AddTags>Tag"`
+	RemoveTagKeys []string `xml:"RemoveTagKeys>Key"`
+}
+
+type createHostedZoneRequest struct {
+	Name             string
+	CallerReference  string
+	HostedZoneConfig *xmlZoneConfig
+	VPC              *xmlVPC
+	DelegationSetId  string
+}
+
+type listHostedZonesResponse struct {
+	XMLName     xml.Name        `xml:"ListHostedZonesResponse"`
+	Xmlns       string          `xml:"xmlns,attr"`
+	HostedZones []xmlHostedZone `xml:"HostedZones>HostedZone"`
+	Marker      string          `xml:"Marker,omitempty"`
+	IsTruncated bool
+	NextMarker  string `xml:"NextMarker,omitempty"`
+	MaxItems    int
+}
+
+type listHostedZonesByNameResponse struct {
+	XMLName          xml.Name        `xml:"ListHostedZonesByNameResponse"`
+	Xmlns            string          `xml:"xmlns,attr"`
+	HostedZones      []xmlHostedZone `xml:"HostedZones>HostedZone"`
+	DNSName          string          `xml:"DNSName,omitempty"`
+	HostedZoneId     string          `xml:"HostedZoneId,omitempty"`
+	IsTruncated      bool
+	NextDNSName      string `xml:"NextDNSName,omitempty"`
+	NextHostedZoneId string `xml:"NextHostedZoneId,omitempty"`
+	MaxItems         int
+}
+
+type getHostedZoneResponse struct {
+	XMLName       xml.Name          `xml:"GetHostedZoneResponse"`
+	Xmlns         string            `xml:"xmlns,attr"`
+	HostedZone    xmlHostedZone     `xml:"HostedZone"`
+	DelegationSet *xmlDelegationSet `xml:"DelegationSet,omitempty"`
+	VPCs          []xmlVPC          `xml:"VPCs>VPC,omitempty"`
+}
+
+type createHostedZoneResponse struct {
+	XMLName       xml.Name          `xml:"CreateHostedZoneResponse"`
+	Xmlns         string            `xml:"xmlns,attr"`
+	HostedZone    xmlHostedZone     `xml:"HostedZone"`
+	ChangeInfo    xmlChangeInfo     `xml:"ChangeInfo"`
+	DelegationSet *xmlDelegationSet `xml:"DelegationSet,omitempty"`
+	VPC           *xmlVPC           `xml:"VPC,omitempty"`
+}
+
+type updateHostedZoneCommentRequest struct {
+	Comment string
+}
+
+type updateHostedZoneCommentResponse struct {
+	XMLName    xml.Name      `xml:"UpdateHostedZoneCommentResponse"`
+	Xmlns      string        `xml:"xmlns,attr"`
+	HostedZone xmlHostedZone `xml:"HostedZone"`
+}
+
+type deleteHostedZoneResponse struct {
+	XMLName    xml.Name      `xml:"DeleteHostedZoneResponse"`
+	Xmlns      string        `xml:"xmlns,attr"`
+	ChangeInfo xmlChangeInfo `xml:"ChangeInfo"`
+}
+
+type listResourceRecordSetsResponse struct {
+	XMLName              xml.Name       `xml:"ListResourceRecordSetsResponse"`
+	Xmlns                string         `xml:"xmlns,attr"`
+	ResourceRecordSets   []xmlRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated          bool
+	NextRecordName       string `xml:"NextRecordName,omitempty"`
+	NextRecordType       string `xml:"NextRecordType,omitempty"`
+	NextRecordIdentifier string `xml:"NextRecordIdentifier,omitempty"`
+	MaxItems             int
+}
+
+type changeResourceRecordSetsResponse struct {
+	XMLName    xml.Name      `xml:"ChangeResourceRecordSetsResponse"`
+	Xmlns      string        `xml:"xmlns,attr"`
+	ChangeInfo xmlChangeInfo `xml:"ChangeInfo"`
+}
+
+type getChangeResponse struct {
+	XMLName    xml.Name      `xml:"GetChangeResponse"`
+	Xmlns      string        `xml:"xmlns,attr"`
+	ChangeInfo xmlChangeInfo `xml:"ChangeInfo"`
+}
+
+type getDNSSECResponse struct {
+	XMLName        xml.Name `xml:"GetDNSSECResponse"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	ServeSignature string   `xml:"Status>ServeSignature"`
+	KeySigningKeys []string `xml:"KeySigningKeys>KeySigningKey"`
+}
+
+type listCidrCollectionsResponse struct {
+	XMLName         xml.Name            `xml:"ListCidrCollectionsResponse"`
+	Xmlns           string              `xml:"xmlns,attr"`
+	CidrCollections []xmlCidrCollection `xml:"CidrCollections>member"`
+}
+
+type createCidrCollectionResponse struct {
+	XMLName    xml.Name          `xml:"CreateCidrCollectionResponse"`
+	Xmlns      string            `xml:"xmlns,attr"`
+	Collection xmlCidrCollection `xml:"Collection"`
+}
+
+type changeCidrCollectionResponse struct {
+	XMLName xml.Name `xml:"ChangeCidrCollectionResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Id      string
+}
+
+type listCidrBlocksResponse struct {
+	XMLName    xml.Name       `xml:"ListCidrBlocksResponse"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	CidrBlocks []xmlCidrBlock `xml:"CidrBlocks>member"`
+}
+
+type createHealthCheckResponse struct {
+	XMLName     xml.Name       `xml:"CreateHealthCheckResponse"`
+	Xmlns       string         `xml:"xmlns,attr"`
+	HealthCheck xmlHealthCheck `xml:"HealthCheck"`
+}
+
+type getHealthCheckResponse struct {
+	XMLName     xml.Name       `xml:"GetHealthCheckResponse"`
+	Xmlns       string         `xml:"xmlns,attr"`
+	HealthCheck xmlHealthCheck `xml:"HealthCheck"`
+}
+
+type listHealthChecksResponse struct {
+	XMLName      xml.Name         `xml:"ListHealthChecksResponse"`
+	Xmlns        string           `xml:"xmlns,attr"`
+	HealthChecks []xmlHealthCheck `xml:"HealthChecks>HealthCheck"`
+	Marker       string
+	IsTruncated  bool
+	MaxItems     int
+}
+
+type listTagsForResourcesResponse struct {
+	XMLName         xml.Name            `xml:"ListTagsForResourcesResponse"`
+	Xmlns           string              `xml:"xmlns,attr"`
+	ResourceTagSets []xmlResourceTagSet `xml:"ResourceTagSets>ResourceTagSet"`
+}
+
+type changeTagsForResourceResponse struct {
+	XMLName xml.Name `xml:"ChangeTagsForResourceResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	Type      string   `xml:"Error>Type"`
+	Code      string   `xml:"Error>Code"`
+	Message   string   `xml:"Error>Message"`
+	RequestId string
+}
+
+type invalidChangeBatchResponse struct {
+	XMLName   xml.Name `xml:"InvalidChangeBatch"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	Messages  []string `xml:"Messages>Message"`
+	RequestId string
+}
+
+type getCallerIdentityResponse struct {
+	XMLName   xml.Name `xml:"GetCallerIdentityResponse"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	Arn       string   `xml:"GetCallerIdentityResult>Arn"`
+	UserId    string   `xml:"GetCallerIdentityResult>UserId"`
+	Account   string   `xml:"GetCallerIdentityResult>Account"`
+	RequestId string   `xml:"ResponseMetadata>RequestId"`
+}
+
+type assumeRoleResponse struct {
+	XMLName         xml.Name `xml:"AssumeRoleResponse"`
+	Xmlns           string   `xml:"xmlns,attr"`
+	AccessKeyId     string   `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+	SecretAccessKey string   `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+	SessionToken    string   `xml:"AssumeRoleResult>Credentials>SessionToken"`
+	Expiration      string   `xml:"AssumeRoleResult>Credentials>Expiration"`
+	Arn             string   `xml:"AssumeRoleResult>AssumedRoleUser>Arn"`
+	AssumedRoleId   string   `xml:"AssumeRoleResult>AssumedRoleUser>AssumedRoleId"`
+	RequestId       string   `xml:"ResponseMetadata>RequestId"`
+}
+
+func toXMLRecordSet(rs rtypes.ResourceRecordSet) xmlRecordSet {
+	x := xmlRecordSet{
+		Name:                    aws.ToString(rs.Name),
+		Type:                    string(rs.Type),
+		SetIdentifier:           aws.ToString(rs.SetIdentifier),
+		Weight:                  rs.Weight,
+		Region:                  string(rs.Region),
+		Failover:                string(rs.Failover),
+		MultiValueAnswer:        rs.MultiValueAnswer,
+		TTL:                     rs.TTL,
+		HealthCheckId:           aws.ToString(rs.HealthCheckId),
+		TrafficPolicyInstanceId: aws.ToString(rs.TrafficPolicyInstanceId),
+	}
+	for _, rr := range rs.ResourceRecords {
+		x.ResourceRecords = append(x.ResourceRecords, xmlResourceRecord{Value: aws.ToString(rr.Value)})
+	}
+	if rs.GeoLocation != nil {
+		x.GeoLocation = &xmlGeoLocation{
+			ContinentCode:   aws.ToString(rs.GeoLocation.ContinentCode),
+			CountryCode:     aws.ToString(rs.GeoLocation.CountryCode),
+			SubdivisionCode: aws.ToString(rs.GeoLocation.SubdivisionCode),
+		}
+	}
+	if rs.AliasTarget != nil {
+		x.AliasTarget = &xmlAliasTarget{
+			HostedZoneId:         aws.ToString(rs.AliasTarget.HostedZoneId),
+			DNSName:              aws.ToString(rs.AliasTarget.DNSName),
+			EvaluateTargetHealth: rs.AliasTarget.EvaluateTargetHealth,
+		}
+	}
+	if rs.CidrRoutingConfig != nil {
+		x.CidrRoutingConfig = &xmlCidrRoutingConfig{
+			CollectionId: aws.ToString(rs.CidrRoutingConfig.CollectionId),
+			LocationName: aws.ToString(rs.CidrRoutingConfig.LocationName),
+		}
+	}
+	return x
+}
+
+func fromXMLRecordSet(x xmlRecordSet) rtypes.ResourceRecordSet {
+	rs := rtypes.ResourceRecordSet{
+		Name:             aws.String(x.Name),
+		Type:             rtypes.RRType(x.Type),
+		SetIdentifier:    optionalString(x.SetIdentifier),
+		Weight:           x.Weight,
+		Region:           rtypes.ResourceRecordSetRegion(x.Region),
+		Failover:         rtypes.ResourceRecordSetFailover(x.Failover),
+		MultiValueAnswer: x.MultiValueAnswer,
+		TTL:              x.TTL,
+		HealthCheckId:    optionalString(x.HealthCheckId),
+	}
+	rs.TrafficPolicyInstanceId = optionalString(x.TrafficPolicyInstanceId)
+	for _, rr := range x.ResourceRecords {
+		rs.ResourceRecords = append(rs.ResourceRecords, rtypes.ResourceRecord{Value: aws.String(rr.Value)})
+	}
+	if x.GeoLocation != nil {
+		rs.GeoLocation = &rtypes.GeoLocation{
+			ContinentCode:   optionalString(x.GeoLocation.ContinentCode),
+			CountryCode:     optionalString(x.GeoLocation.CountryCode),
+			SubdivisionCode: optionalString(x.GeoLocation.SubdivisionCode),
+		}
+	}
+	if x.AliasTarget != nil {
+		rs.AliasTarget = &rtypes.AliasTarget{
+			HostedZoneId:         aws.String(x.AliasTarget.HostedZoneId),
+			DNSName:              aws.String(x.AliasTarget.DNSName),
+			EvaluateTargetHealth: x.AliasTarget.EvaluateTargetHealth,
+		}
+	}
+	if x.CidrRoutingConfig != nil {
+		rs.CidrRoutingConfig = &rtypes.CidrRoutingConfig{
+			CollectionId: aws.String(x.CidrRoutingConfig.CollectionId),
+			LocationName: aws.String(x.CidrRoutingConfig.LocationName),
+		}
+	}
+	return rs
+}
+
+func toXMLHealthCheckConfig(cfg rtypes.HealthCheckConfig) xmlHealthCheckConfig {
+	x := xmlHealthCheckConfig{
+		IPAddress:                    aws.ToString(cfg.IPAddress),
+		Port:                         cfg.Port,
+		Type:                         string(cfg.Type),
+		ResourcePath:                 aws.ToString(cfg.ResourcePath),
+		FullyQualifiedDomainName:     aws.ToString(cfg.FullyQualifiedDomainName),
+		SearchString:                 aws.ToString(cfg.SearchString),
+		RequestInterval:              cfg.RequestInterval,
+		FailureThreshold:             cfg.FailureThreshold,
+		MeasureLatency:               cfg.MeasureLatency,
+		Inverted:                     cfg.Inverted,
+		Disabled:                     cfg.Disabled,
+		HealthThreshold:              cfg.HealthThreshold,
+		ChildHealthChecks:            cfg.ChildHealthChecks,
+		EnableSNI:                    cfg.EnableSNI,
+		InsufficientDataHealthStatus: string(cfg.InsufficientDataHealthStatus),
+	}
+	for _, region := range cfg.Regions {
+		x.Regions = append(x.Regions, string(region))
+	}
+	if cfg.AlarmIdentifier != nil {
+		x.AlarmIdentifier = &xmlAlarmIdentifier{
+			Region: string(cfg.AlarmIdentifier.Region),
+			Name:   aws.ToString(cfg.AlarmIdentifier.Name),
+		}
+	}
+	return x
+}
+
+func fromXMLHealthCheckConfig(x xmlHealthCheckConfig) rtypes.HealthCheckConfig {
+	cfg := rtypes.HealthCheckConfig{
+		IPAddress:                    optionalString(x.IPAddress),
+		Port:                         x.Port,
+		Type:                         rtypes.HealthCheckType(x.Type),
+		ResourcePath:                 optionalString(x.ResourcePath),
+		FullyQualifiedDomainName:     optionalString(x.FullyQualifiedDomainName),
+		SearchString:                 optionalString(x.SearchString),
+		RequestInterval:              x.RequestInterval,
+		FailureThreshold:             x.FailureThreshold,
+		MeasureLatency:               x.MeasureLatency,
+		Inverted:                     x.Inverted,
+		Disabled:                     x.Disabled,
+		HealthThreshold:              x.HealthThreshold,
+		ChildHealthChecks:            x.ChildHealthChecks,
+		EnableSNI:                    x.EnableSNI,
+		InsufficientDataHealthStatus: rtypes.InsufficientDataHealthStatus(x.InsufficientDataHealthStatus),
+	}
+	for _, region := range x.Regions {
+		cfg.Regions = append(cfg.Regions, rtypes.HealthCheckRegion(region))
+	}
+	if x.AlarmIdentifier != nil {
+		cfg.AlarmIdentifier = &rtypes.AlarmIdentifier{
+			Region: rtypes.CloudWatchRegion(x.AlarmIdentifier.Region),
+			Name:   aws.String(x.AlarmIdentifier.Name),
+		}
+	}
+	return cfg
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func toXMLChangeInfo(c change) xmlChangeInfo {
+	return xmlChangeInfo{
+		Id:          "/change/" + c.id,
+		Status:      string(c.status),
+		SubmittedAt: c.submittedAt.Format(time.RFC3339),
+		Comment:     c.comment,
+	}
+}
@@ -0,0 +1,125 @@
+// Package logging adds levels on top of the standard logger, so output can
+// be silenced or made more detailed from the command line while still going
+// through log.Writer.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+type Level int32
+
+const (
+	// LevelDebug shows the individual API requests.
+	LevelDebug Level = iota
+	// LevelInfo shows the progress of each step. This is the default.
+	LevelInfo
+	// LevelSummary only shows the final outcome of a run.
+	LevelSummary
+	LevelWarn
+	LevelError
+)
+
+var level = int32(LevelInfo)
+
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+func Enabled(l Level) bool {
+	return int32(l) >= atomic.LoadInt32(&level)
+}
+
+// Configure sets the level from the --verbose and --quiet flags.
+func Configure(verbose, quiet bool) error {
+	switch {
+	case verbose && quiet:
+		return errors.New("--verbose and --quiet cannot be used together")
+	case verbose:
+		SetLevel(LevelDebug)
+	case quiet:
+		SetLevel(LevelSummary)
+	default:
+		SetLevel(LevelInfo)
+	}
+	return nil
+}
+
+func output(l Level, prefix, msg string) {
+	if Enabled(l) {
+		_ = log.Output(3, prefix+msg)
+	}
+}
+
+func Debugf(format string, v ...interface{}) {
+	output(LevelDebug, "[DEBUG] ", fmt.Sprintf(format, v...))
+}
+
+func Infof(format string, v ...interface{}) {
+	output(LevelInfo, "", fmt.Sprintf(format, v...))
+}
+
+func Infoln(v ...interface{}) {
+	output(LevelInfo, "", fmt.Sprintln(v...))
+}
+
+func Summaryf(format string, v ...interface{}) {
+	output(LevelSummary, "", fmt.Sprintf(format, v...))
+}
+
+func Warnf(format string, v ...interface{}) {
+	output(LevelWarn, "Warning: ", fmt.Sprintf(format, v...))
+}
+
+func Errorf(format string, v ...interface{}) {
+	output(LevelError, "Error: ", fmt.Sprintf(format, v...))
+}
+
+// Logger writes log lines starting with a prefix, such as the zone a line is
+// about when several zones are copied concurrently.
+type Logger struct {
+	prefix string
+}
+
+type prefixKey struct{}
+
+// WithPrefix returns a context whose Logger, see From, starts its lines
+// with prefix.
+func WithPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, prefixKey{}, prefix)
+}
+
+// From returns the Logger of ctx, which writes lines without a prefix unless
+// one was set with WithPrefix.
+func From(ctx context.Context) Logger {
+	prefix, _ := ctx.Value(prefixKey{}).(string)
+	return Logger{prefix: prefix}
+}
+
+func (l Logger) Debugf(format string, v ...interface{}) {
+	output(LevelDebug, l.prefix+"[DEBUG] ", fmt.Sprintf(format, v...))
+}
+
+func (l Logger) Infof(format string, v ...interface{}) {
+	output(LevelInfo, l.prefix, fmt.Sprintf(format, v...))
+}
+
+func (l Logger) Infoln(v ...interface{}) {
+	output(LevelInfo, l.prefix, fmt.Sprintln(v...))
+}
+
+func (l Logger) Summaryf(format string, v ...interface{}) {
+	output(LevelSummary, l.prefix, fmt.Sprintf(format, v...))
+}
+
+func (l Logger) Warnf(format string, v ...interface{}) {
+	output(LevelWarn, l.prefix+"Warning: ", fmt.Sprintf(format, v...))
+}
+
+func (l Logger) Errorf(format string, v ...interface{}) {
+	output(LevelError, l.prefix+"Error: ", fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,450 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid output format: %s (valid formats: %s,%s)", format, FormatText, FormatJSON)
+}
+
+// SilenceLog discards the standard logger output, so only the report is
+// written. The returned function restores the previous output.
+func SilenceLog() func() {
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	return func() {
+		log.SetOutput(w)
+	}
+}
+
+// Report is the machine readable result of a run. Fields are only ever
+// added, so scripts can rely on the existing ones.
+type Report struct {
+	Command string   `json:"command"`
+	Domain  string   `json:"domain"`
+	DryRun  bool     `json:"dry_run"`
+	ZoneID  string   `json:"zone_id,omitempty"`
+	Changes []Change `json:"changes"`
+	Batches []Batch  `json:"batches"`
+	Total   int      `json:"total"`
+	Applied int      `json:"applied"`
+	Error   string   `json:"error,omitempty"`
+	// Unchanged is the number of record sets left out because the
+	// destination already holds them as is.
+	Unchanged int `json:"unchanged,omitempty"`
+
+	Verification *Verification `json:"verification,omitempty"`
+	Warnings     []Warning     `json:"warnings,omitempty"`
+	Skipped      []Skipped     `json:"skipped,omitempty"`
+	Conflicts    []Conflict    `json:"conflicts,omitempty"`
+	DNSSEC       *DNSSEC       `json:"dnssec,omitempty"`
+	Timings      []Timing      `json:"timings,omitempty"`
+
+	// Duration and Zones are only set when copying every zone of an
+	// account, where each zone gets its own report.
+	Duration float64   `json:"duration_seconds,omitempty"`
+	Zones    []*Report `json:"zones,omitempty"`
+
+	// Profile, CreatedZone and Destinations are only set when copying to
+	// several destination profiles, where each destination gets its own
+	// report.
+	Profile      string    `json:"profile,omitempty"`
+	CreatedZone  bool      `json:"created_zone,omitempty"`
+	Destinations []*Report `json:"destinations,omitempty"`
+}
+
+type Change struct {
+	Action        string       `json:"action"`
+	Name          string       `json:"name"`
+	Type          string       `json:"type"`
+	SetIdentifier string       `json:"set_identifier,omitempty"`
+	TTL           *int64       `json:"ttl,omitempty"`
+	Values        []string     `json:"values,omitempty"`
+	AliasTarget   *AliasTarget `json:"alias_target,omitempty"`
+}
+
+type AliasTarget struct {
+	DNSName              string `json:"dns_name"`
+	HostedZoneID         string `json:"hosted_zone_id"`
+	EvaluateTargetHealth bool   `json:"evaluate_target_health"`
+}
+
+type Batch struct {
+	ChangeID    string  `json:"change_id"`
+	Status      string  `json:"status"`
+	Changes     int     `json:"changes"`
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// Warning is a copied record that refers to a resource of the source
+// account.
+type Warning struct {
+	Record
+	Reason string `json:"reason"`
+}
+
+// Skipped is a source record left out of the copy. Cause is one of the
+// dns.Excluded constants.
+type Skipped struct {
+	Record
+	Cause  string `json:"cause"`
+	Reason string `json:"reason"`
+}
+
+// Conflict is a source record the destination holds with other values,
+// which was not overwritten.
+type Conflict struct {
+	Record
+	Source      []string `json:"source"`
+	Destination []string `json:"destination"`
+}
+
+// DNSSEC is the signing status of the source and destination zones, with
+// the DS records to publish at the registrar.
+type DNSSEC struct {
+	SourceStatus      string   `json:"source_status"`
+	DestinationStatus string   `json:"destination_status,omitempty"`
+	DSRecords         []string `json:"ds_records,omitempty"`
+}
+
+// Timing is the count and durations of a Route53 operation, see dns.Timing.
+type Timing struct {
+	Operation    string  `json:"operation"`
+	Count        int     `json:"count"`
+	TotalSeconds float64 `json:"total_seconds"`
+	MaxSeconds   float64 `json:"max_seconds"`
+}
+
+// Verification summarizes the records found to differ after a copy.
+type Verification struct {
+	OK            bool          `json:"ok"`
+	Missing       []Record      `json:"missing"`
+	Different     []Record      `json:"different"`
+	DNSMismatches []DNSMismatch `json:"dns_mismatches"`
+}
+
+type Record struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	SetIdentifier string `json:"set_identifier,omitempty"`
+}
+
+type DNSMismatch struct {
+	Record
+	Nameserver string   `json:"nameserver"`
+	Expected   []string `json:"expected"`
+	Answers    []string `json:"answers"`
+	Error      string   `json:"error,omitempty"`
+}
+
+func NewReport(command, domain string, dryRun bool) *Report {
+	return &Report{
+		Command: command,
+		Domain:  domain,
+		DryRun:  dryRun,
+		Changes: []Change{},
+		Batches: []Batch{},
+	}
+}
+
+func (r *Report) AddChanges(changes []rtypes.Change) {
+	for _, c := range changes {
+		rs := c.ResourceRecordSet
+		change := Change{
+			Action:        string(c.Action),
+			Name:          aws.ToString(rs.Name),
+			Type:          string(rs.Type),
+			SetIdentifier: aws.ToString(rs.SetIdentifier),
+			TTL:           rs.TTL,
+		}
+		if len(rs.ResourceRecords) > 0 {
+			change.Values = dns.DisplayValues(*rs)
+		}
+		if rs.AliasTarget != nil {
+			change.AliasTarget = &AliasTarget{
+				DNSName:              aws.ToString(rs.AliasTarget.DNSName),
+				HostedZoneID:         aws.ToString(rs.AliasTarget.HostedZoneId),
+				EvaluateTargetHealth: rs.AliasTarget.EvaluateTargetHealth,
+			}
+		}
+		r.Changes = append(r.Changes, change)
+	}
+	r.Total += len(changes)
+}
+
+func (r *Report) AddBatches(results []dns.BatchResult) {
+	for _, b := range results {
+		r.Batches = append(r.Batches, Batch{
+			ChangeID:    aws.ToString(b.ChangeInfo.Id),
+			Status:      string(b.ChangeInfo.Status),
+			Changes:     b.Changes,
+			WaitSeconds: b.Waited.Seconds(),
+		})
+		if b.ChangeInfo.Status == rtypes.ChangeStatusInsync {
+			r.Applied += b.Changes
+		}
+	}
+}
+
+func (r *Report) SetVerification(v dns.Verification) {
+	r.Verification = &Verification{
+		OK:            v.OK(),
+		Missing:       []Record{},
+		Different:     []Record{},
+		DNSMismatches: []DNSMismatch{},
+	}
+	for _, rs := range v.Missing {
+		r.Verification.Missing = append(r.Verification.Missing, newRecord(rs))
+	}
+	for _, u := range v.Different {
+		r.Verification.Different = append(r.Verification.Different, newRecord(u.To))
+	}
+	for _, m := range v.DNSMismatches {
+		mismatch := DNSMismatch{
+			Record:     newRecord(m.Record),
+			Nameserver: m.Nameserver,
+			Expected:   dns.DisplayValues(m.Record),
+		}
+		if m.Answers != nil {
+			mismatch.Answers = []string{}
+		}
+		for _, a := range m.Answers {
+			mismatch.Answers = append(mismatch.Answers, dns.DisplayValue(m.Record.Type, a))
+		}
+		if m.Err != nil {
+			mismatch.Error = m.Err.Error()
+		}
+		r.Verification.DNSMismatches = append(r.Verification.DNSMismatches, mismatch)
+	}
+}
+
+func (r *Report) AddWarnings(warnings []dns.Warning) {
+	for _, w := range warnings {
+		r.Warnings = append(r.Warnings, Warning{Record: newRecord(w.Record), Reason: w.Reason})
+	}
+}
+
+func (r *Report) AddSkipped(excluded []dns.ExcludedRecord) {
+	for _, e := range excluded {
+		r.Skipped = append(r.Skipped, Skipped{Record: newRecord(e.Record), Cause: e.Cause, Reason: e.Reason})
+	}
+}
+
+func (r *Report) AddConflicts(conflicts []dns.Conflict) {
+	for _, c := range conflicts {
+		r.Conflicts = append(r.Conflicts, Conflict{
+			Record:      newRecord(c.Source),
+			Source:      recordValues(c.Source),
+			Destination: recordValues(c.Destination),
+		})
+	}
+}
+
+// recordValues returns the values of a record set, or its alias target.
+func recordValues(rs rtypes.ResourceRecordSet) []string {
+	if rs.AliasTarget != nil {
+		return []string{"ALIAS " + aws.ToString(rs.AliasTarget.DNSName)}
+	}
+	return dns.DisplayValues(rs)
+}
+
+// SetDNSSEC records the signing status of the zones, when it was looked up.
+func (r *Report) SetDNSSEC(src, dst dns.DNSSEC) {
+	if src.Status == "" && dst.Status == "" {
+		return
+	}
+	r.DNSSEC = &DNSSEC{
+		SourceStatus:      src.Status,
+		DestinationStatus: dst.Status,
+		DSRecords:         dst.DSRecords,
+	}
+}
+
+func newRecord(rs rtypes.ResourceRecordSet) Record {
+	return Record{
+		Name:          aws.ToString(rs.Name),
+		Type:          string(rs.Type),
+		SetIdentifier: aws.ToString(rs.SetIdentifier),
+	}
+}
+
+// AddZone adds the report of a single zone, adding its totals to r.
+func (r *Report) AddZone(zone *Report) {
+	r.Zones = append(r.Zones, zone)
+	r.Total += zone.Total
+	r.Applied += zone.Applied
+}
+
+// PrintZones prints a summary table of the zone reports.
+func PrintZones(w io.Writer, reports []*Report) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Zone", "Records", "Status", "Duration"})
+	for _, r := range reports {
+		status := "copied"
+		if r.DryRun {
+			status = "dry run"
+		}
+		if r.Error != "" {
+			status = "failed: " + r.Error
+		}
+		duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Second)
+		table.Append([]string{r.Domain, strconv.Itoa(r.Total), status, duration.String()})
+	}
+	table.Render()
+}
+
+// AddDestination adds the report of a single destination profile, adding
+// its totals to r.
+func (r *Report) AddDestination(dest *Report) {
+	r.Destinations = append(r.Destinations, dest)
+	r.Total += dest.Total
+	r.Applied += dest.Applied
+}
+
+// PrintDestinations prints a summary table of the destination reports.
+func PrintDestinations(w io.Writer, reports []*Report) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Destination", "Zone ID", "Zone", "Change IDs", "Records", "Status", "Duration"})
+	for _, r := range reports {
+		zone := "existing"
+		if r.CreatedZone {
+			zone = "created"
+		}
+		if r.ZoneID == "" {
+			zone = "-"
+		}
+		changeIDs := []string{}
+		for _, b := range r.Batches {
+			changeIDs = append(changeIDs, b.ChangeID)
+		}
+		status := "copied"
+		if r.DryRun {
+			status = "dry run"
+		}
+		if r.Error != "" {
+			status = "failed: " + r.Error
+		}
+		duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Second)
+		table.Append([]string{r.Profile, r.ZoneID, zone, strings.Join(changeIDs, "\n"), strconv.Itoa(r.Total),
+			status, duration.String()})
+	}
+	table.Render()
+}
+
+// PrintInventory prints the zones of an account as a table, see
+// dns.InventoryZones.
+func PrintInventory(w io.Writer, zones []dns.ZoneInfo) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Zone", "Zone ID", "Records", "Private", "Registered", "DNSSEC"})
+	for _, z := range zones {
+		registered := "unknown"
+		if z.Registered != nil {
+			registered = yesNo(*z.Registered)
+		}
+		dnssec := z.DNSSEC
+		if dnssec == "" {
+			dnssec = "-"
+		}
+		table.Append([]string{z.Name, z.ID, strconv.FormatInt(z.Records, 10), yesNo(z.Private), registered, dnssec})
+	}
+	table.Render()
+}
+
+// WriteInventory writes the zones of an account as JSON.
+func WriteInventory(w io.Writer, zones []dns.ZoneInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(zones)
+}
+
+// PrintDelegationSets writes the reusable delegation sets of an account as a
+// table.
+func PrintDelegationSets(w io.Writer, sets []dns.DelegationSetInfo) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Delegation set ID", "Caller reference", "Nameservers"})
+	for _, s := range sets {
+		table.Append([]string{s.ID, s.CallerReference, strings.Join(s.NameServers, "\n")})
+	}
+	table.Render()
+}
+
+// WriteDelegationSets writes the reusable delegation sets of an account as
+// JSON.
+func WriteDelegationSets(w io.Writer, sets []dns.DelegationSetInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sets)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func (r *Report) SetTimings(timings []dns.Timing) {
+	r.Timings = []Timing{}
+	for _, t := range timings {
+		r.Timings = append(r.Timings, Timing{
+			Operation:    t.Operation,
+			Count:        t.Count,
+			TotalSeconds: t.Total.Seconds(),
+			MaxSeconds:   t.Max.Seconds(),
+		})
+	}
+}
+
+// PrintTimings writes the count, total, average and maximum duration of each
+// operation as a table.
+func PrintTimings(w io.Writer, timings []dns.Timing) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Operation", "Count", "Total", "Average", "Max"})
+	for _, t := range timings {
+		average := time.Duration(0)
+		if t.Count > 0 {
+			average = t.Total / time.Duration(t.Count)
+		}
+		table.Append([]string{t.Operation, strconv.Itoa(t.Count), roundDuration(t.Total),
+			roundDuration(average), roundDuration(t.Max)})
+	}
+	table.Render()
+}
+
+// roundDuration formats d with millisecond precision.
+func roundDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func (r *Report) SetError(err error) {
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+func (r *Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,190 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestReportGolden(t *testing.T) {
+	www := rtypes.ResourceRecordSet{
+		Name:            aws.String("www.example.com."),
+		Type:            rtypes.RRTypeA,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String("192.0.2.1")}, {Value: aws.String("192.0.2.2")}},
+	}
+	txt := rtypes.ResourceRecordSet{
+		Name:            aws.String("example.com."),
+		Type:            rtypes.RRTypeTxt,
+		TTL:             aws.Int64(3600),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(`"v=spf1 -all"`)}},
+	}
+	weighted := rtypes.ResourceRecordSet{
+		Name:            aws.String("api.example.com."),
+		Type:            rtypes.RRTypeCname,
+		SetIdentifier:   aws.String("blue"),
+		Weight:          aws.Int64(90),
+		TTL:             aws.Int64(60),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String("blue.example.net.")}},
+	}
+	alias := rtypes.ResourceRecordSet{
+		Name: aws.String("cdn.example.com."),
+		Type: rtypes.RRTypeA,
+		AliasTarget: &rtypes.AliasTarget{
+			DNSName:              aws.String("d111111abcdef8.cloudfront.net."),
+			HostedZoneId:         aws.String("Z2FDTNDATAQYW2"),
+			EvaluateTargetHealth: false,
+		},
+	}
+	changes := []rtypes.Change{
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &www},
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &txt},
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &weighted},
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &alias},
+	}
+
+	tests := []struct {
+		name   string
+		report func() *Report
+	}{
+		{
+			name: "copy_dry_run",
+			report: func() *Report {
+				r := NewReport("route53copy", "example.com", true)
+				r.AddChanges(changes)
+				return r
+			},
+		},
+		{
+			name: "copy",
+			report: func() *Report {
+				r := NewReport("route53copy", "example.com", false)
+				r.ZoneID = "Z0123456789ABCDEFGHIJ"
+				r.AddChanges(changes)
+				r.AddBatches([]dns.BatchResult{
+					{
+						ChangeInfo: &rtypes.ChangeInfo{Id: aws.String("/change/C1"), Status: rtypes.ChangeStatusInsync},
+						Changes:    3,
+						Waited:     1500 * time.Millisecond,
+					},
+					{
+						ChangeInfo: &rtypes.ChangeInfo{Id: aws.String("/change/C2"), Status: rtypes.ChangeStatusPending},
+						Changes:    1,
+					},
+				})
+				r.AddWarnings([]dns.Warning{{Record: alias, Reason: "alias target is a CloudFront distribution of the source account"}})
+				r.AddSkipped([]dns.ExcludedRecord{{Record: txt, Cause: dns.ExcludedName, Reason: "matches --exclude-name example.com"}})
+				r.AddConflicts([]dns.Conflict{{Source: www, Destination: txt}})
+				r.SetDNSSEC(dns.DNSSEC{Status: "SIGNING"}, dns.DNSSEC{Status: "NOT_SIGNING"})
+				r.SetVerification(dns.Verification{
+					Missing:   []rtypes.ResourceRecordSet{weighted},
+					Different: []dns.RecordSetUpdate{{From: txt, To: www}},
+					DNSMismatches: []dns.DNSMismatch{
+						{Record: www, Nameserver: "ns-1.awsdns-01.org.", Answers: []string{"192.0.2.9"}},
+						{Record: txt, Nameserver: "ns-2.awsdns-02.com.", Err: errors.New("i/o timeout")},
+					},
+				})
+				r.SetTimings([]dns.Timing{{Operation: "ChangeResourceRecordSets", Count: 2, Total: 3 * time.Second, Max: 2 * time.Second}})
+				return r
+			},
+		},
+		{
+			name: "delete_error",
+			report: func() *Report {
+				r := NewReport("route53delete", "example.com", false)
+				r.AddChanges([]rtypes.Change{{Action: rtypes.ChangeActionDelete, ResourceRecordSet: &www}})
+				r.SetError(errors.New("the zone is locked"))
+				return r
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.report().Write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("the report differs from %s, run go test -update if intended:\n%s", golden, buf.String())
+			}
+		})
+	}
+}
+
+func TestPrintTimings(t *testing.T) {
+	var buf bytes.Buffer
+	PrintTimings(&buf, []dns.Timing{
+		{Operation: dns.OpChangeRecords, Count: 2, Total: 3 * time.Second, Max: 2 * time.Second},
+		{Operation: dns.OpListRecords, Count: 3, Total: 100 * time.Millisecond, Max: 51500 * time.Microsecond},
+	})
+	for _, want := range []string{
+		"| ChangeResourceRecordSets |     2 | 3s    | 1.5s    | 2s   |",
+		"| ListResourceRecordSets   |     3 | 100ms | 33ms    | 52ms |",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReportRedacted(t *testing.T) {
+	dns.SetRedaction(true)
+	t.Cleanup(func() { dns.SetRedaction(false) })
+	secret := "google-site-verification=s3cr3t-t0k3n"
+	txt := rtypes.ResourceRecordSet{
+		Name:            aws.String("example.com."),
+		Type:            rtypes.RRTypeTxt,
+		TTL:             aws.Int64(3600),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(`"` + secret + `"`)}},
+	}
+	spf := txt
+	spf.Type = rtypes.RRTypeSpf
+	other := txt
+	other.ResourceRecords = []rtypes.ResourceRecord{{Value: aws.String(`"` + secret + `-2"`)}}
+
+	r := NewReport("route53copy", "example.com", false)
+	r.AddChanges([]rtypes.Change{
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &txt},
+		{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &spf},
+	})
+	r.AddConflicts([]dns.Conflict{{Source: txt, Destination: other}})
+	r.SetVerification(dns.Verification{
+		Different: []dns.RecordSetUpdate{{From: other, To: txt}},
+		DNSMismatches: []dns.DNSMismatch{
+			{Record: txt, Nameserver: "ns-1.awsdns-01.org.", Answers: []string{`"` + secret + `-2"`}},
+		},
+	})
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), secret) {
+		t.Fatalf("a TXT value is in the report:\n%s", buf.String())
+	}
+	for _, want := range []string{`"example.com."`, `"TXT"`, `"SPF"`, "3600", dns.RedactValue(rtypes.RRTypeTxt, `"`+secret+`"`)} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+	"github.com/pedrokiefer/route53copy/pkg/logging"
+)
+
+// NewProgress returns a dns.Progress rendering to f. On a terminal a single
+// status line is updated in place, otherwise progress is logged
+// periodically.
+func NewProgress(f *os.File) dns.Progress {
+	if IsTerminal(f) {
+		return &terminalProgress{w: f, start: time.Now()}
+	}
+	return &periodicProgress{interval: 10 * time.Second}
+}
+
+// IsTerminal reports whether f is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+type terminalProgress struct {
+	w      io.Writer
+	start  time.Time
+	active bool
+}
+
+func (p *terminalProgress) Update(phase string, done, total int) {
+	count := fmt.Sprintf("%d", done)
+	if total > 0 {
+		count = fmt.Sprintf("%d/%d", done, total)
+	}
+	elapsed := time.Since(p.start).Round(time.Second)
+	fmt.Fprintf(p.w, "\r\033[K%s: %s (%s elapsed)", phase, count, elapsed)
+	p.active = true
+}
+
+func (p *terminalProgress) Done(phase string) {
+	if p.active {
+		fmt.Fprintln(p.w)
+		p.active = false
+	}
+}
+
+type periodicProgress struct {
+	interval time.Duration
+	last     time.Time
+}
+
+func (p *periodicProgress) Update(phase string, done, total int) {
+	if phase != dns.PhaseFetch {
+		dns.LogProgress{}.Update(phase, done, total)
+		return
+	}
+	if time.Since(p.last) < p.interval {
+		return
+	}
+	p.last = time.Now()
+	logging.Infof("%d records fetched\n", done)
+}
+
+func (p *periodicProgress) Done(phase string) {}
@@ -0,0 +1,165 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/manifoldco/promptui"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+)
+
+// ErrAborted is returned when the user declines a confirmation prompt.
+var ErrAborted = errors.New("aborted by user")
+
+// Confirm asks the user to confirm with label, writing the prompt to stderr
+// when the output format is JSON. It returns ErrAborted when the user
+// declines, and an error when there is no terminal to ask on.
+func Confirm(label, format string) error {
+	if !IsTerminal(os.Stdin) {
+		return errors.New("confirmation required but no terminal is attached, use --yes to skip it")
+	}
+
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	if format == FormatJSON {
+		prompt.Stdout = os.Stderr
+	}
+
+	result, err := prompt.Run()
+	if errors.Is(err, promptui.ErrAbort) || errors.Is(err, promptui.ErrInterrupt) {
+		return ErrAborted
+	}
+	if err != nil {
+		return fmt.Errorf("prompt failed: %w", err)
+	}
+	if result != "y" && result != "Y" {
+		return ErrAborted
+	}
+	return nil
+}
+
+// SelectZone asks the user to pick one of zones with label, showing their
+// ids, visibility, record counts and comments. It returns ErrAborted when
+// the user interrupts the selection.
+func SelectZone(label string, zones []rtypes.HostedZone, format string) (rtypes.HostedZone, error) {
+	if !IsTerminal(os.Stdin) {
+		return rtypes.HostedZone{}, errors.New("zone selection required but no terminal is attached")
+	}
+
+	items := []string{}
+	for _, z := range zones {
+		visibility := "public"
+		comment := ""
+		if z.Config != nil {
+			if z.Config.PrivateZone {
+				visibility = "private"
+			}
+			comment = aws.ToString(z.Config.Comment)
+		}
+		items = append(items, fmt.Sprintf("%s  %s  %d records  %q",
+			strings.TrimPrefix(aws.ToString(z.Id), "/hostedzone/"), visibility, aws.ToInt64(z.ResourceRecordSetCount), comment))
+	}
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+	}
+	if format == FormatJSON {
+		prompt.Stdout = os.Stderr
+	}
+
+	i, _, err := prompt.Run()
+	if errors.Is(err, promptui.ErrAbort) || errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+		return rtypes.HostedZone{}, ErrAborted
+	}
+	if err != nil {
+		return rtypes.HostedZone{}, fmt.Errorf("prompt failed: %w", err)
+	}
+	return zones[i], nil
+}
+
+// SelectChanges asks the user to toggle which groups of changes to copy with
+// label, showing the name, type, TTL and first value of each, and returns the
+// indexes of the selected groups. Every group starts selected. A group holds
+// all the record sets of a name and type, see dns.GroupChanges, so routing
+// policy siblings are selected together. It returns ErrAborted when the user
+// interrupts the selection.
+func SelectChanges(label string, groups [][]rtypes.Change, format string) ([]int, error) {
+	if !IsTerminal(os.Stdin) {
+		return nil, errors.New("record selection required but no terminal is attached")
+	}
+
+	selected := make([]bool, len(groups))
+	for i := range selected {
+		selected[i] = true
+	}
+	cursor := 0
+	for {
+		count := 0
+		items := []string{}
+		for i, group := range groups {
+			mark := "[ ]"
+			if selected[i] {
+				mark = "[x]"
+				count++
+			}
+			items = append(items, mark+" "+changeGroupLine(group))
+		}
+		items = append([]string{fmt.Sprintf("Copy the %d selected records", count)}, items...)
+
+		prompt := promptui.Select{
+			Label:        label + " (enter toggles a record)",
+			Items:        items,
+			Size:         15,
+			HideSelected: true,
+		}
+		if format == FormatJSON {
+			prompt.Stdout = os.Stderr
+		}
+
+		i, _, err := prompt.RunCursorAt(cursor, cursor-7)
+		if errors.Is(err, promptui.ErrAbort) || errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+			return nil, ErrAborted
+		}
+		if err != nil {
+			return nil, fmt.Errorf("prompt failed: %w", err)
+		}
+		if i == 0 {
+			break
+		}
+		selected[i-1] = !selected[i-1]
+		cursor = i
+	}
+
+	indexes := []int{}
+	for i, s := range selected {
+		if s {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// changeGroupLine formats a group of changes of the same name and type on a
+// single line.
+func changeGroupLine(group []rtypes.Change) string {
+	rs := *group[0].ResourceRecordSet
+	line := fmt.Sprintf("%s  %s  ", dns.DecodeName(aws.ToString(rs.Name)), rs.Type)
+	switch values := dns.DisplayValues(rs); {
+	case rs.AliasTarget != nil:
+		line += "ALIAS " + aws.ToString(rs.AliasTarget.DNSName)
+	case len(values) > 1:
+		line += fmt.Sprintf("%d  %s (+%d)", aws.ToInt64(rs.TTL), values[0], len(values)-1)
+	case len(values) == 1:
+		line += fmt.Sprintf("%d  %s", aws.ToInt64(rs.TTL), values[0])
+	}
+	if len(group) > 1 {
+		line += fmt.Sprintf("  (%d routing record sets)", len(group))
+	}
+	return line
+}
@@ -0,0 +1,237 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+)
+
+// RecordReport is the per-record report written with --report. It is
+// written as CSV when the file name ends in .csv, and as JSON otherwise.
+// Zones copied concurrently may add their records at the same time.
+type RecordReport struct {
+	Command string      `json:"command"`
+	Error   string      `json:"error,omitempty"`
+	Records []RecordRow `json:"records"`
+
+	mu sync.Mutex
+}
+
+// RecordRow is the outcome of a single record, see dns.RecordOutcome.
+// Error is set on the records that were not applied because of it.
+type RecordRow struct {
+	Zone          string `json:"zone"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	SetIdentifier string `json:"set_identifier,omitempty"`
+	Action        string `json:"action"`
+	Previous      string `json:"previous,omitempty"`
+	Value         string `json:"value,omitempty"`
+	ChangeID      string `json:"change_id,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	// OriginalTTL is the source TTL of a record copied with another TTL,
+	// which --restore-ttls sets back.
+	OriginalTTL int64 `json:"original_ttl,omitempty"`
+}
+
+var recordColumns = []string{"zone", "name", "type", "set_identifier", "action", "previous", "value", "change_id", "status", "error", "original_ttl"}
+
+// ValidateRecordReportFile checks that file names a JSON or CSV file.
+func ValidateRecordReportFile(file string) error {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json", ".csv":
+		return nil
+	}
+	return fmt.Errorf("invalid report file: %s (must end in .json or .csv)", file)
+}
+
+func NewRecordReport(command string) *RecordReport {
+	return &RecordReport{
+		Command: command,
+		Records: []RecordRow{},
+	}
+}
+
+// Add adds the outcomes of the changes to zone. err is the error that
+// stopped the zone, noted on the records it kept from being applied.
+func (r *RecordReport) Add(zone string, outcomes []dns.RecordOutcome, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range outcomes {
+		rs := o.Change.ResourceRecordSet
+		row := RecordRow{
+			Zone:          zone,
+			Name:          aws.ToString(rs.Name),
+			Type:          string(rs.Type),
+			SetIdentifier: aws.ToString(rs.SetIdentifier),
+			Action:        string(o.Change.Action),
+			ChangeID:      o.ChangeID,
+			Status:        o.Status,
+			OriginalTTL:   o.OriginalTTL,
+		}
+		if o.Previous != nil {
+			row.Previous = recordValue(*o.Previous)
+		}
+		if o.Change.Action != rtypes.ChangeActionDelete {
+			row.Value = recordValue(*rs)
+		}
+		if o.Error != "" {
+			row.Error = o.Error
+		} else if err != nil && (o.Status == dns.OutcomeFailed || o.Status == dns.OutcomeNotApplied) {
+			row.Error = err.Error()
+		}
+		r.Records = append(r.Records, row)
+	}
+}
+
+// recordValue formats the values of a record set on a single line.
+func recordValue(rs rtypes.ResourceRecordSet) string {
+	if rs.AliasTarget != nil {
+		return "ALIAS " + aws.ToString(rs.AliasTarget.DNSName)
+	}
+	return strings.Join(dns.DisplayValues(rs), ", ")
+}
+
+// WriteFile writes the report to file, noting err as the error that stopped
+// the run.
+func (r *RecordReport) WriteFile(file string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	f, ferr := os.Create(file)
+	if ferr != nil {
+		return ferr
+	}
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		ferr = r.writeCSV(f)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		ferr = enc.Encode(r)
+	}
+	if cerr := f.Close(); ferr == nil {
+		ferr = cerr
+	}
+	return ferr
+}
+
+func (r *RecordReport) writeCSV(f *os.File) error {
+	w := csv.NewWriter(f)
+	err := w.Write(recordColumns)
+	if err != nil {
+		return err
+	}
+	for _, row := range r.Records {
+		originalTTL := ""
+		if row.OriginalTTL > 0 {
+			originalTTL = strconv.FormatInt(row.OriginalTTL, 10)
+		}
+		err := w.Write([]string{row.Zone, row.Name, row.Type, row.SetIdentifier, row.Action,
+			row.Previous, row.Value, row.ChangeID, row.Status, row.Error, originalTTL})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ReadRecordReportFile reads a report written by WriteFile.
+func ReadRecordReportFile(file string) (*RecordReport, error) {
+	err := ValidateRecordReportFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := NewRecordReport("")
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		err = r.readCSV(f)
+	} else {
+		err = json.NewDecoder(f).Decode(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid report file %s: %w", file, err)
+	}
+	return r, nil
+}
+
+func (r *RecordReport) readCSV(f *os.File) error {
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return errors.New("missing header")
+	}
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+	for _, row := range rows[1:] {
+		rr := RecordRow{
+			Zone:          field(row, "zone"),
+			Name:          field(row, "name"),
+			Type:          field(row, "type"),
+			SetIdentifier: field(row, "set_identifier"),
+			Action:        field(row, "action"),
+			Previous:      field(row, "previous"),
+			Value:         field(row, "value"),
+			ChangeID:      field(row, "change_id"),
+			Status:        field(row, "status"),
+			Error:         field(row, "error"),
+		}
+		if ttl := field(row, "original_ttl"); ttl != "" {
+			rr.OriginalTTL, err = strconv.ParseInt(ttl, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid original_ttl %q of %s", ttl, rr.Name)
+			}
+		}
+		r.Records = append(r.Records, rr)
+	}
+	return nil
+}
+
+// TTLChanges returns the records with an original TTL by zone.
+func (r *RecordReport) TTLChanges() map[string][]dns.TTLChange {
+	zones := map[string][]dns.TTLChange{}
+	for _, row := range r.Records {
+		if row.OriginalTTL <= 0 {
+			continue
+		}
+		rs := rtypes.ResourceRecordSet{
+			Name: aws.String(row.Name),
+			Type: rtypes.RRType(row.Type),
+		}
+		if row.SetIdentifier != "" {
+			rs.SetIdentifier = aws.String(row.SetIdentifier)
+		}
+		zones[row.Zone] = append(zones[row.Zone], dns.TTLChange{Record: rs, Original: row.OriginalTTL})
+	}
+	return zones
+}
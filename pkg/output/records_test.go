@@ -0,0 +1,58 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rtypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pedrokiefer/route53copy/pkg/dns"
+)
+
+func TestRecordReportRedacted(t *testing.T) {
+	dns.SetRedaction(true)
+	t.Cleanup(func() { dns.SetRedaction(false) })
+	secret := "google-site-verification=s3cr3t-t0k3n"
+	txt := rtypes.ResourceRecordSet{
+		Name:            aws.String("example.com."),
+		Type:            rtypes.RRTypeTxt,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String(`"` + secret + `"`)}},
+	}
+	previous := txt
+	previous.ResourceRecords = []rtypes.ResourceRecord{{Value: aws.String(`"` + secret + `-old"`)}}
+	www := rtypes.ResourceRecordSet{
+		Name:            aws.String("www.example.com."),
+		Type:            rtypes.RRTypeA,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []rtypes.ResourceRecord{{Value: aws.String("192.0.2.1")}},
+	}
+	r := NewRecordReport("route53copy")
+	r.Add("example.com", []dns.RecordOutcome{
+		{Change: rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &txt}, Previous: &previous, Status: dns.OutcomeApplied},
+		{Change: rtypes.Change{Action: rtypes.ChangeActionUpsert, ResourceRecordSet: &www}, Status: dns.OutcomeApplied},
+	}, nil)
+
+	for _, name := range []string{"report.json", "report.csv"} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), name)
+			if err := r.WriteFile(file, nil); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), secret) {
+				t.Fatalf("a TXT value is in the report:\n%s", data)
+			}
+			for _, want := range []string{"example.com.", "TXT", "192.0.2.1", dns.RedactValue(rtypes.RRTypeTxt, `"`+secret+`"`)} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("missing %s in:\n%s", want, data)
+				}
+			}
+		})
+	}
+}